@@ -1,78 +1,480 @@
+// Command cmms runs the CMMS services.
+//
+//	cmms serve --all                                  every service in one process
+//	cmms serve asset|service|consumable|maintenance   one service
+//	cmms check [-repair] [-json] [-user NAME]         the consistency check
+//	cmms migrate                                      brings the schema up to date
+//	cmms copy-to-sql                                  copies MongoDB into storage.dsn
+//
+// Run together, the services call each other in-process; run alone, they
+// call the others over HTTP and gRPC at the addresses in the configuration.
+// Every serve process answers /healthz while it runs, /readyz when its
+// database and the services it calls answer, and /metrics for Prometheus.
+// Every serve process relays the domain events its services recorded
+// through the configured transport. The data is kept in MongoDB, or in the
+// SQLite or PostgreSQL database of storage.dsn. The collections' indexes or
+// the tables are created, and documents reshaped, by versioned migrations
+// run on start and by cmms migrate.
 package main
 
 import (
+	"cmms/project/asset"
+	"cmms/project/consumable"
+	"cmms/project/maintenence"
+	"cmms/project/rpc"
+	"cmms/project/service"
 	"context"
+	"embed"
+	"errors"
 	"fmt"
-	"html/template"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"shared/audit"
+	"shared/config"
+	"shared/csrf"
+	"shared/events"
+	"shared/flash"
+	"shared/health"
+	"shared/httpclient"
+	"shared/metrics"
+	"shared/routes"
+	"shared/store"
+	"shared/trash"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc"
 )
 
-var (
-	db        *mongo.Database
-	client    *mongo.Client
-	templates *template.Template
-)
+// style holds the stylesheets served under /style/ when running --all
+//
+//go:embed style
+var style embed.FS
+
+// grpcServers add the gRPC servers of the services the maintenance service
+// looks records up in
+var grpcServers = map[string]func(grpc.ServiceRegistrar, *config.Config, store.Backend){
+	config.Asset:      asset.RegisterGRPC,
+	config.Service:    service.RegisterGRPC,
+	config.Consumable: consumable.RegisterGRPC,
+}
+
+const usage = `usage:
+  cmms serve --all
+  cmms serve asset|service|consumable|maintenance
+  cmms check [-repair] [-json] [-user NAME]
+  cmms migrate
+  cmms copy-to-sql`
 
 func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	//initializing db
-	var err error
-	db, client, err = NewDB(ctx)
+	conf, err := config.Load()
 	if err != nil {
 		log.Fatal(err)
-		return
 	}
 
-	defer client.Disconnect(ctx)
+	switch os.Args[1] {
+	case "serve":
+		if len(os.Args) != 3 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		if err := serve(ctx, conf, os.Args[2]); err != nil {
+			log.Fatal(err)
+		}
+	case "check":
+		b, err := open(ctx, conf)
+		if err != nil {
+			log.Fatal(err)
+		}
+		code := maintenence.RunCheck(ctx, conf, b, os.Args[2:])
+		closeBackend(b)
+		os.Exit(code)
+	case "migrate":
+		if err := migrate(ctx, conf); err != nil {
+			log.Fatal(err)
+		}
+	case "copy-to-sql":
+		if err := copyToSQL(ctx, conf); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// serve runs every service with --all, or the named one, until ctx is done
+func serve(ctx context.Context, conf *config.Config, what string) error {
+	var names []string
+	addrOf := what
+	switch {
+	case what == "--all":
+		names, addrOf = routes.Services, config.Monolith
+		conf.Monolithic()
+	case slices.Contains(routes.Services, what):
+		names = []string{what}
+	default:
+		return fmt.Errorf("unknown service %q, expected --all or one of %s", what, strings.Join(routes.Services, ", "))
+	}
+
+	if conf.Session.Secret != "" {
+		flash.SetKey([]byte(conf.Session.Secret))
+		csrf.SetKey([]byte(conf.Session.Secret))
+	}
+	audit.SetTrustedProxies(conf.TrustedProxies())
+	trash.SetRetention(conf.TrashRetention())
+
+	b, err := open(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer closeBackend(b)
+
+	bus, err := eventTransport(ctx, conf, b, len(names) > 1)
+	if err != nil {
+		return err
+	}
+	relay := events.NewSQLRelay(b.SQL, bus)
+	if b.Mongo != nil {
+		relay = events.NewRelay(b.Mongo.Database(conf.Mongo.Database), bus)
+	}
+
+	// Run together, the services reach each other through the combined
+	// handler and an in-process gRPC server instead of the network
+	var (
+		transport *httpclient.HandlerTransport
+		pipe      *rpc.Pipe
+	)
+	if len(names) > 1 {
+		transport = &httpclient.HandlerTransport{}
+		pipe = rpc.NewPipe()
+	}
+
+	grpcServer := grpc.NewServer()
+	services := map[string]http.Handler{}
+	for _, name := range names {
+		if register, ok := grpcServers[name]; ok {
+			register(grpcServer, conf, b)
+		}
+		h, err := handler(ctx, conf, b, name, transport, pipe)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		services[name] = h
+		if name == config.Maintenance {
+			maintenence.ConsumeEvents(ctx, bus)
+		}
+	}
+	go relay.Run(ctx)
+
+	root := services[what]
+	if transport != nil {
+		root, err = combine(services)
+		if err != nil {
+			return err
+		}
+		transport.Handler = root
+	}
+
+	var grpcListener net.Listener
+	switch {
+	case pipe != nil:
+		grpcListener = pipe
+	case grpcServers[what] != nil:
+		if grpcListener, err = net.Listen("tcp", conf.GRPCAddr(what)); err != nil {
+			return err
+		}
+		log.Printf("serving %s over gRPC on %s", what, grpcListener.Addr())
+	}
+	if grpcListener != nil {
+		go grpcServer.Serve(grpcListener)
+	}
+
+	srv := &http.Server{Addr: conf.Addr(addrOf), Handler: probes(root, readiness(conf, b, what))}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+		grpcServer.GracefulStop()
+	}()
+
+	log.Printf("serving %s on %s", strings.Join(names, ", "), srv.Addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// dependencies are the services each service calls, which its readiness
+// probe checks when it runs alone
+var dependencies = map[string][]string{
+	config.Asset:       {config.Maintenance},
+	config.Service:     {config.Maintenance},
+	config.Consumable:  {config.Maintenance},
+	config.Maintenance: {config.Asset, config.Service, config.Consumable},
+}
+
+// readiness returns the checks of the readiness probe of what, a service or
+// --all: the database answers and so do the services it calls. They are
+// asked whether they are alive rather than ready, so services depending on
+// each other do not wait for each other.
+func readiness(conf *config.Config, b store.Backend, what string) []health.Check {
+	checks := []health.Check{{Name: "database", Run: b.Ping}}
+	client := &http.Client{Timeout: 3 * time.Second}
+	for _, name := range dependencies[what] {
+		checks = append(checks, health.Check{Name: name, Run: health.Ping(client, conf.URL(name))})
+	}
+	return checks
+}
+
+// probes serves the liveness and readiness probes and the metrics of the
+// process in front of root
+func probes(root http.Handler, checks []health.Check) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /healthz", health.Live())
+	mux.Handle("GET /readyz", health.Ready(checks...))
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.Handle("/", root)
+	return mux
+}
+
+// handler sets the named service up and returns its routes. With a transport
+// and pipe it calls the other services through them.
+func handler(ctx context.Context, conf *config.Config, b store.Backend, name string, transport *httpclient.HandlerTransport, pipe *rpc.Pipe) (http.Handler, error) {
+	var rt http.RoundTripper
+	if transport != nil {
+		rt = transport
+	}
+	switch name {
+	case config.Asset:
+		return asset.Handler(ctx, conf, b, rt)
+	case config.Service:
+		return service.Handler(ctx, conf, b, rt)
+	case config.Consumable:
+		return consumable.Handler(ctx, conf, b, rt)
+	default:
+		var opts []grpc.DialOption
+		if pipe != nil {
+			opts = append(opts, grpc.WithContextDialer(pipe.Dial))
+		}
+		return maintenence.Handler(ctx, conf, b, opts...)
+	}
+}
+
+// eventTransport returns the configured transport of the domain events; the
+// in-process one only works for services run together and the mongo one
+// needs MongoDB storage
+func eventTransport(ctx context.Context, conf *config.Config, b store.Backend, together bool) (events.Transport, error) {
+	switch conf.EventTransport() {
+	case config.EventsInProcess:
+		if !together {
+			return nil, errors.New("events.transport inprocess needs serve --all")
+		}
+		return events.NewInProcess(), nil
+	case config.EventsNATS:
+		t, err := events.NewNATS(ctx, conf.Events.NATSURL)
+		if err != nil {
+			return nil, fmt.Errorf("events: %w", err)
+		}
+		go func() {
+			<-ctx.Done()
+			t.Close()
+		}()
+		return t, nil
+	default:
+		if b.Mongo == nil {
+			return nil, fmt.Errorf("storage.driver %s needs events.transport nats, or serve --all", conf.StorageDriver())
+		}
+		return events.NewMongoStream(b.Mongo.Database(conf.Mongo.Database)), nil
+	}
+}
 
-	templates = template.Must(template.New("").Funcs(template.FuncMap{
-		"add": func(a, b int) int { return a + b },
-	}).ParseGlob("templates/*.html"))
-	templates = template.Must(templates.ParseGlob("templates/*/*.html"))
+// combine routes every path to the service owning it, as the gateway does,
+// and serves the shared stylesheets
+func combine(services map[string]http.Handler) (http.Handler, error) {
+	styleDir, err := fs.Sub(style, "style")
+	if err != nil {
+		return nil, err
+	}
 
-	fs := http.FileServer(http.Dir("style"))
-	http.Handle("/style/", http.StripPrefix("/style/", fs))
+	mux := http.NewServeMux()
+	mux.Handle("/style/", http.StripPrefix("/style/", http.FileServer(http.FS(styleDir))))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/assets", http.StatusFound)
+			return
+		}
+		services[routes.ServiceFor(r.URL.Path)].ServeHTTP(w, r)
+	})
+	return mux, nil
+}
 
-	http.HandleFunc("/service", serviceListHandler)
-	http.HandleFunc("/service/create", serviceCreateHandler)
-	http.HandleFunc("/service/edit", serviceEditHandler)
-	http.HandleFunc("/service/delete", serviceDeleteHandler)
+// open connects to the storage of the services, the SQL database of
+// storage.dsn or MongoDB, with the schema of the audit trail and the domain
+// events brought up to date
+func open(ctx context.Context, conf *config.Config) (store.Backend, error) {
+	var b store.Backend
+	if conf.SQLStorage() {
+		db, err := store.OpenSQL(ctx, conf.StorageDriver(), conf.Storage.DSN)
+		if err != nil {
+			return b, err
+		}
+		b.SQL = db
+	} else {
+		client, err := connect(ctx, conf)
+		if err != nil {
+			return b, err
+		}
+		b.Mongo = client
+	}
+	if err := migrateShared(ctx, conf, b); err != nil {
+		closeBackend(b)
+		return store.Backend{}, err
+	}
+	return b, nil
+}
 
-	http.HandleFunc("/consumable", consumableListHandler)
-	http.HandleFunc("/consumable/create", consumableCreateHandler)
-	http.HandleFunc("/consumable/edit", consumableEditHandler)
-	http.HandleFunc("/consumable/delete", consumableDeleteHandler)
+// migrateShared brings the schema of the audit trail and the domain events
+// on b up to date
+func migrateShared(ctx context.Context, conf *config.Config, b store.Backend) error {
+	if b.SQL != nil {
+		if err := audit.MigrateSQL(ctx, b.SQL); err != nil {
+			return err
+		}
+		return events.MigrateSQL(ctx, b.SQL)
+	}
+	shared := b.Mongo.Database(conf.Mongo.Database)
+	if err := audit.MigrateMongo(ctx, shared); err != nil {
+		return err
+	}
+	return events.MigrateMongo(ctx, shared)
+}
 
-	// Maintenance Routes
-	http.HandleFunc("/maintenances", listMaintenance)
-	http.HandleFunc("/maintenances/create", createMaintenance)
-	http.HandleFunc("/maintenances/edit", editMaintenance)
-	http.HandleFunc("/maintenances/view", viewMaintenance)
-	http.HandleFunc("/maintenances/delete", deleteMaintenance)
+// migrations bring the schema of each service up to date, as the services
+// do when they start
+var migrations = map[string]func(context.Context, *config.Config, store.Backend) error{
+	config.Asset:       asset.Migrate,
+	config.Service:     service.Migrate,
+	config.Consumable:  consumable.Migrate,
+	config.Maintenance: maintenence.Migrate,
+}
 
-	// Schedule Routes
-	http.HandleFunc("/schedules", listSchedules)
-	http.HandleFunc("/schedules/add", addSchedule)
-	http.HandleFunc("/schedules/edit", editSchedule)
-	http.HandleFunc("/schedules/delete", deleteSchedule)
+// migrateAll brings the schema of every service and of what they share on b
+// up to date
+func migrateAll(ctx context.Context, conf *config.Config, b store.Backend) error {
+	if err := migrateShared(ctx, conf, b); err != nil {
+		return err
+	}
+	for _, name := range routes.Services {
+		if err := migrations[name](ctx, conf, b); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
 
-	// Deprecated routes - keeping for backward compatibility
-	http.HandleFunc("/shedules/add", addShedule)
-	http.HandleFunc("/shedules/delete", deleteShedule)
+// migrate implements `cmms migrate`: the migrations not yet applied to the
+// configured storage run, e.g. before the services are deployed
+func migrate(ctx context.Context, conf *config.Config) error {
+	b, err := open(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer closeBackend(b)
+	if err := migrateAll(ctx, conf, b); err != nil {
+		return err
+	}
+	log.Print("schema up to date")
+	return nil
+}
+
+// closeBackend closes the connection opened by open
+func closeBackend(b store.Backend) {
+	if b.SQL != nil {
+		b.SQL.Close()
+		return
+	}
+	b.Mongo.Disconnect(context.Background())
+}
+
+// copies are the records `cmms copy-to-sql` copies, by what they are
+var copies = []struct {
+	name string
+	copy func(context.Context, *config.Config, *mongo.Client, *store.SQL) (int, error)
+}{
+	{config.Asset, asset.CopyToSQL},
+	{config.Service, service.CopyToSQL},
+	{config.Consumable, consumable.CopyToSQL},
+	{config.Maintenance, maintenence.CopyToSQL},
+	{"audit", func(ctx context.Context, conf *config.Config, client *mongo.Client, to *store.SQL) (int, error) {
+		return audit.CopyToSQL(ctx, client.Database(conf.Mongo.Database), to)
+	}},
+}
+
+// copyToSQL implements `cmms copy-to-sql`: the records of every service and
+// the audit trail are copied from MongoDB at mongo.uri into the SQL database
+// of storage.dsn, replacing the rows copied before. MongoDB is migrated
+// first, so the records are copied in their current shape. The notification
+// subscriptions, webhooks and undelivered domain events are not copied.
+func copyToSQL(ctx context.Context, conf *config.Config) error {
+	if !conf.SQLStorage() {
+		return errors.New("copy-to-sql needs storage.driver sqlite or postgres")
+	}
+	client, err := connect(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+	if err := migrateAll(ctx, conf, store.Backend{Mongo: client}); err != nil {
+		return fmt.Errorf("migrating MongoDB: %w", err)
+	}
+	b, err := open(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer closeBackend(b)
 
-	http.HandleFunc("/assets", getAssets(db))
-	http.HandleFunc("/add_asset", addAsset(db))
-	http.HandleFunc("/edit_asset/", editAsset(db))
-	http.HandleFunc("/delete_asset/", deleteAsset(db))
+	for _, c := range copies {
+		n, err := c.copy(ctx, conf, client, b.SQL)
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+		fmt.Printf("%s: %d records copied\n", c.name, n)
+	}
+	return nil
+}
 
-	fmt.Printf("Using database: %v", db.Name())
+// connect opens the MongoDB connection shared by the services, timing its
+// commands in the metrics
+func connect(ctx context.Context, conf *config.Config) (*mongo.Client, error) {
+	opts := options.Client().ApplyURI(conf.Mongo.URI).SetConnectTimeout(conf.Mongo.ConnectTimeout).SetMonitor(store.MongoMonitor())
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error creating server connection: %v", err)
+	}
 
-	//Intialising server
-	http.ListenAndServe("localhost:8080", nil)
+	pingCtx, cancel := context.WithTimeout(ctx, conf.Mongo.ConnectTimeout)
+	defer cancel()
+	if err := client.Ping(pingCtx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("error pinging server: %v", err)
+	}
+	return client, nil
 }
@@ -0,0 +1,132 @@
+// Package asset is the asset register: the pages and API to manage assets,
+// their failure events and reliability KPIs.
+package asset
+
+import (
+	"cmms/project/asset/internal"
+	"cmms/project/rpc/cmmspb"
+	"context"
+	"embed"
+	"io/fs"
+	"net/http"
+	"shared/config"
+	"shared/csrf"
+	"shared/metrics"
+	"shared/store"
+	"shared/trash"
+	"shared/webhook"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc"
+)
+
+//go:embed templates style
+var assets embed.FS
+
+// Handler sets the asset service up on b, starts its background jobs
+// until ctx is done and returns its routes. Calls to the maintenance service
+// go through transport when it is set, and over the network otherwise.
+func Handler(ctx context.Context, conf *config.Config, b store.Backend, transport http.RoundTripper) (http.Handler, error) {
+	if err := internal.Configure(conf, assets, transport); err != nil {
+		return nil, err
+	}
+
+	if err := Migrate(ctx, conf, b); err != nil {
+		return nil, err
+	}
+	var (
+		s     *internal.Store
+		hooks webhook.Store
+	)
+	if b.SQL != nil {
+		s = internal.NewSQLStore(b.SQL)
+		hooks = webhook.NewSQLStore(b.SQL)
+	} else {
+		db := b.Mongo.Database(conf.Database(config.Asset))
+		s = internal.NewMongoStore(db, b.Mongo.Database(conf.Mongo.Database))
+		hooks = webhook.NewMongoStore(db)
+	}
+
+	// Deliver queued webhook events in the background
+	go webhook.NewDispatcher(hooks).Run(ctx)
+
+	// Purge assets that have been in the recycle bin longer than the retention period
+	trash.StartPurger(ctx, trash.Retention(), map[string]trash.Purger{"assets": s.Assets})
+
+	return routes(s)
+}
+
+// Migrate creates or updates the tables or indexes of the asset service on b
+func Migrate(ctx context.Context, conf *config.Config, b store.Backend) error {
+	if b.SQL != nil {
+		return internal.MigrateSQL(ctx, b.SQL)
+	}
+	return internal.MigrateMongo(ctx, b.Mongo.Database(conf.Database(config.Asset)))
+}
+
+// routes returns the routes of the asset service over s, behind the CSRF
+// check, counted and timed by route in the metrics
+func routes(s *internal.Store) (http.Handler, error) {
+	style, err := fs.Sub(assets, "style")
+	if err != nil {
+		return nil, err
+	}
+
+	//initialising router
+	r := mux.NewRouter()
+	r.PathPrefix("/style/").Handler(http.StripPrefix("/style/", http.FileServer(http.FS(style))))
+	r.HandleFunc("/assets", internal.GetAssetsByID(s)).Methods("GET").Queries("ids", "{ids}")
+	r.HandleFunc("/assets", internal.GetAssets(s)).Methods("GET")
+	r.HandleFunc("/assets", internal.AddAsset(s)).Methods("POST")
+	r.HandleFunc("/assets/trash", internal.GetTrash(s)).Methods("GET")
+	r.HandleFunc("/assets/{id}", internal.GetAsset(s)).Methods("GET")
+	r.HandleFunc("/api/assets", internal.ListAssets(s)).Methods("GET")
+	r.HandleFunc("/assets/{id}/edit", internal.EditAsset(s)).Methods("POST")
+	r.HandleFunc("/assets/{id}/delete", internal.DeleteAsset(s)).Methods("POST")
+	r.HandleFunc("/assets/{id}/restore", internal.RestoreAsset(s)).Methods("POST")
+	r.HandleFunc("/assets/{id}/purge", internal.PurgeAsset(s)).Methods("POST")
+
+	// Reliability routes
+	r.HandleFunc("/assets/{id}/events", internal.GetAssetEvents(s)).Methods("GET")
+	r.HandleFunc("/assets/{id}/events", internal.RecordFailure(s)).Methods("POST")
+	r.HandleFunc("/assets/{id}/events/{eventID}/close", internal.CloseFailure(s)).Methods("POST")
+	r.HandleFunc("/kpis", internal.GetKPIs(s)).Methods("GET")
+	r.HandleFunc("/api/kpis", internal.GetKPIsJSON(s)).Methods("GET")
+
+	return metrics.Instrument(config.Asset, route(r), csrf.Protect(r)), nil
+}
+
+// route returns the path template of the route of r a request matches, for
+// the metrics
+func route(r *mux.Router) func(req *http.Request) string {
+	return func(req *http.Request) string {
+		var m mux.RouteMatch
+		if !r.Match(req, &m) || m.Route == nil {
+			return ""
+		}
+		tpl, err := m.Route.GetPathTemplate()
+		if err != nil {
+			return ""
+		}
+		return tpl
+	}
+}
+
+// RegisterGRPC adds the gRPC server of the assets to s; it streams the changes
+// made through the Handler of the same process
+func RegisterGRPC(s grpc.ServiceRegistrar, conf *config.Config, b store.Backend) {
+	var assets internal.AssetRepository
+	if b.SQL != nil {
+		assets = internal.NewSQLStore(b.SQL).Assets
+	} else {
+		assets = internal.NewMongoStore(b.Mongo.Database(conf.Database(config.Asset)), b.Mongo.Database(conf.Mongo.Database)).Assets
+	}
+	cmmspb.RegisterAssetRegisterServer(s, internal.NewAssetRegister(assets))
+}
+
+// CopyToSQL copies the assets and failure events kept in MongoDB by client
+// into to and returns how many records it copied
+func CopyToSQL(ctx context.Context, conf *config.Config, client *mongo.Client, to *store.SQL) (int, error) {
+	return internal.CopyToSQL(ctx, client.Database(conf.Database(config.Asset)), to)
+}
@@ -0,0 +1,638 @@
+package asset
+
+import (
+	"cmms/project/asset/internal"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"shared/config"
+	"shared/csrf"
+	"shared/events"
+	"shared/flash"
+	"shared/httpclient"
+	"shared/references"
+	"shared/store"
+	"shared/webhook"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fakeMaintenance is the reference API of the maintenance service, answering
+// refs until they are resolved
+type fakeMaintenance struct {
+	refs     []references.Reference
+	resolved []url.Values
+}
+
+func (f *fakeMaintenance) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/api/references":
+		refs := f.refs
+		if refs == nil {
+			refs = []references.Reference{}
+		}
+		json.NewEncoder(w).Encode(refs)
+	case "/api/references/resolve":
+		r.ParseForm()
+		f.resolved = append(f.resolved, r.PostForm)
+		json.NewEncoder(w).Encode(references.Result{Updated: len(f.refs)})
+		f.refs = nil
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newTestServer returns the routes of the asset service over an in-memory
+// store, with the maintenance service faked
+func newTestServer(t *testing.T) (*internal.Store, http.Handler, *fakeMaintenance) {
+	t.Helper()
+	maintenance := &fakeMaintenance{}
+	if err := internal.Configure(config.Default(), assets, &httpclient.HandlerTransport{Handler: maintenance}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := internal.NewMemoryStore()
+	h, err := routes(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, h, maintenance
+}
+
+func do(h http.Handler, method, target string, form url.Values) *httptest.ResponseRecorder {
+	var r *http.Request
+	if form != nil {
+		r = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	r.AddCookie(&http.Cookie{Name: csrf.CookieName, Value: "test"})
+	r.Header.Set(csrf.HeaderName, csrf.Token(r))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func seed(t *testing.T, s *internal.Store, assets ...internal.Asset) []internal.Asset {
+	t.Helper()
+	for i := range assets {
+		assets[i].ID = primitive.NewObjectID()
+		if err := s.Assets.Insert(context.Background(), assets[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return assets
+}
+
+// redirectedTo returns the location of a See Other answer
+func redirectedTo(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusSeeOther)
+	}
+	return w.Header().Get("Location")
+}
+
+// flashOf returns the flash message an answer leaves for the next page
+func flashOf(w *httptest.ResponseRecorder) flash.Message {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	m, _ := flash.Get(httptest.NewRecorder(), r)
+	return m
+}
+
+func TestGetAssets(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	seed(t, s, internal.Asset{Label: "Pump 1"}, internal.Asset{Label: "Boiler"})
+
+	set := httptest.NewRecorder()
+	flash.Set(set, flash.Success, "Saved")
+	r := httptest.NewRequest(http.MethodGet, "/assets", nil)
+	r.AddCookie(set.Result().Cookies()[0])
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	for _, want := range []string{"Pump 1", "Boiler", "Saved"} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("page does not show %q", want)
+		}
+	}
+	cleared := false
+	for _, c := range w.Result().Cookies() {
+		cleared = cleared || c.Name == flash.CookieName && c.MaxAge < 0
+	}
+	if !cleared {
+		t.Errorf("flash cookie not cleared: %v", w.Result().Cookies())
+	}
+
+	// Messages in the URL or in a cookie the service did not sign are not shown
+	r = httptest.NewRequest(http.MethodGet, "/assets?success=Forged&error=Forged", nil)
+	r.AddCookie(&http.Cookie{Name: flash.CookieName, Value: "eyJraW5kIjoiZXJyb3IiLCJ0ZXh0IjoiRm9yZ2VkIn0.bad"})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if strings.Contains(w.Body.String(), "Forged") {
+		t.Error("page shows a forged message")
+	}
+}
+
+func TestAddAsset(t *testing.T) {
+	s, h, _ := newTestServer(t)
+
+	w := do(h, http.MethodPost, "/assets", url.Values{
+		"label": {"Pump 1"}, "type": {"pump"}, "location": {"Plant A"}, "effective_date": {"2024-03-01"},
+	})
+	if loc := redirectedTo(t, w); loc != "/assets" || flashOf(w).Kind != flash.Success {
+		t.Errorf("redirected to %s with %+v", loc, flashOf(w))
+	}
+	list, _ := s.Assets.List(context.Background())
+	if len(list) != 1 || list[0].Type != "pump" || !list[0].EffectiveDate.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("assets = %+v", list)
+	}
+	if e := s.Outbox.(*events.MemoryOutbox).Events(); len(e) != 1 || e[0].Type != events.AssetCreated {
+		t.Errorf("outbox = %+v", e)
+	}
+	if e := s.Webhooks.(*webhook.MemoryPublisher).Events(); len(e) != 1 {
+		t.Errorf("webhook events = %+v", e)
+	}
+
+	for date, want := range map[string]string{"": "This field is required", "01/03/2024": "Enter a date as YYYY-MM-DD"} {
+		w := do(h, http.MethodPost, "/assets", url.Values{"label": {"Bad"}, "type": {"pump"}, "location": {"Plant A"}, "effective_date": {date}})
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), want) || !strings.Contains(w.Body.String(), `value="Bad"`) {
+			t.Errorf("date %q: status = %d, page does not show %q with the input", date, w.Code, want)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/assets", strings.NewReader(`{"label": "Bad"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var body struct{ Errors map[string]string }
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil || w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, %v", w.Code, err)
+	}
+	if body.Errors["type"] == "" || body.Errors["effective_date"] == "" || body.Errors["label"] != "" {
+		t.Errorf("errors = %v", body.Errors)
+	}
+	if list, _ := s.Assets.List(context.Background()); len(list) != 1 {
+		t.Errorf("invalid assets saved: %+v", list)
+	}
+}
+
+func TestEditAsset(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	a := seed(t, s, internal.Asset{Label: "Pump 1", Type: "pump"})[0]
+
+	redirectedTo(t, do(h, http.MethodPost, "/assets/"+a.ID.Hex()+"/edit", url.Values{
+		"label": {"Pump 2"}, "type": {"pump"}, "location": {"Plant B"}, "effective_date": {"2024-04-01"}, "version": {"0"},
+	}))
+	got, err := s.Assets.Get(context.Background(), a.ID)
+	if err != nil || got.Label != "Pump 2" || got.Location != "Plant B" || got.Version != 1 {
+		t.Fatalf("asset = %+v, %v", got, err)
+	}
+
+	w := do(h, http.MethodPost, "/assets/"+primitive.NewObjectID().Hex()+"/edit", url.Values{"effective_date": {"2024-04-01"}})
+	redirectedTo(t, w)
+	if m := flashOf(w); m.Kind != flash.Error || m.Text != "Asset not found" {
+		t.Errorf("unknown asset: flash = %+v", m)
+	}
+
+	w = do(h, http.MethodPost, "/assets/not-an-id/edit", url.Values{"effective_date": {"2024-04-01"}})
+	redirectedTo(t, w)
+	if m := flashOf(w); m.Kind != flash.Error || m.Text != "Invalid asset ID" {
+		t.Errorf("invalid id: flash = %+v", m)
+	}
+
+	w = do(h, http.MethodPost, "/assets/"+a.ID.Hex()+"/edit", url.Values{
+		"label": {""}, "type": {"pump"}, "location": {"Plant C"}, "effective_date": {"2024-04-01"}, "version": {"1"},
+	})
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "This field is required") {
+		t.Errorf("blank label: status = %d", w.Code)
+	}
+	if got, _ := s.Assets.Get(context.Background(), a.ID); got.Location != "Plant B" {
+		t.Errorf("invalid edit saved: %+v", got)
+	}
+}
+
+func TestEditAssetConflict(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	a := seed(t, s, internal.Asset{Label: "Pump 1", Type: "pump", Location: "Plant A"})[0]
+	target := "/assets/" + a.ID.Hex() + "/edit"
+
+	// An API caller reads the version of the asset from its ETag
+	w := do(h, http.MethodGet, "/assets/"+a.ID.Hex(), nil)
+	etag := w.Header().Get("ETag")
+	if etag != `"0"` {
+		t.Fatalf("ETag = %s", etag)
+	}
+
+	// Someone else saves version 0 in the meantime
+	redirectedTo(t, do(h, http.MethodPost, target, url.Values{
+		"label": {"Pump 1"}, "type": {"pump"}, "location": {"Plant B"}, "effective_date": {"2024-04-01"}, "version": {"0"},
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"label": "Pump 9", "type": "pump", "location": "Plant C", "effective_date": "2024-04-01"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("If-Match", etag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusConflict || w.Header().Get("ETag") != `"1"` || !strings.Contains(w.Body.String(), `"location":"Plant B"`) {
+		t.Fatalf("JSON: status = %d, ETag = %s, body = %s", w.Code, w.Header().Get("ETag"), w.Body)
+	}
+
+	// A stale form gets the page with both versions
+	w = do(h, http.MethodPost, target, url.Values{
+		"label": {"Pump 1"}, "type": {"pump"}, "location": {"Plant C"}, "effective_date": {"2024-04-01"}, "version": {"0"},
+	})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("stale form: status = %d", w.Code)
+	}
+	for _, want := range []string{"Plant B", "Plant C", `class="changed"`, `name="version" value="1"`} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("conflict page does not show %q", want)
+		}
+	}
+	if got, _ := s.Assets.Get(context.Background(), a.ID); got.Location != "Plant B" || got.Version != 1 {
+		t.Errorf("stale edit saved: %+v", got)
+	}
+	if e := s.Outbox.(*events.MemoryOutbox).Events(); len(e) != 1 {
+		t.Errorf("outbox = %d events, want the first edit only", len(e))
+	}
+}
+
+func TestDeleteAsset(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	a := seed(t, s, internal.Asset{Label: "Pump 1"})[0]
+
+	redirectedTo(t, do(h, http.MethodPost, "/assets/"+a.ID.Hex()+"/delete", nil))
+	if _, err := s.Assets.GetDeleted(context.Background(), a.ID); err != nil {
+		t.Fatalf("asset not in the recycle bin: %v", err)
+	}
+	if e := s.Outbox.(*events.MemoryOutbox).Events(); len(e) != 1 || e[0].Type != events.AssetDeleted {
+		t.Errorf("outbox = %+v", e)
+	}
+}
+
+func TestDeleteReferencedAsset(t *testing.T) {
+	s, h, maintenance := newTestServer(t)
+	list := seed(t, s, internal.Asset{Label: "Pump 1"}, internal.Asset{Label: "Pump 2"})
+	maintenance.refs = []references.Reference{{Entity: references.KindMaintenance, ID: primitive.NewObjectID(), Label: "Yearly service", AssetID: list[0].ID, Field: "asset_id"}}
+
+	w := do(h, http.MethodPost, "/assets/"+list[0].ID.Hex()+"/delete", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Yearly service") {
+		t.Fatalf("status = %d, confirmation page not shown", w.Code)
+	}
+
+	// Reassigning needs an asset to move to
+	w = do(h, http.MethodPost, "/assets/"+list[0].ID.Hex()+"/delete", url.Values{"action": {references.ActionReassign}})
+	if w.Code != http.StatusOK || len(maintenance.resolved) != 0 {
+		t.Fatalf("status = %d, resolved = %+v", w.Code, maintenance.resolved)
+	}
+
+	redirectedTo(t, do(h, http.MethodPost, "/assets/"+list[0].ID.Hex()+"/delete", url.Values{
+		"action": {references.ActionReassign}, "to": {list[1].ID.Hex()},
+	}))
+	if len(maintenance.resolved) != 1 || maintenance.resolved[0].Get("to") != list[1].ID.Hex() {
+		t.Errorf("resolved = %+v", maintenance.resolved)
+	}
+	if _, err := s.Assets.GetDeleted(context.Background(), list[0].ID); err != nil {
+		t.Errorf("asset not in the recycle bin: %v", err)
+	}
+}
+
+func TestGetAsset(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	a := seed(t, s, internal.Asset{Label: "Pump 1"})[0]
+
+	var got internal.Asset
+	w := do(h, http.MethodGet, "/assets/"+a.ID.Hex(), nil)
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil || got.Label != "Pump 1" {
+		t.Fatalf("asset = %+v, %v", got, err)
+	}
+
+	if w := do(h, http.MethodGet, "/assets/"+primitive.NewObjectID().Hex(), nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown asset: status = %d", w.Code)
+	}
+	if w := do(h, http.MethodGet, "/assets/nope", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid id: status = %d", w.Code)
+	}
+}
+
+func TestAssetLists(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	list := seed(t, s,
+		internal.Asset{Label: "Pump 1", Type: "pump", Location: "Plant A"},
+		internal.Asset{Label: "Pump 2", Type: "pump", Location: "Plant B"},
+		internal.Asset{Label: "Boiler", Type: "boiler", Location: "Plant A"},
+	)
+
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{"/api/assets", 3},
+		{"/api/assets?type=pump", 2},
+		{"/api/assets?type=pump&location=Plant+A", 1},
+		{"/assets?ids=" + list[0].ID.Hex() + "," + list[2].ID.Hex(), 2},
+	}
+	for _, tt := range tests {
+		var got []internal.Asset
+		w := do(h, http.MethodGet, tt.target, nil)
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil || len(got) != tt.want {
+			t.Errorf("%s: %d assets, %v; want %d", tt.target, len(got), err, tt.want)
+		}
+	}
+
+	if w := do(h, http.MethodGet, "/assets?ids=nope", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid ids: status = %d", w.Code)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	list := seed(t, s, internal.Asset{Label: "Pump 1"}, internal.Asset{Label: "Pump 2"})
+	for _, a := range list {
+		if err := s.Assets.Delete(context.Background(), a.ID, "tester"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := do(h, http.MethodGet, "/assets/trash", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Pump 2") {
+		t.Fatalf("status = %d, deleted asset not listed", w.Code)
+	}
+
+	redirectedTo(t, do(h, http.MethodPost, "/assets/"+list[0].ID.Hex()+"/restore", nil))
+	if _, err := s.Assets.Get(context.Background(), list[0].ID); err != nil {
+		t.Errorf("asset not restored: %v", err)
+	}
+
+	redirectedTo(t, do(h, http.MethodPost, "/assets/"+list[1].ID.Hex()+"/purge", nil))
+	if deleted, _ := s.Assets.ListDeleted(context.Background()); len(deleted) != 0 {
+		t.Errorf("recycle bin = %+v", deleted)
+	}
+
+	w = do(h, http.MethodPost, "/assets/"+list[1].ID.Hex()+"/restore", nil)
+	redirectedTo(t, w)
+	if m := flashOf(w); m.Kind != flash.Error {
+		t.Errorf("purged asset restored: flash = %+v", m)
+	}
+}
+
+func TestFailureEvents(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	a := seed(t, s, internal.Asset{Label: "Pump 1"})[0]
+	events := "/assets/" + a.ID.Hex() + "/events"
+
+	redirectedTo(t, do(h, http.MethodPost, events, url.Values{"failure_code": {"LEAK"}, "start": {"2024-03-01T08:00"}}))
+	w := do(h, http.MethodPost, events, url.Values{
+		"failure_code": {"JAM"}, "start": {"2024-03-02T08:00"}, "end": {"2024-03-01T08:00"},
+	})
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "Must be after the failure") {
+		t.Errorf("end before start: status = %d", w.Code)
+	}
+
+	list, _ := s.Failures.ListByAsset(context.Background(), a.ID)
+	if len(list) != 1 || list[0].End != nil {
+		t.Fatalf("events = %+v", list)
+	}
+
+	w = do(h, http.MethodGet, events, nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "LEAK") {
+		t.Fatalf("status = %d, event not listed", w.Code)
+	}
+
+	w = do(h, http.MethodPost, events+"/"+list[0].ID.Hex()+"/close", url.Values{"end": {"2024-02-28T12:00"}})
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "Must be after the failure") {
+		t.Errorf("repaired before the failure: status = %d", w.Code)
+	}
+	redirectedTo(t, do(h, http.MethodPost, events+"/"+list[0].ID.Hex()+"/close", url.Values{"end": {"2024-03-01T12:00"}}))
+	list, _ = s.Failures.ListByAsset(context.Background(), a.ID)
+	if list[0].End == nil || !list[0].End.Equal(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("event not closed: %+v", list[0])
+	}
+
+	// A closed event cannot be closed again
+	w = do(h, http.MethodPost, events+"/"+list[0].ID.Hex()+"/close", nil)
+	redirectedTo(t, w)
+	if m := flashOf(w); m.Kind != flash.Error {
+		t.Errorf("closed twice: flash = %+v", m)
+	}
+}
+
+func TestKPIs(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	a := seed(t, s, internal.Asset{Label: "Pump 1", Type: "pump"})[0]
+	for _, day := range []int{2, 10} {
+		start := time.Date(2024, 3, day, 8, 0, 0, 0, time.UTC)
+		end := start.Add(4 * time.Hour)
+		event := internal.FailureEvent{ID: primitive.NewObjectID(), AssetID: a.ID, FailureCode: "LEAK", Start: start, End: &end}
+		if err := s.Failures.Insert(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var report internal.KPIReport
+	w := do(h, http.MethodGet, "/api/kpis?from=2024-03-01&to=2024-03-31", nil)
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("status = %d: %v", w.Code, err)
+	}
+	if len(report.Rows) != 1 || report.Rows[0].Failures != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+
+	if w := do(h, http.MethodGet, "/api/kpis?from=2024-03-31&to=2024-03-01", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("empty period: status = %d", w.Code)
+	}
+	if w := do(h, http.MethodGet, "/kpis?from=2024-03-01&to=2024-03-31", nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Pump 1") {
+		t.Errorf("status = %d, KPI page does not show the asset", w.Code)
+	}
+}
+
+func TestCloseFailureBeforeStart(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	a := seed(t, s, internal.Asset{Label: "Pump 1", Type: "pump"})[0]
+	ctx := context.Background()
+	start := time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC)
+	open := internal.FailureEvent{ID: primitive.NewObjectID(), AssetID: a.ID, FailureCode: "LEAK", Start: start}
+	if err := s.Failures.Insert(ctx, open); err != nil {
+		t.Fatal(err)
+	}
+
+	w := do(h, http.MethodPost, "/assets/"+a.ID.Hex()+"/events/"+open.ID.Hex()+"/close", url.Values{"end": {"2024-03-02T07:59"}})
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "Must be after the failure") {
+		t.Errorf("repaired before the failure: status = %d", w.Code)
+	}
+	if list, _ := s.Failures.ListByAsset(ctx, a.ID); len(list) != 1 || list[0].End != nil {
+		t.Fatalf("event closed: %+v", list)
+	}
+
+	// An event closed before its start by an earlier version gives no
+	// negative repair time
+	end := start.Add(-2 * time.Hour)
+	stale := internal.FailureEvent{ID: primitive.NewObjectID(), AssetID: a.ID, FailureCode: "JAM", Start: start.Add(time.Hour), End: &end}
+	if err := s.Failures.Insert(ctx, stale); err != nil {
+		t.Fatal(err)
+	}
+	var report internal.KPIReport
+	w = do(h, http.MethodGet, "/api/kpis?from=2024-03-01&to=2024-03-31", nil)
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("status = %d: %v", w.Code, err)
+	}
+	if len(report.Rows) != 1 || report.Rows[0].MTTR == nil || *report.Rows[0].MTTR < 0 || report.Rows[0].Downtime < 0 {
+		t.Errorf("report = %+v", report.Rows)
+	}
+}
+
+// TestKPIsOnEveryStore runs the same events, two of them closed before they
+// started, through the KPI aggregation of every store. MongoDB is only tried
+// when CMMS_TEST_MONGO_URI names a server.
+func TestKPIsOnEveryStore(t *testing.T) {
+	stores := map[string]func(t *testing.T) *internal.Store{
+		"memory": func(t *testing.T) *internal.Store { return internal.NewMemoryStore() },
+		"sqlite": func(t *testing.T) *internal.Store {
+			ctx := context.Background()
+			db, err := store.OpenSQL(ctx, store.SQLite, filepath.Join(t.TempDir(), "cmms.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			if err := internal.MigrateSQL(ctx, db); err != nil {
+				t.Fatal(err)
+			}
+			return internal.NewSQLStore(db)
+		},
+		"mongo": func(t *testing.T) *internal.Store {
+			uri := os.Getenv("CMMS_TEST_MONGO_URI")
+			if uri == "" {
+				t.Skip("CMMS_TEST_MONGO_URI not set")
+			}
+			ctx := context.Background()
+			client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+			if err != nil {
+				t.Fatal(err)
+			}
+			db := client.Database("cmms_test_" + primitive.NewObjectID().Hex())
+			t.Cleanup(func() {
+				db.Drop(ctx)
+				client.Disconnect(ctx)
+			})
+			return internal.NewMongoStore(db, db)
+		},
+	}
+
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			newTestServer(t)
+			s := open(t)
+			h, err := routes(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a := seed(t, s, internal.Asset{Label: "Pump 1", Type: "pump", EffectiveDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})[0]
+
+			at := func(day, hour int) time.Time { return time.Date(2024, 3, day, hour, 0, 0, 0, time.UTC) }
+			for _, e := range []struct{ start, end time.Time }{
+				{at(2, 8), at(2, 12)},
+				{at(5, 10), at(5, 8)},
+				// Started inside the period, closed before it
+				{at(1, 1), at(1, 1).Add(-2 * time.Hour)},
+			} {
+				end := e.end
+				event := internal.FailureEvent{ID: primitive.NewObjectID(), AssetID: a.ID, FailureCode: "LEAK", Start: e.start, End: &end}
+				if err := s.Failures.Insert(context.Background(), event); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var report internal.KPIReport
+			w := do(h, http.MethodGet, "/api/kpis?from=2024-03-01&to=2024-03-31", nil)
+			if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+				t.Fatalf("status = %d: %v", w.Code, err)
+			}
+			if len(report.Rows) != 1 {
+				t.Fatalf("report = %+v", report)
+			}
+			k := report.Rows[0]
+			if k.Failures != 3 || k.Repairs != 3 || k.Downtime != 4 || k.MTTR == nil || *k.MTTR != 4.0/3 ||
+				k.Availability == nil || *k.Availability > 1 {
+				t.Errorf("kpi = %+v", k)
+			}
+		})
+	}
+}
+
+func TestKPIStorageErrors(t *testing.T) {
+	newTestServer(t)
+	ctx := context.Background()
+	db, err := store.OpenSQL(ctx, store.SQLite, filepath.Join(t.TempDir(), "cmms.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := internal.MigrateSQL(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	s := internal.NewSQLStore(db)
+	h, err := routes(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if w := do(h, http.MethodGet, "/api/kpis?from=2024-03-01&to=2024-03-31", nil); w.Code != http.StatusInternalServerError {
+		t.Errorf("storage error: status = %d", w.Code)
+	}
+	if w := do(h, http.MethodGet, "/api/kpis?group_by=colour", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown group_by: status = %d", w.Code)
+	}
+	w := do(h, http.MethodGet, "/kpis?from=2024-03-01&to=2024-03-31", nil)
+	if body := w.Body.String(); !strings.Contains(body, "Error computing KPIs") || strings.Contains(body, "database is closed") {
+		t.Errorf("KPI page shows the storage error: %s", body)
+	}
+
+}
+
+// failingLookups fails to list the failure events of an asset
+type failingLookups struct {
+	internal.FailureEventRepository
+}
+
+func (failingLookups) ListByAsset(ctx context.Context, assetID primitive.ObjectID) ([]internal.FailureEvent, error) {
+	return nil, errors.New("lookup failed")
+}
+
+func TestCloseFailureLookupError(t *testing.T) {
+	s, _, _ := newTestServer(t)
+	a := seed(t, s, internal.Asset{Label: "Pump 1"})[0]
+	open := internal.FailureEvent{ID: primitive.NewObjectID(), AssetID: a.ID, FailureCode: "LEAK", Start: time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC)}
+	if err := s.Failures.Insert(context.Background(), open); err != nil {
+		t.Fatal(err)
+	}
+	s.Failures = failingLookups{s.Failures}
+	h, err := routes(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The repair is not recorded when the failure cannot be checked
+	w := do(h, http.MethodPost, "/assets/"+a.ID.Hex()+"/events/"+open.ID.Hex()+"/close", url.Values{"end": {"2024-03-02T07:59"}})
+	redirectedTo(t, w)
+	if m := flashOf(w); m.Kind != flash.Error {
+		t.Errorf("lookup error: flash = %+v", m)
+	}
+}
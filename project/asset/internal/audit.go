@@ -0,0 +1,19 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"shared/audit"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// record adds an entry to the audit trail; a failure is logged but never
+// fails the request, since the change itself was saved
+func record(ctx context.Context, r *http.Request, s *Store, action, entity string, id primitive.ObjectID, label string, before, after interface{}) {
+	logger := audit.NewLogger(s.Audit, "asset")
+	if err := logger.Record(ctx, r, action, entity, id, label, before, after); err != nil {
+		log.Printf("error recording audit entry for %s %s: %v", entity, id.Hex(), err)
+	}
+}
@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"io/fs"
+	"net/http"
+	"shared/config"
+	"shared/metrics"
+	"shared/references"
+)
+
+// Settings taken from the shared configuration by Configure
+var (
+	maintenanceURL  string
+	referenceClient *references.Client
+)
+
+// Configure points the handlers at the maintenance service and parses the
+// page templates found in fsys; it must be called before the routes are
+// served. Calls to the maintenance service go
+// through transport when it is set, and over the network otherwise; they are
+// counted in the metrics.
+func Configure(conf *config.Config, fsys fs.FS, transport http.RoundTripper) error {
+	maintenanceURL = conf.PublicURL(config.Maintenance)
+	referenceClient = references.NewClient(conf.URL(config.Maintenance))
+	referenceClient.HTTP.Transport = metrics.Transport(config.Maintenance, transport)
+	return parseTemplates(fsys)
+}
@@ -1,32 +1,70 @@
 package internal
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
 	"html/template"
+	"io/fs"
 	"log"
 	"net/http"
+	"shared/audit"
+	"shared/conflict"
+	"shared/csrf"
+	"shared/events"
+	"shared/flash"
+	"shared/jsonapi"
+	"shared/references"
+	"shared/store"
+	"shared/trash"
+	"shared/validate"
+	"shared/webhook"
 	"time"
 
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var templates *template.Template
 
-func init() {
-	templates = template.Must(template.New("").Funcs(template.FuncMap{
-		"add": func(a, b int) int { return a + b },
-	}).ParseGlob("templates/*.html"))
+// parseTemplates parses the templates/*.html pages of fsys
+func parseTemplates(fsys fs.FS) error {
+	var err error
+	templates, err = template.New("").Funcs(template.FuncMap{
+		"add":            func(a, b int) int { return a + b },
+		"maintenanceURL": func() string { return maintenanceURL },
+		"hours": func(v *float64) string {
+			if v == nil {
+				return "-"
+			}
+			return fmt.Sprintf("%.1f", *v)
+		},
+		"percent": func(v *float64) string {
+			if v == nil {
+				return "-"
+			}
+			return fmt.Sprintf("%.2f%%", *v*100)
+		},
+		"days":           func(d time.Duration) int { return int(d.Hours() / 24) },
+		"assetTypes":     func() []string { return assetTypes },
+		"assetLocations": func() []string { return assetLocations },
+		"purgeDate": func(deletedAt *time.Time, retention time.Duration) string {
+			if deletedAt == nil {
+				return "-"
+			}
+			return trash.PurgeDate(*deletedAt, retention).Format("2006-01-02")
+		},
+	}).ParseFS(fsys, "templates/*.html")
+	return err
 }
 
 // GetAssets renders all asset records on the asset page
-func GetAssets(db *mongo.Database) http.HandlerFunc {
+func GetAssets(s *Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		var result AssetsPageData
+		result := AssetsPageData{CSRF: csrf.Field(r)}
 
-		data, err := getAllAssets(ctx, db)
+		data, err := s.Assets.List(ctx)
 		if err != nil {
 			log.Printf("error fetching records: %v", err)
 			result.Error = "Error fetching records"
@@ -34,12 +72,7 @@ func GetAssets(db *mongo.Database) http.HandlerFunc {
 			result.Data = data
 		}
 
-		if msg := r.URL.Query().Get("success"); msg != "" {
-			result.Message = msg
-		}
-		if errMsg := r.URL.Query().Get("error"); errMsg != "" {
-			result.Error = errMsg
-		}
+		showFlash(w, r, &result.Message, &result.Error)
 
 		if err := templates.ExecuteTemplate(w, "Asset.html", result); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -48,79 +81,161 @@ func GetAssets(db *mongo.Database) http.HandlerFunc {
 	}
 }
 
+// assetForm checks the input of the add and edit forms, named name
+func assetForm(r *http.Request, name string) (*validate.Form, Asset, error) {
+	f, err := validate.Parse(r, name)
+	if err != nil {
+		return nil, Asset{}, err
+	}
+	f.Required("label", "type", "location")
+	f.MaxLength("label", 200)
+	asset := Asset{
+		Label:         f.Get("label"),
+		Type:          f.Get("type"),
+		Location:      f.Get("location"),
+		EffectiveDate: f.Time("effective_date", "2006-01-02"),
+	}
+	return f, asset, nil
+}
+
+// showAssetForm answers a form that did not validate: the asset page again
+// with the form open, its input kept and the errors next to the fields, or
+// the errors as JSON for an API call
+func showAssetForm(w http.ResponseWriter, r *http.Request, s *Store, f *validate.Form) {
+	if validate.WantsJSON(r) {
+		validate.WriteErrors(w, f.Errors)
+		return
+	}
+	result := AssetsPageData{Form: f, CSRF: csrf.Field(r)}
+	data, err := s.Assets.List(r.Context())
+	if err != nil {
+		log.Printf("error fetching records: %v", err)
+		result.Error = "Error fetching records"
+	}
+	result.Data = data
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	if err := templates.ExecuteTemplate(w, "Asset.html", result); err != nil {
+		log.Printf("error rendering assets: %v", err)
+	}
+}
+
+// showFlash puts the flash message left by the handler that redirected to
+// the page in message or errMsg, by its kind
+func showFlash(w http.ResponseWriter, r *http.Request, message, errMsg *string) {
+	m, ok := flash.Get(w, r)
+	if !ok {
+		return
+	}
+	if m.Kind == flash.Error {
+		*errMsg = m.Text
+	} else {
+		*message = m.Text
+	}
+}
+
 // AddAsset inserts a new asset record into the database
-func AddAsset(db *mongo.Database) http.HandlerFunc {
+func AddAsset(s *Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
-		asset := Asset{
-			Label:    r.FormValue("label"),
-			Type:     r.FormValue("type"),
-			Location: r.FormValue("location"),
-		}
-
-		dateStr := r.FormValue("effective_date")
-		if dateStr == "" {
-			http.Redirect(w, r, "/assets?error=Effective+date+required", http.StatusSeeOther)
+		f, asset, err := assetForm(r, "create")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		parsedDate, err := time.Parse("2006-01-02", dateStr)
-		if err != nil {
-			http.Redirect(w, r, "/assets?error=Invalid+date+format", http.StatusSeeOther)
+		if !f.Valid() {
+			showAssetForm(w, r, s, f)
 			return
 		}
-		asset.EffectiveDate = parsedDate
+		asset.ID = primitive.NewObjectID()
 
-		err = insertAsset(ctx, db, asset)
+		err = saveWithEvent(ctx, r, s, events.AssetCreated, asset, func(ctx context.Context) error {
+			return s.Assets.Insert(ctx, asset)
+		})
 		if err != nil {
-			http.Redirect(w, r, "/assets?error=Failed+to+insert+asset", http.StatusSeeOther)
+			flash.Redirect(w, r, "/assets", flash.Error, "Failed to insert asset")
 			return
 		}
+		record(ctx, r, s, audit.ActionCreate, "asset", asset.ID, asset.Label, nil, asset)
+		publish(ctx, s, webhook.AssetCreated, asset)
 
-		http.Redirect(w, r, "/assets?success=Asset+added+successfully!", http.StatusSeeOther)
+		flash.Redirect(w, r, "/assets", flash.Success, "Asset added successfully!")
 	}
 }
 
 // EditAsset updates an existing asset record identified by its ID
-func EditAsset(db *mongo.Database) http.HandlerFunc {
+func EditAsset(s *Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		vars := mux.Vars(r)
 		idStr := vars["id"]
 
-		objID, _ := primitive.ObjectIDFromHex(idStr)
-
-		label := r.FormValue("label")
-		typ := r.FormValue("type")
-		location := r.FormValue("location")
-		dateStr := r.FormValue("effective_date")
+		objID, err := primitive.ObjectIDFromHex(idStr)
+		if err != nil {
+			flash.Redirect(w, r, "/assets", flash.Error, "Invalid asset ID")
+			return
+		}
 
-		effectiveDate, err := time.Parse("2006-01-02", dateStr)
+		before, err := s.Assets.Get(ctx, objID)
 		if err != nil {
-			http.Redirect(w, r, "/assets?error=Invalid+date", http.StatusSeeOther)
+			flash.Redirect(w, r, "/assets", flash.Error, "Asset not found")
 			return
 		}
 
-		asset := Asset{
-			Label:         label,
-			Type:          typ,
-			Location:      location,
-			EffectiveDate: effectiveDate,
+		f, asset, err := assetForm(r, idStr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
+		asset.Version = f.Version(r)
+		if !f.Valid() {
+			showAssetForm(w, r, s, f)
+			return
+		}
+		asset.ID = objID
+		after := asset
+		after.Version++
 
-		err = updateAsset(ctx, db, objID, asset)
+		err = saveWithEvent(ctx, r, s, events.AssetUpdated, after, func(ctx context.Context) error {
+			return s.Assets.Update(ctx, asset)
+		})
+		if errors.Is(err, store.ErrConflict) {
+			showAssetConflict(w, r, asset, before)
+			return
+		}
 		if err != nil {
-			http.Redirect(w, r, "/assets?error=Error+updating+asset", http.StatusSeeOther)
+			flash.Redirect(w, r, "/assets", flash.Error, "Error updating asset")
 			return
 		}
+		record(ctx, r, s, audit.ActionUpdate, "asset", objID, after.Label, before, after)
+		publish(ctx, s, webhook.AssetUpdated, after)
 
-		http.Redirect(w, r, "/assets?success=Asset+updated+successfully", http.StatusSeeOther)
+		w.Header().Set("ETag", jsonapi.ETag(after.Version))
+		flash.Redirect(w, r, "/assets", flash.Success, "Asset updated successfully")
 	}
 }
 
-// DeleteAsset deletes an existing asset record by its ID
-func DeleteAsset(db *mongo.Database) http.HandlerFunc {
+// showAssetConflict answers an edit, mine, rejected because someone else
+// saved the asset since: the conflict page showing both versions, or the
+// current one as JSON for an API call
+func showAssetConflict(w http.ResponseWriter, r *http.Request, mine, current Asset) {
+	const day = "2006-01-02"
+	page := conflict.NewPage("ASSET "+current.Label, "/assets", "/assets/"+current.ID.Hex()+"/edit", r.PostForm, current.Version,
+		conflict.Field{Label: "Label", Mine: mine.Label, Current: current.Label},
+		conflict.Field{Label: "Type", Mine: mine.Type, Current: current.Type},
+		conflict.Field{Label: "Location", Mine: mine.Location, Current: current.Location},
+		conflict.Field{Label: "Effective Date", Mine: mine.EffectiveDate.Format(day), Current: current.EffectiveDate.Format(day)},
+	)
+	err := conflict.Write(w, r, page, current, current.Version, func(w http.ResponseWriter, p conflict.Page) error {
+		return templates.ExecuteTemplate(w, "ConflictAsset.html", p)
+	})
+	if err != nil {
+		log.Printf("error rendering the conflict page: %v", err)
+	}
+}
+
+// DeleteAsset moves an existing asset record to the recycle bin
+func DeleteAsset(s *Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		vars := mux.Vars(r)
@@ -128,22 +243,52 @@ func DeleteAsset(db *mongo.Database) http.HandlerFunc {
 
 		objID, err := primitive.ObjectIDFromHex(idStr)
 		if err != nil {
-			http.Redirect(w, r, "/assets?error=Invalid+asset+ID", http.StatusSeeOther)
+			flash.Redirect(w, r, "/assets", flash.Error, "Invalid asset ID")
 			return
 		}
 
-		err = deleteAssetByID(ctx, db, objID)
+		// Keep the deleted record for the webhook payload
+		asset, err := s.Assets.Get(ctx, objID)
 		if err != nil {
-			http.Redirect(w, r, "/assets?error=Failed+to+delete+asset", http.StatusSeeOther)
+			asset = Asset{ID: objID}
+		}
+
+		// Maintenances and schedules of the asset must be deleted or moved first
+		refs, err := referenceClient.Find(ctx, references.KindAsset, objID)
+		if err != nil {
+			log.Printf("error checking references to asset %s: %v", idStr, err)
+			flash.Redirect(w, r, "/assets", flash.Error, "Could not check the maintenances of the asset")
 			return
 		}
+		if len(refs) > 0 {
+			action := r.FormValue("action")
+			if action == "" {
+				confirmDeleteAsset(w, r, s, asset, refs, "")
+				return
+			}
+			if err := resolveAssetReferences(ctx, r, s, objID, action); err != nil {
+				confirmDeleteAsset(w, r, s, asset, refs, err.Error())
+				return
+			}
+		}
 
-		http.Redirect(w, r, "/assets?success=Asset+deleted+successfully", http.StatusSeeOther)
+		err = saveWithEvent(ctx, r, s, events.AssetDeleted, asset, func(ctx context.Context) error {
+			return s.Assets.Delete(ctx, objID, audit.Actor(r))
+		})
+		if err != nil {
+			flash.Redirect(w, r, "/assets", flash.Error, "Failed to delete asset")
+			return
+		}
+		record(ctx, r, s, audit.ActionDelete, "asset", objID, asset.Label, asset, nil)
+		publish(ctx, s, webhook.AssetDeleted, asset)
+
+		flash.Redirect(w, r, "/assets", flash.Success, "Asset moved to the recycle bin")
 	}
 }
 
-// GetAsset returns a single asset by its ID in JSON format
-func GetAsset(db *mongo.Database) http.HandlerFunc {
+// GetAsset returns a single asset by its ID in JSON format, with its
+// version as ETag for an edit to send back in If-Match
+func GetAsset(s *Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		vars := mux.Vars(r)
@@ -155,9 +300,9 @@ func GetAsset(db *mongo.Database) http.HandlerFunc {
 			return
 		}
 
-		asset, err := getAssetByID(ctx, db, objID)
+		asset, err := s.Assets.Get(ctx, objID)
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
+			if errors.Is(err, store.ErrNotFound) {
 				http.Error(w, "Asset not found", http.StatusNotFound)
 			} else {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -165,10 +310,42 @@ func GetAsset(db *mongo.Database) http.HandlerFunc {
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(asset); err != nil {
+		jsonapi.WriteRecord(w, r, asset, asset.Version)
+	}
+}
+
+// GetAssetsByID returns the assets listed in ?ids= in JSON format, so other
+// services resolve many labels with one request
+func GetAssetsByID(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, _, err := jsonapi.IDs(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		assets, err := s.Assets.GetByIDs(r.Context(), ids)
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+
+		jsonapi.WriteJSON(w, r, assets)
+	}
+}
+
+// ListAssets returns the assets in JSON format, optionally filtered by type and location
+func ListAssets(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		q := r.URL.Query()
+
+		assets, err := s.Assets.Find(ctx, q.Get("type"), q.Get("location"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		jsonapi.WriteJSON(w, r, assets)
 	}
 }
@@ -0,0 +1,296 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"shared/audit"
+	"shared/csrf"
+	"shared/flash"
+	"shared/store"
+	"shared/validate"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const dateTimeLayout = "2006-01-02T15:04"
+
+// GetAssetEvents renders the failure and repair history of an asset
+func GetAssetEvents(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+
+		objID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			http.Error(w, "Invalid ID format", http.StatusBadRequest)
+			return
+		}
+
+		asset, err := s.Assets.Get(ctx, objID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				http.Error(w, "Asset not found", http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		result := AssetEventsPageData{Asset: asset, CSRF: csrf.Field(r)}
+		events, err := s.Failures.ListByAsset(ctx, objID)
+		if err != nil {
+			log.Printf("error fetching failure events: %v", err)
+			result.Error = "Error fetching events"
+		} else {
+			result.Events = events
+		}
+
+		showFlash(w, r, &result.Message, &result.Error)
+
+		if err := templates.ExecuteTemplate(w, "AssetEvents.html", result); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// showEventForm answers a form of the events page that did not validate:
+// the page again with the form open, its input kept and the errors next to
+// the fields, or the errors as JSON for an API call
+func showEventForm(w http.ResponseWriter, r *http.Request, s *Store, asset Asset, f *validate.Form) {
+	if validate.WantsJSON(r) {
+		validate.WriteErrors(w, f.Errors)
+		return
+	}
+	result := AssetEventsPageData{Asset: asset, Form: f, CSRF: csrf.Field(r)}
+	events, err := s.Failures.ListByAsset(r.Context(), asset.ID)
+	if err != nil {
+		log.Printf("error fetching failure events: %v", err)
+		result.Error = "Error fetching events"
+	}
+	result.Events = events
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	if err := templates.ExecuteTemplate(w, "AssetEvents.html", result); err != nil {
+		log.Printf("error rendering failure events: %v", err)
+	}
+}
+
+// RecordFailure records a failure event for an asset; the repair end is optional
+func RecordFailure(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+		idStr := vars["id"]
+		redirect := "/assets/" + idStr + "/events"
+
+		objID, err := primitive.ObjectIDFromHex(idStr)
+		if err != nil {
+			flash.Redirect(w, r, "/assets", flash.Error, "Invalid asset ID")
+			return
+		}
+		asset, err := s.Assets.Get(ctx, objID)
+		if err != nil {
+			flash.Redirect(w, r, "/assets", flash.Error, "Asset not found")
+			return
+		}
+
+		f, err := validate.Parse(r, "create")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Required("failure_code")
+		f.MaxLength("failure_code", 100)
+		f.MaxLength("notes", 2000)
+		event := FailureEvent{
+			ID:          primitive.NewObjectID(),
+			AssetID:     objID,
+			FailureCode: f.Get("failure_code"),
+			Notes:       f.Get("notes"),
+			Start:       f.Time("start", dateTimeLayout),
+			End:         f.OptionalTime("end", dateTimeLayout),
+		}
+		if event.End != nil && f.Errors["start"] == "" {
+			f.Check(!event.End.Before(event.Start), "end", "Must be after the failure")
+		}
+		if !f.Valid() {
+			showEventForm(w, r, s, asset, f)
+			return
+		}
+
+		if err := s.Failures.Insert(ctx, event); err != nil {
+			flash.Redirect(w, r, redirect, flash.Error, "Failed to record failure")
+			return
+		}
+		record(ctx, r, s, audit.ActionCreate, "failure_event", event.ID, event.FailureCode, nil, event)
+
+		flash.Redirect(w, r, redirect, flash.Success, "Failure recorded successfully")
+	}
+}
+
+// CloseFailure records the repair of an open failure event
+func CloseFailure(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+		idStr := vars["id"]
+		redirect := "/assets/" + idStr + "/events"
+
+		objID, err := primitive.ObjectIDFromHex(idStr)
+		if err != nil {
+			flash.Redirect(w, r, "/assets", flash.Error, "Invalid asset ID")
+			return
+		}
+		eventID, err := primitive.ObjectIDFromHex(vars["eventID"])
+		if err != nil {
+			flash.Redirect(w, r, redirect, flash.Error, "Invalid event ID")
+			return
+		}
+
+		f, err := validate.Parse(r, eventID.Hex())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		end := time.Now().UTC()
+		if t := f.OptionalTime("end", dateTimeLayout); t != nil {
+			end = *t
+		}
+		if f.Valid() {
+			event, ok, err := findFailure(ctx, s, objID, eventID)
+			if err != nil {
+				log.Printf("error fetching failure events: %v", err)
+				flash.Redirect(w, r, redirect, flash.Error, "Failed to record repair")
+				return
+			}
+			if ok {
+				f.Check(!end.Before(event.Start), "end", "Must be after the failure")
+			}
+		}
+		if !f.Valid() {
+			asset, err := s.Assets.Get(ctx, objID)
+			if err != nil {
+				flash.Redirect(w, r, "/assets", flash.Error, "Asset not found")
+				return
+			}
+			showEventForm(w, r, s, asset, f)
+			return
+		}
+
+		err = s.Failures.Close(ctx, objID, eventID, end)
+		if err != nil {
+			flash.Redirect(w, r, redirect, flash.Error, "Failed to record repair")
+			return
+		}
+		record(ctx, r, s, audit.ActionUpdate, "failure_event", eventID, "", bson.M{"end": nil}, bson.M{"end": end})
+
+		flash.Redirect(w, r, redirect, flash.Success, "Repair recorded successfully")
+	}
+}
+
+// findFailure returns the failure event eventID of the asset assetID, if
+// there is one
+func findFailure(ctx context.Context, s *Store, assetID, eventID primitive.ObjectID) (FailureEvent, bool, error) {
+	events, err := s.Failures.ListByAsset(ctx, assetID)
+	if err != nil {
+		return FailureEvent{}, false, err
+	}
+	for _, e := range events {
+		if e.ID == eventID {
+			return e, true, nil
+		}
+	}
+	return FailureEvent{}, false, nil
+}
+
+// GetKPIs renders the reliability KPI page
+func GetKPIs(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var result KPIPageData
+
+		report, err := kpiQueryFromRequest(r)
+		if err != nil {
+			result.Error = "Invalid period: " + err.Error()
+		} else if report, err = computeKPIs(r.Context(), s.Failures, report.From, report.To, report.GroupBy, report.Type, report.Location); err != nil {
+			log.Printf("error computing KPIs: %v", err)
+			result.Error = "Error computing KPIs"
+		}
+		result.Report = report
+
+		if err := templates.ExecuteTemplate(w, "KPI.html", result); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetKPIsJSON returns the reliability KPIs in JSON format
+func GetKPIsJSON(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report, err := kpiQueryFromRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		report, err = computeKPIs(r.Context(), s.Failures, report.From, report.To, report.GroupBy, report.Type, report.Location)
+		if err != nil {
+			log.Printf("error computing KPIs: %v", err)
+			http.Error(w, "Error computing KPIs", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// kpiQueryFromRequest reads the period, grouping and filters of a report
+// from the query string; its errors are those of the request. The period
+// defaults to the last 30 days and both dates are inclusive.
+func kpiQueryFromRequest(r *http.Request) (KPIReport, error) {
+	q := r.URL.Query()
+
+	groupBy := q.Get("group_by")
+	switch groupBy {
+	case "":
+		groupBy = GroupByAsset
+	case GroupByAsset, GroupByType, GroupByLocation:
+	default:
+		return KPIReport{GroupBy: GroupByAsset}, fmt.Errorf("unknown group_by %q", groupBy)
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	to := today.AddDate(0, 0, 1)
+	if s := q.Get("to"); s != "" {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return KPIReport{GroupBy: groupBy}, err
+		}
+		to = d.AddDate(0, 0, 1)
+	}
+
+	from := to.AddDate(0, 0, -30)
+	if s := q.Get("from"); s != "" {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return KPIReport{GroupBy: groupBy}, err
+		}
+		from = d
+	}
+
+	if !from.Before(to) {
+		return KPIReport{From: from, To: to, GroupBy: groupBy}, errInvalidPeriod
+	}
+
+	return KPIReport{From: from, To: to, GroupBy: groupBy, Type: q.Get("type"), Location: q.Get("location")}, nil
+}
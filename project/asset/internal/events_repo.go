@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"shared/store"
+	"shared/trash"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const failureEventsCollection = "failure_events"
+
+// mongoFailureEvents is the FailureEventRepository of the failure_events
+// collection next to the assets
+type mongoFailureEvents struct {
+	db *mongo.Database
+}
+
+func (m mongoFailureEvents) Insert(ctx context.Context, event FailureEvent) error {
+	_, err := m.db.Collection(failureEventsCollection).InsertOne(ctx, event)
+	return err
+}
+
+func (m mongoFailureEvents) Close(ctx context.Context, assetID, eventID primitive.ObjectID, end time.Time) error {
+	res, err := m.db.Collection(failureEventsCollection).UpdateOne(
+		ctx,
+		bson.M{"_id": eventID, "asset_id": assetID, "end": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"end": end}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (m mongoFailureEvents) ListByAsset(ctx context.Context, assetID primitive.ObjectID) ([]FailureEvent, error) {
+	var result []FailureEvent
+
+	opts := options.Find().SetSort(bson.D{{Key: "start", Value: -1}})
+	cur, err := m.db.Collection(failureEventsCollection).Find(ctx, bson.M{"asset_id": assetID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	if err := cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Totals runs the KPI aggregation: the matching assets joined with their
+// failure events overlapping the period
+func (m mongoFailureEvents) Totals(ctx context.Context, from, to time.Time, groupBy, typ, location string) ([]kpiTotals, error) {
+	var groupKey interface{}
+	switch groupBy {
+	case GroupByAsset:
+		groupKey = bson.M{"$toString": "$_id"}
+	case GroupByType:
+		groupKey = "$type"
+	case GroupByLocation:
+		groupKey = "$location"
+	default:
+		return nil, fmt.Errorf("unknown group_by %q", groupBy)
+	}
+
+	match := trash.Live(bson.M{"effective_date": bson.M{"$lt": to}})
+	if typ != "" {
+		match["type"] = typ
+	}
+	if location != "" {
+		match["location"] = location
+	}
+
+	// An event closed before it started, which the forms no longer accept,
+	// ends at its start as in totalsOf: repaired at once, no negative time
+	endOrStart := bson.M{"$cond": bson.A{
+		bson.M{"$and": bson.A{
+			bson.M{"$eq": bson.A{bson.M{"$type": "$end"}, "date"}},
+			bson.M{"$lt": bson.A{"$end", "$start"}},
+		}},
+		"$start",
+		"$end",
+	}}
+	isClosed := bson.M{"$eq": bson.A{bson.M{"$type": "$$this.end"}, "date"}}
+	clippedEnd := bson.M{"$min": bson.A{bson.M{"$ifNull": bson.A{"$$this.end", to}}, to}}
+	clippedStart := bson.M{"$max": bson.A{"$$this.start", from}}
+	repairedInPeriod := bson.M{"$filter": bson.M{
+		"input": "$events",
+		"cond": bson.M{"$and": bson.A{
+			isClosed,
+			bson.M{"$gte": bson.A{"$$this.end", from}},
+			bson.M{"$lt": bson.A{"$$this.end", to}},
+		}},
+	}}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$lookup", Value: bson.M{
+			"from": failureEventsCollection,
+			"let":  bson.M{"aid": "$_id"},
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{"$expr": bson.M{"$and": bson.A{
+					bson.M{"$eq": bson.A{"$asset_id", "$$aid"}},
+					bson.M{"$lt": bson.A{"$start", to}},
+				}}}},
+				bson.M{"$addFields": bson.M{"end": endOrStart}},
+				bson.M{"$match": bson.M{"$expr": bson.M{
+					"$gt": bson.A{bson.M{"$ifNull": bson.A{"$end", to}}, from},
+				}}},
+			},
+			"as": "events",
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"label":    1,
+			"type":     1,
+			"location": 1,
+			"observed_ms": bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{
+				to, bson.M{"$max": bson.A{from, "$effective_date"}},
+			}}}},
+			"failures": bson.M{"$size": bson.M{"$filter": bson.M{
+				"input": "$events",
+				"cond":  bson.M{"$gte": bson.A{"$$this.start", from}},
+			}}},
+			"downtime_ms": bson.M{"$sum": bson.M{"$map": bson.M{
+				"input": "$events",
+				"in":    bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{clippedEnd, clippedStart}}}},
+			}}},
+			"repairs": bson.M{"$size": repairedInPeriod},
+			"repair_ms": bson.M{"$sum": bson.M{"$map": bson.M{
+				"input": repairedInPeriod,
+				"in":    bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{"$$this.end", "$$this.start"}}}},
+			}}},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":         groupKey,
+			"label":       bson.M{"$first": "$label"},
+			"assets":      bson.M{"$sum": 1},
+			"failures":    bson.M{"$sum": "$failures"},
+			"repairs":     bson.M{"$sum": "$repairs"},
+			"observed_ms": bson.M{"$sum": "$observed_ms"},
+			"downtime_ms": bson.M{"$sum": "$downtime_ms"},
+			"repair_ms":   bson.M{"$sum": "$repair_ms"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+
+	cur, err := m.db.Collection("assets").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var totals []kpiTotals
+	if err := cur.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
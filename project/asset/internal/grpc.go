@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"cmms/project/rpc"
+	"cmms/project/rpc/cmmspb"
+	"context"
+	"errors"
+	"shared/store"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// changes streams the assets changed by this process to WatchAssets
+var changes rpc.Feed[*cmmspb.AssetChange]
+
+// AssetRegister serves the assets over gRPC to the maintenance service
+type AssetRegister struct {
+	cmmspb.UnimplementedAssetRegisterServer
+	assets AssetRepository
+}
+
+// NewAssetRegister returns the gRPC server of assets
+func NewAssetRegister(assets AssetRepository) *AssetRegister {
+	return &AssetRegister{assets: assets}
+}
+
+func (s *AssetRegister) GetAsset(ctx context.Context, req *cmmspb.GetRequest) (*cmmspb.Asset, error) {
+	id, err := rpc.ObjectID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	asset, err := s.assets.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "asset %s not found", req.GetId())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return asset.proto(), nil
+}
+
+func (s *AssetRegister) BatchGetAssets(ctx context.Context, req *cmmspb.BatchGetRequest) (*cmmspb.Assets, error) {
+	ids, err := rpc.ObjectIDs(req.GetIds())
+	if err != nil {
+		return nil, err
+	}
+	assets, err := s.assets.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return assetsProto(assets), nil
+}
+
+func (s *AssetRegister) ListAssets(ctx context.Context, req *cmmspb.ListAssetsRequest) (*cmmspb.Assets, error) {
+	assets, err := s.assets.Find(ctx, req.GetType(), req.GetLocation())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return assetsProto(assets), nil
+}
+
+func (s *AssetRegister) ListDeletedAssets(ctx context.Context, _ *cmmspb.ListDeletedRequest) (*cmmspb.Assets, error) {
+	assets, err := s.assets.ListDeleted(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return assetsProto(assets), nil
+}
+
+func (s *AssetRegister) WatchAssets(_ *cmmspb.WatchRequest, stream cmmspb.AssetRegister_WatchAssetsServer) error {
+	return changes.Stream(stream.Context(), stream.Send)
+}
+
+func (a Asset) proto() *cmmspb.Asset {
+	return &cmmspb.Asset{
+		Id:            a.ID.Hex(),
+		Label:         a.Label,
+		Type:          a.Type,
+		Location:      a.Location,
+		EffectiveDate: timestamppb.New(a.EffectiveDate),
+	}
+}
+
+func assetsProto(assets []Asset) *cmmspb.Assets {
+	out := &cmmspb.Assets{Assets: make([]*cmmspb.Asset, len(assets))}
+	for i, a := range assets {
+		out.Assets[i] = a.proto()
+	}
+	return out
+}
@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// KPI grouping keys accepted by computeKPIs
+const (
+	GroupByAsset    = "asset"
+	GroupByType     = "type"
+	GroupByLocation = "location"
+)
+
+var errInvalidPeriod = errors.New("from must be before to")
+
+// kpiTotals are the sums of one group before they are turned into a KPI.
+// All durations are in milliseconds, as returned by Mongo date arithmetic.
+type kpiTotals struct {
+	Key        string `bson:"_id"`
+	Label      string `bson:"label"`
+	Assets     int    `bson:"assets"`
+	Failures   int    `bson:"failures"`
+	Repairs    int    `bson:"repairs"`
+	ObservedMS int64  `bson:"observed_ms"`
+	DowntimeMS int64  `bson:"downtime_ms"`
+	RepairMS   int64  `bson:"repair_ms"`
+}
+
+// computeKPIs computes MTBF, MTTR and availability of the matching assets
+// over [from, to), grouped per asset, type or location.
+//
+// Each asset is observed from the later of from and its effective date.
+// Downtime is the part of every failure event falling inside the period (an
+// open event lasts until to), a failure is counted when it started inside the
+// period and a repair when it ended inside the period.
+func computeKPIs(ctx context.Context, failures FailureEventRepository, from, to time.Time, groupBy, typ, location string) (KPIReport, error) {
+	report := KPIReport{From: from, To: to, GroupBy: groupBy, Type: typ, Location: location, Rows: []KPI{}}
+
+	switch groupBy {
+	case GroupByAsset, GroupByType, GroupByLocation:
+	default:
+		return report, fmt.Errorf("unknown group_by %q", groupBy)
+	}
+
+	totals, err := failures.Totals(ctx, from, to, groupBy, typ, location)
+	if err != nil {
+		return report, err
+	}
+
+	for _, t := range totals {
+		report.Rows = append(report.Rows, t.toKPI(groupBy))
+	}
+
+	return report, nil
+}
+
+// totalsOf computes what FailureEventRepository.Totals returns from the
+// matching assets and the failure events of the assets
+func totalsOf(assets []Asset, failures []FailureEvent, from, to time.Time, groupBy string) []kpiTotals {
+	ms := func(d time.Duration) int64 { return d.Milliseconds() }
+	later := func(a, b time.Time) time.Time {
+		if a.After(b) {
+			return a
+		}
+		return b
+	}
+	earlier := func(a, b time.Time) time.Time {
+		if a.Before(b) {
+			return a
+		}
+		return b
+	}
+
+	byAsset := map[primitive.ObjectID][]FailureEvent{}
+	for _, f := range failures {
+		if f.End != nil && f.End.Before(f.Start) {
+			// Closed before it started, which the forms no longer accept;
+			// counted as repaired at once rather than as negative downtime
+			start := f.Start
+			f.End = &start
+		}
+		end := to
+		if f.End != nil {
+			end = *f.End
+		}
+		if f.Start.Before(to) && end.After(from) {
+			byAsset[f.AssetID] = append(byAsset[f.AssetID], f)
+		}
+	}
+
+	groups := map[string]*kpiTotals{}
+	for _, a := range assets {
+		if !a.EffectiveDate.Before(to) {
+			continue
+		}
+		var key string
+		switch groupBy {
+		case GroupByAsset:
+			key = a.ID.Hex()
+		case GroupByType:
+			key = a.Type
+		default:
+			key = a.Location
+		}
+		t, ok := groups[key]
+		if !ok {
+			t = &kpiTotals{Key: key, Label: a.Label}
+			groups[key] = t
+		}
+
+		t.Assets++
+		if observed := to.Sub(later(from, a.EffectiveDate)); observed > 0 {
+			t.ObservedMS += ms(observed)
+		}
+		for _, f := range byAsset[a.ID] {
+			if !f.Start.Before(from) {
+				t.Failures++
+			}
+			end := to
+			if f.End != nil {
+				end = earlier(*f.End, to)
+			}
+			t.DowntimeMS += ms(end.Sub(later(f.Start, from)))
+			if f.End != nil && !f.End.Before(from) && f.End.Before(to) {
+				t.Repairs++
+				t.RepairMS += ms(f.End.Sub(f.Start))
+			}
+		}
+	}
+
+	totals := make([]kpiTotals, 0, len(groups))
+	for _, t := range groups {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Key < totals[j].Key })
+	return totals
+}
+
+func (t kpiTotals) toKPI(groupBy string) KPI {
+	k := KPI{
+		Key:      t.Key,
+		Label:    t.Key,
+		Assets:   t.Assets,
+		Failures: t.Failures,
+		Repairs:  t.Repairs,
+		Observed: msToHours(t.ObservedMS),
+		Downtime: msToHours(t.DowntimeMS),
+	}
+	if groupBy == GroupByAsset && t.Label != "" {
+		k.Label = t.Label
+	}
+
+	k.Uptime = k.Observed - k.Downtime
+	if k.Uptime < 0 {
+		k.Uptime = 0
+	}
+
+	if t.Failures > 0 {
+		mtbf := k.Uptime / float64(t.Failures)
+		k.MTBF = &mtbf
+	}
+	if t.Repairs > 0 {
+		mttr := msToHours(t.RepairMS) / float64(t.Repairs)
+		k.MTTR = &mttr
+	}
+	if k.Observed > 0 {
+		availability := k.Uptime / k.Observed
+		k.Availability = &availability
+	}
+
+	return k
+}
+
+// LastDay returns the inclusive end date of the report period
+func (r KPIReport) LastDay() time.Time {
+	return r.To.AddDate(0, 0, -1)
+}
+
+func msToHours(ms int64) float64 {
+	return float64(ms) / float64(time.Hour/time.Millisecond)
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"context"
+	"shared/store"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryAssets is an AssetRepository kept in memory, for tests
+type memoryAssets struct {
+	table *store.Table[Asset]
+}
+
+func newMemoryAssets() memoryAssets {
+	return memoryAssets{store.NewTable(func(a *Asset) (*primitive.ObjectID, **time.Time, *string) {
+		return &a.ID, &a.DeletedAt, &a.DeletedBy
+	})}
+}
+
+func (m memoryAssets) List(ctx context.Context) ([]Asset, error) {
+	return m.table.Live(nil), nil
+}
+
+func (m memoryAssets) Find(ctx context.Context, typ, location string) ([]Asset, error) {
+	return m.table.Live(func(a Asset) bool {
+		return (typ == "" || a.Type == typ) && (location == "" || a.Location == location)
+	}), nil
+}
+
+func (m memoryAssets) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Asset, error) {
+	wanted := map[primitive.ObjectID]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	return m.table.Live(func(a Asset) bool { return wanted[a.ID] }), nil
+}
+
+func (m memoryAssets) Get(ctx context.Context, id primitive.ObjectID) (Asset, error) {
+	return m.table.Get(id)
+}
+
+func (m memoryAssets) Insert(ctx context.Context, asset Asset) error {
+	return m.table.Insert(asset)
+}
+
+func (m memoryAssets) Update(ctx context.Context, asset Asset) error {
+	_, err := m.table.UpdateVersion(asset.ID, asset.Version, assetVersion, func(a *Asset) {
+		a.Label, a.Type, a.Location, a.EffectiveDate = asset.Label, asset.Type, asset.Location, asset.EffectiveDate
+	})
+	return err
+}
+
+func (m memoryAssets) Delete(ctx context.Context, id primitive.ObjectID, actor string) error {
+	return m.table.Delete(id, actor)
+}
+
+func (m memoryAssets) ListDeleted(ctx context.Context) ([]Asset, error) {
+	return m.table.Deleted(), nil
+}
+
+func (m memoryAssets) GetDeleted(ctx context.Context, id primitive.ObjectID) (Asset, error) {
+	return m.table.GetDeleted(id)
+}
+
+func (m memoryAssets) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Restore(id)
+}
+
+func (m memoryAssets) Purge(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Purge(id)
+}
+
+func (m memoryAssets) PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	return m.table.PurgeExpired(retention), nil
+}
+
+// memoryFailureEvents is a FailureEventRepository kept in memory, for tests
+type memoryFailureEvents struct {
+	mu     sync.Mutex
+	events []FailureEvent
+	assets memoryAssets
+}
+
+func (m *memoryFailureEvents) Insert(ctx context.Context, event FailureEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *memoryFailureEvents) Close(ctx context.Context, assetID, eventID primitive.ObjectID, end time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, e := range m.events {
+		if e.ID == eventID && e.AssetID == assetID && e.End == nil {
+			m.events[i].End = &end
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+func (m *memoryFailureEvents) ListByAsset(ctx context.Context, assetID primitive.ObjectID) ([]FailureEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []FailureEvent
+	for _, e := range m.events {
+		if e.AssetID == assetID {
+			result = append(result, e)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Start.After(result[j].Start) })
+	return result, nil
+}
+
+func (m *memoryFailureEvents) Totals(ctx context.Context, from, to time.Time, groupBy, typ, location string) ([]kpiTotals, error) {
+	assets, err := m.assets.Find(ctx, typ, location)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return totalsOf(assets, m.events, from, to, groupBy), nil
+}
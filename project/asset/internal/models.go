@@ -1,6 +1,9 @@
 package internal
 
 import (
+	"html/template"
+	"shared/references"
+	"shared/validate"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -12,10 +15,99 @@ type Asset struct {
 	Type          string             `bson:"type" json:"type"`
 	Location      string             `bson:"location" json:"location"`
 	EffectiveDate time.Time          `bson:"effective_date" json:"effective_date"`
+	Version       int64              `bson:"version" json:"version"` // counts the saved changes
+	DeletedAt     *time.Time         `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy     string             `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
 }
 
+// assetVersion is the version field of the versioned updates of the tables
+func assetVersion(a *Asset) *int64 {
+	return &a.Version
+}
+
+// assetTypes and assetLocations are the choices of the asset forms
+var (
+	assetTypes     = []string{"Machine", "Equipment", "Tool", "Vehicle", "Furniture", "IT Device", "Safety Gear"}
+	assetLocations = []string{"Production Floor", "Warehouse", "Assembly Line", "Quality Control Lab", "Maintenance Room", "Office", "Loading Dock"}
+)
+
 type AssetsPageData struct {
 	Data    []Asset
 	Message string
 	Error   string
+	// Form is the input of a form that did not validate, shown again
+	Form *validate.Form
+	// CSRF is the token field of the forms
+	CSRF template.HTML
+}
+
+// FailureEvent records a breakdown of an asset. End stays nil until the
+// asset has been repaired and is back in service.
+type FailureEvent struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	AssetID     primitive.ObjectID `bson:"asset_id" json:"asset_id"`
+	FailureCode string             `bson:"failure_code" json:"failure_code"`
+	Notes       string             `bson:"notes" json:"notes"`
+	Start       time.Time          `bson:"start" json:"start"`
+	End         *time.Time         `bson:"end,omitempty" json:"end,omitempty"`
+}
+
+type AssetEventsPageData struct {
+	Asset   Asset
+	Events  []FailureEvent
+	Message string
+	Error   string
+	// Form is the input of a form that did not validate, shown again
+	Form *validate.Form
+	// CSRF is the token field of the forms
+	CSRF template.HTML
+}
+
+// KPI holds the reliability figures of one group (an asset, a type or a
+// location) over a period. Durations are expressed in hours; MTBF and MTTR
+// are nil when there is nothing to average over.
+type KPI struct {
+	Key          string   `json:"key"`
+	Label        string   `json:"label"`
+	Assets       int      `json:"assets"`
+	Failures     int      `json:"failures"`
+	Repairs      int      `json:"repairs"`
+	Observed     float64  `json:"observed_hours"`
+	Downtime     float64  `json:"downtime_hours"`
+	Uptime       float64  `json:"uptime_hours"`
+	MTBF         *float64 `json:"mtbf_hours"`
+	MTTR         *float64 `json:"mttr_hours"`
+	Availability *float64 `json:"availability"`
+}
+
+type KPIReport struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	GroupBy  string    `json:"group_by"`
+	Type     string    `json:"type,omitempty"`
+	Location string    `json:"location,omitempty"`
+	Rows     []KPI     `json:"rows"`
+}
+
+type KPIPageData struct {
+	Report KPIReport
+	Error  string
+}
+
+type TrashPageData struct {
+	Data      []Asset
+	Retention time.Duration
+	Message   string
+	Error     string
+	CSRF      template.HTML
+}
+
+// DeleteAssetPageData lists what still references an asset being deleted
+// and the assets the references can be moved to
+type DeleteAssetPageData struct {
+	Asset   Asset
+	Refs    []references.Reference
+	Targets []Asset
+	Error   string
+	CSRF    template.HTML
 }
@@ -0,0 +1,19 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"shared/audit"
+)
+
+// saveWithEvent runs save and adds the domain event of the change to the
+// outbox, in one transaction so the other services hear of every change
+// saved and of no other
+func saveWithEvent(ctx context.Context, r *http.Request, s *Store, eventType string, asset Asset, save func(ctx context.Context) error) error {
+	return s.Tx.Atomically(ctx, func(ctx context.Context) error {
+		if err := save(ctx); err != nil {
+			return err
+		}
+		return s.Outbox.Record(ctx, "asset", eventType, asset.ID, audit.Actor(r), asset)
+	})
+}
@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"shared/csrf"
+	"shared/references"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// resolveAssetReferences deletes the maintenances and schedules of an asset
+// or moves them to the asset chosen in the to form value
+func resolveAssetReferences(ctx context.Context, r *http.Request, s *Store, id primitive.ObjectID, action string) error {
+	var to primitive.ObjectID
+	if action == references.ActionReassign {
+		var err error
+		if to, err = primitive.ObjectIDFromHex(r.FormValue("to")); err != nil {
+			return errors.New("choose an asset to move the maintenances to")
+		}
+		if _, err := s.Assets.Get(ctx, to); err != nil {
+			return errors.New("asset to move the maintenances to not found")
+		}
+	}
+
+	_, err := referenceClient.Resolve(ctx, r, references.KindAsset, id, action, to)
+	return err
+}
+
+// confirmDeleteAsset shows the maintenances and schedules still using an
+// asset and lets the user delete them along with it or move them to another
+// asset
+func confirmDeleteAsset(w http.ResponseWriter, r *http.Request, s *Store, asset Asset, refs []references.Reference, errMsg string) {
+	result := DeleteAssetPageData{Asset: asset, Refs: refs, Error: errMsg, CSRF: csrf.Field(r)}
+
+	all, err := s.Assets.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, a := range all {
+		if a.ID != asset.ID {
+			result.Targets = append(result.Targets, a)
+		}
+	}
+
+	if err := templates.ExecuteTemplate(w, "DeleteAsset.html", result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
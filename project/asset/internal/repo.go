@@ -2,65 +2,97 @@ package internal
 
 import (
 	"context"
+	"shared/store"
+	"shared/trash"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func getAllAssets(ctx context.Context, db *mongo.Database) ([]Asset, error) {
-	var result []Asset
-	collection := db.Collection("assets")
+// mongoAssets is the AssetRepository of the assets collection
+type mongoAssets struct {
+	coll *mongo.Collection
+}
+
+func (m mongoAssets) List(ctx context.Context) ([]Asset, error) {
+	return m.find(ctx, trash.Live(nil))
+}
+
+func (m mongoAssets) Find(ctx context.Context, typ, location string) ([]Asset, error) {
+	filter := trash.Live(nil)
+	if typ != "" {
+		filter["type"] = typ
+	}
+	if location != "" {
+		filter["location"] = location
+	}
+	return m.find(ctx, filter)
+}
 
-	cur, err := collection.Find(ctx, bson.M{})
+func (m mongoAssets) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Asset, error) {
+	return m.find(ctx, trash.Live(bson.M{"_id": bson.M{"$in": ids}}))
+}
+
+func (m mongoAssets) find(ctx context.Context, filter bson.M) ([]Asset, error) {
+	result := []Asset{}
+	cur, err := m.coll.Find(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 	defer cur.Close(ctx)
 
-	err = cur.All(ctx, &result)
-	if err != nil {
+	if err := cur.All(ctx, &result); err != nil {
 		return nil, err
 	}
-
 	return result, nil
 }
 
-func insertAsset(ctx context.Context, db *mongo.Database, asset Asset) error {
-	collection := db.Collection("assets")
-	_, err := collection.InsertOne(ctx, asset)
-	return err
+func (m mongoAssets) Get(ctx context.Context, id primitive.ObjectID) (Asset, error) {
+	var result Asset
+	err := m.coll.FindOne(ctx, trash.Live(bson.M{"_id": id})).Decode(&result)
+	return result, store.MongoErr(err)
 }
 
-func updateAsset(ctx context.Context, db *mongo.Database, id primitive.ObjectID, asset Asset) error {
-	collection := db.Collection("assets")
-	_, err := collection.UpdateOne(
-		ctx,
-		bson.M{"_id": id},
-		bson.M{"$set": bson.M{
-			"label":          asset.Label,
-			"type":           asset.Type,
-			"location":       asset.Location,
-			"effective_date": asset.EffectiveDate,
-		}},
-	)
+func (m mongoAssets) Insert(ctx context.Context, asset Asset) error {
+	_, err := m.coll.InsertOne(ctx, asset)
 	return err
 }
 
-func deleteAssetByID(ctx context.Context, db *mongo.Database, id primitive.ObjectID) error {
-	collection := db.Collection("assets")
-	_, err := collection.DeleteOne(ctx, bson.M{"_id": id})
-	return err
+func (m mongoAssets) Update(ctx context.Context, asset Asset) error {
+	return store.UpdateMongoVersion(ctx, m.coll, trash.Live(bson.M{"_id": asset.ID}), asset.Version, bson.M{
+		"label":          asset.Label,
+		"type":           asset.Type,
+		"location":       asset.Location,
+		"effective_date": asset.EffectiveDate,
+	})
+}
+
+func (m mongoAssets) Delete(ctx context.Context, id primitive.ObjectID, actor string) error {
+	return store.MongoErr(trash.Delete(ctx, m.coll, id, actor))
 }
 
-func getAssetByID(ctx context.Context, db *mongo.Database, id primitive.ObjectID) (Asset, error) {
+func (m mongoAssets) ListDeleted(ctx context.Context) ([]Asset, error) {
+	result := []Asset{}
+	err := trash.List(ctx, m.coll, &result)
+	return result, err
+}
+
+func (m mongoAssets) GetDeleted(ctx context.Context, id primitive.ObjectID) (Asset, error) {
 	var result Asset
-	collection := db.Collection("assets")
+	err := m.coll.FindOne(ctx, trash.Deleted(bson.M{"_id": id})).Decode(&result)
+	return result, store.MongoErr(err)
+}
 
-	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&result)
-	if err != nil {
-		return Asset{}, err
-	}
+func (m mongoAssets) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return store.MongoErr(trash.Restore(ctx, m.coll, id))
+}
 
-	return result, nil
-}
\ No newline at end of file
+func (m mongoAssets) Purge(ctx context.Context, id primitive.ObjectID) error {
+	return store.MongoErr(trash.Purge(ctx, m.coll, id))
+}
+
+func (m mongoAssets) PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	return trash.PurgeExpired(ctx, m.coll, retention)
+}
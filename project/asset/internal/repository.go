@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"context"
+	"shared/audit"
+	"shared/events"
+	"shared/store"
+	"shared/webhook"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AssetRepository keeps the assets. Deleted assets stay in the recycle bin
+// until they are restored or purged; only Deleted* and Restore and Purge see
+// them. A missing asset is store.ErrNotFound.
+type AssetRepository interface {
+	// List returns the live assets
+	List(ctx context.Context) ([]Asset, error)
+	// Find returns the live assets of the given type and location; empty
+	// values match everything
+	Find(ctx context.Context, typ, location string) ([]Asset, error)
+	GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Asset, error)
+	Get(ctx context.Context, id primitive.ObjectID) (Asset, error)
+	Insert(ctx context.Context, asset Asset) error
+	// Update saves the label, type, location and effective date of a live
+	// asset when it is still at asset.Version, the version the edit is based
+	// on, and moves it to the next version. It returns store.ErrConflict when
+	// the asset is at another version.
+	Update(ctx context.Context, asset Asset) error
+	// Delete moves an asset to the recycle bin
+	Delete(ctx context.Context, id primitive.ObjectID, actor string) error
+	// ListDeleted returns the recycle bin, most recently deleted first
+	ListDeleted(ctx context.Context) ([]Asset, error)
+	GetDeleted(ctx context.Context, id primitive.ObjectID) (Asset, error)
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	Purge(ctx context.Context, id primitive.ObjectID) error
+	// PurgeExpired purges the assets deleted more than retention ago
+	PurgeExpired(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// FailureEventRepository keeps the failure events of the assets
+type FailureEventRepository interface {
+	Insert(ctx context.Context, event FailureEvent) error
+	// Close sets the end of the open failure event of the asset, or returns
+	// store.ErrNotFound
+	Close(ctx context.Context, assetID, eventID primitive.ObjectID, end time.Time) error
+	// ListByAsset returns the events of an asset, latest start first
+	ListByAsset(ctx context.Context, assetID primitive.ObjectID) ([]FailureEvent, error)
+	// Totals sums the failures of the live assets of the given type and
+	// location over [from, to), grouped per asset, type or location and
+	// sorted by group, as described at computeKPIs
+	Totals(ctx context.Context, from, to time.Time, groupBy, typ, location string) ([]kpiTotals, error)
+}
+
+// Store is where the asset service keeps its data and records its changes:
+// the audit trail, the webhook events and the domain events, which are saved
+// in the transaction of the change
+type Store struct {
+	Assets   AssetRepository
+	Failures FailureEventRepository
+	Tx       store.Tx
+	Outbox   events.Outbox
+	Audit    audit.Store
+	Webhooks webhook.Publisher
+}
+
+// NewMongoStore returns the store of the asset service in db, with the audit
+// trail and the outbox in shared, the database shared by the services
+func NewMongoStore(db, shared *mongo.Database) *Store {
+	return &Store{
+		Assets:   mongoAssets{db.Collection("assets")},
+		Failures: mongoFailureEvents{db},
+		Tx:       store.NewMongoTx(db.Client()),
+		Outbox:   events.NewOutbox(shared),
+		Audit:    audit.NewMongoStore(shared),
+		Webhooks: webhook.NewPublisher(webhook.NewMongoStore(db)),
+	}
+}
+
+// mongoMigrations create the indexes of the asset service: the recycle bin,
+// the type and location the assets are filtered by, their labels and the
+// failure events of an asset, newest first
+var mongoMigrations = []store.MongoMigration{
+	{Version: 1, Name: "assets and failure_events indexes", Up: store.Steps(
+		store.Indexes("assets",
+			mongo.IndexModel{Keys: store.Keys("deleted_at")},
+			mongo.IndexModel{Keys: store.Keys("type", "location")},
+			mongo.IndexModel{Keys: store.Keys("label")},
+		),
+		store.Indexes(failureEventsCollection,
+			mongo.IndexModel{Keys: bson.D{{Key: "asset_id", Value: 1}, {Key: "start", Value: -1}}},
+		),
+	)},
+}
+
+// MigrateMongo creates or updates the indexes of the asset service and its
+// webhooks in db
+func MigrateMongo(ctx context.Context, db *mongo.Database) error {
+	if err := store.MigrateMongo(ctx, db, "asset", mongoMigrations); err != nil {
+		return err
+	}
+	return webhook.MigrateMongo(ctx, db)
+}
+
+// NewMemoryStore returns a store keeping everything in memory, for tests
+func NewMemoryStore() *Store {
+	assets := newMemoryAssets()
+	return &Store{
+		Assets:   assets,
+		Failures: &memoryFailureEvents{assets: assets},
+		Tx:       store.MemoryTx{},
+		Outbox:   &events.MemoryOutbox{},
+		Audit:    &audit.MemoryStore{},
+		Webhooks: &webhook.MemoryPublisher{},
+	}
+}
@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"context"
+	"shared/audit"
+	"shared/events"
+	"shared/store"
+	"shared/webhook"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// migrations create the tables of the asset service in a SQL database
+var migrations = []store.Migration{
+	{Version: 1, Name: "create assets and failure_events", Statements: []string{
+		`CREATE TABLE assets (
+			id TEXT PRIMARY KEY,
+			doc TEXT NOT NULL,
+			deleted_at BIGINT,
+			deleted_by TEXT NOT NULL DEFAULT '',
+			type TEXT NOT NULL,
+			location TEXT NOT NULL
+		)`,
+		"CREATE INDEX assets_deleted_at ON assets (deleted_at)",
+		"CREATE INDEX assets_type_location ON assets (type, location)",
+		`CREATE TABLE failure_events (
+			id TEXT PRIMARY KEY,
+			doc TEXT NOT NULL,
+			deleted_at BIGINT,
+			deleted_by TEXT NOT NULL DEFAULT '',
+			asset_id TEXT NOT NULL,
+			start BIGINT NOT NULL
+		)`,
+		"CREATE INDEX failure_events_asset_id ON failure_events (asset_id, start)",
+	}},
+}
+
+// MigrateSQL creates or updates the tables of the asset service and the
+// webhooks in db
+func MigrateSQL(ctx context.Context, db *store.SQL) error {
+	if err := db.Migrate(ctx, "asset", migrations); err != nil {
+		return err
+	}
+	return webhook.MigrateSQL(ctx, db)
+}
+
+// NewSQLStore returns the store of the asset service in db, which keeps the
+// audit trail, the outbox and the webhooks too
+func NewSQLStore(db *store.SQL) *Store {
+	assets := sqlAssets{assetsTable(db)}
+	return &Store{
+		Assets:   assets,
+		Failures: sqlFailureEvents{table: failureEventsTable(db), assets: assets},
+		Tx:       db,
+		Outbox:   events.NewSQLOutbox(db),
+		Audit:    audit.NewSQLStore(db),
+		Webhooks: webhook.NewPublisher(webhook.NewSQLStore(db)),
+	}
+}
+
+func assetsTable(db *store.SQL) *store.SQLTable[Asset] {
+	return store.NewSQLTable(db, "assets",
+		func(a *Asset) (*primitive.ObjectID, **time.Time, *string) { return &a.ID, &a.DeletedAt, &a.DeletedBy },
+		store.Column[Asset]{Name: "type", Value: func(a *Asset) interface{} { return a.Type }},
+		store.Column[Asset]{Name: "location", Value: func(a *Asset) interface{} { return a.Location }},
+	)
+}
+
+func failureEventsTable(db *store.SQL) *store.SQLTable[FailureEvent] {
+	return store.NewSQLTable(db, failureEventsCollection,
+		func(e *FailureEvent) (*primitive.ObjectID, **time.Time, *string) { return &e.ID, nil, nil },
+		store.Column[FailureEvent]{Name: "asset_id", Value: func(e *FailureEvent) interface{} { return e.AssetID.Hex() }},
+		store.Column[FailureEvent]{Name: "start", Value: func(e *FailureEvent) interface{} { return store.Millis(e.Start) }},
+	)
+}
+
+// CopyToSQL copies the assets and failure events of db, the database of the
+// asset service in MongoDB, into to and returns how many records it copied
+func CopyToSQL(ctx context.Context, db *mongo.Database, to *store.SQL) (int, error) {
+	if err := MigrateSQL(ctx, to); err != nil {
+		return 0, err
+	}
+	assets, err := store.CopyCollection(ctx, db.Collection("assets"), assetsTable(to))
+	if err != nil {
+		return assets, err
+	}
+	failures, err := store.CopyCollection(ctx, db.Collection(failureEventsCollection), failureEventsTable(to))
+	return assets + failures, err
+}
+
+// sqlAssets is the AssetRepository of the assets table
+type sqlAssets struct {
+	table *store.SQLTable[Asset]
+}
+
+func (m sqlAssets) List(ctx context.Context) ([]Asset, error) {
+	return m.table.Select(ctx, "")
+}
+
+func (m sqlAssets) Find(ctx context.Context, typ, location string) ([]Asset, error) {
+	return m.table.Select(ctx, "(? = '' OR type = ?) AND (? = '' OR location = ?)", typ, typ, location, location)
+}
+
+func (m sqlAssets) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Asset, error) {
+	return m.table.Select(ctx, store.In("id", len(ids)), store.Hexes(ids)...)
+}
+
+func (m sqlAssets) Get(ctx context.Context, id primitive.ObjectID) (Asset, error) {
+	return m.table.Get(ctx, id)
+}
+
+func (m sqlAssets) Insert(ctx context.Context, asset Asset) error {
+	return m.table.Insert(ctx, asset)
+}
+
+func (m sqlAssets) Update(ctx context.Context, asset Asset) error {
+	_, err := m.table.UpdateVersion(ctx, asset.ID, asset.Version, assetVersion, func(a *Asset) {
+		a.Label, a.Type, a.Location, a.EffectiveDate = asset.Label, asset.Type, asset.Location, asset.EffectiveDate
+	})
+	return err
+}
+
+func (m sqlAssets) Delete(ctx context.Context, id primitive.ObjectID, actor string) error {
+	return m.table.Delete(ctx, id, actor)
+}
+
+func (m sqlAssets) ListDeleted(ctx context.Context) ([]Asset, error) {
+	return m.table.Deleted(ctx)
+}
+
+func (m sqlAssets) GetDeleted(ctx context.Context, id primitive.ObjectID) (Asset, error) {
+	return m.table.GetDeleted(ctx, id)
+}
+
+func (m sqlAssets) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Restore(ctx, id)
+}
+
+func (m sqlAssets) Purge(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Purge(ctx, id)
+}
+
+func (m sqlAssets) PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	return m.table.PurgeExpired(ctx, retention)
+}
+
+// sqlFailureEvents is the FailureEventRepository of the failure_events table
+type sqlFailureEvents struct {
+	table  *store.SQLTable[FailureEvent]
+	assets sqlAssets
+}
+
+func (m sqlFailureEvents) Insert(ctx context.Context, event FailureEvent) error {
+	return m.table.Insert(ctx, event)
+}
+
+func (m sqlFailureEvents) Close(ctx context.Context, assetID, eventID primitive.ObjectID, end time.Time) error {
+	closed := false
+	_, err := m.table.Update(ctx, eventID, func(e *FailureEvent) {
+		if e.AssetID == assetID && e.End == nil {
+			e.End, closed = &end, true
+		}
+	})
+	if err == nil && !closed {
+		return store.ErrNotFound
+	}
+	return err
+}
+
+func (m sqlFailureEvents) ListByAsset(ctx context.Context, assetID primitive.ObjectID) ([]FailureEvent, error) {
+	events, err := m.table.Select(ctx, "asset_id = ?", assetID.Hex())
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.After(events[j].Start) })
+	return events, err
+}
+
+// Totals sums the failures in Go, as the in-memory repository does, over the
+// events starting before the end of the period
+func (m sqlFailureEvents) Totals(ctx context.Context, from, to time.Time, groupBy, typ, location string) ([]kpiTotals, error) {
+	assets, err := m.assets.Find(ctx, typ, location)
+	if err != nil {
+		return nil, err
+	}
+	events, err := m.table.Select(ctx, "start < ?", store.Millis(to))
+	if err != nil {
+		return nil, err
+	}
+	return totalsOf(assets, events, from, to, groupBy), nil
+}
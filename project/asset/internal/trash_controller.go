@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"log"
+	"net/http"
+	"shared/audit"
+	"shared/csrf"
+	"shared/flash"
+	"shared/trash"
+	"shared/webhook"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetTrash renders the deleted assets that can still be restored
+func GetTrash(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		result := TrashPageData{Retention: trash.Retention(), CSRF: csrf.Field(r)}
+
+		data, err := s.Assets.ListDeleted(ctx)
+		if err != nil {
+			log.Printf("error fetching deleted records: %v", err)
+			result.Error = "Error fetching records"
+		} else {
+			result.Data = data
+		}
+
+		showFlash(w, r, &result.Message, &result.Error)
+
+		if err := templates.ExecuteTemplate(w, "Trash.html", result); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// RestoreAsset takes an asset out of the recycle bin
+func RestoreAsset(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		objID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			flash.Redirect(w, r, "/assets/trash", flash.Error, "Invalid asset ID")
+			return
+		}
+
+		asset, err := s.Assets.GetDeleted(ctx, objID)
+		if err != nil {
+			flash.Redirect(w, r, "/assets/trash", flash.Error, "Asset not found in recycle bin")
+			return
+		}
+
+		if err := s.Assets.Restore(ctx, objID); err != nil {
+			flash.Redirect(w, r, "/assets/trash", flash.Error, "Failed to restore asset")
+			return
+		}
+		restored := asset
+		restored.DeletedAt, restored.DeletedBy = nil, ""
+		record(ctx, r, s, audit.ActionRestore, "asset", objID, asset.Label, asset, restored)
+		publish(ctx, s, webhook.AssetRestored, restored)
+
+		flash.Redirect(w, r, "/assets/trash", flash.Success, "Asset restored successfully")
+	}
+}
+
+// PurgeAsset permanently removes an asset from the recycle bin
+func PurgeAsset(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		objID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			flash.Redirect(w, r, "/assets/trash", flash.Error, "Invalid asset ID")
+			return
+		}
+
+		asset, err := s.Assets.GetDeleted(ctx, objID)
+		if err != nil {
+			flash.Redirect(w, r, "/assets/trash", flash.Error, "Asset not found in recycle bin")
+			return
+		}
+
+		if err := s.Assets.Purge(ctx, objID); err != nil {
+			flash.Redirect(w, r, "/assets/trash", flash.Error, "Failed to purge asset")
+			return
+		}
+		record(ctx, r, s, audit.ActionPurge, "asset", objID, asset.Label, asset, nil)
+
+		flash.Redirect(w, r, "/assets/trash", flash.Success, "Asset permanently deleted")
+	}
+}
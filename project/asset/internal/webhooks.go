@@ -0,0 +1,26 @@
+package internal
+
+import (
+	"cmms/project/rpc/cmmspb"
+	"context"
+	"log"
+	"shared/webhook"
+)
+
+// changeTypes are the gRPC change types of the asset webhook events
+var changeTypes = map[string]cmmspb.ChangeType{
+	webhook.AssetCreated:  cmmspb.ChangeType_CHANGE_TYPE_CREATED,
+	webhook.AssetUpdated:  cmmspb.ChangeType_CHANGE_TYPE_UPDATED,
+	webhook.AssetDeleted:  cmmspb.ChangeType_CHANGE_TYPE_DELETED,
+	webhook.AssetRestored: cmmspb.ChangeType_CHANGE_TYPE_RESTORED,
+}
+
+// publish queues a webhook event and tells the WatchAssets streams; a
+// failure is logged but never fails the request, since the asset itself was
+// saved
+func publish(ctx context.Context, s *Store, eventType string, asset Asset) {
+	changes.Publish(&cmmspb.AssetChange{Type: changeTypes[eventType], Asset: asset.proto()})
+	if err := s.Webhooks.Publish(ctx, "asset", eventType, asset); err != nil {
+		log.Printf("error publishing %s webhook: %v", eventType, err)
+	}
+}
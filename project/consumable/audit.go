@@ -0,0 +1,20 @@
+package consumable
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"shared/audit"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var auditLog *audit.Logger
+
+// recordAudit adds an entry to the audit trail; a failure is logged but
+// never fails the request, since the change itself was saved
+func recordAudit(r *http.Request, action string, id primitive.ObjectID, label string, before, after interface{}) {
+	if err := auditLog.Record(context.Background(), r, action, "consumable", id, label, before, after); err != nil {
+		log.Printf("error recording audit entry for consumable %s: %v", id.Hex(), err)
+	}
+}
@@ -0,0 +1,84 @@
+// Package consumable is the consumable catalogue: the pages to manage
+// consumables and the /consumables API the maintenance service reads them
+// from.
+package consumable
+
+import (
+	"context"
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"shared/audit"
+	"shared/config"
+	"shared/csrf"
+	"shared/metrics"
+	"shared/references"
+	"shared/store"
+	"shared/trash"
+)
+
+var (
+	templates *template.Template
+	// repo is where the handlers keep the consumables; it is set up by routes
+	repo *Store
+)
+
+//go:embed templates style
+var assets embed.FS
+
+// Handler sets the consumable service up on b, starts its background jobs
+// until ctx is done and returns its routes. Calls to the maintenance service
+// go through transport when it is set, and over the network otherwise.
+func Handler(ctx context.Context, conf *config.Config, b store.Backend, transport http.RoundTripper) (http.Handler, error) {
+	referenceClient = references.NewClient(conf.URL(config.Maintenance))
+	referenceClient.HTTP.Transport = metrics.Transport(config.Maintenance, transport)
+
+	if err := Migrate(ctx, conf, b); err != nil {
+		return nil, err
+	}
+	s := storeOn(conf, b)
+
+	// Purge consumables that have been in the recycle bin longer than the retention period
+	trash.StartPurger(ctx, trash.Retention(), map[string]trash.Purger{"consumables": s.Consumables})
+
+	return routes(s, conf.PublicURL(config.Maintenance))
+}
+
+// routes returns the routes of the consumable catalogue over s, behind the
+// CSRF check and counted by route in the metrics, linking to the maintenance
+// service at maintenanceURL
+func routes(s *Store, maintenanceURL string) (http.Handler, error) {
+	repo = s
+	auditLog = audit.NewLogger(s.Audit, "consumable")
+
+	var err error
+	templates, err = template.New("").Funcs(template.FuncMap{
+		"maintenanceURL": func() string { return maintenanceURL },
+	}).ParseFS(assets, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	style, err := fs.Sub(assets, "style")
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/style/", http.StripPrefix("/style/", http.FileServer(http.FS(style))))
+
+	// Routes
+	mux.HandleFunc("/consumable", consumableListHandler)
+	mux.HandleFunc("/consumable/create", consumableCreateHandler)
+	mux.HandleFunc("/consumable/edit", consumableEditHandler)
+	mux.HandleFunc("/consumable/delete", consumableDeleteHandler)
+	mux.HandleFunc("/consumable/trash", consumableTrashHandler)
+	mux.HandleFunc("/consumable/restore", consumableRestoreHandler)
+	mux.HandleFunc("/consumable/purge", consumablePurgeHandler)
+
+	// API routes for other microservices
+	mux.HandleFunc("/consumables", consumableAPIHandler)
+
+	return metrics.Instrument(config.Consumable, metrics.ServeMuxRoute(mux), csrf.Protect(mux)), nil
+}
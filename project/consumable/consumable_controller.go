@@ -1,63 +1,98 @@
-package main
+package consumable
 
 import (
+	"cmms/project/rpc/cmmspb"
 	"context"
-	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
 	"net/http"
+	"shared/audit"
+	"shared/conflict"
+	"shared/csrf"
+	"shared/flash"
+	"shared/jsonapi"
+	"shared/references"
+	"shared/store"
+	"shared/validate"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// consumablePage is the data of consumable.html: the consumables, the flash message
+// left by the last change and, when a form did not validate, its input and
+// errors
+type consumablePage struct {
+	Consumables []Consumable
+	Form        *validate.Form
+	Flash       flash.Message
+	CSRF        template.HTML
+}
+
 // List Consumables
 func consumableListHandler(w http.ResponseWriter, r *http.Request) {
-	cur, err := consumableCollection.Find(context.Background(), bson.M{})
+	consumables, err := repo.Consumables.List(context.Background())
 	if err != nil {
 		http.Error(w, "Failed to retrieve consumables", http.StatusInternalServerError)
 		return
 	}
-	var consumables []Consumable
-	cur.All(context.Background(), &consumables)
-
-	data := struct {
-		Consumables []Consumable
-		Error       string
-	}{
-		Consumables: consumables,
-		Error:       "",
+
+	page := consumablePage{Consumables: consumables, CSRF: csrf.Field(r)}
+	page.Flash, _ = flash.Get(w, r)
+	templates.ExecuteTemplate(w, "consumable.html", page)
+}
+
+// consumableForm checks the input of the create and edit forms, named name
+func consumableForm(r *http.Request, name string) (*validate.Form, error) {
+	f, err := validate.Parse(r, name)
+	if err != nil {
+		return nil, err
 	}
+	f.Required("label")
+	f.MaxLength("label", 200)
+	f.MaxLength("notes", 2000)
+	return f, nil
+}
 
-	templates.ExecuteTemplate(w, "consumable.html", data)
+// showForm answers a form that did not validate: the page again with the
+// form open, its input kept and the errors next to the fields, or the
+// errors as JSON for an API call
+func showForm(w http.ResponseWriter, r *http.Request, f *validate.Form) {
+	if validate.WantsJSON(r) {
+		validate.WriteErrors(w, f.Errors)
+		return
+	}
+	consumables, _ := repo.Consumables.List(context.Background())
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	templates.ExecuteTemplate(w, "consumable.html", consumablePage{Consumables: consumables, Form: f, CSRF: csrf.Field(r)})
 }
 
 // Create Consumable
 func consumableCreateHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodPost {
-		label := r.FormValue("label")
-		notes := r.FormValue("notes")
-
-		if label == "" {
-			cur, _ := consumableCollection.Find(context.Background(), bson.M{})
-			var consumables []Consumable
-			cur.All(context.Background(), &consumables)
-
-			data := struct {
-				Consumables []Consumable
-				Error       string
-			}{
-				Consumables: consumables,
-				Error:       "Label is required!",
-			}
-			templates.ExecuteTemplate(w, "consumable.html", data)
+		f, err := consumableForm(r, "create")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !f.Valid() {
+			showForm(w, r, f)
 			return
 		}
 
-		consumableCollection.InsertOne(context.Background(), Consumable{
+		doc := Consumable{
 			ID:    primitive.NewObjectID(),
-			Label: label,
-			Notes: notes,
-		})
-		http.Redirect(w, r, "/consumable", http.StatusSeeOther)
+			Label: f.Get("label"),
+			Notes: f.Get("notes"),
+		}
+		if err := repo.Consumables.Insert(context.Background(), doc); err != nil {
+			log.Printf("error creating consumable: %v", err)
+			flash.Redirect(w, r, "/consumable", flash.Error, "Failed to create consumable")
+			return
+		}
+		recordAudit(r, audit.ActionCreate, doc.ID, doc.Label, nil, doc)
+		publishChange(cmmspb.ChangeType_CHANGE_TYPE_CREATED, doc)
+		flash.Redirect(w, r, "/consumable", flash.Success, "Consumable created successfully")
 	}
 }
 
@@ -68,55 +103,113 @@ func consumableEditHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid ID", http.StatusBadRequest)
 		return
 	}
-
 	if r.Method == http.MethodPost {
-		label := r.FormValue("label")
-		notes := r.FormValue("notes")
-
-		if label == "" {
-			cur, _ := consumableCollection.Find(context.Background(), bson.M{})
-			var consumables []Consumable
-			cur.All(context.Background(), &consumables)
-
-			data := struct {
-				Consumables []Consumable
-				Error       string
-			}{
-				Consumables: consumables,
-				Error:       "Label is required!",
-			}
-			templates.ExecuteTemplate(w, "consumable.html", data)
+		f, err := consumableForm(r, id.Hex())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		version := f.Version(r)
+		if !f.Valid() {
+			showForm(w, r, f)
 			return
 		}
+		before, _ := repo.Consumables.Get(context.Background(), id)
+		after := Consumable{ID: id, Label: f.Get("label"), Notes: f.Get("notes"), Version: version}
+		err = repo.Consumables.Update(context.Background(), after)
+		if errors.Is(err, store.ErrConflict) {
+			showConflict(w, r, after, before)
+			return
+		}
+		if err != nil {
+			log.Printf("error updating consumable %s: %v", id.Hex(), err)
+			flash.Redirect(w, r, "/consumable", flash.Error, "Failed to update consumable")
+			return
+		}
+		after.Version++
+		recordAudit(r, audit.ActionUpdate, id, after.Label, before, after)
+		publishChange(cmmspb.ChangeType_CHANGE_TYPE_UPDATED, after)
+		w.Header().Set("ETag", jsonapi.ETag(after.Version))
+		flash.Redirect(w, r, "/consumable", flash.Success, "Consumable updated successfully")
+	}
+}
 
-		consumableCollection.UpdateOne(context.Background(),
-			bson.M{"_id": id},
-			bson.M{"$set": bson.M{"label": label, "notes": notes}})
-		http.Redirect(w, r, "/consumable", http.StatusSeeOther)
+// showConflict answers an edit, mine, rejected because someone else saved
+// the consumable since: the conflict page showing both versions, or the current
+// one as JSON for an API call
+func showConflict(w http.ResponseWriter, r *http.Request, mine, current Consumable) {
+	page := conflict.NewPage("Consumable "+current.Label, "/consumable", "/consumable/edit?id="+current.ID.Hex(), r.PostForm, current.Version,
+		conflict.Field{Label: "Label", Mine: mine.Label, Current: current.Label},
+		conflict.Field{Label: "Notes", Mine: mine.Notes, Current: current.Notes},
+	)
+	err := conflict.Write(w, r, page, current, current.Version, func(w http.ResponseWriter, p conflict.Page) error {
+		return templates.ExecuteTemplate(w, "conflict.html", p)
+	})
+	if err != nil {
+		log.Printf("error rendering the conflict page: %v", err)
 	}
 }
 
 // Delete Consumable
 func consumableDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 	id, err := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
 	if err != nil {
 		http.Error(w, "Invalid ID", http.StatusBadRequest)
 		return
 	}
-	consumableCollection.DeleteOne(context.Background(), bson.M{"_id": id})
-	http.Redirect(w, r, "/consumable", http.StatusSeeOther)
+	before, _ := repo.Consumables.Get(context.Background(), id)
+
+	// Schedules using the consumable must drop it or switch to another consumable first
+	refs, err := referenceClient.Find(r.Context(), references.KindConsumable, id)
+	if err != nil {
+		http.Error(w, "Could not check the schedules using the consumable: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	if len(refs) > 0 {
+		action := r.FormValue("action")
+		if action == "" {
+			consumableDeleteConfirm(w, r, before, refs, "")
+			return
+		}
+		if err := resolveConsumableReferences(r, id, action); err != nil {
+			consumableDeleteConfirm(w, r, before, refs, err.Error())
+			return
+		}
+	}
+
+	if err := deleteWithEvent(r, id, before); err != nil {
+		log.Printf("error deleting consumable %s: %v", id.Hex(), err)
+		flash.Redirect(w, r, "/consumable", flash.Error, "Failed to delete consumable")
+		return
+	}
+	recordAudit(r, audit.ActionDelete, id, before.Label, before, nil)
+	publishChange(cmmspb.ChangeType_CHANGE_TYPE_DELETED, before)
+	flash.Redirect(w, r, "/consumable", flash.Success, "Consumable moved to the recycle bin")
 }
 
-// API handler for other microservices to fetch consumables
+// API handler for other microservices to fetch consumables, all of them or
+// those listed in ?ids=
 func consumableAPIHandler(w http.ResponseWriter, r *http.Request) {
-	cur, err := consumableCollection.Find(context.Background(), bson.M{})
+	ids, ok, err := jsonapi.IDs(r)
 	if err != nil {
-		http.Error(w, "Failed to retrieve consumables", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+
 	var consumables []Consumable
-	cur.All(context.Background(), &consumables)
+	if ok {
+		consumables, err = repo.Consumables.GetByIDs(context.Background(), ids)
+	} else {
+		consumables, err = repo.Consumables.List(context.Background())
+	}
+	if err != nil {
+		http.Error(w, "Failed to retrieve consumables", http.StatusInternalServerError)
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(consumables)
+	jsonapi.WriteJSON(w, r, consumables)
 }
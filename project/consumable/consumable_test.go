@@ -0,0 +1,375 @@
+package consumable
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"shared/audit"
+	"shared/csrf"
+	"shared/events"
+	"shared/references"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeMaintenance is the reference API of the maintenance service, answering
+// refs until they are resolved
+type fakeMaintenance struct {
+	refs     []references.Reference
+	resolved []url.Values
+}
+
+func (f *fakeMaintenance) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/api/references":
+		refs := f.refs
+		if refs == nil {
+			refs = []references.Reference{}
+		}
+		json.NewEncoder(w).Encode(refs)
+	case "/api/references/resolve":
+		r.ParseForm()
+		f.resolved = append(f.resolved, r.PostForm)
+		json.NewEncoder(w).Encode(references.Result{Updated: len(f.refs)})
+		f.refs = nil
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newTestServer returns the routes of the consumable service over an in-memory store,
+// with the maintenance service faked
+func newTestServer(t *testing.T) (*Store, http.Handler, *fakeMaintenance) {
+	t.Helper()
+	maintenance := &fakeMaintenance{}
+	srv := httptest.NewServer(maintenance)
+	t.Cleanup(srv.Close)
+	referenceClient = references.NewClient(srv.URL)
+
+	s := NewMemoryStore()
+	h, err := routes(s, "http://maintenance.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, h, maintenance
+}
+
+func do(h http.Handler, method, target string, form url.Values) *httptest.ResponseRecorder {
+	var r *http.Request
+	if form != nil {
+		r = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	r.AddCookie(&http.Cookie{Name: csrf.CookieName, Value: "test"})
+	r.Header.Set(csrf.HeaderName, csrf.Token(r))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// follow gets the page a See Other answer redirects to, with the cookies the
+// answer set
+func follow(t *testing.T, h http.Handler, w *httptest.ResponseRecorder) *httptest.ResponseRecorder {
+	t.Helper()
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusSeeOther)
+	}
+	r := httptest.NewRequest(http.MethodGet, w.Header().Get("Location"), nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	page := httptest.NewRecorder()
+	h.ServeHTTP(page, r)
+	return page
+}
+
+func seed(t *testing.T, s *Store, labels ...string) []Consumable {
+	t.Helper()
+	var consumables []Consumable
+	for _, label := range labels {
+		item := Consumable{ID: primitive.NewObjectID(), Label: label}
+		if err := s.Consumables.Insert(context.Background(), item); err != nil {
+			t.Fatal(err)
+		}
+		consumables = append(consumables, item)
+	}
+	return consumables
+}
+
+func TestList(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	seed(t, s, "Brake fluid", "Air filter")
+
+	w := do(h, http.MethodGet, "/consumable", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	for _, label := range []string{"Brake fluid", "Air filter"} {
+		if !strings.Contains(w.Body.String(), label) {
+			t.Errorf("list does not show %q", label)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	s, h, _ := newTestServer(t)
+
+	w := do(h, http.MethodPost, "/consumable/create", url.Values{"label": {"Brake fluid"}, "notes": {"DOT 3"}})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d", w.Code)
+	}
+	consumables, _ := s.Consumables.List(context.Background())
+	if len(consumables) != 1 || consumables[0].Label != "Brake fluid" || consumables[0].Notes != "DOT 3" {
+		t.Fatalf("consumables = %+v", consumables)
+	}
+	if entries := s.Audit.(*audit.MemoryStore).Entries(); len(entries) != 1 {
+		t.Errorf("audit entries = %d, want 1", len(entries))
+	}
+	if page := follow(t, h, w); !strings.Contains(page.Body.String(), "Consumable created successfully") {
+		t.Error("list does not show the flash message")
+	}
+	if page := do(h, http.MethodGet, "/consumable", nil); strings.Contains(page.Body.String(), "created successfully") {
+		t.Error("flash message shown twice")
+	}
+}
+
+func TestCreateRequiresLabel(t *testing.T) {
+	s, h, _ := newTestServer(t)
+
+	w := do(h, http.MethodPost, "/consumable/create", url.Values{"label": {" "}, "notes": {"kept"}})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d", w.Code)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "This field is required") || !strings.Contains(body, "kept") {
+		t.Errorf("body does not show the error and the input")
+	}
+	if consumables, _ := s.Consumables.List(context.Background()); len(consumables) != 0 {
+		t.Errorf("consumables = %+v", consumables)
+	}
+
+	// API callers get the errors as JSON
+	r := httptest.NewRequest(http.MethodPost, "/consumable/create", strings.NewReader(`{"label": "", "notes": "x"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `"label":"This field is required"`) {
+		t.Errorf("JSON: status = %d, body = %s", w.Code, w.Body)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	item := seed(t, s, "Brake fluid")[0]
+
+	w := do(h, http.MethodPost, "/consumable/edit?id="+item.ID.Hex(), url.Values{"label": {"Brake fluid DOT 4"}, "notes": {"yearly"}, "version": {"0"}})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if etag := w.Header().Get("ETag"); etag != `"1"` {
+		t.Errorf("ETag = %s, want \"1\"", etag)
+	}
+	got, err := s.Consumables.Get(context.Background(), item.ID)
+	if err != nil || got.Label != "Brake fluid DOT 4" || got.Notes != "yearly" || got.Version != 1 {
+		t.Fatalf("consumable = %+v, %v", got, err)
+	}
+
+	if w := do(h, http.MethodPost, "/consumable/edit?id=nope", url.Values{"label": {"x"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid id: status = %d", w.Code)
+	}
+}
+
+func TestEditConflict(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	item := seed(t, s, "Brake fluid")[0]
+	target := "/consumable/edit?id=" + item.ID.Hex()
+
+	// Two people open version 0; the first one saves
+	if w := do(h, http.MethodPost, target, url.Values{"label": {"Brake fluid DOT 4"}, "version": {"0"}}); w.Code != http.StatusSeeOther {
+		t.Fatalf("first edit: status = %d", w.Code)
+	}
+
+	// The second edit is rejected with both versions shown
+	w := do(h, http.MethodPost, target, url.Values{"label": {"Brake fluid DOT 5"}, "notes": {"mine"}, "version": {"0"}})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("stale edit: status = %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"Brake fluid DOT 4", "Brake fluid DOT 5", `name="version" value="1"`, `name="csrf_token"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("conflict page does not show %q", want)
+		}
+	}
+	if got, _ := s.Consumables.Get(context.Background(), item.ID); got.Label != "Brake fluid DOT 4" || got.Version != 1 {
+		t.Fatalf("stale edit saved: %+v", got)
+	}
+
+	// Saving it again from the conflict page applies it over version 1
+	if w := do(h, http.MethodPost, target, url.Values{"label": {"Brake fluid DOT 5"}, "notes": {"mine"}, "version": {"1"}}); w.Code != http.StatusSeeOther {
+		t.Fatalf("resubmit: status = %d", w.Code)
+	}
+	if got, _ := s.Consumables.Get(context.Background(), item.ID); got.Label != "Brake fluid DOT 5" || got.Version != 2 {
+		t.Fatalf("consumable = %+v", got)
+	}
+
+	// API callers send the version in If-Match and get the current record
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"label": "x"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("If-Match", `"1"`)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusConflict || w.Header().Get("ETag") != `"2"` || !strings.Contains(w.Body.String(), `"Label":"Brake fluid DOT 5"`) || !strings.Contains(w.Body.String(), `"version":2`) {
+		t.Errorf("JSON: status = %d, ETag = %s, body = %s", w.Code, w.Header().Get("ETag"), w.Body)
+	}
+
+	// An edit not saying which version it is based on is refused
+	if w := do(h, http.MethodPost, target, url.Values{"label": {"x"}}); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("no version: status = %d", w.Code)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	item := seed(t, s, "Brake fluid")[0]
+
+	if w := do(h, http.MethodGet, "/consumable/delete?id="+item.ID.Hex(), nil); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET delete: status = %d", w.Code)
+	}
+	w := do(h, http.MethodPost, "/consumable/delete?id="+item.ID.Hex(), nil)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if _, err := s.Consumables.GetDeleted(context.Background(), item.ID); err != nil {
+		t.Fatalf("consumable not in the recycle bin: %v", err)
+	}
+	outbox := s.Outbox.(*events.MemoryOutbox).Events()
+	if len(outbox) != 1 || outbox[0].Type != events.ConsumableDeleted || outbox[0].EntityID != item.ID {
+		t.Errorf("outbox = %+v", outbox)
+	}
+}
+
+func TestDeleteReferenced(t *testing.T) {
+	s, h, maintenance := newTestServer(t)
+	item := seed(t, s, "Brake fluid")[0]
+	maintenance.refs = []references.Reference{{Entity: "schedule", ID: primitive.NewObjectID(), Label: "Weekly check", Field: "consumables"}}
+
+	// Without an action the schedules using the consumable are shown
+	w := do(h, http.MethodPost, "/consumable/delete?id="+item.ID.Hex(), nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Weekly check") {
+		t.Fatalf("status = %d, confirmation page not shown", w.Code)
+	}
+	if _, err := s.Consumables.Get(context.Background(), item.ID); err != nil {
+		t.Fatalf("consumable deleted before confirmation: %v", err)
+	}
+
+	w = do(h, http.MethodPost, "/consumable/delete?id="+item.ID.Hex(), url.Values{"action": {references.ActionCascade}})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if len(maintenance.resolved) != 1 || maintenance.resolved[0].Get("action") != references.ActionCascade {
+		t.Errorf("resolved = %+v", maintenance.resolved)
+	}
+	if _, err := s.Consumables.GetDeleted(context.Background(), item.ID); err != nil {
+		t.Errorf("consumable not in the recycle bin: %v", err)
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	seed(t, s, "Brake fluid")
+
+	// The page gives a token in every POST form, matching its cookie
+	page := httptest.NewRecorder()
+	h.ServeHTTP(page, httptest.NewRequest(http.MethodGet, "/consumable", nil))
+	cookies := page.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != csrf.CookieName {
+		t.Fatalf("cookies = %v", cookies)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookies[0])
+	field := `<input type="hidden" name="csrf_token" value="` + csrf.Token(r) + `">`
+	if forms, fields := strings.Count(page.Body.String(), `<form method="POST"`), strings.Count(page.Body.String(), field); forms == 0 || fields != forms {
+		t.Errorf("%d POST forms, %d with the token", forms, fields)
+	}
+
+	post := func(token string) int {
+		r := httptest.NewRequest(http.MethodPost, "/consumable/create", strings.NewReader(url.Values{"label": {"Other"}, "csrf_token": {token}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(cookies[0])
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := post(""); code != http.StatusForbidden {
+		t.Errorf("without token: status = %d", code)
+	}
+	if code := post("forged"); code != http.StatusForbidden {
+		t.Errorf("forged token: status = %d", code)
+	}
+	if list, _ := s.Consumables.List(context.Background()); len(list) != 1 {
+		t.Fatalf("consumables = %+v", list)
+	}
+	if code := post(csrf.Token(r)); code != http.StatusSeeOther {
+		t.Errorf("with token: status = %d", code)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	consumables := seed(t, s, "Brake fluid", "Air filter")
+	for _, item := range consumables {
+		if err := s.Consumables.Delete(context.Background(), item.ID, "tester"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := do(h, http.MethodGet, "/consumable/trash", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Air filter") {
+		t.Fatalf("status = %d, deleted consumable not listed", w.Code)
+	}
+
+	if w := do(h, http.MethodGet, "/consumable/restore?id="+consumables[0].ID.Hex(), nil); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET restore: status = %d", w.Code)
+	}
+	do(h, http.MethodPost, "/consumable/restore?id="+consumables[0].ID.Hex(), nil)
+	if _, err := s.Consumables.Get(context.Background(), consumables[0].ID); err != nil {
+		t.Errorf("consumable not restored: %v", err)
+	}
+
+	do(h, http.MethodPost, "/consumable/purge?id="+consumables[1].ID.Hex(), nil)
+	if deleted, _ := s.Consumables.ListDeleted(context.Background()); len(deleted) != 0 {
+		t.Errorf("recycle bin = %+v", deleted)
+	}
+
+	page := follow(t, h, do(h, http.MethodPost, "/consumable/restore?id="+consumables[1].ID.Hex(), nil))
+	if !strings.Contains(page.Body.String(), "Failed to restore consumable") {
+		t.Error("restoring a purged consumable: page does not show the error")
+	}
+}
+
+func TestAPI(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	consumables := seed(t, s, "Brake fluid", "Air filter")
+
+	var all []Consumable
+	w := do(h, http.MethodGet, "/consumables", nil)
+	if err := json.NewDecoder(w.Body).Decode(&all); err != nil || len(all) != 2 {
+		t.Fatalf("consumables = %+v, %v", all, err)
+	}
+
+	var some []Consumable
+	w = do(h, http.MethodGet, "/consumables?ids="+consumables[1].ID.Hex(), nil)
+	if err := json.NewDecoder(w.Body).Decode(&some); err != nil || len(some) != 1 || some[0].Label != "Air filter" {
+		t.Fatalf("consumables = %+v, %v", some, err)
+	}
+
+	if w := do(h, http.MethodGet, "/consumables?ids=nope", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid ids: status = %d", w.Code)
+	}
+}
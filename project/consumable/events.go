@@ -0,0 +1,22 @@
+package consumable
+
+import (
+	"context"
+	"net/http"
+	"shared/audit"
+	"shared/events"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// deleteWithEvent moves the consumable to the recycle bin and adds
+// ConsumableDeleted to the outbox, in one transaction, so the maintenance
+// service takes it out of the schedules still using it
+func deleteWithEvent(r *http.Request, id primitive.ObjectID, before Consumable) error {
+	return repo.Tx.Atomically(r.Context(), func(ctx context.Context) error {
+		if err := repo.Consumables.Delete(ctx, id, audit.Actor(r)); err != nil {
+			return err
+		}
+		return repo.Outbox.Record(ctx, "consumable", events.ConsumableDeleted, id, audit.Actor(r), before)
+	})
+}
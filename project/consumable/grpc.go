@@ -0,0 +1,87 @@
+package consumable
+
+import (
+	"cmms/project/rpc"
+	"cmms/project/rpc/cmmspb"
+	"context"
+	"errors"
+	"shared/config"
+	"shared/store"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// changes streams the consumables changed by this process to WatchConsumables
+var changes rpc.Feed[*cmmspb.ConsumableChange]
+
+// publishChange tells the WatchConsumables streams about a saved change
+func publishChange(typ cmmspb.ChangeType, s Consumable) {
+	changes.Publish(&cmmspb.ConsumableChange{Type: typ, Consumable: s.proto()})
+}
+
+// RegisterGRPC adds the gRPC server of the consumables to s; it streams the
+// changes made through the Handler of the same process
+func RegisterGRPC(s grpc.ServiceRegistrar, conf *config.Config, b store.Backend) {
+	cmmspb.RegisterConsumableCatalogServer(s, &catalog{consumables: storeOn(conf, b).Consumables})
+}
+
+// catalog serves the consumables over gRPC to the maintenance service
+type catalog struct {
+	cmmspb.UnimplementedConsumableCatalogServer
+	consumables ConsumableRepository
+}
+
+func (c *catalog) GetConsumable(ctx context.Context, req *cmmspb.GetRequest) (*cmmspb.Consumable, error) {
+	id, err := rpc.ObjectID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.consumables.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "consumable %s not found", req.GetId())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return s.proto(), nil
+}
+
+func (c *catalog) BatchGetConsumables(ctx context.Context, req *cmmspb.BatchGetRequest) (*cmmspb.Consumables, error) {
+	ids, err := rpc.ObjectIDs(req.GetIds())
+	if err != nil {
+		return nil, err
+	}
+	consumables, err := c.consumables.GetByIDs(ctx, ids)
+	return consumablesProto(consumables, err)
+}
+
+func (c *catalog) ListConsumables(ctx context.Context, _ *cmmspb.ListConsumablesRequest) (*cmmspb.Consumables, error) {
+	consumables, err := c.consumables.List(ctx)
+	return consumablesProto(consumables, err)
+}
+
+func (c *catalog) ListDeletedConsumables(ctx context.Context, _ *cmmspb.ListDeletedRequest) (*cmmspb.Consumables, error) {
+	consumables, err := c.consumables.ListDeleted(ctx)
+	return consumablesProto(consumables, err)
+}
+
+func (c *catalog) WatchConsumables(_ *cmmspb.WatchRequest, stream cmmspb.ConsumableCatalog_WatchConsumablesServer) error {
+	return changes.Stream(stream.Context(), stream.Send)
+}
+
+func consumablesProto(consumables []Consumable, err error) (*cmmspb.Consumables, error) {
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	out := &cmmspb.Consumables{Consumables: make([]*cmmspb.Consumable, len(consumables))}
+	for i, s := range consumables {
+		out.Consumables[i] = s.proto()
+	}
+	return out, nil
+}
+
+func (s Consumable) proto() *cmmspb.Consumable {
+	return &cmmspb.Consumable{Id: s.ID.Hex(), Label: s.Label, Notes: s.Notes}
+}
@@ -0,0 +1,63 @@
+package consumable
+
+import (
+	"context"
+	"shared/store"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryConsumables is a ConsumableRepository kept in memory, for tests
+type memoryConsumables struct {
+	table *store.Table[Consumable]
+}
+
+func (m memoryConsumables) List(ctx context.Context) ([]Consumable, error) {
+	return m.table.Live(nil), nil
+}
+
+func (m memoryConsumables) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Consumable, error) {
+	wanted := map[primitive.ObjectID]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	return m.table.Live(func(c Consumable) bool { return wanted[c.ID] }), nil
+}
+
+func (m memoryConsumables) Get(ctx context.Context, id primitive.ObjectID) (Consumable, error) {
+	return m.table.Get(id)
+}
+
+func (m memoryConsumables) Insert(ctx context.Context, c Consumable) error {
+	return m.table.Insert(c)
+}
+
+func (m memoryConsumables) Update(ctx context.Context, c Consumable) error {
+	_, err := m.table.UpdateVersion(c.ID, c.Version, consumableVersion, func(rec *Consumable) { rec.Label, rec.Notes = c.Label, c.Notes })
+	return err
+}
+
+func (m memoryConsumables) Delete(ctx context.Context, id primitive.ObjectID, actor string) error {
+	return m.table.Delete(id, actor)
+}
+
+func (m memoryConsumables) ListDeleted(ctx context.Context) ([]Consumable, error) {
+	return m.table.Deleted(), nil
+}
+
+func (m memoryConsumables) GetDeleted(ctx context.Context, id primitive.ObjectID) (Consumable, error) {
+	return m.table.GetDeleted(id)
+}
+
+func (m memoryConsumables) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Restore(id)
+}
+
+func (m memoryConsumables) Purge(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Purge(id)
+}
+
+func (m memoryConsumables) PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	return m.table.PurgeExpired(retention), nil
+}
@@ -1,9 +1,23 @@
-package main
+package consumable
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Consumable struct {
 	ID    primitive.ObjectID `bson:"_id"`
 	Label string             `bson:"label"`
 	Notes string             `bson:"notes"`
+	// Version counts the saved changes; an edit must be based on the latest
+	Version int64 `bson:"version" json:"version"`
+
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"-"`
+	DeletedBy string     `bson:"deleted_by,omitempty" json:"-"`
+}
+
+// consumableVersion is the version field of the versioned updates of the tables
+func consumableVersion(c *Consumable) *int64 {
+	return &c.Version
 }
@@ -0,0 +1,66 @@
+package consumable
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+	"shared/csrf"
+	"shared/references"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// referenceClient asks the maintenance service which schedules use a consumable;
+// it is set up in Handler from the configuration
+var referenceClient *references.Client
+
+// resolveConsumableReferences removes a consumable from the schedules using it or
+// replaces it with the consumable chosen in the to form value
+func resolveConsumableReferences(r *http.Request, id primitive.ObjectID, action string) error {
+	var to primitive.ObjectID
+	if action == references.ActionReassign {
+		var err error
+		if to, err = primitive.ObjectIDFromHex(r.FormValue("to")); err != nil {
+			return errors.New("choose a consumable to replace it with")
+		}
+		if _, err := repo.Consumables.Get(context.Background(), to); err != nil {
+			return errors.New("consumable to replace it with not found")
+		}
+	}
+
+	_, err := referenceClient.Resolve(r.Context(), r, references.KindConsumable, id, action, to)
+	return err
+}
+
+// consumableDeleteConfirm shows the schedules still using a consumable and lets
+// the user remove it from them or replace it with another consumable
+func consumableDeleteConfirm(w http.ResponseWriter, r *http.Request, consumable Consumable, refs []references.Reference, errMsg string) {
+	consumables, err := repo.Consumables.List(context.Background())
+	if err != nil {
+		http.Error(w, "Failed to retrieve consumables", http.StatusInternalServerError)
+		return
+	}
+	var others []Consumable
+	for _, s := range consumables {
+		if s.ID != consumable.ID {
+			others = append(others, s)
+		}
+	}
+
+	data := struct {
+		Consumable Consumable
+		Refs       []references.Reference
+		Targets    []Consumable
+		Error      string
+		CSRF       template.HTML
+	}{
+		Consumable: consumable,
+		Refs:       refs,
+		Targets:    others,
+		Error:      errMsg,
+		CSRF:       csrf.Field(r),
+	}
+
+	templates.ExecuteTemplate(w, "delete.html", data)
+}
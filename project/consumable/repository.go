@@ -0,0 +1,155 @@
+package consumable
+
+import (
+	"context"
+	"shared/audit"
+	"shared/events"
+	"shared/store"
+	"shared/trash"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ConsumableRepository keeps the consumables. Deleted consumables stay in the
+// recycle bin until they are restored or purged; only Deleted* and Restore
+// and Purge see them. A missing consumable is store.ErrNotFound.
+type ConsumableRepository interface {
+	// List returns the live consumables
+	List(ctx context.Context) ([]Consumable, error)
+	GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Consumable, error)
+	Get(ctx context.Context, id primitive.ObjectID) (Consumable, error)
+	Insert(ctx context.Context, c Consumable) error
+	// Update saves the label and notes of a live consumable when it is still at
+	// c.Version, the version the edit is based on, and moves it to the next
+	// version. It returns store.ErrConflict when the consumable is at another
+	// version.
+	Update(ctx context.Context, c Consumable) error
+	// Delete moves a consumable to the recycle bin
+	Delete(ctx context.Context, id primitive.ObjectID, actor string) error
+	// ListDeleted returns the recycle bin, most recently deleted first
+	ListDeleted(ctx context.Context) ([]Consumable, error)
+	GetDeleted(ctx context.Context, id primitive.ObjectID) (Consumable, error)
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	Purge(ctx context.Context, id primitive.ObjectID) error
+	// PurgeExpired purges the consumables deleted more than retention ago
+	PurgeExpired(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// Store is where the consumable catalogue keeps its consumables and records
+// their changes: the audit trail and the domain events, which are saved in
+// the transaction of the change
+type Store struct {
+	Consumables ConsumableRepository
+	Tx          store.Tx
+	Outbox      events.Outbox
+	Audit       audit.Store
+}
+
+// NewMongoStore returns the store of the consumable catalogue in db, with the
+// audit trail and the outbox in shared, the database shared by the services
+func NewMongoStore(db, shared *mongo.Database) *Store {
+	return &Store{
+		Consumables: mongoConsumables{db.Collection("consumables")},
+		Tx:          store.NewMongoTx(db.Client()),
+		Outbox:      events.NewOutbox(shared),
+		Audit:       audit.NewMongoStore(shared),
+	}
+}
+
+// mongoMigrations create the indexes of the consumables collection: the
+// recycle bin and the labels they are looked up and sorted by
+var mongoMigrations = []store.MongoMigration{
+	{Version: 1, Name: "consumables indexes", Up: store.Indexes("consumables",
+		mongo.IndexModel{Keys: store.Keys("deleted_at")},
+		mongo.IndexModel{Keys: store.Keys("label")},
+	)},
+}
+
+// MigrateMongo creates or updates the indexes of the consumable catalogue in db
+func MigrateMongo(ctx context.Context, db *mongo.Database) error {
+	return store.MigrateMongo(ctx, db, "consumable", mongoMigrations)
+}
+
+// NewMemoryStore returns a store keeping everything in memory, for tests
+func NewMemoryStore() *Store {
+	return &Store{
+		Consumables: memoryConsumables{store.NewTable(func(c *Consumable) (*primitive.ObjectID, **time.Time, *string) {
+			return &c.ID, &c.DeletedAt, &c.DeletedBy
+		})},
+		Tx:     store.MemoryTx{},
+		Outbox: &events.MemoryOutbox{},
+		Audit:  &audit.MemoryStore{},
+	}
+}
+
+// mongoConsumables is the ConsumableRepository of the consumables collection
+type mongoConsumables struct {
+	coll *mongo.Collection
+}
+
+func (m mongoConsumables) List(ctx context.Context) ([]Consumable, error) {
+	return m.find(ctx, trash.Live(nil))
+}
+
+func (m mongoConsumables) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Consumable, error) {
+	return m.find(ctx, trash.Live(bson.M{"_id": bson.M{"$in": ids}}))
+}
+
+func (m mongoConsumables) find(ctx context.Context, filter bson.M) ([]Consumable, error) {
+	consumables := []Consumable{}
+	cur, err := m.coll.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.All(ctx, &consumables); err != nil {
+		return nil, err
+	}
+	return consumables, nil
+}
+
+func (m mongoConsumables) Get(ctx context.Context, id primitive.ObjectID) (Consumable, error) {
+	var c Consumable
+	err := m.coll.FindOne(ctx, trash.Live(bson.M{"_id": id})).Decode(&c)
+	return c, store.MongoErr(err)
+}
+
+func (m mongoConsumables) Insert(ctx context.Context, c Consumable) error {
+	_, err := m.coll.InsertOne(ctx, c)
+	return err
+}
+
+func (m mongoConsumables) Update(ctx context.Context, c Consumable) error {
+	return store.UpdateMongoVersion(ctx, m.coll, trash.Live(bson.M{"_id": c.ID}), c.Version,
+		bson.M{"label": c.Label, "notes": c.Notes})
+}
+
+func (m mongoConsumables) Delete(ctx context.Context, id primitive.ObjectID, actor string) error {
+	return store.MongoErr(trash.Delete(ctx, m.coll, id, actor))
+}
+
+func (m mongoConsumables) ListDeleted(ctx context.Context) ([]Consumable, error) {
+	consumables := []Consumable{}
+	err := trash.List(ctx, m.coll, &consumables)
+	return consumables, err
+}
+
+func (m mongoConsumables) GetDeleted(ctx context.Context, id primitive.ObjectID) (Consumable, error) {
+	var c Consumable
+	err := m.coll.FindOne(ctx, trash.Deleted(bson.M{"_id": id})).Decode(&c)
+	return c, store.MongoErr(err)
+}
+
+func (m mongoConsumables) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return store.MongoErr(trash.Restore(ctx, m.coll, id))
+}
+
+func (m mongoConsumables) Purge(ctx context.Context, id primitive.ObjectID) error {
+	return store.MongoErr(trash.Purge(ctx, m.coll, id))
+}
+
+func (m mongoConsumables) PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	return trash.PurgeExpired(ctx, m.coll, retention)
+}
@@ -0,0 +1,126 @@
+package consumable
+
+import (
+	"context"
+	"shared/audit"
+	"shared/config"
+	"shared/events"
+	"shared/store"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// migrations create the tables of the consumable catalogue in a SQL database
+var migrations = []store.Migration{
+	{Version: 1, Name: "create consumables", Statements: []string{
+		`CREATE TABLE consumables (
+			id TEXT PRIMARY KEY,
+			doc TEXT NOT NULL,
+			deleted_at BIGINT,
+			deleted_by TEXT NOT NULL DEFAULT ''
+		)`,
+		"CREATE INDEX consumables_deleted_at ON consumables (deleted_at)",
+	}},
+}
+
+// MigrateSQL creates or updates the tables of the consumable catalogue in db
+func MigrateSQL(ctx context.Context, db *store.SQL) error {
+	return db.Migrate(ctx, "consumable", migrations)
+}
+
+// NewSQLStore returns the store of the consumable catalogue in db, which keeps
+// the audit trail and the outbox too
+func NewSQLStore(db *store.SQL) *Store {
+	return &Store{
+		Consumables: sqlConsumables{consumablesTable(db)},
+		Tx:          db,
+		Outbox:      events.NewSQLOutbox(db),
+		Audit:       audit.NewSQLStore(db),
+	}
+}
+
+func consumablesTable(db *store.SQL) *store.SQLTable[Consumable] {
+	return store.NewSQLTable(db, "consumables", func(c *Consumable) (*primitive.ObjectID, **time.Time, *string) {
+		return &c.ID, &c.DeletedAt, &c.DeletedBy
+	})
+}
+
+// Migrate creates or updates the tables or indexes of the consumable catalogue
+// on b
+func Migrate(ctx context.Context, conf *config.Config, b store.Backend) error {
+	if b.SQL != nil {
+		return MigrateSQL(ctx, b.SQL)
+	}
+	return MigrateMongo(ctx, b.Mongo.Database(conf.Database(config.Consumable)))
+}
+
+// storeOn returns the store of the consumable catalogue on b; its tables or
+// indexes are created by Migrate
+func storeOn(conf *config.Config, b store.Backend) *Store {
+	if b.SQL != nil {
+		return NewSQLStore(b.SQL)
+	}
+	return NewMongoStore(b.Mongo.Database(conf.Database(config.Consumable)), b.Mongo.Database(conf.Mongo.Database))
+}
+
+// CopyToSQL copies the consumables kept in MongoDB by client into to and
+// returns how many it copied
+func CopyToSQL(ctx context.Context, conf *config.Config, client *mongo.Client, to *store.SQL) (int, error) {
+	if err := MigrateSQL(ctx, to); err != nil {
+		return 0, err
+	}
+	db := client.Database(conf.Database(config.Consumable))
+	return store.CopyCollection(ctx, db.Collection("consumables"), consumablesTable(to))
+}
+
+// sqlConsumables is the ConsumableRepository of the consumables table
+type sqlConsumables struct {
+	table *store.SQLTable[Consumable]
+}
+
+func (m sqlConsumables) List(ctx context.Context) ([]Consumable, error) {
+	return m.table.Select(ctx, "")
+}
+
+func (m sqlConsumables) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Consumable, error) {
+	return m.table.Select(ctx, store.In("id", len(ids)), store.Hexes(ids)...)
+}
+
+func (m sqlConsumables) Get(ctx context.Context, id primitive.ObjectID) (Consumable, error) {
+	return m.table.Get(ctx, id)
+}
+
+func (m sqlConsumables) Insert(ctx context.Context, c Consumable) error {
+	return m.table.Insert(ctx, c)
+}
+
+func (m sqlConsumables) Update(ctx context.Context, c Consumable) error {
+	_, err := m.table.UpdateVersion(ctx, c.ID, c.Version, consumableVersion, func(rec *Consumable) { rec.Label, rec.Notes = c.Label, c.Notes })
+	return err
+}
+
+func (m sqlConsumables) Delete(ctx context.Context, id primitive.ObjectID, actor string) error {
+	return m.table.Delete(ctx, id, actor)
+}
+
+func (m sqlConsumables) ListDeleted(ctx context.Context) ([]Consumable, error) {
+	return m.table.Deleted(ctx)
+}
+
+func (m sqlConsumables) GetDeleted(ctx context.Context, id primitive.ObjectID) (Consumable, error) {
+	return m.table.GetDeleted(ctx, id)
+}
+
+func (m sqlConsumables) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Restore(ctx, id)
+}
+
+func (m sqlConsumables) Purge(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Purge(ctx, id)
+}
+
+func (m sqlConsumables) PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	return m.table.PurgeExpired(ctx, retention)
+}
@@ -0,0 +1,94 @@
+package consumable
+
+import (
+	"cmms/project/rpc/cmmspb"
+	"context"
+	"html/template"
+	"log"
+	"net/http"
+	"shared/audit"
+	"shared/csrf"
+	"shared/flash"
+	"shared/trash"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Recycle bin of deleted consumables
+func consumableTrashHandler(w http.ResponseWriter, r *http.Request) {
+	consumables, err := repo.Consumables.ListDeleted(context.Background())
+	if err != nil {
+		http.Error(w, "Failed to retrieve deleted consumables", http.StatusInternalServerError)
+		return
+	}
+
+	type item struct {
+		Consumable
+		PurgeOn time.Time
+	}
+	retention := trash.Retention()
+	items := make([]item, 0, len(consumables))
+	for _, s := range consumables {
+		items = append(items, item{Consumable: s, PurgeOn: trash.PurgeDate(*s.DeletedAt, retention)})
+	}
+
+	data := struct {
+		Items         []item
+		RetentionDays int
+		Flash         flash.Message
+		CSRF          template.HTML
+	}{
+		Items:         items,
+		RetentionDays: int(retention.Hours() / 24),
+		CSRF:          csrf.Field(r),
+	}
+	data.Flash, _ = flash.Get(w, r)
+
+	templates.ExecuteTemplate(w, "trash.html", data)
+}
+
+// Restore consumable from the recycle bin
+func consumableRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	before, _ := repo.Consumables.GetDeleted(context.Background(), id)
+	if err := repo.Consumables.Restore(context.Background(), id); err != nil {
+		log.Printf("error restoring consumable %s: %v", id.Hex(), err)
+		flash.Redirect(w, r, "/consumable/trash", flash.Error, "Failed to restore consumable")
+		return
+	}
+	after := before
+	after.DeletedAt, after.DeletedBy = nil, ""
+	recordAudit(r, audit.ActionRestore, id, before.Label, before, after)
+	publishChange(cmmspb.ChangeType_CHANGE_TYPE_RESTORED, after)
+	flash.Redirect(w, r, "/consumable/trash", flash.Success, before.Label+" restored successfully")
+}
+
+// Permanently delete consumable from the recycle bin
+func consumablePurgeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	before, _ := repo.Consumables.GetDeleted(context.Background(), id)
+	if err := repo.Consumables.Purge(context.Background(), id); err != nil {
+		log.Printf("error purging consumable %s: %v", id.Hex(), err)
+		flash.Redirect(w, r, "/consumable/trash", flash.Error, "Failed to purge consumable")
+		return
+	}
+	recordAudit(r, audit.ActionPurge, id, before.Label, before, nil)
+	flash.Redirect(w, r, "/consumable/trash", flash.Success, before.Label+" permanently deleted")
+}
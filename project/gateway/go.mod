@@ -0,0 +1,12 @@
+module gateway
+
+go 1.23.2
+
+require (
+	golang.org/x/crypto v0.37.0
+	shared v0.0.0
+)
+
+require gopkg.in/yaml.v3 v3.0.1 // indirect
+
+replace shared => ../shared
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"shared/config"
+	"shared/health"
+	"shared/routes"
+	"strings"
+	"sync"
+	"time"
+)
+
+// healthPaths are cheap requests that reach the database of each backend
+var healthPaths = map[string]string{
+	config.Asset:       "/assets?ids=",
+	config.Service:     "/services?ids=",
+	config.Consumable:  "/consumables?ids=",
+	config.Maintenance: "/api/references?kind=asset&id=000000000000000000000000",
+}
+
+// BackendHealth is the state of one service as seen by the gateway
+type BackendHealth struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Up      bool   `json:"up"`
+	Status  int    `json:"status,omitempty"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+// healthHandler probes every backend at once and answers 200 when all are
+// up, 503 otherwise, with the state of each in JSON
+func healthHandler(conf *config.Config) http.Handler {
+	client := &http.Client{Timeout: 3 * time.Second}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		results := make([]BackendHealth, len(routes.Services))
+		var wg sync.WaitGroup
+		for i, name := range routes.Services {
+			wg.Add(1)
+			go func(i int, name string) {
+				defer wg.Done()
+				results[i] = probe(r.Context(), client, name, conf.URL(name))
+			}(i, name)
+		}
+		wg.Wait()
+
+		status, code := "ok", http.StatusOK
+		for _, b := range results {
+			if !b.Up {
+				status, code = "degraded", http.StatusServiceUnavailable
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(struct {
+			Status   string          `json:"status"`
+			Backends []BackendHealth `json:"backends"`
+		}{status, results})
+	})
+}
+
+// backendChecks are the checks of the readiness probe of the gateway: every
+// backend is alive
+func backendChecks(conf *config.Config) []health.Check {
+	client := &http.Client{Timeout: 3 * time.Second}
+	checks := make([]health.Check, len(routes.Services))
+	for i, name := range routes.Services {
+		checks[i] = health.Check{Name: name, Run: health.Ping(client, conf.URL(name))}
+	}
+	return checks
+}
+
+// backendOf labels the requests of the gateway in the metrics with the
+// service they are proxied to
+func backendOf(r *http.Request) string {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/style/"):
+		return "/style/"
+	case r.URL.Path == "/" || r.URL.Path == "/gateway/health":
+		return r.URL.Path
+	}
+	return routes.ServiceFor(r.URL.Path)
+}
+
+func probe(ctx context.Context, client *http.Client, name, baseURL string) (h BackendHealth) {
+	h = BackendHealth{Name: name, URL: baseURL}
+	start := time.Now()
+	defer func() { h.Latency = time.Since(start).Round(time.Millisecond).String() }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+healthPaths[name], nil)
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	resp.Body.Close()
+
+	h.Status = resp.StatusCode
+	h.Up = resp.StatusCode == http.StatusOK
+	if !h.Up {
+		h.Error = fmt.Sprintf("answered %s", resp.Status)
+	}
+	return h
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"shared/config"
+	"shared/health"
+	"shared/metrics"
+	"shared/routes"
+)
+
+// The gateway serves every CMMS service on one port: it proxies each path to
+// the service owning it, serves the shared stylesheets, checks logins and
+// logs every request. It answers /healthz, /readyz and /metrics like the
+// services.
+func main() {
+	conf, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	proxies, err := newProxies(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	auth, err := newAuthenticator(conf.Gateway.UsersFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if auth == nil {
+		log.Printf("no users file configured, requests are passed on without authentication, as anonymous")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/style/", http.StripPrefix("/style/", http.FileServer(http.Dir(conf.Gateway.StyleDir))))
+	mux.Handle("/gateway/health", healthHandler(conf))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/assets", http.StatusFound)
+			return
+		}
+		proxies[routes.ServiceFor(r.URL.Path)].ServeHTTP(w, r)
+	})
+
+	// The probes and metrics are answered without a login, for the monitoring
+	root := http.NewServeMux()
+	root.Handle("GET /healthz", health.Live())
+	root.Handle("GET /readyz", health.Ready(backendChecks(conf)...))
+	root.Handle("GET /metrics", metrics.Handler())
+	root.Handle("/", metrics.Instrument(config.Gateway, backendOf, auth.require(mux)))
+
+	addr := conf.Addr(config.Gateway)
+	log.Printf("gateway listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, logRequests(root)))
+}
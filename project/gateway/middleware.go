@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"shared/routes"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// authenticator checks Basic logins against an htpasswd file of
+// user:bcrypt-hash lines (htpasswd -B). A nil authenticator lets every
+// request through.
+type authenticator struct {
+	users map[string][]byte
+
+	// verified remembers logins that passed bcrypt, which is slow on purpose
+	mu       sync.Mutex
+	verified map[string][sha256.Size]byte
+}
+
+// newAuthenticator loads the users file, or returns nil when there is none
+func newAuthenticator(path string) (*authenticator, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	a := &authenticator{users: map[string][]byte{}, verified: map[string][sha256.Size]byte{}}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok || user == "" {
+			return nil, fmt.Errorf("%s:%d: expected user:hash", path, n)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v (only bcrypt hashes are supported)", path, n, err)
+		}
+		a.users[user] = []byte(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(a.users) == 0 {
+		return nil, fmt.Errorf("%s: no users", path)
+	}
+	return a, nil
+}
+
+func (a *authenticator) check(user, password string) bool {
+	hash, ok := a.users[user]
+	if !ok {
+		return false
+	}
+	sum := sha256.Sum256([]byte(user + ":" + password))
+
+	a.mu.Lock()
+	known, seen := a.verified[user]
+	a.mu.Unlock()
+	if seen && known == sum {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return false
+	}
+	a.mu.Lock()
+	a.verified[user] = sum
+	a.mu.Unlock()
+	return true
+}
+
+// require asks for a login on every request but the health check, and
+// passes the user on to the services in X-Remote-User, which they record in
+// the audit trail. Identity headers sent by the client are dropped so they
+// cannot be forged, with or without a login: a nil authenticator passes
+// every request on as anonymous.
+func (a *authenticator) require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del("X-Remote-User")
+		r.Header.Del("X-Forwarded-User")
+		if a == nil || r.URL.Path == "/gateway/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, password, ok := r.BasicAuth()
+		if !ok || !a.check(user, password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="CMMS", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		r.Header.Del("Authorization")
+		r.Header.Set("X-Remote-User", user)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder keeps the status code written through it
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// logRequests logs every request with its user, backend, status and duration
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		user := r.Header.Get("X-Remote-User")
+		if user == "" {
+			user = "-"
+		}
+		backend := routes.ServiceFor(r.URL.Path)
+		if strings.HasPrefix(r.URL.Path, "/style/") || strings.HasPrefix(r.URL.Path, "/gateway/") {
+			backend = "gateway"
+		}
+		log.Printf("%s %s %s %s -> %s %d %s", r.RemoteAddr, user, r.Method, r.URL.RequestURI(), backend, rec.status, time.Since(start).Round(time.Millisecond))
+	})
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"shared/config"
+	"shared/metrics"
+	"shared/routes"
+)
+
+// newProxies returns a reverse proxy for every backend, counting its calls
+// in the metrics
+func newProxies(conf *config.Config) (map[string]http.Handler, error) {
+	proxies := map[string]http.Handler{}
+	for _, name := range routes.Services {
+		target, err := url.Parse(conf.URL(name))
+		if err != nil {
+			return nil, fmt.Errorf("services.%s.url: %w", name, err)
+		}
+
+		name := name
+		proxies[name] = &httputil.ReverseProxy{
+			Transport: metrics.Transport(name, nil),
+			Rewrite: func(pr *httputil.ProxyRequest) {
+				pr.SetURL(target)
+				pr.SetXForwarded()
+			},
+			ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+				log.Printf("%s %s: %s service: %v", r.Method, r.URL.Path, name, err)
+				http.Error(w, "The "+name+" service is unavailable", http.StatusBadGateway)
+			},
+		}
+	}
+	return proxies, nil
+}
@@ -1,80 +1,443 @@
-package main
+package maintenence
 
 import (
-	"encoding/json"
-	"net/http"
+	"cmms/project/rpc"
+	"cmms/project/rpc/cmmspb"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"shared/config"
+	"shared/jsonapi"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-// Helper function to fetch services from API
-func fetchServicesFromAPI() ([]Service, error) {
-	resp, err := http.Get("http://localhost:8081/services")
-	if err != nil {
-		return nil, err
+// Generated clients of the asset, service and consumable services, set up by
+// dialServices
+var (
+	assetClient      cmmspb.AssetRegisterClient
+	serviceClient    cmmspb.ServiceCatalogClient
+	consumableClient cmmspb.ConsumableCatalogClient
+)
+
+// callTimeout bounds every lookup in the other services
+const callTimeout = 5 * time.Second
+
+// nameCacheTTL is how long an answer is kept when the Watch stream of its
+// service is down and cannot tell that a record changed
+const nameCacheTTL = 30 * time.Second
+
+// Keys of the lookups cache start with the service they come from, so a
+// change streamed by one service drops only its own answers
+const (
+	assetKey      = "asset:"
+	serviceKey    = "service:"
+	consumableKey = "consumable:"
+)
+
+// dialServices connects to the gRPC servers of the other services, with opts
+// added to the connections, until ctx is done. The lookups are retried and
+// stopped by a circuit breaker per service while it fails; every call made
+// is counted in the metrics.
+func dialServices(ctx context.Context, opts ...grpc.DialOption) error {
+	var conns []*grpc.ClientConn
+	for _, name := range []string{config.Asset, config.Service, config.Consumable} {
+		conn, err := rpc.Dial(conf.GRPCTarget(name), append([]grpc.DialOption{rpc.Resilient(name, rpc.DefaultPolicy()), rpc.Counted(name)}, opts...)...)
+		if err != nil {
+			for _, c := range conns {
+				c.Close()
+			}
+			return fmt.Errorf("connecting to the %s service: %w", name, err)
+		}
+		conns = append(conns, conn)
+	}
+	assetClient = cmmspb.NewAssetRegisterClient(conns[0])
+	serviceClient = cmmspb.NewServiceCatalogClient(conns[1])
+	consumableClient = cmmspb.NewConsumableCatalogClient(conns[2])
+
+	go func() {
+		<-ctx.Done()
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+	return nil
+}
+
+// watchServices keeps the lookups cache current with the Watch streams of
+// the other services until ctx is done
+func watchServices(ctx context.Context) {
+	go watch(ctx, assetKey, func(ctx context.Context) (grpc.ServerStreamingClient[cmmspb.AssetChange], error) {
+		return assetClient.WatchAssets(ctx, &cmmspb.WatchRequest{})
+	})
+	go watch(ctx, serviceKey, func(ctx context.Context) (grpc.ServerStreamingClient[cmmspb.ServiceChange], error) {
+		return serviceClient.WatchServices(ctx, &cmmspb.WatchRequest{})
+	})
+	go watch(ctx, consumableKey, func(ctx context.Context) (grpc.ServerStreamingClient[cmmspb.ConsumableChange], error) {
+		return consumableClient.WatchConsumables(ctx, &cmmspb.WatchRequest{})
+	})
+}
+
+// watch drops the cached answers under prefix on every change streamed by
+// open, reopening the stream with backoff when it ends
+func watch[T any](ctx context.Context, prefix string, open func(context.Context) (grpc.ServerStreamingClient[T], error)) {
+	backoff := time.Second
+	for {
+		stream, err := open(ctx)
+		if err == nil {
+			// Changes made while the stream was down are not replayed
+			lookups.invalidate(prefix)
+			for {
+				if _, err = stream.Recv(); err != nil {
+					break
+				}
+				lookups.invalidate(prefix)
+				backoff = time.Second
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("watching %s changes: %v; retrying in %s", strings.TrimSuffix(prefix, ":"), err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
 	}
-	defer resp.Body.Close()
+}
+
+// incomplete collects what could not be fetched from the other services
+// while building a page; templates show it as a "data may be incomplete"
+// banner instead of silently rendering empty lists
+type incomplete []string
 
-	var services []Service
-	if err := json.NewDecoder(resp.Body).Decode(&services); err != nil {
-		return nil, err
+func (w *incomplete) add(err error) {
+	if err == nil {
+		return
 	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			w.add(e)
+		}
+		return
+	}
+	log.Printf("data may be incomplete: %v", err)
+	for _, msg := range *w {
+		if msg == err.Error() {
+			return
+		}
+	}
+	*w = append(*w, err.Error())
+}
 
-	return services, nil
+// errStale wraps the error of a failed lookup answered from an expired copy
+var errStale = errors.New("serving cached copy")
+
+// lookups keeps the answers of the other services in memory; see cached
+var lookups = &lookupCache{entries: map[string]lookupEntry{}, maxEntries: 1000}
+
+type lookupCache struct {
+	mu         sync.Mutex
+	entries    map[string]lookupEntry
+	maxEntries int
 }
 
-// Helper function to fetch consumables from API
-func fetchConsumablesFromAPI() ([]Consumable, error) {
-	resp, err := http.Get("http://localhost:8082/consumables")
+type lookupEntry struct {
+	value     interface{}
+	fetchedAt time.Time
+}
+
+func (c *lookupCache) get(key string) (lookupEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	return e, ok
+}
+
+func (c *lookupCache) put(key string, e lookupEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		var oldest string
+		for k, v := range c.entries {
+			if oldest == "" || v.fetchedAt.Before(c.entries[oldest].fetchedAt) {
+				oldest = k
+			}
+		}
+		delete(c.entries, oldest)
+	}
+	c.entries[key] = e
+}
+
+// invalidate drops the answers whose key starts with prefix; an empty
+// prefix drops them all
+func (c *lookupCache) invalidate(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.entries {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// cached answers from the lookups cache while the answer under key is
+// younger than nameCacheTTL, and calls fetch otherwise. When the service
+// cannot be reached the last answer, if any, is returned along with the error.
+func cached[T any](ctx context.Context, key string, fetch func(context.Context) (T, error)) (T, error) {
+	now := time.Now()
+	e, ok := lookups.get(key)
+	if ok && now.Sub(e.fetchedAt) < nameCacheTTL {
+		return e.value.(T), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+	v, err := fetch(ctx)
 	if err != nil {
-		return nil, err
+		if ok && status.Code(err) != codes.NotFound {
+			return e.value.(T), fmt.Errorf("%w from %s: %v", errStale, e.fetchedAt.Format("15:04:05"), err)
+		}
+		return v, err
 	}
-	defer resp.Body.Close()
+	lookups.put(key, lookupEntry{value: v, fetchedAt: now})
+	return v, nil
+}
+
+// The fetch helpers below go through the lookups cache. When a service
+// cannot be reached they return its last known answer, if any, along with
+// the error.
 
-	var consumables []Consumable
-	if err := json.NewDecoder(resp.Body).Decode(&consumables); err != nil {
-		return nil, err
+// Helper function to fetch services from API
+func fetchServicesFromAPI(ctx context.Context) ([]Service, error) {
+	services, err := cached(ctx, serviceKey+"all", func(ctx context.Context) ([]Service, error) {
+		resp, err := serviceClient.ListServices(ctx, &cmmspb.ListServicesRequest{})
+		return servicesFromProto(resp.GetServices()), err
+	})
+	if err != nil {
+		return services, fmt.Errorf("fetching services: %w", err)
 	}
+	return services, nil
+}
 
+// Helper function to fetch consumables from API
+func fetchConsumablesFromAPI(ctx context.Context) ([]Consumable, error) {
+	consumables, err := cached(ctx, consumableKey+"all", func(ctx context.Context) ([]Consumable, error) {
+		resp, err := consumableClient.ListConsumables(ctx, &cmmspb.ListConsumablesRequest{})
+		return consumablesFromProto(resp.GetConsumables()), err
+	})
+	if err != nil {
+		return consumables, fmt.Errorf("fetching consumables: %w", err)
+	}
 	return consumables, nil
 }
 
 // Helper function to fetch asset from API
-func fetchAssetFromAPI(assetID string) (*Asset, error) {
-	resp, err := http.Get("http://localhost:5500/assets/" + assetID)
+func fetchAssetFromAPI(ctx context.Context, assetID string) (*Asset, error) {
+	asset, err := cached(ctx, assetKey+assetID, func(ctx context.Context) (*Asset, error) {
+		resp, err := assetClient.GetAsset(ctx, &cmmspb.GetRequest{Id: assetID})
+		if err != nil {
+			return nil, err
+		}
+		a := assetFromProto(resp)
+		return &a, nil
+	})
 	if err != nil {
-		return nil, err
+		if errors.Is(err, errStale) {
+			return asset, fmt.Errorf("fetching asset %s: %w", assetID, err)
+		}
+		return nil, fmt.Errorf("fetching asset %s: %w", assetID, err)
 	}
-	defer resp.Body.Close()
+	return asset, nil
+}
+
+// fetchByIDs fetches the records with the given ids with batchGet, in as few
+// calls as possible
+func fetchByIDs[T any](ctx context.Context, prefix string, ids []primitive.ObjectID, batchGet func(context.Context, *cmmspb.BatchGetRequest) ([]T, error)) ([]T, error) {
+	// Sorted and deduplicated so the same set of ids hits the same cache entry
+	sorted := make([]string, 0, len(ids))
+	seen := map[primitive.ObjectID]bool{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			sorted = append(sorted, id.Hex())
+		}
+	}
+	sort.Strings(sorted)
 
-	var asset Asset
-	if err := json.NewDecoder(resp.Body).Decode(&asset); err != nil {
-		return nil, err
+	result := []T{}
+	var errs []error
+	for start := 0; start < len(sorted); start += jsonapi.MaxIDs {
+		chunk := sorted[start:min(start+jsonapi.MaxIDs, len(sorted))]
+		batch, err := cached(ctx, prefix+strings.Join(chunk, ","), func(ctx context.Context) ([]T, error) {
+			return batchGet(ctx, &cmmspb.BatchGetRequest{Ids: chunk})
+		})
+		result = append(result, batch...)
+		errs = append(errs, err)
 	}
+	return result, errors.Join(errs...)
+}
 
-	return &asset, nil
+// fetchServicesByID fetches only the services with the given ids
+func fetchServicesByID(ctx context.Context, ids []primitive.ObjectID) ([]Service, error) {
+	services, err := fetchByIDs(ctx, serviceKey, ids, func(ctx context.Context, req *cmmspb.BatchGetRequest) ([]Service, error) {
+		resp, err := serviceClient.BatchGetServices(ctx, req)
+		return servicesFromProto(resp.GetServices()), err
+	})
+	if err != nil {
+		err = fmt.Errorf("fetching services: %w", err)
+	}
+	return services, err
 }
 
-// Helper function to fetch services and consumables (updated to use API)
-func fetchServicesAndConsumables() ([]struct {
+// fetchConsumablesByID fetches only the consumables with the given ids
+func fetchConsumablesByID(ctx context.Context, ids []primitive.ObjectID) ([]Consumable, error) {
+	consumables, err := fetchByIDs(ctx, consumableKey, ids, func(ctx context.Context, req *cmmspb.BatchGetRequest) ([]Consumable, error) {
+		resp, err := consumableClient.BatchGetConsumables(ctx, req)
+		return consumablesFromProto(resp.GetConsumables()), err
+	})
+	if err != nil {
+		err = fmt.Errorf("fetching consumables: %w", err)
+	}
+	return consumables, err
+}
+
+// fetchAssetsByID fetches only the assets with the given ids
+func fetchAssetsByID(ctx context.Context, ids []primitive.ObjectID) ([]Asset, error) {
+	assets, err := fetchByIDs(ctx, assetKey, ids, func(ctx context.Context, req *cmmspb.BatchGetRequest) ([]Asset, error) {
+		resp, err := assetClient.BatchGetAssets(ctx, req)
+		return assetsFromProto(resp.GetAssets()), err
+	})
+	if err != nil {
+		err = fmt.Errorf("fetching assets: %w", err)
+	}
+	return assets, err
+}
+
+// Helper function to fetch assets from API, optionally filtered by type and location
+func fetchAssetsFromAPI(ctx context.Context, typ, location string) ([]Asset, error) {
+	req := &cmmspb.ListAssetsRequest{Type: typ, Location: location}
+	assets, err := cached(ctx, assetKey+"list?"+typ+"&"+location, func(ctx context.Context) ([]Asset, error) {
+		resp, err := assetClient.ListAssets(ctx, req)
+		return assetsFromProto(resp.GetAssets()), err
+	})
+	if err != nil {
+		return assets, fmt.Errorf("fetching assets: %w", err)
+	}
+	return assets, nil
+}
+
+// fetchDeletedAssets fetches the assets in the recycle bin
+func fetchDeletedAssets(ctx context.Context) ([]Asset, error) {
+	assets, err := cached(ctx, assetKey+"deleted", func(ctx context.Context) ([]Asset, error) {
+		resp, err := assetClient.ListDeletedAssets(ctx, &cmmspb.ListDeletedRequest{})
+		return assetsFromProto(resp.GetAssets()), err
+	})
+	if err != nil {
+		return assets, fmt.Errorf("fetching deleted assets: %w", err)
+	}
+	return assets, nil
+}
+
+// fetchDeletedServices fetches the services in the recycle bin
+func fetchDeletedServices(ctx context.Context) ([]Service, error) {
+	services, err := cached(ctx, serviceKey+"deleted", func(ctx context.Context) ([]Service, error) {
+		resp, err := serviceClient.ListDeletedServices(ctx, &cmmspb.ListDeletedRequest{})
+		return servicesFromProto(resp.GetServices()), err
+	})
+	if err != nil {
+		return services, fmt.Errorf("fetching deleted services: %w", err)
+	}
+	return services, nil
+}
+
+// fetchDeletedConsumables fetches the consumables in the recycle bin
+func fetchDeletedConsumables(ctx context.Context) ([]Consumable, error) {
+	consumables, err := cached(ctx, consumableKey+"deleted", func(ctx context.Context) ([]Consumable, error) {
+		resp, err := consumableClient.ListDeletedConsumables(ctx, &cmmspb.ListDeletedRequest{})
+		return consumablesFromProto(resp.GetConsumables()), err
+	})
+	if err != nil {
+		return consumables, fmt.Errorf("fetching deleted consumables: %w", err)
+	}
+	return consumables, nil
+}
+
+// isNotFound reports whether err is a NOT_FOUND answer, i.e. the service is
+// up but the record does not exist
+func isNotFound(err error) bool {
+	return status.Code(err) == codes.NotFound
+}
+
+// The conversions from the gRPC messages are the only place the maintenance
+// service depends on their fields
+
+func serviceFromProto(s *cmmspb.Service) Service {
+	id, _ := primitive.ObjectIDFromHex(s.GetId())
+	return Service{ID: id, Label: s.GetLabel(), Notes: s.GetNotes()}
+}
+
+func servicesFromProto(list []*cmmspb.Service) []Service {
+	services := make([]Service, len(list))
+	for i, s := range list {
+		services[i] = serviceFromProto(s)
+	}
+	return services
+}
+
+func consumableFromProto(c *cmmspb.Consumable) Consumable {
+	id, _ := primitive.ObjectIDFromHex(c.GetId())
+	return Consumable{ID: id, Label: c.GetLabel(), Notes: c.GetNotes()}
+}
+
+func consumablesFromProto(list []*cmmspb.Consumable) []Consumable {
+	consumables := make([]Consumable, len(list))
+	for i, c := range list {
+		consumables[i] = consumableFromProto(c)
+	}
+	return consumables
+}
+
+func assetFromProto(a *cmmspb.Asset) Asset {
+	id, _ := primitive.ObjectIDFromHex(a.GetId())
+	asset := Asset{ID: id, Label: a.GetLabel(), Type: a.GetType(), Location: a.GetLocation()}
+	if a.GetEffectiveDate() != nil {
+		asset.EffectiveDate = a.GetEffectiveDate().AsTime()
+	}
+	return asset
+}
+
+func assetsFromProto(list []*cmmspb.Asset) []Asset {
+	assets := make([]Asset, len(list))
+	for i, a := range list {
+		assets[i] = assetFromProto(a)
+	}
+	return assets
+}
+
+// Helper function to fetch services and consumables (updated to use API).
+// Whatever could be fetched is returned along with the errors of the rest.
+func fetchServicesAndConsumables(ctx context.Context) ([]struct {
 	ID    primitive.ObjectID `bson:"_id"`
 	Label string             `bson:"label"`
 }, []struct {
 	ID    primitive.ObjectID `bson:"_id"`
 	Label string             `bson:"label"`
-}) {
+}, error) {
 	// Fetch from API
-	services, err := fetchServicesFromAPI()
-	if err != nil {
-		// Handle error appropriately
-		services = []Service{}
-	}
-
-	consumables, err := fetchConsumablesFromAPI()
-	if err != nil {
-		// Handle error appropriately
-		consumables = []Consumable{}
-	}
+	services, svcErr := fetchServicesFromAPI(ctx)
+	consumables, consErr := fetchConsumablesFromAPI(ctx)
 
 	// Convert to the expected structure
 	serviceStructs := make([]struct {
@@ -99,5 +462,5 @@ func fetchServicesAndConsumables() ([]struct {
 		}{ID: cons.ID, Label: cons.Label}
 	}
 
-	return serviceStructs, consumableStructs
+	return serviceStructs, consumableStructs, errors.Join(svcErr, consErr)
 }
@@ -0,0 +1,102 @@
+package maintenence
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"shared/audit"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var auditLog *audit.Logger
+
+// recordAudit adds an entry to the audit trail; a failure is logged but
+// never fails the request, since the change itself was saved
+func recordAudit(ctx context.Context, r *http.Request, action, entity string, id primitive.ObjectID, label string, before, after interface{}) {
+	if err := auditLog.Record(ctx, r, action, entity, id, label, before, after); err != nil {
+		log.Printf("error recording audit entry for %s %s: %v", entity, id.Hex(), err)
+	}
+}
+
+// Searchable audit trail of all services; with entity and entity_id set it
+// is the history of a single record
+func auditTrail(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	filter := audit.Filter{
+		Service:  q.Get("service"),
+		Entity:   q.Get("entity"),
+		EntityID: q.Get("entity_id"),
+		Actor:    strings.TrimSpace(q.Get("actor")),
+		Action:   q.Get("action"),
+		Text:     strings.TrimSpace(q.Get("q")),
+		Limit:    500,
+	}
+
+	from, err := parseDateParam(q, "from", time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseDateParam(q, "to", time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.From = from
+	if !to.IsZero() {
+		// to is inclusive in the query string
+		filter.To = to.AddDate(0, 0, 1)
+	}
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	entries, err := repo.Audit.Search(ctx, filter)
+	if err != nil {
+		http.Error(w, "Failed to search audit trail: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The latest label of the record whose history is shown
+	title := ""
+	if filter.EntityID != "" {
+		title = filter.Entity + " " + filter.EntityID
+		for _, e := range entries {
+			if e.Label != "" {
+				title = filter.Entity + " " + e.Label
+				break
+			}
+		}
+	}
+
+	data := struct {
+		Entries  []audit.Entry
+		Title    string
+		Query    map[string]string
+		Services []string
+		Entities []string
+		Actions  []string
+	}{
+		Entries: entries,
+		Title:   title,
+		Query: map[string]string{
+			"service":   filter.Service,
+			"entity":    filter.Entity,
+			"entity_id": filter.EntityID,
+			"actor":     filter.Actor,
+			"action":    filter.Action,
+			"q":         filter.Text,
+			"from":      q.Get("from"),
+			"to":        q.Get("to"),
+		},
+		Services: []string{"asset", "service", "consumable", "maintenance"},
+		Entities: []string{"asset", "failure_event", "service", "consumable", "maintenance", "schedule", "schedule_completion"},
+		Actions:  audit.Actions,
+	}
+
+	renderTemplate(w, "audit.html", data)
+}
@@ -0,0 +1,338 @@
+package maintenence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxWindowDays bounds the date window accepted by the occurrences endpoint
+const maxWindowDays = 366
+
+// occurrenceFilterFromQuery reads the calendar filters shared by the JSON
+// endpoint, the calendar and the timeline
+func occurrenceFilterFromQuery(q url.Values) (OccurrenceFilter, error) {
+	f := OccurrenceFilter{
+		AssetType: q.Get("asset_type"),
+		Location:  q.Get("location"),
+	}
+
+	for name, dst := range map[string]**primitive.ObjectID{
+		"asset_id":       &f.AssetID,
+		"maintenance_id": &f.MaintenanceID,
+		"service_id":     &f.ServiceID,
+	} {
+		if s := q.Get(name); s != "" {
+			oid, err := primitive.ObjectIDFromHex(s)
+			if err != nil {
+				return f, &paramError{name}
+			}
+			*dst = &oid
+		}
+	}
+
+	return f, nil
+}
+
+type paramError struct{ name string }
+
+func (e *paramError) Error() string { return "Invalid " + e.name }
+
+// parseDateParam parses an optional YYYY-MM-DD query parameter
+func parseDateParam(q url.Values, name string, def time.Time) (time.Time, error) {
+	s := q.Get(name)
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return def, &paramError{name}
+	}
+	return d, nil
+}
+
+// CalendarFilterOptions lists the values offered in the filter dropdowns
+type CalendarFilterOptions struct {
+	AssetTypes   []string
+	Locations    []string
+	Maintenances []MainteneceShedule
+	Services     []Service
+}
+
+// calendarFilterOptions returns the filter values along with the errors of
+// the services that could not be reached
+func calendarFilterOptions(ctx context.Context) (CalendarFilterOptions, error) {
+	var opts CalendarFilterOptions
+
+	assets, assetsErr := fetchAssetsFromAPI(ctx, "", "")
+	types := map[string]bool{}
+	locations := map[string]bool{}
+	for _, a := range assets {
+		if a.Type != "" && !types[a.Type] {
+			types[a.Type] = true
+			opts.AssetTypes = append(opts.AssetTypes, a.Type)
+		}
+		if a.Location != "" && !locations[a.Location] {
+			locations[a.Location] = true
+			opts.Locations = append(opts.Locations, a.Location)
+		}
+	}
+	sort.Strings(opts.AssetTypes)
+	sort.Strings(opts.Locations)
+
+	if maintenances, err := repo.Maintenances.List(ctx); err == nil {
+		opts.Maintenances = maintenances
+	}
+
+	services, servicesErr := fetchServicesFromAPI(ctx)
+	opts.Services = services
+
+	return opts, errors.Join(assetsErr, servicesErr)
+}
+
+// Occurrences of all matching schedules in a date window, in JSON format
+func occurrencesAPI(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	filter, err := occurrenceFilterFromQuery(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	today := truncateDay(time.Now())
+	from, err := parseDateParam(q, "from", today)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseDateParam(q, "to", from.AddDate(0, 0, 30))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if q.Get("to") != "" {
+		// to is inclusive in the query string
+		to = to.AddDate(0, 0, 1)
+	}
+	if !from.Before(to) || to.Sub(from) > maxWindowDays*24*time.Hour {
+		http.Error(w, "Window must be between 1 and 366 days", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	occurrences, _, err := projectOccurrences(ctx, filter, from, to)
+	if err != nil {
+		http.Error(w, "Failed to project occurrences: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(occurrences)
+}
+
+type CalendarDay struct {
+	Date        time.Time
+	InRange     bool
+	Today       bool
+	Occurrences []Occurrence
+}
+
+// Month and week calendar of planned maintenance across all assets
+func calendarView(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	filter, err := occurrenceFilterFromQuery(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	today := truncateDay(time.Now())
+	date, err := parseDateParam(q, "date", today)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	view := q.Get("view")
+	var rangeStart, rangeEnd, prev, next time.Time
+	var title string
+	if view == "week" {
+		rangeStart = startOfWeek(date)
+		rangeEnd = rangeStart.AddDate(0, 0, 7)
+		prev, next = rangeStart.AddDate(0, 0, -7), rangeEnd
+		title = "Week of " + rangeStart.Format("2 Jan 2006")
+	} else {
+		view = "month"
+		rangeStart = time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		rangeEnd = rangeStart.AddDate(0, 1, 0)
+		prev, next = rangeStart.AddDate(0, -1, 0), rangeEnd
+		title = rangeStart.Format("January 2006")
+	}
+
+	// The grid always shows whole weeks, Monday to Sunday
+	gridStart := startOfWeek(rangeStart)
+	gridEnd := startOfWeek(rangeEnd.AddDate(0, 0, -1)).AddDate(0, 0, 7)
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	occurrences, warnings, err := projectOccurrences(ctx, filter, gridStart, gridEnd)
+	if err != nil {
+		http.Error(w, "Failed to project occurrences: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	options, err := calendarFilterOptions(ctx)
+	warnings.add(err)
+
+	byDay := map[time.Time][]Occurrence{}
+	for _, o := range occurrences {
+		byDay[o.Due] = append(byDay[o.Due], o)
+	}
+
+	var weeks [][]CalendarDay
+	for d := gridStart; d.Before(gridEnd); d = d.AddDate(0, 0, 7) {
+		var week []CalendarDay
+		for i := 0; i < 7; i++ {
+			day := d.AddDate(0, 0, i)
+			week = append(week, CalendarDay{
+				Date:        day,
+				InRange:     !day.Before(rangeStart) && day.Before(rangeEnd),
+				Today:       day.Equal(today),
+				Occurrences: byDay[day],
+			})
+		}
+		weeks = append(weeks, week)
+	}
+
+	data := struct {
+		View     string
+		Title    string
+		Date     string
+		Prev     string
+		Next     string
+		Weeks    [][]CalendarDay
+		Query    url.Values
+		Options  CalendarFilterOptions
+		Warnings []string
+	}{
+		View:     view,
+		Title:    title,
+		Date:     date.Format("2006-01-02"),
+		Prev:     prev.Format("2006-01-02"),
+		Next:     next.Format("2006-01-02"),
+		Weeks:    weeks,
+		Query:    q,
+		Options:  options,
+		Warnings: warnings,
+	}
+
+	renderTemplate(w, "calendar.html", data)
+}
+
+type TimelineRow struct {
+	AssetID    string
+	AssetLabel string
+	Location   string
+	Cells      [][]Occurrence
+}
+
+// Timeline of planned maintenance, one row per asset and one column per day
+func timelineView(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	filter, err := occurrenceFilterFromQuery(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	today := truncateDay(time.Now())
+	from, err := parseDateParam(q, "from", today)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseDateParam(q, "to", from.AddDate(0, 0, 27))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to = to.AddDate(0, 0, 1)
+	if !from.Before(to) || to.Sub(from) > 92*24*time.Hour {
+		http.Error(w, "Timeline must span between 1 and 92 days", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	occurrences, warnings, err := projectOccurrences(ctx, filter, from, to)
+	if err != nil {
+		http.Error(w, "Failed to project occurrences: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	options, err := calendarFilterOptions(ctx)
+	warnings.add(err)
+
+	var days []time.Time
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+
+	rowIndex := map[string]int{}
+	var rows []TimelineRow
+	for _, o := range occurrences {
+		i, ok := rowIndex[o.AssetID]
+		if !ok {
+			i = len(rows)
+			rowIndex[o.AssetID] = i
+			rows = append(rows, TimelineRow{
+				AssetID:    o.AssetID,
+				AssetLabel: o.AssetLabel,
+				Location:   o.Location,
+				Cells:      make([][]Occurrence, len(days)),
+			})
+		}
+		col := int(o.Due.Sub(from).Hours() / 24)
+		rows[i].Cells[col] = append(rows[i].Cells[col], o)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].AssetLabel < rows[j].AssetLabel })
+
+	data := struct {
+		From     string
+		To       string
+		Days     []time.Time
+		Today    time.Time
+		Rows     []TimelineRow
+		Query    url.Values
+		Options  CalendarFilterOptions
+		Warnings []string
+	}{
+		From:     from.Format("2006-01-02"),
+		To:       to.AddDate(0, 0, -1).Format("2006-01-02"),
+		Days:     days,
+		Today:    today,
+		Rows:     rows,
+		Query:    q,
+		Options:  options,
+		Warnings: warnings,
+	}
+
+	renderTemplate(w, "timeline.html", data)
+}
+
+// startOfWeek returns the Monday on or before t
+func startOfWeek(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return truncateDay(t).AddDate(0, 0, -offset)
+}
@@ -0,0 +1,395 @@
+package maintenence
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"shared/audit"
+	"shared/flash"
+	"shared/validate"
+	"shared/webhook"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Occurrence statuses used by the compliance report
+const (
+	StatusOnTime  = "on-time"
+	StatusLate    = "late"
+	StatusMissed  = "missed"
+	StatusPending = "pending"
+)
+
+// defaultToleranceDays is how long after its due date an occurrence may be
+// completed and still count as on time
+const defaultToleranceDays = 3
+
+type ComplianceOccurrence struct {
+	Schedule         ScheduleDoc
+	AssetLabel       string
+	MaintenanceLabel string
+	Due              time.Time
+	Deadline         time.Time
+	CompletedAt      *time.Time
+	Status           string
+}
+
+// ComplianceSummary counts the occurrences of one group. Pending occurrences
+// are not yet due and are left out of the percentage.
+type ComplianceSummary struct {
+	Key     string
+	Label   string
+	OnTime  int
+	Late    int
+	Missed  int
+	Pending int
+}
+
+func (c *ComplianceSummary) add(status string) {
+	switch status {
+	case StatusOnTime:
+		c.OnTime++
+	case StatusLate:
+		c.Late++
+	case StatusMissed:
+		c.Missed++
+	default:
+		c.Pending++
+	}
+}
+
+// Total returns the number of occurrences that were due in the period
+func (c ComplianceSummary) Total() int {
+	return c.OnTime + c.Late + c.Missed
+}
+
+// Percent returns the share of due occurrences completed on time
+func (c ComplianceSummary) Percent() string {
+	if c.Total() == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(c.OnTime)*100/float64(c.Total()))
+}
+
+// classifyOccurrence compares a due date with its completion, if any. The
+// returned deadline is the last day on which completing still counts as on
+// time.
+func classifyOccurrence(due time.Time, completedAt *time.Time, tolerance time.Duration, now time.Time) (time.Time, string) {
+	deadline := due.Add(tolerance)
+	cutoff := deadline.Add(24 * time.Hour)
+
+	switch {
+	case completedAt != nil && completedAt.Before(cutoff):
+		return deadline, StatusOnTime
+	case completedAt != nil:
+		return deadline, StatusLate
+	case now.After(cutoff):
+		return deadline, StatusMissed
+	default:
+		return deadline, StatusPending
+	}
+}
+
+// buildComplianceOccurrences expands every schedule into its due dates in
+// [from, to) and matches them against the recorded completions
+func buildComplianceOccurrences(ctx context.Context, schedules []ScheduleDoc, from, to time.Time, tolerance time.Duration) ([]ComplianceOccurrence, error) {
+	var scheduleIDs []primitive.ObjectID
+	for _, s := range schedules {
+		scheduleIDs = append(scheduleIDs, s.ID)
+	}
+
+	completed, err := completedOccurrences(ctx, scheduleIDs, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var result []ComplianceOccurrence
+	for _, s := range schedules {
+		for _, due := range occurrencesBetween(s, from, to) {
+			occ := ComplianceOccurrence{Schedule: s, Due: due}
+			if at, ok := completed[completionKey(s.ID, due)]; ok {
+				occ.CompletedAt = &at
+			}
+			occ.Deadline, occ.Status = classifyOccurrence(due, occ.CompletedAt, tolerance, now)
+			result = append(result, occ)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Due.Before(result[j].Due) })
+	return result, nil
+}
+
+// completedOccurrences returns the completion time of every occurrence of the
+// given schedules due in [from, to), keyed by completionKey
+func completedOccurrences(ctx context.Context, scheduleIDs []primitive.ObjectID, from, to time.Time) (map[string]time.Time, error) {
+	completed := map[string]time.Time{}
+	if len(scheduleIDs) == 0 {
+		return completed, nil
+	}
+
+	completions, err := repo.Completions.List(ctx, scheduleIDs, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range completions {
+		completed[completionKey(c.ScheduleID, c.DueDate)] = c.CompletedAt
+	}
+
+	return completed, nil
+}
+
+func completionKey(scheduleID primitive.ObjectID, due time.Time) string {
+	return scheduleID.Hex() + "/" + truncateDay(due).Format("2006-01-02")
+}
+
+// summarize groups occurrences by the key returned by keyFn
+func summarize(occurrences []ComplianceOccurrence, keyFn func(ComplianceOccurrence) (string, string)) []ComplianceSummary {
+	index := map[string]int{}
+	var result []ComplianceSummary
+	for _, o := range occurrences {
+		key, label := keyFn(o)
+		i, ok := index[key]
+		if !ok {
+			i = len(result)
+			index[key] = i
+			result = append(result, ComplianceSummary{Key: key, Label: label})
+		}
+		result[i].add(o.Status)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Label < result[j].Label })
+	return result
+}
+
+func complianceGroupKey(group string, o ComplianceOccurrence) (string, string) {
+	switch group {
+	case "maintenance":
+		if o.Schedule.MaintenanceID == nil {
+			return "", "(No maintenance)"
+		}
+		return o.Schedule.MaintenanceID.Hex(), o.MaintenanceLabel
+	case "type":
+		return o.Schedule.SheduleType, o.Schedule.SheduleType
+	default:
+		return o.Schedule.AssetID.Hex(), o.AssetLabel
+	}
+}
+
+// Compliance report of preventive maintenance over a date range
+func complianceReport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	today := truncateDay(time.Now())
+	to := today.AddDate(0, 0, 1)
+	if s := q.Get("to"); s != "" {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			http.Error(w, "Invalid to date", http.StatusBadRequest)
+			return
+		}
+		to = d.AddDate(0, 0, 1)
+	}
+
+	from := to.AddDate(0, 0, -90)
+	if s := q.Get("from"); s != "" {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			http.Error(w, "Invalid from date", http.StatusBadRequest)
+			return
+		}
+		from = d
+	}
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	toleranceDays := defaultToleranceDays
+	if s := q.Get("tolerance"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid tolerance", http.StatusBadRequest)
+			return
+		}
+		toleranceDays = n
+	}
+
+	var filter ScheduleFilter
+	assetID := q.Get("asset_id")
+	if assetID != "" {
+		objAssetID, err := primitive.ObjectIDFromHex(assetID)
+		if err != nil {
+			http.Error(w, "Invalid asset_id", http.StatusBadRequest)
+			return
+		}
+		filter.AssetIDs = []primitive.ObjectID{objAssetID}
+	}
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	schedules, err := repo.Schedules.Find(ctx, filter)
+	if err != nil {
+		http.Error(w, "Failed to fetch schedules: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	occurrences, err := buildComplianceOccurrences(ctx, schedules, from, to, time.Duration(toleranceDays)*24*time.Hour)
+	if err != nil {
+		http.Error(w, "Failed to fetch completions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Resolve asset and maintenance labels once per id
+	maintenances, err := repo.Maintenances.List(ctx)
+	if err != nil {
+		http.Error(w, "Failed to fetch maintenances: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	maintMap := map[primitive.ObjectID]string{}
+	for _, m := range maintenances {
+		maintMap[m.ID] = m.Lable
+	}
+
+	var assetIDs []primitive.ObjectID
+	for _, o := range occurrences {
+		assetIDs = append(assetIDs, o.Schedule.AssetID)
+	}
+	var warnings incomplete
+	assetLabels, err := getAssetLabels(ctx, assetIDs)
+	warnings.add(err)
+	for i := range occurrences {
+		o := &occurrences[i]
+		o.AssetLabel = assetLabels[o.Schedule.AssetID.Hex()]
+		if o.Schedule.MaintenanceID != nil {
+			o.MaintenanceLabel = maintMap[*o.Schedule.MaintenanceID]
+		}
+	}
+
+	// Drill down into a single group when one is selected
+	group := q.Get("group")
+	key := q.Get("key")
+	var details []ComplianceOccurrence
+	if group != "" {
+		for _, o := range occurrences {
+			if k, _ := complianceGroupKey(group, o); k == key {
+				details = append(details, o)
+			}
+		}
+	}
+
+	overall := ComplianceSummary{Label: "All"}
+	for _, o := range occurrences {
+		overall.add(o.Status)
+	}
+
+	data := struct {
+		From          string
+		To            string
+		Tolerance     int
+		AssetID       string
+		Overall       ComplianceSummary
+		ByAsset       []ComplianceSummary
+		ByMaintenance []ComplianceSummary
+		ByType        []ComplianceSummary
+		Group         string
+		Key           string
+		Details       []ComplianceOccurrence
+		Warnings      []string
+	}{
+		From:          from.Format("2006-01-02"),
+		To:            to.AddDate(0, 0, -1).Format("2006-01-02"),
+		Tolerance:     toleranceDays,
+		AssetID:       assetID,
+		Overall:       overall,
+		ByAsset:       summarize(occurrences, func(o ComplianceOccurrence) (string, string) { return complianceGroupKey("asset", o) }),
+		ByMaintenance: summarize(occurrences, func(o ComplianceOccurrence) (string, string) { return complianceGroupKey("maintenance", o) }),
+		ByType:        summarize(occurrences, func(o ComplianceOccurrence) (string, string) { return complianceGroupKey("type", o) }),
+		Group:         group,
+		Key:           key,
+		Details:       details,
+		Warnings:      warnings,
+	}
+
+	renderTemplate(w, "compliance.html", data)
+}
+
+// Mark the occurrence of a schedule as done
+func completeSchedule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	objSchedule, err := primitive.ObjectIDFromHex(r.FormValue("schedule_id"))
+	if err != nil {
+		http.Error(w, "Invalid Schedule ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	sched, err := repo.Schedules.Get(ctx, objSchedule)
+	if err != nil {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+
+	f, err := validate.Parse(r, "complete-"+sched.ID.Hex())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	completedAt := time.Now()
+	if t := f.OptionalTime("completed_at", "2006-01-02"); t != nil {
+		completedAt = *t
+	}
+	dueDate := f.OptionalTime("due_date", "2006-01-02")
+	f.MaxLength("notes", 2000)
+	if !f.Valid() {
+		showForm(w, r, f, func(w http.ResponseWriter, r *http.Request, f *validate.Form) {
+			renderSchedules(w, r, sched.AssetID, f)
+		})
+		return
+	}
+
+	// The due date is snapped to the closest occurrence so it matches the report
+	due := nearestOccurrence(sched, completedAt)
+	if dueDate != nil {
+		due = nearestOccurrence(sched, *dueDate)
+	}
+
+	completion := ScheduleCompletion{
+		ID:          primitive.NewObjectID(),
+		ScheduleID:  sched.ID,
+		AssetID:     sched.AssetID,
+		DueDate:     due,
+		CompletedAt: completedAt,
+		Notes:       f.Get("notes"),
+	}
+
+	// Completing an occurrence again replaces the earlier completion
+	action := audit.ActionCreate
+	var before interface{}
+	if existing, err := repo.Completions.Get(ctx, sched.ID, due); err == nil {
+		completion.ID = existing.ID
+		action, before = audit.ActionUpdate, existing
+	}
+
+	_, err = changeSchedule(ctx, r, sched.ID, func(ctx context.Context) error {
+		return repo.Completions.Save(ctx, completion)
+	})
+	if err != nil {
+		http.Error(w, "Insert error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordAudit(ctx, r, action, "schedule_completion", completion.ID, sched.Lable+" due "+due.Format("2006-01-02"), before, completion)
+	publishEvent(ctx, webhook.ScheduleCompleted, completion)
+
+	flash.Redirect(w, r, "/schedules?asset_id="+sched.AssetID.Hex(), flash.Success, "Schedule due "+due.Format("2006-01-02")+" marked as done")
+}
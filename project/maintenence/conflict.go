@@ -0,0 +1,65 @@
+package maintenence
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"shared/conflict"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// showMaintenanceConflict answers an edit, mine, rejected because someone
+// else saved the maintenance since: the conflict page showing both versions,
+// or the current one as JSON for an API call
+func showMaintenanceConflict(w http.ResponseWriter, r *http.Request, mine, current MainteneceShedule) {
+	page := conflict.NewPage("Maintenance "+current.Lable, "/maintenances?asset_id="+current.AssetID.Hex(), "/maintenances/edit", r.PostForm, current.Version,
+		conflict.Field{Label: "Label", Mine: mine.Lable, Current: current.Lable},
+	)
+	writeConflict(w, r, page, current, current.Version)
+}
+
+// showScheduleConflict is showMaintenanceConflict for schedules; services and
+// consumables are shown by their labels
+func showScheduleConflict(ctx context.Context, w http.ResponseWriter, r *http.Request, mine, current ScheduleDoc) {
+	svcIDs, consIDs := collectScheduleIDs([]Shedule{
+		{Services: mine.Services, Consumables: mine.Consumables},
+		{Services: current.Services, Consumables: current.Consumables},
+	})
+	svcNames, consNames, err := buildNameMaps(ctx, svcIDs, consIDs)
+	if err != nil {
+		log.Printf("conflict page of schedule %s: %v", current.ID.Hex(), err)
+	}
+
+	page := conflict.NewPage("Schedule "+current.Lable, "/schedules?asset_id="+current.AssetID.Hex(), "/schedules/edit", r.PostForm, current.Version,
+		conflict.Field{Label: "Label", Mine: mine.Lable, Current: current.Lable},
+		conflict.Field{Label: "Schedule Type", Mine: mine.SheduleType, Current: current.SheduleType},
+		conflict.Field{Label: "Days", Mine: strconv.Itoa(mine.Days), Current: strconv.Itoa(current.Days)},
+		conflict.Field{Label: "Services", Mine: names(mine.Services, svcNames), Current: names(current.Services, svcNames)},
+		conflict.Field{Label: "Consumables", Mine: names(mine.Consumables, consNames), Current: names(current.Consumables, consNames)},
+		conflict.Field{Label: "Notes", Mine: mine.Notes, Current: current.Notes},
+	)
+	writeConflict(w, r, page, current, current.Version)
+}
+
+// writeConflict writes the conflict page p of current at version
+func writeConflict(w http.ResponseWriter, r *http.Request, p conflict.Page, current interface{}, version int64) {
+	err := conflict.Write(w, r, p, current, version, func(w http.ResponseWriter, p conflict.Page) error {
+		return templates.ExecuteTemplate(w, "conflict.html", p)
+	})
+	if err != nil {
+		log.Printf("error rendering the conflict page: %v", err)
+	}
+}
+
+// names lists the labels of ids in the order given, from a map of
+// buildNameMaps
+func names(ids []primitive.ObjectID, labels map[string]string) string {
+	list := make([]string, len(ids))
+	for i, id := range ids {
+		list[i] = labels[id.Hex()]
+	}
+	return strings.Join(list, ", ")
+}
@@ -0,0 +1,432 @@
+package maintenence
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"shared/audit"
+	"shared/csrf"
+	"shared/references"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of inconsistencies found by checkConsistency
+const (
+	issueMissingAsset        = "missing_asset"
+	issueMissingService      = "missing_service"
+	issueMissingConsumable   = "missing_consumable"
+	issueMissingMaintenance  = "missing_maintenance"
+	issueMaintenanceMismatch = "maintenance_mismatch"
+	issueDuplicateShedule    = "duplicate_shedule"
+	issueInRecycleBin        = "in_recycle_bin"
+)
+
+// ConsistencyIssue is one broken reference or duplicate found in a
+// maintenance or schedule, with the repair that fixes it
+type ConsistencyIssue struct {
+	Kind     string             `json:"kind"`
+	Entity   string             `json:"entity"`
+	ID       primitive.ObjectID `json:"id"`
+	Label    string             `json:"label"`
+	AssetID  primitive.ObjectID `json:"asset_id"`
+	Detail   string             `json:"detail"`
+	Repair   string             `json:"repair"`
+	Repaired bool               `json:"repaired"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// ConsistencyReport is the outcome of one run of the checker
+type ConsistencyReport struct {
+	CheckedAt    time.Time          `json:"checked_at"`
+	Repair       bool               `json:"repair"`
+	Maintenances int                `json:"maintenances"`
+	Schedules    int                `json:"schedules"`
+	Issues       []ConsistencyIssue `json:"issues"`
+	Skipped      []string           `json:"skipped,omitempty"`
+}
+
+// repairPlan collects the changes to one record so it is written once even
+// when several issues were found in it
+type repairPlan struct {
+	ref    references.Reference
+	trash  bool
+	set    bson.M
+	issues []int
+}
+
+// idSet holds the ids of the records of another service: true for a live
+// record, false for one in the recycle bin, which can still be restored and
+// is left alone. It is nil when the service could not be reached, in which
+// case nothing is reported missing.
+type idSet map[primitive.ObjectID]bool
+
+// newIDSet returns the ids of the live records and those in the recycle bin
+func newIDSet[T any](live, binned []T, id func(T) primitive.ObjectID) idSet {
+	s := idSet{}
+	for _, v := range binned {
+		s[id(v)] = false
+	}
+	for _, v := range live {
+		s[id(v)] = true
+	}
+	return s
+}
+
+// gone reports whether the record id exists neither live nor in the
+// recycle bin
+func (s idSet) gone(id primitive.ObjectID) bool {
+	if s == nil {
+		return false
+	}
+	_, ok := s[id]
+	return !ok
+}
+
+// binned reports whether the record id is in the recycle bin
+func (s idSet) binned(id primitive.ObjectID) bool {
+	live, ok := s[id]
+	return ok && !live
+}
+
+func (s idSet) missing(ids []primitive.ObjectID) []primitive.ObjectID {
+	var out []primitive.ObjectID
+	for _, id := range ids {
+		if s.gone(id) {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func (s idSet) inRecycleBin(ids []primitive.ObjectID) []primitive.ObjectID {
+	var out []primitive.ObjectID
+	for _, id := range ids {
+		if s.binned(id) {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// without returns ids minus the ones gone from s
+func (s idSet) without(ids []primitive.ObjectID) []primitive.ObjectID {
+	out := []primitive.ObjectID{}
+	for _, id := range ids {
+		if !s.gone(id) {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// checkConsistency scans the live maintenances and schedules for references
+// to assets, services, consumables and maintenances that no longer exist,
+// schedules filed under a maintenance of another asset and duplicated
+// embedded schedules. With repair set the issues are fixed on behalf of the
+// user of r: records of missing assets go to the recycle bin, missing
+// services and consumables are dropped, a schedule follows its maintenance's
+// asset (or loses a missing maintenance) and duplicates are removed.
+// References to records in the recycle bin are reported but never repaired,
+// since the records can still be restored.
+func checkConsistency(ctx context.Context, r *http.Request, repair bool) (ConsistencyReport, error) {
+	report := ConsistencyReport{CheckedAt: time.Now(), Repair: repair, Issues: []ConsistencyIssue{}}
+
+	maintenances, err := repo.Maintenances.List(ctx)
+	if err != nil {
+		return report, err
+	}
+	schedules, err := repo.Schedules.List(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	report.Maintenances, report.Schedules = len(maintenances), len(schedules)
+
+	// Records of the other services, fetched afresh so a record created in the
+	// last seconds is not taken for missing; a service that cannot be reached
+	// skips its checks instead of reporting every reference as missing
+	lookups.invalidate("")
+	var assets, services, consumables idSet
+	if live, binned, err := fetchBoth(ctx, func(ctx context.Context) ([]Asset, error) { return fetchAssetsFromAPI(ctx, "", "") }, fetchDeletedAssets); err != nil {
+		report.Skipped = append(report.Skipped, "asset checks: "+err.Error())
+	} else {
+		assets = newIDSet(live, binned, func(a Asset) primitive.ObjectID { return a.ID })
+	}
+	if live, binned, err := fetchBoth(ctx, fetchServicesFromAPI, fetchDeletedServices); err != nil {
+		report.Skipped = append(report.Skipped, "service checks: "+err.Error())
+	} else {
+		services = newIDSet(live, binned, func(s Service) primitive.ObjectID { return s.ID })
+	}
+	if live, binned, err := fetchBoth(ctx, fetchConsumablesFromAPI, fetchDeletedConsumables); err != nil {
+		report.Skipped = append(report.Skipped, "consumable checks: "+err.Error())
+	} else {
+		consumables = newIDSet(live, binned, func(c Consumable) primitive.ObjectID { return c.ID })
+	}
+	deletedMaintenances, err := repo.Maintenances.ListDeleted(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	var plans []*repairPlan
+	// note reports an issue of the record of plan that is left as it is
+	note := func(plan *repairPlan, kind, detail, fix string) {
+		report.Issues = append(report.Issues, ConsistencyIssue{
+			Kind:    kind,
+			Entity:  plan.ref.Entity,
+			ID:      plan.ref.ID,
+			Label:   plan.ref.Label,
+			AssetID: plan.ref.AssetID,
+			Detail:  detail,
+			Repair:  fix,
+		})
+	}
+	add := func(plan *repairPlan, kind, detail, fix string) {
+		plan.issues = append(plan.issues, len(report.Issues))
+		note(plan, kind, detail, fix)
+	}
+	// binned notes the references of plan to records in the recycle bin
+	binned := func(plan *repairPlan, user, what string, ids []primitive.ObjectID) {
+		if len(ids) > 0 {
+			note(plan, issueInRecycleBin, user+"uses "+what+" "+hexList(ids)+" from the recycle bin", "none: restore them or remove them from the schedule")
+		}
+	}
+
+	maintenanceByID := map[primitive.ObjectID]MainteneceShedule{}
+	deletedMaintenanceIDs := idSet{}
+	for _, m := range deletedMaintenances {
+		deletedMaintenanceIDs[m.ID] = false
+	}
+	for _, m := range maintenances {
+		maintenanceByID[m.ID] = m
+		plan := &repairPlan{ref: references.Reference{Entity: references.KindMaintenance, ID: m.ID, Label: m.Lable, AssetID: m.AssetID}, set: bson.M{}}
+
+		switch {
+		case assets.gone(m.AssetID):
+			add(plan, issueMissingAsset, "asset "+m.AssetID.Hex()+" does not exist", "move the maintenance to the recycle bin")
+			plan.trash = true
+		case assets.binned(m.AssetID):
+			note(plan, issueInRecycleBin, "asset "+m.AssetID.Hex()+" is in the recycle bin", "none: restore the asset or delete the maintenance")
+		}
+
+		// Embedded schedules: keep the first of each id, then drop missing ids
+		seen := map[primitive.ObjectID]bool{}
+		fixed := []Shedule{}
+		changed := false
+		for _, s := range m.Shedules {
+			if seen[s.ID] {
+				add(plan, issueDuplicateShedule, "embedded schedule "+s.Lable+" ("+s.ID.Hex()+") appears more than once", "keep the first copy")
+				changed = true
+				continue
+			}
+			seen[s.ID] = true
+			if ids := services.missing(s.Services); len(ids) > 0 {
+				add(plan, issueMissingService, "embedded schedule "+s.Lable+" uses missing services "+hexList(ids), "remove them from the schedule")
+				s.Services = services.without(s.Services)
+				changed = true
+			}
+			if ids := consumables.missing(s.Consumables); len(ids) > 0 {
+				add(plan, issueMissingConsumable, "embedded schedule "+s.Lable+" uses missing consumables "+hexList(ids), "remove them from the schedule")
+				s.Consumables = consumables.without(s.Consumables)
+				changed = true
+			}
+			binned(plan, "embedded schedule "+s.Lable+" ", "services", services.inRecycleBin(s.Services))
+			binned(plan, "embedded schedule "+s.Lable+" ", "consumables", consumables.inRecycleBin(s.Consumables))
+			fixed = append(fixed, s)
+		}
+		if changed {
+			plan.set["shedules"] = fixed
+		}
+
+		if len(plan.issues) > 0 {
+			plans = append(plans, plan)
+		}
+	}
+
+	for _, s := range schedules {
+		plan := &repairPlan{ref: references.Reference{Entity: "schedule", ID: s.ID, Label: s.Lable, AssetID: s.AssetID}, set: bson.M{}}
+
+		// A schedule following its maintenance to another asset is kept even
+		// when its own asset is gone
+		assetID := s.AssetID
+		if s.MaintenanceID != nil {
+			m, ok := maintenanceByID[*s.MaintenanceID]
+			switch {
+			case !ok && deletedMaintenanceIDs.binned(*s.MaintenanceID):
+				note(plan, issueInRecycleBin, "maintenance "+s.MaintenanceID.Hex()+" is in the recycle bin", "none: restore the maintenance or detach the schedule")
+			case !ok:
+				add(plan, issueMissingMaintenance, "maintenance "+s.MaintenanceID.Hex()+" does not exist", "detach the schedule from the maintenance")
+				plan.set["maintenance_id"] = nil
+			case m.AssetID != s.AssetID:
+				add(plan, issueMaintenanceMismatch, "maintenance "+m.Lable+" belongs to asset "+m.AssetID.Hex()+", the schedule to asset "+s.AssetID.Hex(), "move the schedule to the maintenance's asset")
+				plan.set["asset_id"] = m.AssetID
+				assetID = m.AssetID
+			}
+		}
+
+		switch {
+		case assets.gone(assetID):
+			add(plan, issueMissingAsset, "asset "+assetID.Hex()+" does not exist", "move the schedule to the recycle bin")
+			plan.trash = true
+		case assets.binned(assetID):
+			note(plan, issueInRecycleBin, "asset "+assetID.Hex()+" is in the recycle bin", "none: restore the asset or delete the schedule")
+		}
+
+		if ids := services.missing(s.Services); len(ids) > 0 {
+			add(plan, issueMissingService, "uses missing services "+hexList(ids), "remove them from the schedule")
+			plan.set["services"] = services.without(s.Services)
+		}
+		if ids := consumables.missing(s.Consumables); len(ids) > 0 {
+			add(plan, issueMissingConsumable, "uses missing consumables "+hexList(ids), "remove them from the schedule")
+			plan.set["consumables"] = consumables.without(s.Consumables)
+		}
+		binned(plan, "", "services", services.inRecycleBin(s.Services))
+		binned(plan, "", "consumables", consumables.inRecycleBin(s.Consumables))
+
+		if len(plan.issues) > 0 {
+			plans = append(plans, plan)
+		}
+	}
+
+	if !repair {
+		return report, nil
+	}
+
+	for _, plan := range plans {
+		var err error
+		if plan.trash {
+			err = cascadeDelete(ctx, r, plan.ref)
+		} else {
+			err = updateRecord(ctx, r, plan.ref, plan.set)
+		}
+		for _, i := range plan.issues {
+			if err != nil {
+				report.Issues[i].Error = err.Error()
+			} else {
+				report.Issues[i].Repaired = true
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// fetchBoth fetches the live records of a service and those in its recycle
+// bin
+func fetchBoth[T any](ctx context.Context, live, binned func(context.Context) ([]T, error)) ([]T, []T, error) {
+	l, err := live(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := binned(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, b, nil
+}
+
+func hexList(ids []primitive.ObjectID) string {
+	hex := make([]string, len(ids))
+	for i, id := range ids {
+		hex[i] = id.Hex()
+	}
+	return strings.Join(hex, ", ")
+}
+
+// Consistency report page; POSTing to /consistency/repair fixes the issues
+func consistencyReport(w http.ResponseWriter, r *http.Request) {
+	repair := r.URL.Path == "/consistency/repair"
+	if repair && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	report, err := checkConsistency(ctx, r, repair)
+	if err != nil {
+		http.Error(w, "Consistency check failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	renderTemplate(w, "consistency.html", struct {
+		ConsistencyReport
+		CSRF template.HTML
+	}{report, csrf.Field(r)})
+}
+
+// runCheckCommand implements `cmms check [-repair] [-json] [-user NAME]`,
+// printing the consistency report and exiting non-zero when issues remain
+func runCheckCommand(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	repair := fs.Bool("repair", false, "fix the issues found")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	user := fs.String("user", "consistency-check", "user recorded in the audit trail for repairs")
+	fs.Parse(args)
+
+	// Repairs are audited like any other change, under the given user
+	r, _ := http.NewRequestWithContext(audit.WithActor(ctx, *user), http.MethodPost, "/consistency/repair", nil)
+
+	report, err := checkConsistency(ctx, r, *repair)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "consistency check failed:", err)
+		return 2
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		printConsistencyReport(os.Stdout, report)
+	}
+
+	for _, issue := range report.Issues {
+		if !issue.Repaired {
+			return 1
+		}
+	}
+	return 0
+}
+
+func printConsistencyReport(out io.Writer, report ConsistencyReport) {
+	fmt.Fprintf(out, "Checked %d maintenances and %d schedules at %s\n", report.Maintenances, report.Schedules, report.CheckedAt.Format("2006-01-02 15:04:05"))
+	for _, s := range report.Skipped {
+		fmt.Fprintln(out, "Skipped", s)
+	}
+	if len(report.Issues) == 0 {
+		fmt.Fprintln(out, "No issues found")
+		return
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tENTITY\tID\tLABEL\tDETAIL\tREPAIR\tSTATUS")
+	for _, issue := range report.Issues {
+		status := "found"
+		switch {
+		case issue.Error != "":
+			status = "failed: " + issue.Error
+		case issue.Repaired:
+			status = "repaired"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", issue.Kind, issue.Entity, issue.ID.Hex(), issue.Label, issue.Detail, issue.Repair, status)
+	}
+	tw.Flush()
+	fmt.Fprintf(out, "%d issue(s)\n", len(report.Issues))
+}
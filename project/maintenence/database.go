@@ -1,41 +1,319 @@
-package main
+package maintenence
 
 import (
 	"context"
 	"fmt"
-	"log"
+	"shared/store"
+	"shared/trash"
+	"shared/webhook"
+	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var serviceCollection *mongo.Collection
-var consumableCollection *mongo.Collection
-var schedulesCollection *mongo.Collection
+// mongoMigrations create the indexes of the maintenance service and reshape
+// its documents
+var mongoMigrations = []store.MongoMigration{
+	{Version: 1, Name: "maintenances, schedules and completions indexes", Up: store.Steps(
+		store.Indexes("maintenances",
+			mongo.IndexModel{Keys: store.Keys("deleted_at")},
+			mongo.IndexModel{Keys: store.Keys("asset_id")},
+			mongo.IndexModel{Keys: store.Keys("label")},
+			mongo.IndexModel{Keys: store.Keys("shedules.services")},
+			mongo.IndexModel{Keys: store.Keys("shedules.consumables")},
+		),
+		store.Indexes("schedules",
+			mongo.IndexModel{Keys: store.Keys("deleted_at")},
+			mongo.IndexModel{Keys: store.Keys("asset_id")},
+			mongo.IndexModel{Keys: store.Keys("maintenance_id")},
+			mongo.IndexModel{Keys: store.Keys("label")},
+			mongo.IndexModel{Keys: store.Keys("services")},
+			mongo.IndexModel{Keys: store.Keys("consumables")},
+		),
+		store.Indexes("schedule_completions",
+			mongo.IndexModel{Keys: store.Keys("schedule_id", "due_date"), Options: options.Index().SetUnique(true)},
+		),
+		store.Indexes("notification_log", mongo.IndexModel{Keys: store.Keys("sent_at")}),
+	)},
+	{Version: 2, Name: "rename shedule_type to schedule_type", Up: renameScheduleType},
+}
 
-func NewDB(ctx context.Context) (*mongo.Database, *mongo.Client, error) {
-	mongoURI := "mongodb://localhost:27017"
-	dbName := "CMMS"
+// renameScheduleType moves the type of the schedules, standalone and
+// embedded in maintenances, from shedule_type to schedule_type
+func renameScheduleType(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("schedules").UpdateMany(ctx,
+		bson.M{"shedule_type": bson.M{"$exists": true}},
+		bson.M{"$rename": bson.M{"shedule_type": "schedule_type"}})
+	if err != nil {
+		return fmt.Errorf("schedules: %w", err)
+	}
+	_, err = db.Collection("maintenances").UpdateMany(ctx,
+		bson.M{"shedules.shedule_type": bson.M{"$exists": true}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"shedules": bson.M{"$map": bson.M{
+				"input": "$shedules",
+				"in":    bson.M{"$mergeObjects": bson.A{"$$this", bson.M{"schedule_type": "$$this.shedule_type"}}},
+			}}}}},
+			{{Key: "$unset", Value: "shedules.shedule_type"}},
+		})
+	if err != nil {
+		return fmt.Errorf("maintenances: %w", err)
+	}
+	return nil
+}
 
-	clientOptions := options.Client().ApplyURI(mongoURI)
+// MigrateMongo creates or updates the indexes and documents of the
+// maintenance service and its webhooks in db
+func MigrateMongo(ctx context.Context, db *mongo.Database) error {
+	if err := store.MigrateMongo(ctx, db, "maintenance", mongoMigrations); err != nil {
+		return err
+	}
+	return webhook.MigrateMongo(ctx, db)
+}
 
-	client, err := mongo.Connect(ctx, clientOptions)
+// findAll decodes the documents of coll matching filter
+func findAll[T any](ctx context.Context, coll *mongo.Collection, filter bson.M) ([]T, error) {
+	result := []T{}
+	cur, err := coll.Find(ctx, filter)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error creating server connection: %v", err)
+		return nil, err
 	}
+	defer cur.Close(ctx)
 
-	err = client.Ping(ctx, nil)
-	if err != nil {
-		return nil, nil, fmt.Errorf("error pinging server: %v", err)
+	if err := cur.All(ctx, &result); err != nil {
+		return nil, err
 	}
+	return result, nil
+}
+
+// findOne decodes the document of coll matching filter
+func findOne[T any](ctx context.Context, coll *mongo.Collection, filter bson.M) (T, error) {
+	var result T
+	err := coll.FindOne(ctx, filter).Decode(&result)
+	return result, store.MongoErr(err)
+}
+
+// updateLive sets the fields in set on the live document with the given id
+// when it is still at version, and moves it to the next version
+func updateLive(ctx context.Context, coll *mongo.Collection, id primitive.ObjectID, version int64, set bson.M) error {
+	return store.UpdateMongoVersion(ctx, coll, trash.Live(bson.M{"_id": id}), version, set)
+}
+
+// mongoMaintenances is the MaintenanceRepository of the maintenances
+// collection
+type mongoMaintenances struct {
+	coll *mongo.Collection
+}
+
+func (m mongoMaintenances) List(ctx context.Context) ([]MainteneceShedule, error) {
+	return findAll[MainteneceShedule](ctx, m.coll, trash.Live(nil))
+}
+
+func (m mongoMaintenances) ListByAsset(ctx context.Context, assetID primitive.ObjectID) ([]MainteneceShedule, error) {
+	return findAll[MainteneceShedule](ctx, m.coll, trash.Live(bson.M{"asset_id": assetID}))
+}
 
-	db := client.Database(dbName)
-	serviceCollection = db.Collection("services")
-	consumableCollection = db.Collection("consumables")
-	schedulesCollection = db.Collection("schedules")
+func (m mongoMaintenances) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]MainteneceShedule, error) {
+	return findAll[MainteneceShedule](ctx, m.coll, trash.Live(bson.M{"_id": bson.M{"$in": ids}}))
+}
+
+func (m mongoMaintenances) Referencing(ctx context.Context, field string, id primitive.ObjectID) ([]MainteneceShedule, error) {
+	return findAll[MainteneceShedule](ctx, m.coll, trash.Live(bson.M{field: id}))
+}
 
-	log.Println("successfully connected to the database")
+func (m mongoMaintenances) Get(ctx context.Context, id primitive.ObjectID) (MainteneceShedule, error) {
+	return findOne[MainteneceShedule](ctx, m.coll, trash.Live(bson.M{"_id": id}))
+}
+
+func (m mongoMaintenances) Insert(ctx context.Context, item MainteneceShedule) error {
+	_, err := m.coll.InsertOne(ctx, item)
+	return err
+}
+
+func (m mongoMaintenances) Update(ctx context.Context, item MainteneceShedule) error {
+	return updateLive(ctx, m.coll, item.ID, item.Version, bson.M{
+		"label":    item.Lable,
+		"asset_id": item.AssetID,
+		"shedules": item.Shedules,
+	})
+}
+
+func (m mongoMaintenances) Delete(ctx context.Context, id primitive.ObjectID, actor string) error {
+	return store.MongoErr(trash.Delete(ctx, m.coll, id, actor))
+}
+
+func (m mongoMaintenances) ListDeleted(ctx context.Context) ([]MainteneceShedule, error) {
+	result := []MainteneceShedule{}
+	err := trash.List(ctx, m.coll, &result)
+	return result, err
+}
+
+func (m mongoMaintenances) GetDeleted(ctx context.Context, id primitive.ObjectID) (MainteneceShedule, error) {
+	return findOne[MainteneceShedule](ctx, m.coll, trash.Deleted(bson.M{"_id": id}))
+}
+
+func (m mongoMaintenances) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return store.MongoErr(trash.Restore(ctx, m.coll, id))
+}
+
+func (m mongoMaintenances) Purge(ctx context.Context, id primitive.ObjectID) error {
+	return store.MongoErr(trash.Purge(ctx, m.coll, id))
+}
+
+func (m mongoMaintenances) PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	return trash.PurgeExpired(ctx, m.coll, retention)
+}
+
+// mongoSchedules is the ScheduleRepository of the schedules collection
+type mongoSchedules struct {
+	coll *mongo.Collection
+}
+
+func (m mongoSchedules) List(ctx context.Context) ([]ScheduleDoc, error) {
+	return findAll[ScheduleDoc](ctx, m.coll, trash.Live(nil))
+}
+
+func (m mongoSchedules) Find(ctx context.Context, f ScheduleFilter) ([]ScheduleDoc, error) {
+	filter := bson.M{}
+	if f.AssetIDs != nil {
+		filter["asset_id"] = bson.M{"$in": f.AssetIDs}
+	}
+	if f.MaintenanceID != nil {
+		filter["maintenance_id"] = *f.MaintenanceID
+	}
+	if f.ServiceID != nil {
+		filter["services"] = *f.ServiceID
+	}
+	return findAll[ScheduleDoc](ctx, m.coll, trash.Live(filter))
+}
+
+func (m mongoSchedules) Referencing(ctx context.Context, field string, id primitive.ObjectID) ([]ScheduleDoc, error) {
+	return findAll[ScheduleDoc](ctx, m.coll, trash.Live(bson.M{field: id}))
+}
+
+func (m mongoSchedules) Get(ctx context.Context, id primitive.ObjectID) (ScheduleDoc, error) {
+	return findOne[ScheduleDoc](ctx, m.coll, trash.Live(bson.M{"_id": id}))
+}
+
+func (m mongoSchedules) GetAny(ctx context.Context, id primitive.ObjectID) (ScheduleDoc, error) {
+	return findOne[ScheduleDoc](ctx, m.coll, bson.M{"_id": id})
+}
+
+func (m mongoSchedules) Insert(ctx context.Context, s ScheduleDoc) error {
+	_, err := m.coll.InsertOne(ctx, s)
+	return err
+}
+
+func (m mongoSchedules) Update(ctx context.Context, s ScheduleDoc) error {
+	return updateLive(ctx, m.coll, s.ID, s.Version, bson.M{
+		"maintenance_id": s.MaintenanceID,
+		"asset_id":       s.AssetID,
+		"label":          s.Lable,
+		"schedule_type":  s.SheduleType,
+		"days":           s.Days,
+		"services":       s.Services,
+		"consumables":    s.Consumables,
+		"notes":          s.Notes,
+	})
+}
+
+func (m mongoSchedules) Delete(ctx context.Context, id primitive.ObjectID, actor string) error {
+	return store.MongoErr(trash.Delete(ctx, m.coll, id, actor))
+}
+
+func (m mongoSchedules) ListDeleted(ctx context.Context) ([]ScheduleDoc, error) {
+	result := []ScheduleDoc{}
+	err := trash.List(ctx, m.coll, &result)
+	return result, err
+}
+
+func (m mongoSchedules) GetDeleted(ctx context.Context, id primitive.ObjectID) (ScheduleDoc, error) {
+	return findOne[ScheduleDoc](ctx, m.coll, trash.Deleted(bson.M{"_id": id}))
+}
+
+func (m mongoSchedules) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return store.MongoErr(trash.Restore(ctx, m.coll, id))
+}
+
+func (m mongoSchedules) Purge(ctx context.Context, id primitive.ObjectID) error {
+	return store.MongoErr(trash.Purge(ctx, m.coll, id))
+}
+
+func (m mongoSchedules) PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	return trash.PurgeExpired(ctx, m.coll, retention)
+}
+
+// mongoCompletions is the CompletionRepository of the schedule_completions
+// collection
+type mongoCompletions struct {
+	coll *mongo.Collection
+}
+
+func (m mongoCompletions) List(ctx context.Context, scheduleIDs []primitive.ObjectID, from, to time.Time) ([]ScheduleCompletion, error) {
+	return findAll[ScheduleCompletion](ctx, m.coll, bson.M{
+		"schedule_id": bson.M{"$in": scheduleIDs},
+		"due_date":    bson.M{"$gte": from, "$lt": to},
+	})
+}
+
+func (m mongoCompletions) Get(ctx context.Context, scheduleID primitive.ObjectID, due time.Time) (ScheduleCompletion, error) {
+	return findOne[ScheduleCompletion](ctx, m.coll, bson.M{"schedule_id": scheduleID, "due_date": due})
+}
+
+func (m mongoCompletions) Save(ctx context.Context, c ScheduleCompletion) error {
+	_, err := m.coll.UpdateOne(ctx,
+		bson.M{"schedule_id": c.ScheduleID, "due_date": c.DueDate},
+		bson.M{
+			"$set": bson.M{
+				"asset_id":     c.AssetID,
+				"completed_at": c.CompletedAt,
+				"notes":        c.Notes,
+			},
+			"$setOnInsert": bson.M{"_id": c.ID},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// mongoSubscriptions is the SubscriptionRepository of the
+// notification_subscriptions collection
+type mongoSubscriptions struct {
+	coll *mongo.Collection
+}
+
+func (m mongoSubscriptions) List(ctx context.Context) ([]NotificationSubscription, error) {
+	return findAll[NotificationSubscription](ctx, m.coll, bson.M{})
+}
+
+func (m mongoSubscriptions) Insert(ctx context.Context, sub NotificationSubscription) error {
+	_, err := m.coll.InsertOne(ctx, sub)
+	return err
+}
+
+func (m mongoSubscriptions) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := m.coll.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// mongoNotificationLog is the NotificationLogRepository of the
+// notification_log collection, where the unique id rejects a second claim
+type mongoNotificationLog struct {
+	coll *mongo.Collection
+}
+
+func (m mongoNotificationLog) Claim(ctx context.Context, e NotificationLog) (bool, error) {
+	_, err := m.coll.InsertOne(ctx, e)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
 
-	return db, client, nil
+func (m mongoNotificationLog) Release(ctx context.Context, ids []string) error {
+	_, err := m.coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	return err
 }
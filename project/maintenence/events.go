@@ -0,0 +1,69 @@
+package maintenence
+
+import (
+	"context"
+	"net/http"
+	"shared/audit"
+	"shared/events"
+	"shared/references"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// consumerName is the name the maintenance service subscribes to the domain
+// events under
+const consumerName = "maintenance"
+
+// changeSchedule runs change on the schedule with the given id and adds
+// ScheduleChanged with the schedule as it is afterwards to the outbox, in one
+// transaction. It returns the changed schedule, found even when change moved
+// it to the recycle bin.
+func changeSchedule(ctx context.Context, r *http.Request, id primitive.ObjectID, change func(ctx context.Context) error) (ScheduleDoc, error) {
+	var sched ScheduleDoc
+	err := repo.Tx.Atomically(ctx, func(ctx context.Context) error {
+		if err := change(ctx); err != nil {
+			return err
+		}
+		var err error
+		if sched, err = repo.Schedules.GetAny(ctx, id); err != nil {
+			return err
+		}
+		return repo.Outbox.Record(ctx, "maintenance", events.ScheduleChanged, id, audit.Actor(r), sched)
+	})
+	return sched, err
+}
+
+// ConsumeEvents handles the domain events of the other services from t until
+// ctx is done: the maintenances and schedules of a deleted asset go to the
+// recycle bin and a deleted service or consumable is taken out of the
+// schedules, as the consistency check would repair them. Cached lookups of
+// the kind of record changed are dropped either way.
+func ConsumeEvents(ctx context.Context, t events.Transport) {
+	go events.Consume(ctx, t, ledger, consumerName, handleEvent)
+}
+
+// handleEvent reacts to one domain event, auditing what it changes under the
+// actor of the event
+func handleEvent(ctx context.Context, e events.Event) error {
+	var kind string
+	switch e.Type {
+	case events.AssetCreated, events.AssetUpdated:
+		lookups.invalidate(assetKey)
+		return nil
+	case events.AssetDeleted:
+		lookups.invalidate(assetKey)
+		kind = references.KindAsset
+	case events.ServiceDeleted:
+		lookups.invalidate(serviceKey)
+		kind = references.KindService
+	case events.ConsumableDeleted:
+		lookups.invalidate(consumableKey)
+		kind = references.KindConsumable
+	default:
+		return nil
+	}
+
+	r, _ := http.NewRequestWithContext(audit.WithActor(ctx, e.Actor), http.MethodPost, "/events/"+e.Type, nil)
+	_, err := resolveReferences(ctx, r, kind, e.EntityID, references.ActionCascade, primitive.NilObjectID)
+	return err
+}
@@ -0,0 +1,112 @@
+package maintenence
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"shared/validate"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// scheduleTypes are the schedule types the forms accept
+var scheduleTypes = []string{ScheduleDaily, ScheduleWeekly, ScheduleMonthly, ScheduleYearly}
+
+// maxScheduleDays bounds the number of units between two occurrences of a
+// schedule
+const maxScheduleDays = 365
+
+// showForm answers a form that did not validate: an API call gets the
+// errors as JSON, and a page is shown again by render with the form open,
+// its input kept and the errors next to the fields
+func showForm(w http.ResponseWriter, r *http.Request, f *validate.Form, render func(http.ResponseWriter, *http.Request, *validate.Form)) {
+	if validate.WantsJSON(r) {
+		validate.WriteErrors(w, f.Errors)
+		return
+	}
+	render(w, r, f)
+}
+
+// pageStatus writes 422 Unprocessable Entity for a page shown again with the
+// form f that did not validate
+func pageStatus(w http.ResponseWriter, f *validate.Form) {
+	if f != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+}
+
+// maintenanceForm checks the input of the create and edit maintenance forms,
+// named name
+func maintenanceForm(r *http.Request, name string) (*validate.Form, error) {
+	f, err := validate.Parse(r, name)
+	if err != nil {
+		return nil, err
+	}
+	f.Required("label")
+	f.MaxLength("label", 200)
+	return f, nil
+}
+
+// scheduleForm checks the input of the add and edit schedule forms, named
+// name, and returns the schedule it describes; the services and consumables
+// must exist
+func scheduleForm(ctx context.Context, r *http.Request, name string) (*validate.Form, ScheduleDoc, error) {
+	f, err := validate.Parse(r, name)
+	if err != nil {
+		return nil, ScheduleDoc{}, err
+	}
+	f.Required("label")
+	f.MaxLength("label", 200)
+	f.MaxLength("notes", 2000)
+	// shedule_type is the old spelling of the field, still accepted
+	if !f.Values.Has("schedule_type") && f.Values.Has("shedule_type") {
+		f.Values.Set("schedule_type", f.Get("shedule_type"))
+	}
+	sched := ScheduleDoc{
+		Lable:       f.Get("label"),
+		SheduleType: f.OneOf("schedule_type", scheduleTypes...),
+		Days:        f.Int("days", 1, maxScheduleDays),
+		Services:    f.IDs("services[]"),
+		Consumables: f.IDs("consumables[]"),
+		Notes:       f.Get("notes"),
+	}
+
+	services, err := fetchServicesByID(ctx, sched.Services)
+	checkFound(f, "services[]", "service", sched.Services, services, func(s Service) primitive.ObjectID { return s.ID }, err)
+	consumables, err := fetchConsumablesByID(ctx, sched.Consumables)
+	checkFound(f, "consumables[]", "consumable", sched.Consumables, consumables, func(c Consumable) primitive.ObjectID { return c.ID }, err)
+	return f, sched, nil
+}
+
+// checkAsset checks that the asset id exists. When the asset service cannot
+// be reached the asset is taken as existing: the consistency report finds it
+// later if it was not.
+func checkAsset(ctx context.Context, f *validate.Form, field string, id primitive.ObjectID) {
+	_, err := fetchAssetFromAPI(ctx, id.Hex())
+	if isNotFound(err) {
+		f.Errors.Add(field, "The asset does not exist")
+	} else if err != nil {
+		log.Printf("could not check asset %s: %v", id.Hex(), err)
+	}
+}
+
+// checkFound checks that every one of ids is among found, the records
+// fetched for them. Like checkAsset, it lets them through when the fetch
+// failed.
+func checkFound[T any](f *validate.Form, field, kind string, ids []primitive.ObjectID, found []T, id func(T) primitive.ObjectID, err error) {
+	if err != nil {
+		log.Printf("could not check %ss: %v", kind, err)
+		return
+	}
+	exists := map[primitive.ObjectID]bool{}
+	for _, v := range found {
+		exists[id(v)] = true
+	}
+	for _, want := range ids {
+		if !exists[want] {
+			f.Errors.Add(field, fmt.Sprintf("A chosen %s no longer exists", kind))
+			return
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package maintenence
+
+import (
+	"context"
+	"shared/metrics"
+	"time"
+)
+
+// registerGauges adds the business gauges of the maintenance service to the
+// metrics, measured on repo at every scrape
+func registerGauges() {
+	metrics.SetGauge("cmms_maintenances", "Live maintenances.", func(ctx context.Context) (float64, error) {
+		list, err := repo.Maintenances.List(ctx)
+		return float64(len(list)), err
+	})
+	metrics.SetGauge("cmms_schedules", "Live schedules.", func(ctx context.Context) (float64, error) {
+		list, err := repo.Schedules.List(ctx)
+		return float64(len(list)), err
+	})
+	metrics.SetGauge("cmms_schedules_overdue", "Schedules with an occurrence missed in the last 30 days, as in the compliance report.", func(ctx context.Context) (float64, error) {
+		schedules, _, err := overdue(ctx)
+		return float64(schedules), err
+	})
+	metrics.SetGauge("cmms_occurrences_overdue", "Occurrences missed in the last 30 days, as in the compliance report.", func(ctx context.Context) (float64, error) {
+		_, occurrences, err := overdue(ctx)
+		return float64(occurrences), err
+	})
+}
+
+// overdue counts the occurrences of the last overdueLookbackDays days still
+// not completed past the default tolerance, and the schedules they are of
+func overdue(ctx context.Context) (schedules, occurrences int, err error) {
+	list, err := repo.Schedules.List(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	to := truncateDay(time.Now()).AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -overdueLookbackDays)
+	all, err := buildComplianceOccurrences(ctx, list, from, to, defaultToleranceDays*24*time.Hour)
+	if err != nil {
+		return 0, 0, err
+	}
+	missed := map[string]bool{}
+	for _, o := range all {
+		if o.Status == StatusMissed {
+			occurrences++
+			missed[o.Schedule.ID.Hex()] = true
+		}
+	}
+	return len(missed), occurrences, nil
+}
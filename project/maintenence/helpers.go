@@ -1,8 +1,10 @@
-package main
+package maintenence
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"shared/flash"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -15,51 +17,46 @@ func renderTemplate(w http.ResponseWriter, tmpl string, data interface{}) {
 	}
 }
 
+// flashMessage returns the flash message left by the handler that redirected
+// to the page and its kind, flash.Success or flash.Error
+func flashMessage(w http.ResponseWriter, r *http.Request) (message, messageType string) {
+	m, _ := flash.Get(w, r)
+	return m.Text, m.Kind
+}
+
 func getCtx() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), 10*time.Second)
 }
 
-func buildNameMaps(ctx context.Context, svcIDs, consIDs []primitive.ObjectID) (map[string]string, map[string]string) {
-	// Fetch all services and consumables
-	allServices, allConsumables := fetchServicesAndConsumables()
-
-	// Create maps for quick lookup
-	serviceMap := make(map[primitive.ObjectID]struct {
-		ID    primitive.ObjectID `bson:"_id"`
-		Label string             `bson:"label"`
-	})
-	for _, svc := range allServices {
-		serviceMap[svc.ID] = svc
-	}
+// buildNameMaps maps service and consumable ids to their labels, fetching
+// only those ids, and falls back to the id for those that could not be fetched
+func buildNameMaps(ctx context.Context, svcIDs, consIDs []primitive.ObjectID) (map[string]string, map[string]string, error) {
+	services, svcErr := fetchServicesByID(ctx, svcIDs)
+	consumables, consErr := fetchConsumablesByID(ctx, consIDs)
 
-	consumableMap := make(map[primitive.ObjectID]struct {
-		ID    primitive.ObjectID `bson:"_id"`
-		Label string             `bson:"label"`
-	})
-	for _, cons := range allConsumables {
-		consumableMap[cons.ID] = cons
+	svcLabels := map[primitive.ObjectID]string{}
+	for _, svc := range services {
+		svcLabels[svc.ID] = svc.Label
 	}
-
-	// Build name maps for the specific IDs we need
-	svcNames := map[string]string{}
-	for _, id := range svcIDs {
-		if svc, exists := serviceMap[id]; exists {
-			svcNames[id.Hex()] = svc.Label
-		} else {
-			svcNames[id.Hex()] = id.Hex()
-		}
+	consLabels := map[primitive.ObjectID]string{}
+	for _, cons := range consumables {
+		consLabels[cons.ID] = cons.Label
 	}
 
-	consNames := map[string]string{}
-	for _, id := range consIDs {
-		if cons, exists := consumableMap[id]; exists {
-			consNames[id.Hex()] = cons.Label
+	return nameMap(svcIDs, svcLabels), nameMap(consIDs, consLabels), errors.Join(svcErr, consErr)
+}
+
+// nameMap maps the hex of every id to its label, or to itself when unknown
+func nameMap(ids []primitive.ObjectID, labels map[primitive.ObjectID]string) map[string]string {
+	names := map[string]string{}
+	for _, id := range ids {
+		if label, ok := labels[id]; ok {
+			names[id.Hex()] = label
 		} else {
-			consNames[id.Hex()] = id.Hex()
+			names[id.Hex()] = id.Hex()
 		}
 	}
-
-	return svcNames, consNames
+	return names
 }
 
 func collectScheduleIDs(schedules []Shedule) ([]primitive.ObjectID, []primitive.ObjectID) {
@@ -86,16 +83,35 @@ func collectScheduleIDs(schedules []Shedule) ([]primitive.ObjectID, []primitive.
 	return svcIDs, consIDs
 }
 
-// Helper function to get asset label (updated to use API)
-func getAssetLabel(ctx context.Context, assetID primitive.ObjectID) string {
-	asset, err := fetchAssetFromAPI(assetID.Hex())
-	if err != nil || asset == nil {
-		return assetID.Hex()
+// Helper function to get asset label (updated to use API). The id is used
+// when the asset has no label or is gone, and when the asset service cannot
+// be reached, which is also reported.
+func getAssetLabel(ctx context.Context, assetID primitive.ObjectID) (string, error) {
+	asset, err := fetchAssetFromAPI(ctx, assetID.Hex())
+	if err != nil {
+		if isNotFound(err) {
+			err = nil
+		}
+		return assetID.Hex(), err
 	}
 
 	if asset.Label != "" {
-		return asset.Label
+		return asset.Label, nil
 	}
 
-	return assetID.Hex()
-}
\ No newline at end of file
+	return assetID.Hex(), nil
+}
+
+// getAssetLabels resolves the labels of many assets with one request, keyed
+// by hex id. Like getAssetLabel it falls back to the id.
+func getAssetLabels(ctx context.Context, ids []primitive.ObjectID) (map[string]string, error) {
+	assets, err := fetchAssetsByID(ctx, ids)
+
+	labels := map[primitive.ObjectID]string{}
+	for _, a := range assets {
+		if a.Label != "" {
+			labels[a.ID] = a.Label
+		}
+	}
+	return nameMap(ids, labels), err
+}
@@ -0,0 +1,148 @@
+package maintenence
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// defaultHorizonDays is how far ahead the calendar feed looks by default;
+// maxHorizonDays bounds the horizon accepted from the query string
+const (
+	defaultHorizonDays = 90
+	maxHorizonDays     = 730
+)
+
+// iCalendar feed of upcoming schedule occurrences. The feed covers the whole
+// plant unless narrowed with asset_id or location.
+func calendarFeed(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var filter OccurrenceFilter
+	name := "CMMS - Plant maintenance"
+	if assetID := q.Get("asset_id"); assetID != "" {
+		objAssetID, err := primitive.ObjectIDFromHex(assetID)
+		if err != nil {
+			http.Error(w, "Invalid asset_id", http.StatusBadRequest)
+			return
+		}
+		filter.AssetID = &objAssetID
+		label, _ := getAssetLabel(r.Context(), objAssetID)
+		name = "CMMS - " + label
+	} else if location := q.Get("location"); location != "" {
+		filter.Location = location
+		name = "CMMS - " + location
+	}
+
+	horizon := defaultHorizonDays
+	if s := q.Get("horizon"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxHorizonDays {
+			http.Error(w, fmt.Sprintf("horizon must be between 1 and %d days", maxHorizonDays), http.StatusBadRequest)
+			return
+		}
+		horizon = n
+	}
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	from := truncateDay(time.Now())
+	occurrences, _, err := projectOccurrences(ctx, filter, from, from.AddDate(0, 0, horizon))
+	if err != nil {
+		http.Error(w, "Failed to build calendar: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="cmms.ics"`)
+	w.Write([]byte(buildICalendar(name, occurrences, time.Now())))
+}
+
+// buildICalendar renders occurrences as all-day VEVENTs. The UID combines the
+// schedule id and the due date so each occurrence keeps its identity across
+// refreshes.
+func buildICalendar(name string, occurrences []Occurrence, now time.Time) string {
+	var b strings.Builder
+	stamp := now.UTC().Format("20060102T150405Z")
+
+	writeICalLine(&b, "BEGIN:VCALENDAR")
+	writeICalLine(&b, "VERSION:2.0")
+	writeICalLine(&b, "PRODID:-//CMMS//Maintenance Schedules//EN")
+	writeICalLine(&b, "CALSCALE:GREGORIAN")
+	writeICalLine(&b, "METHOD:PUBLISH")
+	writeICalLine(&b, "X-WR-CALNAME:"+escapeICalText(name))
+
+	for _, o := range occurrences {
+		summary := o.ScheduleLabel + " - " + o.AssetLabel
+
+		var desc []string
+		if o.MaintenanceLabel != "" {
+			desc = append(desc, "Maintenance: "+o.MaintenanceLabel)
+		}
+		desc = append(desc, "Schedule: "+o.ScheduleLabel+" ("+o.ScheduleType+")")
+		if len(o.Services) > 0 {
+			desc = append(desc, "Services: "+strings.Join(o.Services, ", "))
+		}
+		if len(o.Consumables) > 0 {
+			desc = append(desc, "Consumables: "+strings.Join(o.Consumables, ", "))
+		}
+		if o.Notes != "" {
+			desc = append(desc, "Notes: "+o.Notes)
+		}
+
+		writeICalLine(&b, "BEGIN:VEVENT")
+		writeICalLine(&b, "UID:"+o.ScheduleID+"-"+o.Due.Format("20060102")+"@cmms")
+		writeICalLine(&b, "DTSTAMP:"+stamp)
+		writeICalLine(&b, "DTSTART;VALUE=DATE:"+o.Due.Format("20060102"))
+		writeICalLine(&b, "DTEND;VALUE=DATE:"+o.Due.AddDate(0, 0, 1).Format("20060102"))
+		writeICalLine(&b, "SUMMARY:"+escapeICalText(summary))
+		writeICalLine(&b, "DESCRIPTION:"+escapeICalText(strings.Join(desc, "\n")))
+		if o.Location != "" {
+			writeICalLine(&b, "LOCATION:"+escapeICalText(o.Location))
+		}
+		writeICalLine(&b, "CATEGORIES:"+escapeICalText(o.ScheduleType))
+		writeICalLine(&b, "TRANSP:TRANSPARENT")
+		writeICalLine(&b, "END:VEVENT")
+	}
+
+	writeICalLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+// writeICalLine writes a content line folded at 75 octets as required by
+// RFC 5545, without splitting UTF-8 sequences
+func writeICalLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// continuation lines start with a space that counts towards the limit
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
+
+func escapeICalText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
@@ -1,9 +1,20 @@
-package main
+package maintenence
 
 import (
+	"context"
+	"errors"
+	"html/template"
+	"log"
 	"net/http"
+	"shared/audit"
+	"shared/csrf"
+	"shared/flash"
+	"shared/jsonapi"
+	"shared/references"
+	"shared/store"
+	"shared/validate"
+	"shared/webhook"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -21,24 +32,26 @@ func listMaintenance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	renderMaintenances(w, r, objAssetID, nil)
+}
+
+// renderMaintenances shows the maintenances of an asset and, when f is not
+// nil, the form that did not validate
+func renderMaintenances(w http.ResponseWriter, r *http.Request, objAssetID primitive.ObjectID, f *validate.Form) {
+	assetID := objAssetID.Hex()
 	ctx, cancel := getCtx()
 	defer cancel()
 
-	cursor, err := db.Collection("maintenances").Find(ctx, bson.M{"asset_id": objAssetID})
+	items, err := repo.Maintenances.ListByAsset(ctx, objAssetID)
 	if err != nil {
 		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer cursor.Close(ctx)
-
-	var items []MainteneceShedule
-	if err := cursor.All(ctx, &items); err != nil {
-		http.Error(w, "Decode error: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
 
 	// Fetch available services and consumables from API
-	services, consumables := fetchServicesAndConsumables()
+	var warnings incomplete
+	services, consumables, fetchErr := fetchServicesAndConsumables(ctx)
+	warnings.add(fetchErr)
 
 	// Convert to the expected structure
 	serviceStructs := make([]struct {
@@ -72,11 +85,10 @@ func listMaintenance(w http.ResponseWriter, r *http.Request) {
 		consNames[c.ID.Hex()] = c.Label
 	}
 
-	assetLabel := getAssetLabel(ctx, objAssetID)
+	assetLabel, fetchErr := getAssetLabel(ctx, objAssetID)
+	warnings.add(fetchErr)
 
-	// Check for any message to display
-	message := r.URL.Query().Get("message")
-	messageType := r.URL.Query().Get("type")
+	message, messageType := flashMessage(w, r)
 
 	data := struct {
 		AssetID    string
@@ -94,6 +106,9 @@ func listMaintenance(w http.ResponseWriter, r *http.Request) {
 		ConsumableNames map[string]string
 		Message         string
 		MessageType     string
+		Warnings        []string
+		Form            *validate.Form
+		CSRF            template.HTML
 	}{
 		AssetID:         assetID,
 		AssetLabel:      assetLabel,
@@ -104,8 +119,12 @@ func listMaintenance(w http.ResponseWriter, r *http.Request) {
 		ConsumableNames: consNames,
 		Message:         message,
 		MessageType:     messageType,
+		Warnings:        warnings,
+		Form:            f,
+		CSRF:            csrf.Field(r),
 	}
 
+	pageStatus(w, f)
 	renderTemplate(w, "list.html", data)
 }
 
@@ -128,25 +147,40 @@ func createMaintenance(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method == http.MethodPost {
-		label := r.FormValue("label")
+		f, err := maintenanceForm(r, "create")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := getCtx()
+		defer cancel()
+
+		checkAsset(ctx, f, "asset_id", objAssetID)
+		if !f.Valid() {
+			showForm(w, r, f, func(w http.ResponseWriter, r *http.Request, f *validate.Form) {
+				renderMaintenances(w, r, objAssetID, f)
+			})
+			return
+		}
 
 		doc := MainteneceShedule{
 			ID:       primitive.NewObjectID(),
-			Lable:    label,
+			Lable:    f.Get("label"),
 			AssetID:  objAssetID,
 			Shedules: []Shedule{},
 		}
 
-		ctx, cancel := getCtx()
-		defer cancel()
-
-		_, err := db.Collection("maintenances").InsertOne(ctx, doc)
+		err = repo.Maintenances.Insert(ctx, doc)
 		if err != nil {
-			http.Redirect(w, r, "/maintenances?asset_id="+assetID+"&message=Error creating maintenance: "+err.Error()+"&type=error", http.StatusSeeOther)
+			log.Printf("error creating maintenance: %v", err)
+			flash.Redirect(w, r, "/maintenances?asset_id="+assetID, flash.Error, "Error creating maintenance")
 			return
 		}
+		recordAudit(ctx, r, audit.ActionCreate, "maintenance", doc.ID, doc.Lable, nil, doc)
+		publishEvent(ctx, webhook.MaintenanceCreated, doc)
 
-		http.Redirect(w, r, "/maintenances?asset_id="+assetID+"&message=Maintenance created successfully&type=success", http.StatusSeeOther)
+		flash.Redirect(w, r, "/maintenances?asset_id="+assetID, flash.Success, "Maintenance created successfully")
 	}
 }
 
@@ -168,15 +202,16 @@ func editMaintenance(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := getCtx()
 		defer cancel()
 
-		var item MainteneceShedule
-		err = db.Collection("maintenances").FindOne(ctx, bson.M{"_id": objID}).Decode(&item)
+		item, err := repo.Maintenances.Get(ctx, objID)
 		if err != nil {
 			http.Error(w, "Not found", http.StatusNotFound)
 			return
 		}
 
 		// Fetch services and consumables from API
-		services, consumables := fetchServicesAndConsumables()
+		var warnings incomplete
+		services, consumables, fetchErr := fetchServicesAndConsumables(ctx)
+		warnings.add(fetchErr)
 
 		// Convert to the expected structure
 		serviceStructs := make([]struct {
@@ -211,10 +246,12 @@ func editMaintenance(w http.ResponseWriter, r *http.Request) {
 				ID    primitive.ObjectID `bson:"_id"`
 				Label string             `bson:"label"`
 			}
+			Warnings []string
 		}{
 			MainteneceShedule: item,
 			Services:          serviceStructs,
 			Consumables:       consumableStructs,
+			Warnings:          warnings,
 		}
 
 		renderTemplate(w, "edit.html", data)
@@ -234,27 +271,46 @@ func editMaintenance(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		label := r.FormValue("label")
-
 		ctx, cancel := getCtx()
 		defer cancel()
 
-		var item MainteneceShedule
-		if err := db.Collection("maintenances").FindOne(ctx, bson.M{"_id": objID}).Decode(&item); err != nil {
+		item, err := repo.Maintenances.Get(ctx, objID)
+		if err != nil {
 			http.Error(w, "Not found", http.StatusNotFound)
 			return
 		}
 
-		_, err = db.Collection("maintenances").UpdateOne(ctx,
-			bson.M{"_id": objID},
-			bson.M{"$set": bson.M{"label": label}},
-		)
+		f, err := maintenanceForm(r, objID.Hex())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		version := f.Version(r)
+		if !f.Valid() {
+			showForm(w, r, f, func(w http.ResponseWriter, r *http.Request, f *validate.Form) {
+				renderMaintenances(w, r, item.AssetID, f)
+			})
+			return
+		}
+
+		before := item
+		item.Lable = f.Get("label")
+		item.Version = version
+		err = repo.Maintenances.Update(ctx, item)
+		if errors.Is(err, store.ErrConflict) {
+			showMaintenanceConflict(w, r, item, before)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Update error: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		item.Version++
+		recordAudit(ctx, r, audit.ActionUpdate, "maintenance", item.ID, item.Lable, before, item)
+		publishEvent(ctx, webhook.MaintenanceUpdated, item)
 
-		http.Redirect(w, r, "/maintenances?asset_id="+item.AssetID.Hex()+"&message=Maintenance updated successfully&type=success", http.StatusSeeOther)
+		w.Header().Set("ETag", jsonapi.ETag(item.Version))
+		flash.Redirect(w, r, "/maintenances?asset_id="+item.AssetID.Hex(), flash.Success, "Maintenance updated successfully")
 		return
 	}
 }
@@ -277,20 +333,41 @@ func deleteMaintenance(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	// Fetch item so we can read AssetID for redirect after delete
-	var item MainteneceShedule
-	if err := db.Collection("maintenances").FindOne(ctx, bson.M{"_id": objID}).Decode(&item); err != nil {
+	item, err := repo.Maintenances.Get(ctx, objID)
+	if err != nil {
 		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
 
-	_, err = db.Collection("maintenances").DeleteOne(ctx, bson.M{"_id": objID})
+	// Schedules of the maintenance must be deleted or moved first
+	refs, err := findReferences(ctx, references.KindMaintenance, objID)
+	if err != nil {
+		http.Error(w, "Failed to check references: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(refs) > 0 {
+		action := r.FormValue("action")
+		if action == "" {
+			confirmDeleteMaintenance(ctx, w, r, item, refs, "")
+			return
+		}
+		to, _ := primitive.ObjectIDFromHex(r.FormValue("to"))
+		if _, err := resolveReferences(ctx, r, references.KindMaintenance, objID, action, to); err != nil {
+			confirmDeleteMaintenance(ctx, w, r, item, refs, err.Error())
+			return
+		}
+	}
+
+	err = repo.Maintenances.Delete(ctx, objID, audit.Actor(r))
 	if err != nil {
 		http.Error(w, "Delete error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	recordAudit(ctx, r, audit.ActionDelete, "maintenance", item.ID, item.Lable, item, nil)
+	publishEvent(ctx, webhook.MaintenanceDeleted, item)
 
 	// Redirect back to list with success message
-	http.Redirect(w, r, "/maintenances?asset_id="+item.AssetID.Hex()+"&message=Maintenance deleted successfully&type=success", http.StatusSeeOther)
+	flash.Redirect(w, r, "/maintenances?asset_id="+item.AssetID.Hex(), flash.Success, "Maintenance moved to the recycle bin")
 }
 
 // View maintenance with all schedules
@@ -310,25 +387,18 @@ func viewMaintenance(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := getCtx()
 	defer cancel()
 
-	var item MainteneceShedule
-	if err := db.Collection("maintenances").FindOne(ctx, bson.M{"_id": objID}).Decode(&item); err != nil {
+	item, err := repo.Maintenances.Get(ctx, objID)
+	if err != nil {
 		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
 
 	// Fetch schedules stored separately that reference this maintenance
-	scur, err := schedulesCollection.Find(ctx, bson.M{"maintenance_id": objID})
+	schedules, err := repo.Schedules.Referencing(ctx, "maintenance_id", objID)
 	if err != nil {
 		http.Error(w, "Failed to fetch schedules: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer scur.Close(ctx)
-
-	var schedules []ScheduleDoc
-	if err := scur.All(ctx, &schedules); err != nil {
-		http.Error(w, "Failed to decode schedules: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
 
 	// Convert ScheduleDoc to Shedule for backward template compatibility
 	var shedules []Shedule
@@ -347,19 +417,57 @@ func viewMaintenance(w http.ResponseWriter, r *http.Request) {
 	svcIDs, consIDs := collectScheduleIDs(shedules)
 
 	// Build name maps
-	svcNames, consNames := buildNameMaps(ctx, svcIDs, consIDs)
+	var warnings incomplete
+	svcNames, consNames, err := buildNameMaps(ctx, svcIDs, consIDs)
+	warnings.add(err)
 
 	data := struct {
 		MainteneceShedule
 		Shedules        []Shedule
 		ServiceNames    map[string]string
 		ConsumableNames map[string]string
+		Warnings        []string
 	}{
 		MainteneceShedule: item,
 		Shedules:          shedules,
 		ServiceNames:      svcNames,
 		ConsumableNames:   consNames,
+		Warnings:          warnings,
 	}
 
 	renderTemplate(w, "view.html", data)
 }
+
+// confirmDeleteMaintenance shows the schedules still using a maintenance and
+// lets the user delete them along with it or move them to another maintenance
+func confirmDeleteMaintenance(ctx context.Context, w http.ResponseWriter, r *http.Request, item MainteneceShedule, refs []references.Reference, errMsg string) {
+	items, err := repo.Maintenances.ListByAsset(ctx, item.AssetID)
+	if err != nil {
+		http.Error(w, "Failed to fetch maintenances: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var others []MainteneceShedule
+	for _, m := range items {
+		if m.ID != item.ID {
+			others = append(others, m)
+		}
+	}
+
+	data := struct {
+		Item      MainteneceShedule
+		Refs      []references.Reference
+		Targets   []MainteneceShedule
+		CancelURL string
+		Error     string
+		CSRF      template.HTML
+	}{
+		Item:      item,
+		Refs:      refs,
+		Targets:   others,
+		CancelURL: "/maintenances?asset_id=" + item.AssetID.Hex(),
+		Error:     errMsg,
+		CSRF:      csrf.Field(r),
+	}
+
+	renderTemplate(w, "maintenance_delete.html", data)
+}
@@ -0,0 +1,187 @@
+// Package maintenence is the maintenance service: maintenances and their
+// schedules, the calendar, compliance report, notifications, webhooks, audit
+// trail, recycle bin and consistency check. It owns every reference between
+// the services and reads assets, services and consumables from the others.
+package maintenence
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"shared/audit"
+	"shared/config"
+	"shared/csrf"
+	"shared/events"
+	"shared/metrics"
+	"shared/store"
+	"shared/trash"
+	"shared/webhook"
+	"strings"
+
+	"google.golang.org/grpc"
+)
+
+var (
+	conf      *config.Config
+	templates *template.Template
+	// ledger records the domain events the service has handled
+	ledger events.Ledger
+	// repo is where the handlers keep the maintenances and schedules; it is
+	// set up by use
+	repo *Store
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+var templateFuncs = template.FuncMap{
+	"add": func(a, b int) int { return a + b },
+	// title capitalises a word such as a schedule type
+	"title": func(s string) string {
+		if s == "" {
+			return s
+		}
+		return strings.ToUpper(s[:1]) + s[1:]
+	},
+	"dict": func(pairs ...interface{}) map[string]interface{} {
+		m := map[string]interface{}{}
+		for i := 0; i+1 < len(pairs); i += 2 {
+			m[fmt.Sprint(pairs[i])] = pairs[i+1]
+		}
+		return m
+	},
+}
+
+// setup points the package at its storage on b and connects to the other
+// services, with opts added to the gRPC connections, until ctx is done. The
+// tables or indexes are created or updated first.
+func setup(ctx context.Context, c *config.Config, b store.Backend, opts []grpc.DialOption) error {
+	conf = c
+	if err := dialServices(ctx, opts...); err != nil {
+		return err
+	}
+
+	if err := Migrate(ctx, c, b); err != nil {
+		return err
+	}
+	if b.SQL != nil {
+		ledger = events.NewSQLLedger(b.SQL)
+		return use(NewSQLStore(b.SQL))
+	}
+	shared := b.Mongo.Database(conf.Mongo.Database)
+	ledger = events.NewMongoLedger(shared)
+	return use(NewMongoStore(b.Mongo.Database(conf.Database(config.Maintenance)), shared))
+}
+
+// Migrate creates or updates the tables or indexes of the maintenance
+// service on b
+func Migrate(ctx context.Context, c *config.Config, b store.Backend) error {
+	if b.SQL != nil {
+		return MigrateSQL(ctx, b.SQL)
+	}
+	return MigrateMongo(ctx, b.Mongo.Database(c.Database(config.Maintenance)))
+}
+
+// use points the handlers at s
+func use(s *Store) error {
+	repo = s
+	auditLog = audit.NewLogger(s.Audit, "maintenance")
+
+	var err error
+	templates, err = template.New("").Funcs(templateFuncs).ParseFS(templateFS, "templates/*.html")
+	return err
+}
+
+// Handler sets the maintenance service up on b, starts its notifier, webhook
+// dispatcher and purger until ctx is done, adds its gauges to the metrics
+// and returns its routes. The other services are reached over gRPC, with
+// opts added to the connections.
+func Handler(ctx context.Context, c *config.Config, b store.Backend, opts ...grpc.DialOption) (http.Handler, error) {
+	if err := setup(ctx, c, b, opts); err != nil {
+		return nil, err
+	}
+	watchServices(ctx)
+	registerGauges()
+
+	startNotifier(ctx, loadNotifierConfig())
+	go webhook.NewDispatcher(repo.WebhookStore).Run(ctx)
+
+	// Purge records that have been in the recycle bin longer than the retention period
+	trash.StartPurger(ctx, trash.Retention(), map[string]trash.Purger{
+		"maintenances": repo.Maintenances,
+		"schedules":    repo.Schedules,
+	})
+
+	return routes(), nil
+}
+
+// routes returns the routes of the maintenance service over repo, behind the
+// CSRF check, counted and timed by route in the metrics
+func routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/maintenances", listMaintenance)
+	mux.HandleFunc("/maintenances/create", createMaintenance)
+	mux.HandleFunc("/maintenances/edit", editMaintenance)
+	mux.HandleFunc("/maintenances/view", viewMaintenance)
+	mux.HandleFunc("/maintenances/delete", deleteMaintenance)
+
+	// Schedule Routes
+	mux.HandleFunc("/schedules", listSchedules)
+	mux.HandleFunc("/schedules/add", addSchedule)
+	mux.HandleFunc("/schedules/edit", editSchedule)
+	mux.HandleFunc("/schedules/delete", deleteSchedule)
+	mux.HandleFunc("/schedules/complete", completeSchedule)
+
+	// Report Routes
+	mux.HandleFunc("/reports/compliance", complianceReport)
+
+	// Calendar Routes
+	mux.HandleFunc("/calendar.ics", calendarFeed)
+	mux.HandleFunc("/calendar", calendarView)
+	mux.HandleFunc("/timeline", timelineView)
+	mux.HandleFunc("/api/occurrences", occurrencesAPI)
+
+	// Notification Routes
+	mux.HandleFunc("/notifications", listSubscriptions)
+	mux.HandleFunc("/notifications/subscribe", subscribeNotifications)
+	mux.HandleFunc("/notifications/unsubscribe", unsubscribeNotifications)
+	mux.HandleFunc("/notifications/run", runNotificationsNow)
+
+	// Webhook Routes
+	mux.HandleFunc("/webhooks", listWebhooks)
+	mux.HandleFunc("/webhooks/create", createWebhook)
+	mux.HandleFunc("/webhooks/toggle", toggleWebhook)
+	mux.HandleFunc("/webhooks/delete", deleteWebhook)
+	mux.HandleFunc("/webhooks/deliveries", listWebhookDeliveries)
+	mux.HandleFunc("/webhooks/redeliver", redeliverWebhook)
+
+	// Reference Routes, used by the other services before a delete
+	mux.HandleFunc("/api/references", referencesAPI)
+	mux.HandleFunc("/api/references/resolve", resolveReferencesAPI)
+
+	// Recycle Bin Routes
+	mux.HandleFunc("/trash", listTrash)
+	mux.HandleFunc("/trash/restore", restoreTrash)
+	mux.HandleFunc("/trash/purge", purgeTrash)
+
+	// Consistency Routes
+	mux.HandleFunc("/consistency", consistencyReport)
+	mux.HandleFunc("/consistency/repair", consistencyReport)
+
+	// Audit Routes
+	mux.HandleFunc("/audit", auditTrail)
+
+	return metrics.Instrument(config.Maintenance, metrics.ServeMuxRoute(mux), csrf.Protect(mux))
+}
+
+// RunCheck implements `cmms check`, see runCheckCommand
+func RunCheck(ctx context.Context, c *config.Config, b store.Backend, args []string) int {
+	if err := setup(ctx, c, b, nil); err != nil {
+		fmt.Fprintln(os.Stderr, "consistency check failed:", err)
+		return 2
+	}
+	return runCheckCommand(ctx, args)
+}
@@ -0,0 +1,891 @@
+package maintenence
+
+import (
+	"cmms/project/rpc/cmmspb"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"shared/audit"
+	"shared/config"
+	"shared/csrf"
+	"shared/events"
+	"shared/metrics"
+	"shared/references"
+	"shared/webhook"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeDirectory answers the lookups of the maintenance service in place of
+// the gRPC servers of the asset, service and consumable services. The Watch
+// streams are left unimplemented; the tests never open them.
+type fakeDirectory struct {
+	cmmspb.AssetRegisterClient
+	cmmspb.ServiceCatalogClient
+	cmmspb.ConsumableCatalogClient
+
+	assets      []*cmmspb.Asset
+	services    []*cmmspb.Service
+	consumables []*cmmspb.Consumable
+
+	// The records in the recycle bins
+	deletedAssets      []*cmmspb.Asset
+	deletedServices    []*cmmspb.Service
+	deletedConsumables []*cmmspb.Consumable
+}
+
+func (d *fakeDirectory) GetAsset(ctx context.Context, in *cmmspb.GetRequest, opts ...grpc.CallOption) (*cmmspb.Asset, error) {
+	for _, a := range d.assets {
+		if a.Id == in.Id {
+			return a, nil
+		}
+	}
+	return nil, status.Error(codes.NotFound, "asset not found")
+}
+
+func (d *fakeDirectory) BatchGetAssets(ctx context.Context, in *cmmspb.BatchGetRequest, opts ...grpc.CallOption) (*cmmspb.Assets, error) {
+	return &cmmspb.Assets{Assets: pick(d.assets, in.Ids, (*cmmspb.Asset).GetId)}, nil
+}
+
+func (d *fakeDirectory) ListAssets(ctx context.Context, in *cmmspb.ListAssetsRequest, opts ...grpc.CallOption) (*cmmspb.Assets, error) {
+	var list []*cmmspb.Asset
+	for _, a := range d.assets {
+		if (in.Type == "" || a.Type == in.Type) && (in.Location == "" || a.Location == in.Location) {
+			list = append(list, a)
+		}
+	}
+	return &cmmspb.Assets{Assets: list}, nil
+}
+
+func (d *fakeDirectory) ListDeletedAssets(ctx context.Context, in *cmmspb.ListDeletedRequest, opts ...grpc.CallOption) (*cmmspb.Assets, error) {
+	return &cmmspb.Assets{Assets: d.deletedAssets}, nil
+}
+
+func (d *fakeDirectory) BatchGetServices(ctx context.Context, in *cmmspb.BatchGetRequest, opts ...grpc.CallOption) (*cmmspb.Services, error) {
+	return &cmmspb.Services{Services: pick(d.services, in.Ids, (*cmmspb.Service).GetId)}, nil
+}
+
+func (d *fakeDirectory) ListServices(ctx context.Context, in *cmmspb.ListServicesRequest, opts ...grpc.CallOption) (*cmmspb.Services, error) {
+	return &cmmspb.Services{Services: d.services}, nil
+}
+
+func (d *fakeDirectory) ListDeletedServices(ctx context.Context, in *cmmspb.ListDeletedRequest, opts ...grpc.CallOption) (*cmmspb.Services, error) {
+	return &cmmspb.Services{Services: d.deletedServices}, nil
+}
+
+func (d *fakeDirectory) BatchGetConsumables(ctx context.Context, in *cmmspb.BatchGetRequest, opts ...grpc.CallOption) (*cmmspb.Consumables, error) {
+	return &cmmspb.Consumables{Consumables: pick(d.consumables, in.Ids, (*cmmspb.Consumable).GetId)}, nil
+}
+
+func (d *fakeDirectory) ListConsumables(ctx context.Context, in *cmmspb.ListConsumablesRequest, opts ...grpc.CallOption) (*cmmspb.Consumables, error) {
+	return &cmmspb.Consumables{Consumables: d.consumables}, nil
+}
+
+func (d *fakeDirectory) ListDeletedConsumables(ctx context.Context, in *cmmspb.ListDeletedRequest, opts ...grpc.CallOption) (*cmmspb.Consumables, error) {
+	return &cmmspb.Consumables{Consumables: d.deletedConsumables}, nil
+}
+
+// pick returns the records of list with the given ids
+func pick[T any](list []T, ids []string, id func(T) string) []T {
+	var result []T
+	for _, v := range list {
+		for _, want := range ids {
+			if id(v) == want {
+				result = append(result, v)
+			}
+		}
+	}
+	return result
+}
+
+// newTestServer returns the routes of the maintenance service over an
+// in-memory store, with the other services answered by the returned
+// directory
+func newTestServer(t *testing.T) (*Store, http.Handler, *fakeDirectory) {
+	t.Helper()
+	conf = config.Default()
+	lookups.invalidate("")
+	// httptest requests come from 192.0.2.1, standing in for the gateway
+	_, gateway, _ := net.ParseCIDR("192.0.2.0/24")
+	audit.SetTrustedProxies([]*net.IPNet{gateway})
+
+	dir := &fakeDirectory{}
+	assetClient, serviceClient, consumableClient = dir, dir, dir
+
+	s := NewMemoryStore()
+	if err := use(s); err != nil {
+		t.Fatal(err)
+	}
+	return s, routes(), dir
+}
+
+func do(h http.Handler, method, target string, form url.Values) *httptest.ResponseRecorder {
+	var r *http.Request
+	if form != nil {
+		r = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	r.Header.Set("X-Remote-User", "tester")
+	r.AddCookie(&http.Cookie{Name: csrf.CookieName, Value: "test"})
+	r.Header.Set(csrf.HeaderName, csrf.Token(r))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// redirectedTo returns the location of a See Other answer
+func redirectedTo(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusSeeOther, w.Body)
+	}
+	return w.Header().Get("Location")
+}
+
+// followFlash gets the page a See Other answer redirects to, with the flash
+// cookie the answer set
+func followFlash(t *testing.T, h http.Handler, w *httptest.ResponseRecorder) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, redirectedTo(t, w), nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	page := httptest.NewRecorder()
+	h.ServeHTTP(page, r)
+	return page
+}
+
+// fixture is a plant of two assets, with a service and a consumable used by
+// a maintenance of the first asset and by its daily schedule
+type fixture struct {
+	pump, boiler primitive.ObjectID
+	oil, filter  primitive.ObjectID
+	maintenance  MainteneceShedule
+	schedule     ScheduleDoc
+}
+
+// anchor is the creation day of the fixture schedule, which its occurrences
+// are counted from
+var anchor = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func newFixture(t *testing.T, s *Store, dir *fakeDirectory) fixture {
+	t.Helper()
+	ctx := context.Background()
+	f := fixture{
+		pump: primitive.NewObjectID(), boiler: primitive.NewObjectID(),
+		oil: primitive.NewObjectID(), filter: primitive.NewObjectID(),
+	}
+	dir.assets = []*cmmspb.Asset{
+		{Id: f.pump.Hex(), Label: "Pump 1", Type: "pump", Location: "Plant A"},
+		{Id: f.boiler.Hex(), Label: "Boiler", Type: "boiler", Location: "Plant B"},
+	}
+	dir.services = []*cmmspb.Service{{Id: f.oil.Hex(), Label: "Oil change"}}
+	dir.consumables = []*cmmspb.Consumable{{Id: f.filter.Hex(), Label: "Oil filter"}}
+
+	f.maintenance = MainteneceShedule{
+		ID: primitive.NewObjectID(), Lable: "Yearly service", AssetID: f.pump,
+		Shedules: []Shedule{{ID: primitive.NewObjectID(), Lable: "Embedded", Services: []primitive.ObjectID{f.oil}}},
+	}
+	if err := s.Maintenances.Insert(ctx, f.maintenance); err != nil {
+		t.Fatal(err)
+	}
+	f.schedule = ScheduleDoc{
+		ID: primitive.NewObjectIDFromTimestamp(anchor), MaintenanceID: &f.maintenance.ID, AssetID: f.pump,
+		Lable: "Daily check", SheduleType: ScheduleDaily, Days: 1,
+		Services: []primitive.ObjectID{f.oil}, Consumables: []primitive.ObjectID{f.filter},
+	}
+	if err := s.Schedules.Insert(ctx, f.schedule); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func contains(t *testing.T, w *httptest.ResponseRecorder, want ...string) {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	for _, s := range want {
+		if !strings.Contains(w.Body.String(), s) {
+			t.Errorf("page does not show %q", s)
+		}
+	}
+}
+
+func TestMaintenancePages(t *testing.T) {
+	s, h, dir := newTestServer(t)
+	f := newFixture(t, s, dir)
+
+	contains(t, do(h, http.MethodGet, "/maintenances?asset_id="+f.pump.Hex(), nil), "Yearly service", "Pump 1", "Oil change")
+
+	for _, target := range []string{"/maintenances", "/maintenances?asset_id=nope", "/maintenances/view?id=nope"} {
+		if w := do(h, http.MethodGet, target, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d", target, w.Code)
+		}
+	}
+	if w := do(h, http.MethodGet, "/maintenances/view?id="+primitive.NewObjectID().Hex(), nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown maintenance: status = %d", w.Code)
+	}
+}
+
+func TestCreateAndEditMaintenance(t *testing.T) {
+	s, h, dir := newTestServer(t)
+	f := newFixture(t, s, dir)
+	ctx := context.Background()
+
+	redirectedTo(t, do(h, http.MethodPost, "/maintenances/create?asset_id="+f.boiler.Hex(), url.Values{"label": {"Descaling"}}))
+	list, _ := s.Maintenances.ListByAsset(ctx, f.boiler)
+	if len(list) != 1 || list[0].Lable != "Descaling" {
+		t.Fatalf("maintenances = %+v", list)
+	}
+
+	redirectedTo(t, do(h, http.MethodPost, "/maintenances/edit", url.Values{"id": {list[0].ID.Hex()}, "label": {"Descaling and flush"}, "version": {"0"}}))
+	got, _ := s.Maintenances.Get(ctx, list[0].ID)
+	if got.Lable != "Descaling and flush" || got.AssetID != f.boiler || got.Version != 1 {
+		t.Errorf("maintenance = %+v", got)
+	}
+
+	entries := s.Audit.(*audit.MemoryStore).Entries()
+	if len(entries) != 2 {
+		t.Fatalf("audit entries = %+v", entries)
+	}
+	published := s.Webhooks.(*webhook.MemoryPublisher).Events()
+	if len(published) != 2 || published[0].Type != webhook.MaintenanceCreated || published[1].Type != webhook.MaintenanceUpdated {
+		t.Errorf("webhook events = %+v", published)
+	}
+}
+
+func TestDeleteMaintenance(t *testing.T) {
+	s, h, dir := newTestServer(t)
+	f := newFixture(t, s, dir)
+	ctx := context.Background()
+	other := MainteneceShedule{ID: primitive.NewObjectID(), Lable: "Overhaul", AssetID: f.boiler}
+	if err := s.Maintenances.Insert(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := do(h, http.MethodGet, "/maintenances/delete?id="+f.maintenance.ID.Hex(), nil); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status = %d", w.Code)
+	}
+
+	// The schedule of the maintenance is shown before anything is deleted
+	contains(t, do(h, http.MethodPost, "/maintenances/delete", url.Values{"id": {f.maintenance.ID.Hex()}}), "Daily check")
+	if _, err := s.Maintenances.Get(ctx, f.maintenance.ID); err != nil {
+		t.Fatalf("maintenance deleted before confirmation: %v", err)
+	}
+
+	// Moving the schedule to another maintenance moves it to its asset
+	redirectedTo(t, do(h, http.MethodPost, "/maintenances/delete", url.Values{
+		"id": {f.maintenance.ID.Hex()}, "action": {references.ActionReassign}, "to": {other.ID.Hex()},
+	}))
+	sched, _ := s.Schedules.Get(ctx, f.schedule.ID)
+	if sched.MaintenanceID == nil || *sched.MaintenanceID != other.ID || sched.AssetID != f.boiler {
+		t.Errorf("schedule = %+v", sched)
+	}
+	if _, err := s.Maintenances.GetDeleted(ctx, f.maintenance.ID); err != nil {
+		t.Errorf("maintenance not in the recycle bin: %v", err)
+	}
+
+	// Cascading moves the schedule to the recycle bin along with it
+	redirectedTo(t, do(h, http.MethodPost, "/maintenances/delete", url.Values{"id": {other.ID.Hex()}, "action": {references.ActionCascade}}))
+	if _, err := s.Schedules.GetDeleted(ctx, f.schedule.ID); err != nil {
+		t.Errorf("schedule not in the recycle bin: %v", err)
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	s, h, dir := newTestServer(t)
+	f := newFixture(t, s, dir)
+	ctx := context.Background()
+
+	contains(t, do(h, http.MethodGet, "/schedules?asset_id="+f.pump.Hex(), nil), "Daily check", "Yearly service", "Oil change")
+
+	// The input is checked field by field and shown again with the errors
+	w := do(h, http.MethodPost, "/schedules/add", url.Values{
+		"maintenance_id": {f.maintenance.ID.Hex()}, "label": {"Weekly greasing"}, "schedule_type": {"fortnightly"}, "days": {"x"},
+		"services[]": {f.oil.Hex(), "nope"}, "consumables[]": {primitive.NewObjectID().Hex()},
+	})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid schedule: status = %d", w.Code)
+	}
+	for _, want := range []string{`value="Weekly greasing"`, "Choose one of daily, weekly, monthly, yearly", "Enter a whole number from 1 to 365",
+		"&#34;nope&#34; is not a valid record", "A chosen consumable no longer exists"} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("page does not show %q", want)
+		}
+	}
+
+	// The asset of a schedule added to a maintenance is the maintenance's
+	redirectedTo(t, do(h, http.MethodPost, "/schedules/add", url.Values{
+		"maintenance_id": {f.maintenance.ID.Hex()}, "label": {"Weekly greasing"}, "schedule_type": {ScheduleWeekly}, "days": {"1"},
+		"services[]": {f.oil.Hex()},
+	}))
+	added, _ := s.Schedules.Find(ctx, ScheduleFilter{ServiceID: &f.oil})
+	if len(added) != 2 {
+		t.Fatalf("schedules = %+v", added)
+	}
+	var greasing ScheduleDoc
+	for _, sched := range added {
+		if sched.Lable == "Weekly greasing" {
+			greasing = sched
+		}
+	}
+	if greasing.AssetID != f.pump || len(greasing.Services) != 1 {
+		t.Fatalf("schedule = %+v", greasing)
+	}
+
+	// Clients still sending the old shedule_type are understood
+	redirectedTo(t, do(h, http.MethodPost, "/schedules/edit", url.Values{
+		"schedule_id": {greasing.ID.Hex()}, "label": {"Greasing"}, "shedule_type": {ScheduleMonthly}, "days": {"2"},
+		"consumables[]": {f.filter.Hex()}, "version": {fmt.Sprint(greasing.Version)},
+	}))
+	edited, _ := s.Schedules.Get(ctx, greasing.ID)
+	if edited.Lable != "Greasing" || edited.SheduleType != ScheduleMonthly || edited.Days != 2 || edited.Version != greasing.Version+1 ||
+		len(edited.Services) != 0 || len(edited.Consumables) != 1 || edited.MaintenanceID == nil {
+		t.Errorf("schedule = %+v", edited)
+	}
+
+	redirectedTo(t, do(h, http.MethodPost, "/schedules/delete", url.Values{"schedule_id": {greasing.ID.Hex()}}))
+	if _, err := s.Schedules.GetDeleted(ctx, greasing.ID); err != nil {
+		t.Errorf("schedule not in the recycle bin: %v", err)
+	}
+
+	// Every change of a schedule is in the outbox
+	var changed int
+	for _, e := range s.Outbox.(*events.MemoryOutbox).Events() {
+		if e.Type == events.ScheduleChanged && e.EntityID == greasing.ID {
+			changed++
+		}
+	}
+	if changed != 3 {
+		t.Errorf("ScheduleChanged events = %d, want 3", changed)
+	}
+
+	tests := []struct {
+		method, target string
+		form           url.Values
+		want           int
+	}{
+		{http.MethodGet, "/schedules/add", nil, http.StatusMethodNotAllowed},
+		{http.MethodPost, "/schedules/add", url.Values{"label": {"x"}}, http.StatusBadRequest},
+		{http.MethodPost, "/schedules/add", url.Values{"maintenance_id": {"nope"}}, http.StatusBadRequest},
+		{http.MethodPost, "/schedules/edit", url.Values{"schedule_id": {primitive.NewObjectID().Hex()}}, http.StatusNotFound},
+		{http.MethodPost, "/schedules/delete", url.Values{"schedule_id": {"nope"}}, http.StatusBadRequest},
+		{http.MethodGet, "/schedules", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := do(h, tt.method, tt.target, tt.form); w.Code != tt.want {
+			t.Errorf("%s %s %v: status = %d, want %d", tt.method, tt.target, tt.form, w.Code, tt.want)
+		}
+	}
+}
+
+func TestCompleteSchedule(t *testing.T) {
+	s, h, dir := newTestServer(t)
+	f := newFixture(t, s, dir)
+	ctx := context.Background()
+
+	for _, notes := range []string{"first", "again"} {
+		redirectedTo(t, do(h, http.MethodPost, "/schedules/complete", url.Values{
+			"schedule_id": {f.schedule.ID.Hex()}, "due_date": {"2024-03-05"}, "completed_at": {"2024-03-06"}, "notes": {notes},
+		}))
+	}
+	due := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	c, err := s.Completions.Get(ctx, f.schedule.ID, due)
+	if err != nil || c.Notes != "again" {
+		t.Fatalf("completion = %+v, %v", c, err)
+	}
+	if list, _ := s.Completions.List(ctx, []primitive.ObjectID{f.schedule.ID}, anchor, anchor.AddDate(0, 1, 0)); len(list) != 1 {
+		t.Errorf("completions = %+v", list)
+	}
+	entries := s.Audit.(*audit.MemoryStore).Entries()
+	if len(entries) != 2 || entries[1].Action != audit.ActionUpdate {
+		t.Errorf("audit entries = %+v", entries)
+	}
+
+	w := do(h, http.MethodPost, "/schedules/complete", url.Values{"schedule_id": {f.schedule.ID.Hex()}, "completed_at": {"05/03/2024"}})
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "Enter a date as YYYY-MM-DD") {
+		t.Errorf("invalid date: status = %d", w.Code)
+	}
+}
+
+func TestComplianceReport(t *testing.T) {
+	s, h, dir := newTestServer(t)
+	f := newFixture(t, s, dir)
+	do(h, http.MethodPost, "/schedules/complete", url.Values{"schedule_id": {f.schedule.ID.Hex()}, "due_date": {"2024-03-02"}, "completed_at": {"2024-03-02"}})
+
+	contains(t, do(h, http.MethodGet, "/reports/compliance?from=2024-03-01&to=2024-03-10&asset_id="+f.pump.Hex(), nil), "Pump 1", "Yearly service")
+
+	for _, q := range []string{"from=2024-03-10&to=2024-03-01", "tolerance=-1", "asset_id=nope", "to=tomorrow"} {
+		if w := do(h, http.MethodGet, "/reports/compliance?"+q, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d", q, w.Code)
+		}
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	s, h, dir := newTestServer(t)
+	f := newFixture(t, s, dir)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"from=2024-03-01&to=2024-03-10", 9},
+		{"from=2024-03-01&to=2024-03-10&asset_id=" + f.pump.Hex(), 9},
+		{"from=2024-03-01&to=2024-03-10&asset_type=boiler", 0},
+		{"from=2024-03-01&to=2024-03-10&location=Plant+A", 9},
+		{"from=2024-03-01&to=2024-03-10&service_id=" + primitive.NewObjectID().Hex(), 0},
+		{"from=2024-03-01&to=2024-03-10&maintenance_id=" + f.maintenance.ID.Hex(), 9},
+	}
+	for _, tt := range tests {
+		var got []Occurrence
+		w := do(h, http.MethodGet, "/api/occurrences?"+tt.query, nil)
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil || len(got) != tt.want {
+			t.Errorf("%s: %d occurrences, %v; want %d", tt.query, len(got), err, tt.want)
+			continue
+		}
+		if len(got) > 0 && (got[0].AssetLabel != "Pump 1" || got[0].MaintenanceLabel != "Yearly service" || got[0].Services[0] != "Oil change") {
+			t.Errorf("%s: occurrence = %+v", tt.query, got[0])
+		}
+	}
+
+	if w := do(h, http.MethodGet, "/api/occurrences?from=2024-01-01&to=2025-06-01", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("window too long: status = %d", w.Code)
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	s, h, dir := newTestServer(t)
+	newFixture(t, s, dir)
+
+	contains(t, do(h, http.MethodGet, "/calendar", nil), "Daily check")
+	contains(t, do(h, http.MethodGet, "/calendar?view=week", nil), "Daily check")
+	contains(t, do(h, http.MethodGet, "/timeline", nil), "Pump 1")
+	contains(t, do(h, http.MethodGet, "/calendar.ics?location=Plant+A&horizon=7", nil), "BEGIN:VCALENDAR", "SUMMARY:Daily check")
+
+	if w := do(h, http.MethodGet, "/calendar.ics?horizon=0", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid horizon: status = %d", w.Code)
+	}
+
+	// A location without assets has an empty calendar
+	w := do(h, http.MethodGet, "/calendar.ics?location=Nowhere&horizon=7", nil)
+	contains(t, w, "BEGIN:VCALENDAR", "END:VCALENDAR")
+	if strings.Contains(w.Body.String(), "BEGIN:VEVENT") {
+		t.Errorf("empty location has events:\n%s", w.Body)
+	}
+	w = do(h, http.MethodGet, "/api/occurrences?location=Nowhere", nil)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("empty location: status = %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestReferencesAPI(t *testing.T) {
+	s, h, dir := newTestServer(t)
+	f := newFixture(t, s, dir)
+	ctx := context.Background()
+
+	var refs []references.Reference
+	w := do(h, http.MethodGet, "/api/references?kind=service&id="+f.oil.Hex(), nil)
+	if err := json.NewDecoder(w.Body).Decode(&refs); err != nil || len(refs) != 2 {
+		t.Fatalf("references = %+v, %v", refs, err)
+	}
+
+	var result references.Result
+	w = do(h, http.MethodPost, "/api/references/resolve", url.Values{
+		"kind": {references.KindService}, "id": {f.oil.Hex()}, "action": {references.ActionCascade},
+	})
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil || result.Updated != 2 {
+		t.Fatalf("result = %+v, %v", result, err)
+	}
+	sched, _ := s.Schedules.Get(ctx, f.schedule.ID)
+	m, _ := s.Maintenances.Get(ctx, f.maintenance.ID)
+	if len(sched.Services) != 0 || len(m.Shedules[0].Services) != 0 {
+		t.Errorf("service still used: %+v, %+v", sched, m)
+	}
+
+	tests := []struct {
+		method, target string
+		form           url.Values
+		want           int
+	}{
+		{http.MethodGet, "/api/references?kind=nope&id=" + f.oil.Hex(), nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/references?kind=asset&id=nope", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/references/resolve", nil, http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/references/resolve", url.Values{"kind": {"asset"}, "id": {f.pump.Hex()}, "action": {"nope"}}, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/api/references/resolve", url.Values{"kind": {"asset"}, "id": {f.pump.Hex()}, "action": {references.ActionReassign}}, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		if w := do(h, tt.method, tt.target, tt.form); w.Code != tt.want {
+			t.Errorf("%s %s %v: status = %d, want %d", tt.method, tt.target, tt.form, w.Code, tt.want)
+		}
+	}
+
+	// The services call resolve without a CSRF token, as no browser form can
+	resolve := func(header string) int {
+		r := httptest.NewRequest(http.MethodPost, "/api/references/resolve", strings.NewReader(url.Values{
+			"kind": {references.KindService}, "id": {f.oil.Hex()}, "action": {references.ActionCascade},
+		}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if header != "" {
+			r.Header.Set("X-Requested-With", header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := resolve(""); code != http.StatusForbidden {
+		t.Errorf("form without token: status = %d", code)
+	}
+	if code := resolve("cmms"); code != http.StatusOK {
+		t.Errorf("service call: status = %d", code)
+	}
+}
+
+func TestAssetDeletedEvent(t *testing.T) {
+	s, _, dir := newTestServer(t)
+	f := newFixture(t, s, dir)
+	ctx := context.Background()
+
+	if err := handleEvent(ctx, events.Event{Type: events.AssetDeleted, EntityID: f.pump, Actor: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Maintenances.GetDeleted(ctx, f.maintenance.ID); err != nil {
+		t.Errorf("maintenance not in the recycle bin: %v", err)
+	}
+	sched, err := s.Schedules.GetDeleted(ctx, f.schedule.ID)
+	if err != nil || sched.DeletedBy != "alice" {
+		t.Errorf("schedule = %+v, %v", sched, err)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	s, h, dir := newTestServer(t)
+	f := newFixture(t, s, dir)
+	ctx := context.Background()
+	if err := s.Maintenances.Delete(ctx, f.maintenance.ID, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Schedules.Delete(ctx, f.schedule.ID, "tester"); err != nil {
+		t.Fatal(err)
+	}
+
+	contains(t, do(h, http.MethodGet, "/trash", nil), "Yearly service", "Daily check", "Pump 1")
+
+	redirectedTo(t, do(h, http.MethodPost, "/trash/restore", url.Values{"kind": {"schedule"}, "id": {f.schedule.ID.Hex()}}))
+	if _, err := s.Schedules.Get(ctx, f.schedule.ID); err != nil {
+		t.Errorf("schedule not restored: %v", err)
+	}
+	published := s.Webhooks.(*webhook.MemoryPublisher).Events()
+	if len(published) != 1 || published[0].Type != webhook.ScheduleRestored {
+		t.Errorf("webhook events = %+v", published)
+	}
+
+	redirectedTo(t, do(h, http.MethodPost, "/trash/purge", url.Values{"kind": {"maintenance"}, "id": {f.maintenance.ID.Hex()}}))
+	if deleted, _ := s.Maintenances.ListDeleted(ctx); len(deleted) != 0 {
+		t.Errorf("recycle bin = %+v", deleted)
+	}
+
+	w := do(h, http.MethodPost, "/trash/purge", url.Values{"kind": {"maintenance"}, "id": {f.maintenance.ID.Hex()}})
+	if loc := redirectedTo(t, w); loc != "/trash" {
+		t.Errorf("purged twice: redirected to %s", loc)
+	}
+	if page := followFlash(t, h, w); !strings.Contains(page.Body.String(), `<div class="message error">Item not found in the recycle bin</div>`) {
+		t.Errorf("purged twice: page does not show the error")
+	}
+	if w := do(h, http.MethodGet, "/trash?message=Forged&type=error", nil); strings.Contains(w.Body.String(), "Forged") {
+		t.Error("page shows a message of the URL")
+	}
+	if w := do(h, http.MethodPost, "/trash/restore", url.Values{"kind": {"asset"}, "id": {f.pump.Hex()}}); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid kind: status = %d", w.Code)
+	}
+	if w := do(h, http.MethodGet, "/trash/restore", nil); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status = %d", w.Code)
+	}
+}
+
+func TestConsistency(t *testing.T) {
+	s, h, dir := newTestServer(t)
+	f := newFixture(t, s, dir)
+	ctx := context.Background()
+	// The consumable of the schedule is gone
+	dir.consumables = nil
+
+	var report ConsistencyReport
+	w := do(h, http.MethodGet, "/consistency?format=json", nil)
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Kind != issueMissingConsumable || report.Issues[0].ID != f.schedule.ID {
+		t.Fatalf("issues = %+v", report.Issues)
+	}
+	contains(t, do(h, http.MethodGet, "/consistency", nil), "Daily check")
+
+	if w := do(h, http.MethodGet, "/consistency/repair", nil); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET repair: status = %d", w.Code)
+	}
+	w = do(h, http.MethodPost, "/consistency/repair?format=json", nil)
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil || !report.Issues[0].Repaired {
+		t.Fatalf("report = %+v, %v", report, err)
+	}
+	if sched, _ := s.Schedules.Get(ctx, f.schedule.ID); len(sched.Consumables) != 0 || len(sched.Services) != 1 {
+		t.Errorf("schedule = %+v", sched)
+	}
+}
+
+func TestConsistencyRecycleBin(t *testing.T) {
+	s, h, dir := newTestServer(t)
+	f := newFixture(t, s, dir)
+	ctx := context.Background()
+	// The pump and the oil change are in the recycle bin, not gone
+	dir.deletedAssets, dir.assets = dir.assets[:1], dir.assets[1:]
+	dir.deletedServices, dir.services = dir.services, nil
+
+	var report ConsistencyReport
+	w := do(h, http.MethodPost, "/consistency/repair?format=json", nil)
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	// The asset and the service of the maintenance, its embedded schedule
+	// and the schedule
+	if len(report.Issues) != 4 {
+		t.Fatalf("issues = %+v", report.Issues)
+	}
+	for _, issue := range report.Issues {
+		if issue.Kind != issueInRecycleBin || issue.Repaired {
+			t.Errorf("issue = %+v", issue)
+		}
+	}
+	if _, err := s.Maintenances.Get(ctx, f.maintenance.ID); err != nil {
+		t.Errorf("maintenance of an asset in the recycle bin was deleted: %v", err)
+	}
+	if sched, err := s.Schedules.Get(ctx, f.schedule.ID); err != nil || len(sched.Services) != 1 {
+		t.Errorf("schedule = %+v, %v", sched, err)
+	}
+}
+
+func TestEditConflict(t *testing.T) {
+	s, h, dir := newTestServer(t)
+	f := newFixture(t, s, dir)
+	ctx := context.Background()
+	edit := func(days, notes string) url.Values {
+		return url.Values{
+			"schedule_id": {f.schedule.ID.Hex()}, "label": {"Daily check"}, "schedule_type": {ScheduleDaily},
+			"days": {days}, "services[]": {f.oil.Hex()}, "notes": {notes}, "version": {"0"},
+		}
+	}
+
+	// Two planners open version 0 of the schedule; the first one saves
+	redirectedTo(t, do(h, http.MethodPost, "/schedules/edit", edit("2", "first")))
+
+	// The second one gets both versions instead of overwriting the first
+	w := do(h, http.MethodPost, "/schedules/edit", edit("3", "second"))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("stale edit: status = %d: %s", w.Code, w.Body)
+	}
+	for _, want := range []string{"first", "second", "Oil change", `name="version" value="1"`, `name="days" value="3"`} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("conflict page does not show %q", want)
+		}
+	}
+	got, _ := s.Schedules.Get(ctx, f.schedule.ID)
+	if got.Notes != "first" || got.Days != 2 || got.Version != 1 {
+		t.Fatalf("schedule = %+v", got)
+	}
+
+	// Saving again from the conflict page applies the edit over version 1
+	resubmit := edit("3", "second")
+	resubmit.Set("version", "1")
+	redirectedTo(t, do(h, http.MethodPost, "/schedules/edit", resubmit))
+	if got, _ := s.Schedules.Get(ctx, f.schedule.ID); got.Notes != "second" || got.Version != 2 {
+		t.Errorf("schedule = %+v", got)
+	}
+
+	// API callers send the version in If-Match
+	r := httptest.NewRequest(http.MethodPost, "/maintenances/edit?id="+f.maintenance.ID.Hex(), strings.NewReader(`{"label": "Renamed"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("If-Match", `"7"`)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusConflict || w.Header().Get("ETag") != `"0"` || !strings.Contains(w.Body.String(), `"label":"Yearly service"`) {
+		t.Errorf("JSON: status = %d, ETag = %s, body = %s", w.Code, w.Header().Get("ETag"), w.Body)
+	}
+}
+
+func TestAuditTrail(t *testing.T) {
+	s, h, dir := newTestServer(t)
+	f := newFixture(t, s, dir)
+
+	redirectedTo(t, do(h, http.MethodPost, "/maintenances/edit", url.Values{"id": {f.maintenance.ID.Hex()}, "label": {"Two-yearly service"}, "version": {fmt.Sprint(f.maintenance.Version)}}))
+
+	contains(t, do(h, http.MethodGet, "/audit?entity=maintenance&entity_id="+f.maintenance.ID.Hex(), nil), "Two-yearly service", "tester")
+	contains(t, do(h, http.MethodGet, "/audit", nil), `<option value="restore"`, `<option value="purge"`)
+
+	// A client reaching the service directly cannot name the actor or its
+	// address
+	r := httptest.NewRequest(http.MethodPost, "/maintenances/edit", strings.NewReader(url.Values{
+		"id": {f.maintenance.ID.Hex()}, "label": {"Forged"}, "version": {"1"},
+	}.Encode()))
+	r.RemoteAddr = "203.0.113.9:4321"
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-Remote-User", "admin")
+	r.Header.Set("X-Forwarded-For", "10.0.0.1")
+	r.AddCookie(&http.Cookie{Name: csrf.CookieName, Value: "test"})
+	r.Header.Set(csrf.HeaderName, csrf.Token(r))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	redirectedTo(t, w)
+	entries := s.Audit.(*audit.MemoryStore).Entries()
+	if last := entries[len(entries)-1]; last.Actor != "anonymous" || last.IP != "203.0.113.9" {
+		t.Errorf("untrusted client recorded as %s from %s", last.Actor, last.IP)
+	}
+
+	// Behind the gateway the client is the last address it forwarded, not
+	// one the client sent itself
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Forwarded-For", "10.0.0.1, 198.51.100.7")
+	if ip := audit.ClientIP(r); ip != "198.51.100.7" {
+		t.Errorf("client behind the gateway = %s", ip)
+	}
+	if w := do(h, http.MethodGet, "/audit?from=yesterday", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid from: status = %d", w.Code)
+	}
+}
+
+func TestSubscriptionValidation(t *testing.T) {
+	_, h, _ := newTestServer(t)
+
+	tests := []struct {
+		method, target string
+		form           url.Values
+		want           int
+	}{
+		{http.MethodGet, "/notifications/subscribe", nil, http.StatusMethodNotAllowed},
+		{http.MethodGet, "/notifications/unsubscribe", nil, http.StatusMethodNotAllowed},
+		{http.MethodPost, "/notifications/unsubscribe", url.Values{"id": {"nope"}}, http.StatusBadRequest},
+		{http.MethodGet, "/notifications/run", nil, http.StatusMethodNotAllowed},
+		{http.MethodGet, "/webhooks/create", nil, http.StatusMethodNotAllowed},
+		{http.MethodPost, "/webhooks/toggle", url.Values{"id": {"nope"}}, http.StatusBadRequest},
+		{http.MethodPost, "/webhooks/delete", url.Values{"id": {"nope"}}, http.StatusBadRequest},
+		{http.MethodGet, "/webhooks/deliveries?subscription_id=nope", nil, http.StatusBadRequest},
+		{http.MethodGet, "/webhooks/redeliver", nil, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if w := do(h, tt.method, tt.target, tt.form); w.Code != tt.want {
+			t.Errorf("%s %s %v: status = %d, want %d", tt.method, tt.target, tt.form, w.Code, tt.want)
+		}
+	}
+
+	// The errors are checked as the JSON of an API call
+	for _, tt := range []struct {
+		target, body, field string
+	}{
+		{"/notifications/subscribe", `{"email": "not an address"}`, "email"},
+		{"/notifications/subscribe", `{"email": "alice@example.com", "days_ahead": 0}`, "days_ahead"},
+		{"/notifications/subscribe", `{"email": "alice@example.com", "asset_id": "nope"}`, "asset_id"},
+		{"/notifications/subscribe", `{"email": "alice@example.com", "asset_id": "` + primitive.NewObjectID().Hex() + `"}`, "asset_id"},
+		{"/webhooks/create", `{"url": "ftp://example.com", "events[]": ["*"]}`, "url"},
+		{"/webhooks/create", `{"url": "https://example.com/hook", "events[]": ["nope"]}`, "events[]"},
+	} {
+		r := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		var body struct{ Errors map[string]string }
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil || w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s %s: status = %d, %v", tt.target, tt.body, w.Code, err)
+		} else if body.Errors[tt.field] == "" {
+			t.Errorf("%s %s: errors = %v", tt.target, tt.body, body.Errors)
+		}
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	s, h, _ := newTestServer(t)
+	ctx := context.Background()
+
+	redirectedTo(t, do(h, http.MethodPost, "/notifications/subscribe", url.Values{"email": {"alice@example.com"}, "days_ahead": {"3"}}))
+	subs, _ := s.Subscriptions.List(ctx)
+	if len(subs) != 1 || subs[0].Email != "alice@example.com" || subs[0].DaysAhead != 3 {
+		t.Fatalf("subscriptions = %+v", subs)
+	}
+	if w := do(h, http.MethodGet, "/notifications", nil); !strings.Contains(w.Body.String(), "alice@example.com") {
+		t.Errorf("notifications page: %d %s", w.Code, w.Body)
+	}
+	redirectedTo(t, do(h, http.MethodPost, "/notifications/unsubscribe", url.Values{"id": {subs[0].ID.Hex()}}))
+	if subs, _ := s.Subscriptions.List(ctx); len(subs) != 0 {
+		t.Errorf("after unsubscribe: %+v", subs)
+	}
+
+	// A webhook event reaches the receiver through the queue and shows in
+	// the delivery log
+	received := make(chan *http.Request, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer receiver.Close()
+
+	redirectedTo(t, do(h, http.MethodPost, "/webhooks/create", url.Values{"url": {receiver.URL}, "events[]": {webhook.ScheduleCompleted}}))
+	hooks, _ := webhook.ListSubscriptions(ctx, s.WebhookStore)
+	if len(hooks) != 1 || !hooks[0].Active {
+		t.Fatalf("webhooks = %+v", hooks)
+	}
+	if err := webhook.Publish(ctx, s.WebhookStore, "maintenance", webhook.ScheduleUpdated, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := webhook.Publish(ctx, s.WebhookStore, "maintenance", webhook.ScheduleCompleted, map[string]string{"id": "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go webhook.NewDispatcher(s.WebhookStore).Run(dctx)
+	select {
+	case r := <-received:
+		if e := r.Header.Get("X-CMMS-Event"); e != webhook.ScheduleCompleted {
+			t.Errorf("event = %s", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the delivery was not attempted")
+	}
+	cancel()
+
+	deliveries, _ := webhook.ListDeliveries(ctx, s.WebhookStore, webhook.DeliveryFilter{})
+	if len(deliveries) != 1 {
+		t.Fatalf("deliveries = %+v", deliveries)
+	}
+	if w := do(h, http.MethodGet, "/webhooks/deliveries", nil); !strings.Contains(w.Body.String(), deliveries[0].ID.Hex()) {
+		t.Errorf("deliveries page: %d %s", w.Code, w.Body)
+	}
+	redirectedTo(t, do(h, http.MethodPost, "/webhooks/delete", url.Values{"id": {hooks[0].ID.Hex()}}))
+	if hooks, _ := webhook.ListSubscriptions(ctx, s.WebhookStore); len(hooks) != 0 {
+		t.Errorf("after delete: %+v", hooks)
+	}
+}
+
+func TestGauges(t *testing.T) {
+	s, _, dir := newTestServer(t)
+	newFixture(t, s, dir)
+	registerGauges()
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	// The daily fixture schedule has never been completed
+	for _, want := range []string{"\ncmms_maintenances 1\n", "\ncmms_schedules 1\n", "\ncmms_schedules_overdue 1\n", "# TYPE cmms_occurrences_overdue gauge"} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("metrics do not have %q:\n%s", want, w.Body)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package maintenence
+
+import (
+	"context"
+	"shared/store"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryMaintenances is a MaintenanceRepository kept in memory, for tests
+type memoryMaintenances struct {
+	table *store.Table[MainteneceShedule]
+}
+
+func newMemoryMaintenances() memoryMaintenances {
+	return memoryMaintenances{store.NewTable(func(m *MainteneceShedule) (*primitive.ObjectID, **time.Time, *string) {
+		return &m.ID, &m.DeletedAt, &m.DeletedBy
+	})}
+}
+
+func (m memoryMaintenances) List(ctx context.Context) ([]MainteneceShedule, error) {
+	return m.table.Live(nil), nil
+}
+
+func (m memoryMaintenances) ListByAsset(ctx context.Context, assetID primitive.ObjectID) ([]MainteneceShedule, error) {
+	return m.table.Live(func(item MainteneceShedule) bool { return item.AssetID == assetID }), nil
+}
+
+func (m memoryMaintenances) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]MainteneceShedule, error) {
+	return m.table.Live(func(item MainteneceShedule) bool { return holds(ids, item.ID) }), nil
+}
+
+func (m memoryMaintenances) Referencing(ctx context.Context, field string, id primitive.ObjectID) ([]MainteneceShedule, error) {
+	return m.table.Live(maintenanceReferences(field, id)), nil
+}
+
+func (m memoryMaintenances) Get(ctx context.Context, id primitive.ObjectID) (MainteneceShedule, error) {
+	return m.table.Get(id)
+}
+
+func (m memoryMaintenances) Insert(ctx context.Context, item MainteneceShedule) error {
+	return m.table.Insert(item)
+}
+
+func (m memoryMaintenances) Update(ctx context.Context, item MainteneceShedule) error {
+	_, err := m.table.UpdateVersion(item.ID, item.Version, maintenanceVersion, func(rec *MainteneceShedule) {
+		rec.Lable, rec.AssetID, rec.Shedules = item.Lable, item.AssetID, item.Shedules
+	})
+	return err
+}
+
+func (m memoryMaintenances) Delete(ctx context.Context, id primitive.ObjectID, actor string) error {
+	return m.table.Delete(id, actor)
+}
+
+func (m memoryMaintenances) ListDeleted(ctx context.Context) ([]MainteneceShedule, error) {
+	return m.table.Deleted(), nil
+}
+
+func (m memoryMaintenances) GetDeleted(ctx context.Context, id primitive.ObjectID) (MainteneceShedule, error) {
+	return m.table.GetDeleted(id)
+}
+
+func (m memoryMaintenances) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Restore(id)
+}
+
+func (m memoryMaintenances) Purge(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Purge(id)
+}
+
+func (m memoryMaintenances) PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	return m.table.PurgeExpired(retention), nil
+}
+
+// memorySchedules is a ScheduleRepository kept in memory, for tests
+type memorySchedules struct {
+	table *store.Table[ScheduleDoc]
+}
+
+func newMemorySchedules() memorySchedules {
+	return memorySchedules{store.NewTable(func(s *ScheduleDoc) (*primitive.ObjectID, **time.Time, *string) {
+		return &s.ID, &s.DeletedAt, &s.DeletedBy
+	})}
+}
+
+func (m memorySchedules) List(ctx context.Context) ([]ScheduleDoc, error) {
+	return m.table.Live(nil), nil
+}
+
+func (m memorySchedules) Find(ctx context.Context, f ScheduleFilter) ([]ScheduleDoc, error) {
+	return m.table.Live(f.matches), nil
+}
+
+func (m memorySchedules) Referencing(ctx context.Context, field string, id primitive.ObjectID) ([]ScheduleDoc, error) {
+	return m.table.Live(scheduleReferences(field, id)), nil
+}
+
+func (m memorySchedules) Get(ctx context.Context, id primitive.ObjectID) (ScheduleDoc, error) {
+	return m.table.Get(id)
+}
+
+func (m memorySchedules) GetAny(ctx context.Context, id primitive.ObjectID) (ScheduleDoc, error) {
+	return m.table.GetAny(id)
+}
+
+func (m memorySchedules) Insert(ctx context.Context, s ScheduleDoc) error {
+	return m.table.Insert(s)
+}
+
+func (m memorySchedules) Update(ctx context.Context, s ScheduleDoc) error {
+	_, err := m.table.UpdateVersion(s.ID, s.Version, scheduleVersion, func(rec *ScheduleDoc) {
+		s.DeletedAt, s.DeletedBy = rec.DeletedAt, rec.DeletedBy
+		*rec = s
+	})
+	return err
+}
+
+func (m memorySchedules) Delete(ctx context.Context, id primitive.ObjectID, actor string) error {
+	return m.table.Delete(id, actor)
+}
+
+func (m memorySchedules) ListDeleted(ctx context.Context) ([]ScheduleDoc, error) {
+	return m.table.Deleted(), nil
+}
+
+func (m memorySchedules) GetDeleted(ctx context.Context, id primitive.ObjectID) (ScheduleDoc, error) {
+	return m.table.GetDeleted(id)
+}
+
+func (m memorySchedules) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Restore(id)
+}
+
+func (m memorySchedules) Purge(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Purge(id)
+}
+
+func (m memorySchedules) PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	return m.table.PurgeExpired(retention), nil
+}
+
+// memoryCompletions is a CompletionRepository kept in memory, for tests
+type memoryCompletions struct {
+	mu          sync.Mutex
+	completions []ScheduleCompletion
+}
+
+func (m *memoryCompletions) List(ctx context.Context, scheduleIDs []primitive.ObjectID, from, to time.Time) ([]ScheduleCompletion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := []ScheduleCompletion{}
+	for _, c := range m.completions {
+		if holds(scheduleIDs, c.ScheduleID) && !c.DueDate.Before(from) && c.DueDate.Before(to) {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+func (m *memoryCompletions) Get(ctx context.Context, scheduleID primitive.ObjectID, due time.Time) (ScheduleCompletion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.completions {
+		if c.ScheduleID == scheduleID && c.DueDate.Equal(due) {
+			return c, nil
+		}
+	}
+	return ScheduleCompletion{}, store.ErrNotFound
+}
+
+func (m *memoryCompletions) Save(ctx context.Context, c ScheduleCompletion) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, existing := range m.completions {
+		if existing.ScheduleID == c.ScheduleID && existing.DueDate.Equal(c.DueDate) {
+			c.ID = existing.ID
+			m.completions[i] = c
+			return nil
+		}
+	}
+	m.completions = append(m.completions, c)
+	return nil
+}
+
+// memorySubscriptions is a SubscriptionRepository kept in memory, for tests
+type memorySubscriptions struct {
+	mu   sync.Mutex
+	subs []NotificationSubscription
+}
+
+func (m *memorySubscriptions) List(ctx context.Context) ([]NotificationSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]NotificationSubscription{}, m.subs...), nil
+}
+
+func (m *memorySubscriptions) Insert(ctx context.Context, sub NotificationSubscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subs = append(m.subs, sub)
+	return nil
+}
+
+func (m *memorySubscriptions) Delete(ctx context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subs = filter(m.subs, func(s NotificationSubscription) bool { return s.ID != id })
+	return nil
+}
+
+// memoryNotificationLog is a NotificationLogRepository kept in memory, for
+// tests
+type memoryNotificationLog struct {
+	mu      sync.Mutex
+	entries map[string]NotificationLog
+}
+
+func (m *memoryNotificationLog) Claim(ctx context.Context, e NotificationLog) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.entries[e.ID]; ok {
+		return false, nil
+	}
+	if m.entries == nil {
+		m.entries = map[string]NotificationLog{}
+	}
+	m.entries[e.ID] = e
+	return true, nil
+}
+
+func (m *memoryNotificationLog) Release(ctx context.Context, ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range ids {
+		delete(m.entries, id)
+	}
+	return nil
+}
@@ -1,4 +1,4 @@
-package main
+package maintenence
 
 import (
 	"time"
@@ -6,47 +6,68 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Service is a service of the service service, converted from its gRPC
+// message by serviceFromProto
 type Service struct {
-	ID    primitive.ObjectID `bson:"_id"`
-	Label string             `bson:"label"`
-	Notes string             `bson:"notes"`
+	ID    primitive.ObjectID
+	Label string
+	Notes string
 }
 
+// Consumable is a consumable of the consumable service, converted from its
+// gRPC message by consumableFromProto
 type Consumable struct {
-	ID    primitive.ObjectID `bson:"_id"`
-	Label string             `bson:"label"`
-	Notes string             `bson:"notes"`
+	ID    primitive.ObjectID
+	Label string
+	Notes string
 }
 
 type Shedule struct {
-	ID          primitive.ObjectID   `bson:"_id"`
-	Lable       string               `bson:"label"`
-	SheduleType string               `bson:"shedule_type"`
-	Days        int                  `bson:"days"`
-	Services    []primitive.ObjectID `bson:"services"`
-	Consumables []primitive.ObjectID `bson:"consumables"`
-	Notes       string               `bson:"notes"`
+	ID          primitive.ObjectID   `bson:"_id" json:"id"`
+	Lable       string               `bson:"label" json:"label"`
+	SheduleType string               `bson:"schedule_type" json:"schedule_type"`
+	Days        int                  `bson:"days" json:"days"`
+	Services    []primitive.ObjectID `bson:"services" json:"services"`
+	Consumables []primitive.ObjectID `bson:"consumables" json:"consumables"`
+	Notes       string               `bson:"notes" json:"notes"`
 }
 
 type MainteneceShedule struct {
-	ID       primitive.ObjectID `bson:"_id"`
-	Lable    string             `bson:"label"`
-	AssetID  primitive.ObjectID `bson:"asset_id"`
-	Shedules []Shedule          `bson:"shedules,omitempty"`
+	ID       primitive.ObjectID `bson:"_id" json:"id"`
+	Lable    string             `bson:"label" json:"label"`
+	AssetID  primitive.ObjectID `bson:"asset_id" json:"asset_id"`
+	Shedules []Shedule          `bson:"shedules,omitempty" json:"shedules,omitempty"`
+	// Version counts the saved changes; an edit must be based on the latest
+	Version int64 `bson:"version" json:"version"`
+
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy string     `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
 }
 
 type ScheduleDoc struct {
-	ID            primitive.ObjectID   `bson:"_id"`
-	MaintenanceID *primitive.ObjectID  `bson:"maintenance_id,omitempty"`
-	AssetID       primitive.ObjectID   `bson:"asset_id"`
-	Lable         string               `bson:"label"`
-	SheduleType   string               `bson:"shedule_type"`
-	Days          int                  `bson:"days"`
-	Services      []primitive.ObjectID `bson:"services"`
-	Consumables   []primitive.ObjectID `bson:"consumables"`
-	Notes         string               `bson:"notes"`
+	ID            primitive.ObjectID   `bson:"_id" json:"id"`
+	MaintenanceID *primitive.ObjectID  `bson:"maintenance_id,omitempty" json:"maintenance_id,omitempty"`
+	AssetID       primitive.ObjectID   `bson:"asset_id" json:"asset_id"`
+	Lable         string               `bson:"label" json:"label"`
+	SheduleType   string               `bson:"schedule_type" json:"schedule_type"`
+	Days          int                  `bson:"days" json:"days"`
+	Services      []primitive.ObjectID `bson:"services" json:"services"`
+	Consumables   []primitive.ObjectID `bson:"consumables" json:"consumables"`
+	Notes         string               `bson:"notes" json:"notes"`
+	// Version counts the saved changes; an edit must be based on the latest
+	Version int64 `bson:"version" json:"version"`
+
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy string     `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
 }
 
+// maintenanceVersion and scheduleVersion are the version fields of the
+// versioned updates of the tables
+func maintenanceVersion(m *MainteneceShedule) *int64 { return &m.Version }
+func scheduleVersion(s *ScheduleDoc) *int64          { return &s.Version }
+
+// Asset is an asset of the asset service, converted from its gRPC message by
+// assetFromProto
 type Asset struct {
 	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Label         string             `bson:"label" json:"label"`
@@ -60,3 +81,37 @@ type AssetsPageData struct {
 	Message string
 	Error   string
 }
+
+// ScheduleCompletion records that the occurrence of a schedule due on DueDate
+// was carried out on CompletedAt.
+type ScheduleCompletion struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	ScheduleID  primitive.ObjectID `bson:"schedule_id" json:"schedule_id"`
+	AssetID     primitive.ObjectID `bson:"asset_id" json:"asset_id"`
+	DueDate     time.Time          `bson:"due_date" json:"due_date"`
+	CompletedAt time.Time          `bson:"completed_at" json:"completed_at"`
+	Notes       string             `bson:"notes" json:"notes"`
+}
+
+// NotificationSubscription asks for emails about schedules of one asset, one
+// location or one maintenance; with none of them set it covers the whole plant.
+type NotificationSubscription struct {
+	ID            primitive.ObjectID  `bson:"_id"`
+	Email         string              `bson:"email"`
+	AssetID       *primitive.ObjectID `bson:"asset_id,omitempty"`
+	Location      string              `bson:"location,omitempty"`
+	MaintenanceID *primitive.ObjectID `bson:"maintenance_id,omitempty"`
+	DaysAhead     int                 `bson:"days_ahead"`
+	Overdue       bool                `bson:"overdue"`
+}
+
+// NotificationLog remembers which occurrence was mailed to whom so that it is
+// never sent twice. The id is derived from its content.
+type NotificationLog struct {
+	ID         string             `bson:"_id"`
+	Email      string             `bson:"email"`
+	ScheduleID primitive.ObjectID `bson:"schedule_id"`
+	DueDate    time.Time          `bson:"due_date"`
+	Kind       string             `bson:"kind"`
+	SentAt     time.Time          `bson:"sent_at"`
+}
@@ -0,0 +1,401 @@
+package maintenence
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"shared/config"
+	"shared/csrf"
+	"shared/flash"
+	"shared/validate"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of notification; each occurrence is mailed at most once per kind
+const (
+	NotifyDue     = "due"
+	NotifyOverdue = "overdue"
+)
+
+// overdueLookbackDays is how far back overdue occurrences are reported
+const overdueLookbackDays = 30
+
+// loadNotifierConfig returns the notify section of the configuration, with
+// the base URL of the links resolved
+func loadNotifierConfig() config.NotifyConfig {
+	cfg := conf.Notify
+	cfg.BaseURL = conf.NotifyBaseURL()
+	return cfg
+}
+
+var (
+	emailText = template.Must(template.ParseFS(templateFS, "templates/email/notification.txt"))
+	emailHTML = htmltemplate.Must(htmltemplate.New("notification.html").Funcs(templateFuncs).ParseFS(templateFS, "templates/email/notification.html"))
+)
+
+// emailData is passed to both email templates
+type emailData struct {
+	Email   string
+	BaseURL string
+	Due     []Occurrence
+	Overdue []Occurrence
+}
+
+// startNotifier runs the notification pass periodically until ctx is done
+func startNotifier(ctx context.Context, cfg config.NotifyConfig) {
+	if !cfg.Enabled {
+		log.Println("email notifications disabled; set notify.enabled to turn them on")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			if err := runNotifications(ctx, cfg); err != nil {
+				log.Printf("notifications: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// runNotifications mails every subscriber the occurrences due within their
+// horizon and, if asked, the overdue ones they were not told about yet
+func runNotifications(ctx context.Context, cfg config.NotifyConfig) error {
+	subs, err := repo.Subscriptions.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		if err := notifySubscriber(ctx, cfg, sub); err != nil {
+			log.Printf("notifications: %s: %v", sub.Email, err)
+		}
+	}
+	return nil
+}
+
+func notifySubscriber(ctx context.Context, cfg config.NotifyConfig, sub NotificationSubscription) error {
+	today := truncateDay(time.Now())
+	daysAhead := sub.DaysAhead
+	if daysAhead < 1 {
+		daysAhead = cfg.DaysAhead
+	}
+
+	from := today
+	if sub.Overdue {
+		from = today.AddDate(0, 0, -overdueLookbackDays)
+	}
+	to := today.AddDate(0, 0, daysAhead+1)
+
+	filter := OccurrenceFilter{
+		AssetID:       sub.AssetID,
+		Location:      sub.Location,
+		MaintenanceID: sub.MaintenanceID,
+	}
+	occurrences, _, err := projectOccurrences(ctx, filter, from, to)
+	if err != nil {
+		return err
+	}
+
+	var scheduleIDs []primitive.ObjectID
+	for _, o := range occurrences {
+		if oid, err := primitive.ObjectIDFromHex(o.ScheduleID); err == nil {
+			scheduleIDs = append(scheduleIDs, oid)
+		}
+	}
+	completed, err := completedOccurrences(ctx, scheduleIDs, from, to)
+	if err != nil {
+		return err
+	}
+
+	// Claim every occurrence in the log before sending; the claim fails for
+	// occurrences that were already mailed
+	data := emailData{Email: sub.Email, BaseURL: cfg.BaseURL}
+	var claimed []string
+	for _, o := range occurrences {
+		oid, _ := primitive.ObjectIDFromHex(o.ScheduleID)
+		if _, done := completed[completionKey(oid, o.Due)]; done {
+			continue
+		}
+
+		kind := NotifyDue
+		if o.Due.Before(today) {
+			kind = NotifyOverdue
+		}
+
+		entry := NotificationLog{
+			ID:         strings.Join([]string{sub.Email, o.ScheduleID, o.Due.Format("2006-01-02"), kind}, "|"),
+			Email:      sub.Email,
+			ScheduleID: oid,
+			DueDate:    o.Due,
+			Kind:       kind,
+			SentAt:     time.Now(),
+		}
+		ok, err := repo.NotificationLog.Claim(ctx, entry)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		claimed = append(claimed, entry.ID)
+
+		if kind == NotifyOverdue {
+			data.Overdue = append(data.Overdue, o)
+		} else {
+			data.Due = append(data.Due, o)
+		}
+	}
+
+	if len(claimed) == 0 {
+		return nil
+	}
+
+	subject := fmt.Sprintf("CMMS: %d maintenance task(s) due soon", len(data.Due))
+	if len(data.Overdue) > 0 {
+		subject = fmt.Sprintf("CMMS: %d overdue and %d upcoming maintenance task(s)", len(data.Overdue), len(data.Due))
+	}
+
+	if err := sendEmail(cfg, sub.Email, subject, data); err != nil {
+		// Release the claims so the next pass tries again
+		_ = repo.NotificationLog.Release(ctx, claimed)
+		return err
+	}
+
+	log.Printf("notifications: sent %d occurrence(s) to %s", len(claimed), sub.Email)
+	return nil
+}
+
+// sendEmail renders both templates into a multipart/alternative message
+func sendEmail(cfg config.NotifyConfig, to, subject string, data emailData) error {
+	var text, html bytes.Buffer
+	if err := emailText.Execute(&text, data); err != nil {
+		return err
+	}
+	if err := emailHTML.Execute(&html, data); err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", text.Bytes()},
+		{"text/html; charset=utf-8", html.Bytes()},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		if _, err := qw.Write(part.content); err != nil {
+			return err
+		}
+		if err := qw.Close(); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.SMTP.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+
+	var auth smtp.Auth
+	if cfg.SMTP.Username != "" {
+		auth = smtp.PlainAuth("", cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.Host)
+	}
+	addr := net.JoinHostPort(cfg.SMTP.Host, strconv.Itoa(cfg.SMTP.Port))
+	return smtp.SendMail(addr, auth, cfg.SMTP.From, []string{to}, msg.Bytes())
+}
+
+// List notification subscriptions
+func listSubscriptions(w http.ResponseWriter, r *http.Request) {
+	renderSubscriptions(w, r, nil)
+}
+
+// renderSubscriptions shows the notification subscriptions and, when f is
+// not nil, the subscribe form that did not validate
+func renderSubscriptions(w http.ResponseWriter, r *http.Request, f *validate.Form) {
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	subs, err := repo.Subscriptions.List(ctx)
+	if err != nil {
+		http.Error(w, "Failed to fetch subscriptions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var assetIDs []primitive.ObjectID
+	for _, s := range subs {
+		if s.AssetID != nil {
+			assetIDs = append(assetIDs, *s.AssetID)
+		}
+	}
+	var warnings incomplete
+	assetLabels, err := getAssetLabels(ctx, assetIDs)
+	warnings.add(err)
+
+	options, err := calendarFilterOptions(ctx)
+	warnings.add(err)
+	maintMap := map[string]string{}
+	for _, m := range options.Maintenances {
+		maintMap[m.ID.Hex()] = m.Lable
+	}
+
+	assets, err := fetchAssetsFromAPI(ctx, "", "")
+	warnings.add(err)
+
+	message, messageType := flashMessage(w, r)
+	data := struct {
+		Subscriptions []NotificationSubscription
+		AssetLabels   map[string]string
+		MaintMap      map[string]string
+		Assets        []Asset
+		Options       CalendarFilterOptions
+		DaysAhead     int
+		Message       string
+		MessageType   string
+		Warnings      []string
+		Form          *validate.Form
+		CSRF          htmltemplate.HTML
+	}{
+		Subscriptions: subs,
+		AssetLabels:   assetLabels,
+		MaintMap:      maintMap,
+		Assets:        assets,
+		Options:       options,
+		DaysAhead:     loadNotifierConfig().DaysAhead,
+		Message:       message,
+		MessageType:   messageType,
+		Warnings:      warnings,
+		Form:          f,
+		CSRF:          csrf.Field(r),
+	}
+
+	pageStatus(w, f)
+	renderTemplate(w, "notifications.html", data)
+}
+
+// Subscribe an email address to notifications
+func subscribeNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	f, err := validate.Parse(r, "create")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sub := NotificationSubscription{
+		ID:            primitive.NewObjectID(),
+		Email:         f.Email("email"),
+		Location:      f.Get("location"),
+		Overdue:       f.Get("overdue") != "",
+		AssetID:       f.OptionalID("asset_id"),
+		MaintenanceID: f.OptionalID("maintenance_id"),
+	}
+	if f.Get("days_ahead") != "" {
+		sub.DaysAhead = f.Int("days_ahead", 1, maxScheduleDays)
+	}
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	if sub.AssetID != nil && f.Errors["asset_id"] == "" {
+		checkAsset(ctx, f, "asset_id", *sub.AssetID)
+	}
+	if sub.MaintenanceID != nil && f.Errors["maintenance_id"] == "" {
+		_, err := repo.Maintenances.Get(ctx, *sub.MaintenanceID)
+		f.Check(err == nil, "maintenance_id", "The maintenance does not exist")
+	}
+	if !f.Valid() {
+		showForm(w, r, f, renderSubscriptions)
+		return
+	}
+
+	if err := repo.Subscriptions.Insert(ctx, sub); err != nil {
+		http.Error(w, "Insert error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	flash.Redirect(w, r, "/notifications", flash.Success, "Subscription added successfully")
+}
+
+// Remove a notification subscription
+func unsubscribeNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	objID, err := primitive.ObjectIDFromHex(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	if err := repo.Subscriptions.Delete(ctx, objID); err != nil {
+		http.Error(w, "Delete error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	flash.Redirect(w, r, "/notifications", flash.Success, "Subscription removed successfully")
+}
+
+// Run a notification pass immediately instead of waiting for the next tick
+func runNotificationsNow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	if err := runNotifications(ctx, loadNotifierConfig()); err != nil {
+		log.Printf("notification run failed: %v", err)
+		flash.Redirect(w, r, "/notifications", flash.Error, "Notification run failed")
+		return
+	}
+
+	flash.Redirect(w, r, "/notifications", flash.Success, "Notifications sent")
+}
@@ -0,0 +1,155 @@
+package maintenence
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Occurrence is a single planned due date of a schedule, with everything
+// needed to display it without further lookups
+type Occurrence struct {
+	ScheduleID       string    `json:"schedule_id"`
+	ScheduleLabel    string    `json:"schedule_label"`
+	ScheduleType     string    `json:"schedule_type"`
+	AssetID          string    `json:"asset_id"`
+	AssetLabel       string    `json:"asset_label"`
+	AssetType        string    `json:"asset_type"`
+	Location         string    `json:"location"`
+	MaintenanceID    string    `json:"maintenance_id,omitempty"`
+	MaintenanceLabel string    `json:"maintenance_label,omitempty"`
+	Due              time.Time `json:"due"`
+	Services         []string  `json:"services"`
+	Consumables      []string  `json:"consumables"`
+	Notes            string    `json:"notes"`
+}
+
+// OccurrenceFilter narrows the schedules projected by projectOccurrences;
+// zero values match everything
+type OccurrenceFilter struct {
+	AssetID       *primitive.ObjectID
+	AssetType     string
+	Location      string
+	MaintenanceID *primitive.ObjectID
+	ServiceID     *primitive.ObjectID
+}
+
+// projectOccurrences expands the recurrence of every matching schedule into
+// its due dates in [from, to). Labels that could not be fetched from the other
+// services fall back to ids and are reported in the returned incomplete.
+func projectOccurrences(ctx context.Context, f OccurrenceFilter, from, to time.Time) ([]Occurrence, incomplete, error) {
+	var warnings incomplete
+
+	// Resolve the assets in scope; type and location only live on the asset service
+	assets := map[primitive.ObjectID]Asset{}
+	scheduleFilter := ScheduleFilter{MaintenanceID: f.MaintenanceID, ServiceID: f.ServiceID}
+	if f.AssetID != nil {
+		scheduleFilter.AssetIDs = []primitive.ObjectID{*f.AssetID}
+		asset, err := fetchAssetFromAPI(ctx, f.AssetID.Hex())
+		if asset != nil {
+			assets[asset.ID] = *asset
+		}
+		if !isNotFound(err) {
+			warnings.add(err)
+		}
+	} else {
+		scoped := f.AssetType != "" || f.Location != ""
+		list, err := fetchAssetsFromAPI(ctx, f.AssetType, f.Location)
+		if err != nil && scoped {
+			return nil, nil, err
+		}
+		warnings.add(err)
+		ids := []primitive.ObjectID{}
+		for _, a := range list {
+			assets[a.ID] = a
+			ids = append(ids, a.ID)
+		}
+		if scoped {
+			if len(ids) == 0 {
+				// No asset of that type or location, so nothing is planned
+				return []Occurrence{}, warnings, nil
+			}
+			scheduleFilter.AssetIDs = ids
+		}
+	}
+
+	schedules, err := repo.Schedules.Find(ctx, scheduleFilter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var svcIDs, consIDs, maintIDs []primitive.ObjectID
+	for _, s := range schedules {
+		svcIDs = append(svcIDs, s.Services...)
+		consIDs = append(consIDs, s.Consumables...)
+		if s.MaintenanceID != nil {
+			maintIDs = append(maintIDs, *s.MaintenanceID)
+		}
+	}
+	svcNames, consNames, err := buildNameMaps(ctx, svcIDs, consIDs)
+	warnings.add(err)
+
+	maintMap := map[primitive.ObjectID]string{}
+	if len(maintIDs) > 0 {
+		maintenances, err := repo.Maintenances.GetByIDs(ctx, maintIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, m := range maintenances {
+			maintMap[m.ID] = m.Lable
+		}
+	}
+
+	result := []Occurrence{}
+	for _, s := range schedules {
+		due := occurrencesBetween(s, from, to)
+		if len(due) == 0 {
+			continue
+		}
+
+		base := Occurrence{
+			ScheduleID:    s.ID.Hex(),
+			ScheduleLabel: s.Lable,
+			ScheduleType:  s.SheduleType,
+			AssetID:       s.AssetID.Hex(),
+			AssetLabel:    s.AssetID.Hex(),
+			Notes:         s.Notes,
+			Services:      []string{},
+			Consumables:   []string{},
+		}
+		if asset, ok := assets[s.AssetID]; ok {
+			if asset.Label != "" {
+				base.AssetLabel = asset.Label
+			}
+			base.AssetType = asset.Type
+			base.Location = asset.Location
+		}
+		if s.MaintenanceID != nil {
+			base.MaintenanceID = s.MaintenanceID.Hex()
+			base.MaintenanceLabel = maintMap[*s.MaintenanceID]
+		}
+		for _, id := range s.Services {
+			base.Services = append(base.Services, svcNames[id.Hex()])
+		}
+		for _, id := range s.Consumables {
+			base.Consumables = append(base.Consumables, consNames[id.Hex()])
+		}
+
+		for _, d := range due {
+			occ := base
+			occ.Due = d
+			result = append(result, occ)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if !result[i].Due.Equal(result[j].Due) {
+			return result[i].Due.Before(result[j].Due)
+		}
+		return result[i].AssetLabel < result[j].AssetLabel
+	})
+
+	return result, warnings, nil
+}
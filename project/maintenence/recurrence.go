@@ -0,0 +1,128 @@
+package maintenence
+
+import (
+	"time"
+)
+
+// Schedule types understood by the recurrence helpers. Days is the number of
+// those units between two occurrences, so a "weekly" schedule with Days=2 is
+// due every other week.
+const (
+	ScheduleDaily   = "daily"
+	ScheduleWeekly  = "weekly"
+	ScheduleMonthly = "monthly"
+	ScheduleYearly  = "yearly"
+)
+
+// maxOccurrences bounds the expansion of a single schedule so that a bad
+// range can never loop for ever.
+const maxOccurrences = 10000
+
+// scheduleAnchor returns the day a schedule started counting from. Schedules
+// have no explicit start date, so the creation time embedded in the ObjectID
+// is used.
+func scheduleAnchor(s ScheduleDoc) time.Time {
+	return truncateDay(s.ID.Timestamp())
+}
+
+// scheduleStep returns the years, months and days between two occurrences
+func scheduleStep(s ScheduleDoc) (int, int, int) {
+	n := s.Days
+	if n < 1 {
+		n = 1
+	}
+
+	switch s.SheduleType {
+	case ScheduleWeekly:
+		return 0, 0, 7 * n
+	case ScheduleMonthly:
+		return 0, n, 0
+	case ScheduleYearly:
+		return n, 0, 0
+	default:
+		return 0, 0, n
+	}
+}
+
+// occurrenceAt returns the k-th due date of a schedule. Occurrences are
+// computed from the anchor each time so nothing drifts, and monthly or yearly
+// schedules anchored on the 31st fall on the last day of shorter months.
+func occurrenceAt(s ScheduleDoc, k int) time.Time {
+	y, m, d := scheduleStep(s)
+	anchor := scheduleAnchor(s)
+	if d != 0 {
+		return anchor.AddDate(0, 0, k*d)
+	}
+
+	first := time.Date(anchor.Year(), anchor.Month()+time.Month(k*(12*y+m)), 1, 0, 0, 0, 0, time.UTC)
+	day := anchor.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// occurrencesBetween returns every due date of a schedule in [from, to). The
+// first occurrence is one interval after the anchor.
+func occurrencesBetween(s ScheduleDoc, from, to time.Time) []time.Time {
+	var result []time.Time
+
+	k := 1
+	if y, m, d := scheduleStep(s); y == 0 && m == 0 {
+		// Fixed length steps: jump straight to the first occurrence near from
+		if skip := int(from.Sub(scheduleAnchor(s)).Hours()/24) / d; skip > k {
+			k = skip
+		}
+	}
+
+	for n := 0; n < maxOccurrences; n, k = n+1, k+1 {
+		due := occurrenceAt(s, k)
+		if !due.Before(to) {
+			break
+		}
+		if !due.Before(from) {
+			result = append(result, due)
+		}
+	}
+
+	return result
+}
+
+// nextOccurrence returns the first due date of a schedule on or after t
+func nextOccurrence(s ScheduleDoc, t time.Time) time.Time {
+	y, m, d := scheduleStep(s)
+	horizon := t.AddDate(y+1, m+1, d+1)
+	if due := occurrencesBetween(s, truncateDay(t), horizon); len(due) > 0 {
+		return due[0]
+	}
+	return horizon
+}
+
+// nearestOccurrence snaps t to the closest due date of a schedule
+func nearestOccurrence(s ScheduleDoc, t time.Time) time.Time {
+	y, m, d := scheduleStep(s)
+	candidates := occurrencesBetween(s, t.AddDate(-y, -m, -d-1), t.AddDate(y, m, d+1))
+	if len(candidates) == 0 {
+		return nextOccurrence(s, t)
+	}
+
+	best := candidates[0]
+	for _, due := range candidates[1:] {
+		if absDuration(due.Sub(t)) < absDuration(best.Sub(t)) {
+			best = due
+		}
+	}
+	return best
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,337 @@
+package maintenence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"shared/audit"
+	"shared/config"
+	"shared/references"
+	"shared/webhook"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// findReferences lists the live maintenances and schedules pointing at the
+// kind record with the given id
+func findReferences(ctx context.Context, kind string, id primitive.ObjectID) ([]references.Reference, error) {
+	var maintenanceField, scheduleField string
+	switch kind {
+	case references.KindAsset:
+		maintenanceField, scheduleField = "asset_id", "asset_id"
+	case references.KindMaintenance:
+		scheduleField = "maintenance_id"
+	case references.KindService:
+		maintenanceField, scheduleField = "shedules.services", "services"
+	case references.KindConsumable:
+		maintenanceField, scheduleField = "shedules.consumables", "consumables"
+	default:
+		return nil, fmt.Errorf("unknown kind %q", kind)
+	}
+
+	refs := []references.Reference{}
+
+	if maintenanceField != "" {
+		items, err := repo.Maintenances.Referencing(ctx, maintenanceField, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range items {
+			refs = append(refs, references.Reference{Entity: references.KindMaintenance, ID: m.ID, Label: m.Lable, AssetID: m.AssetID, Field: maintenanceField, URL: conf.PublicURL(config.Maintenance) + "/maintenances/view?id=" + m.ID.Hex()})
+		}
+	}
+
+	schedules, err := repo.Schedules.Referencing(ctx, scheduleField, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range schedules {
+		refs = append(refs, references.Reference{Entity: "schedule", ID: s.ID, Label: s.Lable, AssetID: s.AssetID, Field: scheduleField, URL: conf.PublicURL(config.Maintenance) + "/schedules?asset_id=" + s.AssetID.Hex()})
+	}
+
+	return refs, nil
+}
+
+// resolveReferences removes every reference to the kind record with the given
+// id so it can be deleted. With references.ActionCascade the referencing
+// maintenances and schedules of an asset or maintenance go to the recycle bin
+// and a service or consumable is taken out of the schedules using it; with
+// references.ActionReassign the references point at to instead. It returns
+// the number of records changed.
+func resolveReferences(ctx context.Context, r *http.Request, kind string, id primitive.ObjectID, action string, to primitive.ObjectID) (int, error) {
+	switch action {
+	case references.ActionCascade:
+	case references.ActionReassign:
+		if to.IsZero() || to == id {
+			return 0, errors.New("choose another record to reassign the references to")
+		}
+	default:
+		return 0, fmt.Errorf("unknown action %q", action)
+	}
+
+	// A schedule moved to another maintenance moves to that maintenance's asset
+	var target MainteneceShedule
+	if kind == references.KindMaintenance && action == references.ActionReassign {
+		var err error
+		if target, err = repo.Maintenances.Get(ctx, to); err != nil {
+			return 0, errors.New("maintenance to reassign to not found")
+		}
+	}
+
+	refs, err := findReferences(ctx, kind, id)
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, ref := range refs {
+		switch {
+		case kind == references.KindAsset || kind == references.KindMaintenance:
+			if action == references.ActionCascade {
+				err = cascadeDelete(ctx, r, ref)
+			} else {
+				set := bson.M{ref.Field: to}
+				if kind == references.KindMaintenance {
+					set["asset_id"] = target.AssetID
+				}
+				err = updateRecord(ctx, r, ref, set)
+			}
+		default:
+			err = replaceInSchedules(ctx, r, ref, id, to)
+		}
+		if err != nil {
+			return updated, fmt.Errorf("%s %s: %w", ref.Entity, ref.Label, err)
+		}
+		updated++
+	}
+
+	return updated, nil
+}
+
+// cascadeDelete moves a referencing maintenance or schedule to the recycle bin
+func cascadeDelete(ctx context.Context, r *http.Request, ref references.Reference) error {
+	if ref.Entity == references.KindMaintenance {
+		item, err := repo.Maintenances.Get(ctx, ref.ID)
+		if err != nil {
+			return err
+		}
+		if err := repo.Maintenances.Delete(ctx, ref.ID, audit.Actor(r)); err != nil {
+			return err
+		}
+		recordAudit(ctx, r, audit.ActionDelete, "maintenance", item.ID, item.Lable, item, nil)
+		publishEvent(ctx, webhook.MaintenanceDeleted, item)
+		return nil
+	}
+
+	sched, err := repo.Schedules.Get(ctx, ref.ID)
+	if err != nil {
+		return err
+	}
+	_, err = changeSchedule(ctx, r, ref.ID, func(ctx context.Context) error {
+		return repo.Schedules.Delete(ctx, ref.ID, audit.Actor(r))
+	})
+	if err != nil {
+		return err
+	}
+	recordAudit(ctx, r, audit.ActionDelete, "schedule", sched.ID, sched.Lable, sched, nil)
+	publishEvent(ctx, webhook.ScheduleDeleted, sched)
+	return nil
+}
+
+// applySet returns rec with the fields in set, named as in the documents,
+// replaced
+func applySet[T any](rec T, set bson.M) (T, error) {
+	var out T
+	raw, err := bson.Marshal(rec)
+	if err != nil {
+		return out, err
+	}
+	doc := bson.M{}
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return out, err
+	}
+	for k, v := range set {
+		doc[k] = v
+	}
+	if raw, err = bson.Marshal(doc); err != nil {
+		return out, err
+	}
+	err = bson.Unmarshal(raw, &out)
+	return out, err
+}
+
+// updateRecord sets the fields in set on a referencing maintenance or schedule
+func updateRecord(ctx context.Context, r *http.Request, ref references.Reference, set bson.M) error {
+	if ref.Entity == references.KindMaintenance {
+		before, err := repo.Maintenances.Get(ctx, ref.ID)
+		if err != nil {
+			return err
+		}
+		after, err := applySet(before, set)
+		if err != nil {
+			return err
+		}
+		if err := repo.Maintenances.Update(ctx, after); err != nil {
+			return err
+		}
+		recordAudit(ctx, r, audit.ActionUpdate, "maintenance", after.ID, after.Lable, before, after)
+		publishEvent(ctx, webhook.MaintenanceUpdated, after)
+		return nil
+	}
+
+	before, err := repo.Schedules.Get(ctx, ref.ID)
+	if err != nil {
+		return err
+	}
+	update, err := applySet(before, set)
+	if err != nil {
+		return err
+	}
+	after, err := changeSchedule(ctx, r, ref.ID, func(ctx context.Context) error {
+		return repo.Schedules.Update(ctx, update)
+	})
+	if err != nil {
+		return err
+	}
+	recordAudit(ctx, r, audit.ActionUpdate, "schedule", after.ID, after.Lable, before, after)
+	publishEvent(ctx, webhook.ScheduleUpdated, after)
+	return nil
+}
+
+// replaceInSchedules swaps the service or consumable id for to in a
+// referencing schedule, or in the embedded schedules of a maintenance; a zero
+// to removes it
+func replaceInSchedules(ctx context.Context, r *http.Request, ref references.Reference, id, to primitive.ObjectID) error {
+	if ref.Entity == references.KindMaintenance {
+		before, err := repo.Maintenances.Get(ctx, ref.ID)
+		if err != nil {
+			return err
+		}
+		after := before
+		after.Shedules = make([]Shedule, len(before.Shedules))
+		for i, s := range before.Shedules {
+			if ref.Field == "shedules.services" {
+				s.Services = replaceID(s.Services, id, to)
+			} else {
+				s.Consumables = replaceID(s.Consumables, id, to)
+			}
+			after.Shedules[i] = s
+		}
+		if err := repo.Maintenances.Update(ctx, after); err != nil {
+			return err
+		}
+		recordAudit(ctx, r, audit.ActionUpdate, "maintenance", after.ID, after.Lable, before, after)
+		publishEvent(ctx, webhook.MaintenanceUpdated, after)
+		return nil
+	}
+
+	before, err := repo.Schedules.Get(ctx, ref.ID)
+	if err != nil {
+		return err
+	}
+	after := before
+	if ref.Field == "services" {
+		after.Services = replaceID(before.Services, id, to)
+	} else {
+		after.Consumables = replaceID(before.Consumables, id, to)
+	}
+	_, err = changeSchedule(ctx, r, ref.ID, func(ctx context.Context) error {
+		return repo.Schedules.Update(ctx, after)
+	})
+	if err != nil {
+		return err
+	}
+	recordAudit(ctx, r, audit.ActionUpdate, "schedule", after.ID, after.Lable, before, after)
+	publishEvent(ctx, webhook.ScheduleUpdated, after)
+	return nil
+}
+
+// replaceID returns ids with id replaced by to, or dropped when to is zero,
+// without duplicating to
+func replaceID(ids []primitive.ObjectID, id, to primitive.ObjectID) []primitive.ObjectID {
+	out := []primitive.ObjectID{}
+	seen := map[primitive.ObjectID]bool{}
+	for _, v := range ids {
+		if v == id {
+			v = to
+		}
+		if v.IsZero() || seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	return out
+}
+
+// JSON list of the maintenances and schedules referencing a record, for the
+// asset, service and consumable services to check before a delete
+func referencesAPI(w http.ResponseWriter, r *http.Request) {
+	kind := r.URL.Query().Get("kind")
+	if !references.ValidKind(kind) {
+		http.Error(w, "Invalid kind", http.StatusBadRequest)
+		return
+	}
+	objID, err := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	refs, err := findReferences(ctx, kind, objID)
+	if err != nil {
+		http.Error(w, "Failed to fetch references: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(refs)
+}
+
+// Cascade or reassign the references to a record before it is deleted
+func resolveReferencesAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reply := func(status int, result references.Result) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(result)
+	}
+
+	if r.Method != http.MethodPost {
+		reply(http.StatusMethodNotAllowed, references.Result{Error: "method not allowed"})
+		return
+	}
+
+	kind := r.FormValue("kind")
+	if !references.ValidKind(kind) {
+		reply(http.StatusBadRequest, references.Result{Error: "invalid kind"})
+		return
+	}
+	objID, err := primitive.ObjectIDFromHex(r.FormValue("id"))
+	if err != nil {
+		reply(http.StatusBadRequest, references.Result{Error: "invalid id"})
+		return
+	}
+	var to primitive.ObjectID
+	if v := r.FormValue("to"); v != "" {
+		if to, err = primitive.ObjectIDFromHex(v); err != nil {
+			reply(http.StatusBadRequest, references.Result{Error: "invalid to"})
+			return
+		}
+	}
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	updated, err := resolveReferences(ctx, r, kind, objID, r.FormValue("action"), to)
+	if err != nil {
+		reply(http.StatusUnprocessableEntity, references.Result{Updated: updated, Error: err.Error()})
+		return
+	}
+	reply(http.StatusOK, references.Result{Updated: updated})
+}
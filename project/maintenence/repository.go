@@ -0,0 +1,218 @@
+package maintenence
+
+import (
+	"context"
+	"shared/audit"
+	"shared/events"
+	"shared/store"
+	"shared/webhook"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MaintenanceRepository keeps the maintenances. Deleted maintenances stay in
+// the recycle bin until they are restored or purged; only Deleted* and
+// Restore and Purge see them. A missing maintenance is store.ErrNotFound.
+type MaintenanceRepository interface {
+	// List returns the live maintenances
+	List(ctx context.Context) ([]MainteneceShedule, error)
+	ListByAsset(ctx context.Context, assetID primitive.ObjectID) ([]MainteneceShedule, error)
+	GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]MainteneceShedule, error)
+	// Referencing returns the live maintenances whose field holds id; field
+	// is asset_id, shedules.services or shedules.consumables, as in
+	// references.Reference
+	Referencing(ctx context.Context, field string, id primitive.ObjectID) ([]MainteneceShedule, error)
+	Get(ctx context.Context, id primitive.ObjectID) (MainteneceShedule, error)
+	Insert(ctx context.Context, m MainteneceShedule) error
+	// Update saves the label, asset and embedded schedules of a live
+	// maintenance when it is still at m.Version, the version the change is
+	// based on, and moves it to the next version. It returns
+	// store.ErrConflict when the maintenance is at another version.
+	Update(ctx context.Context, m MainteneceShedule) error
+	// Delete moves a maintenance to the recycle bin
+	Delete(ctx context.Context, id primitive.ObjectID, actor string) error
+	// ListDeleted returns the recycle bin, most recently deleted first
+	ListDeleted(ctx context.Context) ([]MainteneceShedule, error)
+	GetDeleted(ctx context.Context, id primitive.ObjectID) (MainteneceShedule, error)
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	Purge(ctx context.Context, id primitive.ObjectID) error
+	// PurgeExpired purges the maintenances deleted more than retention ago
+	PurgeExpired(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// ScheduleRepository keeps the schedules, with the recycle bin of
+// MaintenanceRepository
+type ScheduleRepository interface {
+	// List returns the live schedules
+	List(ctx context.Context) ([]ScheduleDoc, error)
+	// Find returns the live schedules matching f
+	Find(ctx context.Context, f ScheduleFilter) ([]ScheduleDoc, error)
+	// Referencing returns the live schedules whose field holds id; field is
+	// asset_id, maintenance_id, services or consumables, as in
+	// references.Reference
+	Referencing(ctx context.Context, field string, id primitive.ObjectID) ([]ScheduleDoc, error)
+	Get(ctx context.Context, id primitive.ObjectID) (ScheduleDoc, error)
+	// GetAny returns the schedule with the given id, live or deleted
+	GetAny(ctx context.Context, id primitive.ObjectID) (ScheduleDoc, error)
+	Insert(ctx context.Context, s ScheduleDoc) error
+	// Update saves everything but the recycle bin fields of a live schedule
+	// when it is still at s.Version, the version the change is based on, and
+	// moves it to the next version. It returns store.ErrConflict when the
+	// schedule is at another version.
+	Update(ctx context.Context, s ScheduleDoc) error
+	// Delete moves a schedule to the recycle bin
+	Delete(ctx context.Context, id primitive.ObjectID, actor string) error
+	// ListDeleted returns the recycle bin, most recently deleted first
+	ListDeleted(ctx context.Context) ([]ScheduleDoc, error)
+	GetDeleted(ctx context.Context, id primitive.ObjectID) (ScheduleDoc, error)
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	Purge(ctx context.Context, id primitive.ObjectID) error
+	// PurgeExpired purges the schedules deleted more than retention ago
+	PurgeExpired(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// ScheduleFilter narrows the schedules returned by ScheduleRepository.Find;
+// zero values match everything
+type ScheduleFilter struct {
+	// AssetIDs are the assets the schedules belong to; nil matches every
+	// asset and an empty list none
+	AssetIDs      []primitive.ObjectID
+	MaintenanceID *primitive.ObjectID
+	ServiceID     *primitive.ObjectID
+}
+
+// matches reports whether f selects s, for the repositories filtering in Go
+func (f ScheduleFilter) matches(s ScheduleDoc) bool {
+	return (f.AssetIDs == nil || holds(f.AssetIDs, s.AssetID)) &&
+		(f.MaintenanceID == nil || s.MaintenanceID != nil && *s.MaintenanceID == *f.MaintenanceID) &&
+		(f.ServiceID == nil || holds(s.Services, *f.ServiceID))
+}
+
+// maintenanceReferences returns whether the field of a maintenance holds id,
+// as MaintenanceRepository.Referencing matches it
+func maintenanceReferences(field string, id primitive.ObjectID) func(MainteneceShedule) bool {
+	return func(m MainteneceShedule) bool {
+		if field == "asset_id" {
+			return m.AssetID == id
+		}
+		for _, s := range m.Shedules {
+			if field == "shedules.services" && holds(s.Services, id) || field == "shedules.consumables" && holds(s.Consumables, id) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// scheduleReferences returns whether the field of a schedule holds id, as
+// ScheduleRepository.Referencing matches it
+func scheduleReferences(field string, id primitive.ObjectID) func(ScheduleDoc) bool {
+	return func(s ScheduleDoc) bool {
+		switch field {
+		case "asset_id":
+			return s.AssetID == id
+		case "maintenance_id":
+			return s.MaintenanceID != nil && *s.MaintenanceID == id
+		case "services":
+			return holds(s.Services, id)
+		case "consumables":
+			return holds(s.Consumables, id)
+		}
+		return false
+	}
+}
+
+// holds reports whether ids contains id
+func holds(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// CompletionRepository keeps the completions of the schedule occurrences,
+// one per schedule and due date
+type CompletionRepository interface {
+	// List returns the completions of the given schedules due in [from, to)
+	List(ctx context.Context, scheduleIDs []primitive.ObjectID, from, to time.Time) ([]ScheduleCompletion, error)
+	// Get returns the completion of the occurrence of a schedule due on due
+	Get(ctx context.Context, scheduleID primitive.ObjectID, due time.Time) (ScheduleCompletion, error)
+	// Save records c, replacing the earlier completion of the same
+	// occurrence but keeping its id
+	Save(ctx context.Context, c ScheduleCompletion) error
+}
+
+// SubscriptionRepository keeps the e-mail subscriptions to the upcoming and
+// overdue occurrences
+type SubscriptionRepository interface {
+	List(ctx context.Context) ([]NotificationSubscription, error)
+	Insert(ctx context.Context, sub NotificationSubscription) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// NotificationLogRepository remembers the occurrences mailed to each
+// subscriber
+type NotificationLogRepository interface {
+	// Claim records e and reports true, or false when an entry with its id
+	// was recorded before: the occurrence was mailed already
+	Claim(ctx context.Context, e NotificationLog) (bool, error)
+	// Release removes the entries with the given ids, so they are mailed
+	// again
+	Release(ctx context.Context, ids []string) error
+}
+
+// Store is where the maintenance service keeps its maintenances, schedules,
+// notification subscriptions and webhooks and records their changes: the
+// audit trail, the webhook events and the domain events, which are saved in
+// the transaction of the change. The audit trail is that of every service,
+// searched on the audit page.
+type Store struct {
+	Maintenances    MaintenanceRepository
+	Schedules       ScheduleRepository
+	Completions     CompletionRepository
+	Subscriptions   SubscriptionRepository
+	NotificationLog NotificationLogRepository
+	WebhookStore    webhook.Store
+	Tx              store.Tx
+	Outbox          events.Outbox
+	Audit           audit.Store
+	Webhooks        webhook.Publisher
+}
+
+// NewMongoStore returns the store of the maintenance service in db, with the
+// audit trail and the outbox in shared, the database shared by the services
+func NewMongoStore(db, shared *mongo.Database) *Store {
+	hooks := webhook.NewMongoStore(db)
+	return &Store{
+		Maintenances:    mongoMaintenances{db.Collection("maintenances")},
+		Schedules:       mongoSchedules{db.Collection("schedules")},
+		Completions:     mongoCompletions{db.Collection("schedule_completions")},
+		Subscriptions:   mongoSubscriptions{db.Collection("notification_subscriptions")},
+		NotificationLog: mongoNotificationLog{db.Collection("notification_log")},
+		WebhookStore:    hooks,
+		Tx:              store.NewMongoTx(db.Client()),
+		Outbox:          events.NewOutbox(shared),
+		Audit:           audit.NewMongoStore(shared),
+		Webhooks:        webhook.NewPublisher(hooks),
+	}
+}
+
+// NewMemoryStore returns a store keeping everything in memory, for tests
+func NewMemoryStore() *Store {
+	return &Store{
+		Maintenances:    newMemoryMaintenances(),
+		Schedules:       newMemorySchedules(),
+		Completions:     &memoryCompletions{},
+		Subscriptions:   &memorySubscriptions{},
+		NotificationLog: &memoryNotificationLog{},
+		WebhookStore:    &webhook.MemoryStore{},
+		Tx:              store.MemoryTx{},
+		Outbox:          &events.MemoryOutbox{},
+		Audit:           &audit.MemoryStore{},
+		Webhooks:        &webhook.MemoryPublisher{},
+	}
+}
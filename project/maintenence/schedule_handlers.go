@@ -1,10 +1,19 @@
-package main
+package maintenence
 
 import (
+	"context"
+	"errors"
+	"html/template"
 	"net/http"
-	"strconv"
+	"shared/audit"
+	"shared/csrf"
+	"shared/flash"
+	"shared/jsonapi"
+	"shared/store"
+	"shared/validate"
+	"shared/webhook"
+	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -22,22 +31,22 @@ func listSchedules(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	renderSchedules(w, r, objAssetID, nil)
+}
+
+// renderSchedules shows the schedules of an asset and, when f is not nil,
+// the form that did not validate
+func renderSchedules(w http.ResponseWriter, r *http.Request, objAssetID primitive.ObjectID, f *validate.Form) {
+	assetID := objAssetID.Hex()
 	ctx, cancel := getCtx()
 	defer cancel()
 
 	// Lists schedules stored as top-level documents in the schedules collection
-	cursor, err := schedulesCollection.Find(ctx, bson.M{"asset_id": objAssetID})
+	scheduleDocs, err := repo.Schedules.Referencing(ctx, "asset_id", objAssetID)
 	if err != nil {
 		http.Error(w, "Failed to fetch schedules: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer cursor.Close(ctx)
-
-	var scheduleDocs []ScheduleDoc
-	if err = cursor.All(ctx, &scheduleDocs); err != nil {
-		http.Error(w, "Failed to decode schedules: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
 
 	// Build helper maps and lists
 	var svcIDs, consIDs []primitive.ObjectID
@@ -46,15 +55,26 @@ func listSchedules(w http.ResponseWriter, r *http.Request) {
 		consIDs = append(consIDs, s.Consumables...)
 	}
 
-	svcNames, consNames := buildNameMaps(ctx, svcIDs, consIDs)
+	var warnings incomplete
+	assetLabel, err := getAssetLabel(ctx, objAssetID)
+	warnings.add(err)
 
-	assetLabel := getAssetLabel(ctx, objAssetID)
+	message, messageType := flashMessage(w, r)
 
-	message := r.URL.Query().Get("message")
-	messageType := r.URL.Query().Get("type")
+	// Fetch services and consumables from API; the dropdowns need the whole
+	// lists, which also give the names of those already used
+	services, consumables, err := fetchServicesAndConsumables(ctx)
+	warnings.add(err)
 
-	// Fetch services and consumables from API
-	services, consumables := fetchServicesAndConsumables()
+	svcLabels := map[primitive.ObjectID]string{}
+	for _, svc := range services {
+		svcLabels[svc.ID] = svc.Label
+	}
+	consLabels := map[primitive.ObjectID]string{}
+	for _, cons := range consumables {
+		consLabels[cons.ID] = cons.Label
+	}
+	svcNames, consNames := nameMap(svcIDs, svcLabels), nameMap(consIDs, consLabels)
 
 	// Convert to the expected structure
 	serviceStructs := make([]struct {
@@ -80,18 +100,11 @@ func listSchedules(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// We also need maintenances list for the dropdown; fetch from maintenances collection
-	mcursor, err := db.Collection("maintenances").Find(ctx, bson.M{"asset_id": objAssetID})
+	maintenances, err := repo.Maintenances.ListByAsset(ctx, objAssetID)
 	if err != nil {
 		http.Error(w, "Failed to fetch maintenances: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer mcursor.Close(ctx)
-
-	var maintenances []MainteneceShedule
-	if err := mcursor.All(ctx, &maintenances); err != nil {
-		http.Error(w, "Failed to decode maintenances: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
 
 	// build maintenance id -> label map for quick lookup in template
 	maintMap := map[string]string{}
@@ -99,6 +112,12 @@ func listSchedules(w http.ResponseWriter, r *http.Request) {
 		maintMap[m.ID.Hex()] = m.Lable
 	}
 
+	// next due date of every schedule, used to prefill the "done" form
+	nextDue := map[string]string{}
+	for _, s := range scheduleDocs {
+		nextDue[s.ID.Hex()] = nearestOccurrence(s, time.Now()).Format("2006-01-02")
+	}
+
 	data := struct {
 		Maintenances []MainteneceShedule
 		Schedules    []ScheduleDoc
@@ -113,13 +132,19 @@ func listSchedules(w http.ResponseWriter, r *http.Request) {
 			Label string             `bson:"label"`
 		}
 		MaintMap        map[string]string
+		NextDue         map[string]string
 		ServiceNames    map[string]string
 		ConsumableNames map[string]string
 		Message         string
 		MessageType     string
+		Warnings        []string
+		ScheduleTypes   []string
+		Form            *validate.Form
+		CSRF            template.HTML
 	}{
 		Maintenances:    maintenances,
 		Schedules:       scheduleDocs,
+		NextDue:         nextDue,
 		AssetID:         assetID,
 		AssetLabel:      assetLabel,
 		Services:        serviceStructs,
@@ -129,8 +154,13 @@ func listSchedules(w http.ResponseWriter, r *http.Request) {
 		MaintMap:        maintMap,
 		Message:         message,
 		MessageType:     messageType,
+		Warnings:        warnings,
+		ScheduleTypes:   scheduleTypes,
+		Form:            f,
+		CSRF:            csrf.Field(r),
 	}
 
+	pageStatus(w, f)
 	renderTemplate(w, "schedule_list.html", data)
 }
 
@@ -141,91 +171,74 @@ func addSchedule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Maintenance ID is optional: schedules are stored per-asset and may be linked to a maintenance
-	maintenanceID := r.FormValue("maintenance_id")
-	var objMaintenance *primitive.ObjectID
-	if maintenanceID != "" {
-		if oid, err := primitive.ObjectIDFromHex(maintenanceID); err == nil {
-			objMaintenance = &oid
-		} else {
-			http.Error(w, "Invalid Maintenance ID", http.StatusBadRequest)
-			return
-		}
-	}
+	ctx, cancel := getCtx()
+	defer cancel()
 
-	// Parse form
-	if err := r.ParseForm(); err != nil {
+	f, shedule, err := scheduleForm(ctx, r, "create")
+	if err != nil {
 		http.Error(w, "Parse form error: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	days, _ := strconv.Atoi(r.FormValue("days"))
-
-	svcVals := r.Form["services[]"]
-	consVals := r.Form["consumables[]"]
-
-	var svcIDs []primitive.ObjectID
-	for _, s := range svcVals {
-		if oid, err := primitive.ObjectIDFromHex(s); err == nil {
-			svcIDs = append(svcIDs, oid)
+	// Maintenance ID is optional: schedules are stored per-asset and may be linked to a maintenance
+	var objMaintenance *primitive.ObjectID
+	var maint *MainteneceShedule
+	if maintenanceID := f.Get("maintenance_id"); maintenanceID != "" {
+		oid, err := primitive.ObjectIDFromHex(maintenanceID)
+		if err != nil {
+			http.Error(w, "Invalid Maintenance ID", http.StatusBadRequest)
+			return
 		}
-	}
-
-	var consIDs []primitive.ObjectID
-	for _, c := range consVals {
-		if oid, err := primitive.ObjectIDFromHex(c); err == nil {
-			consIDs = append(consIDs, oid)
+		objMaintenance = &oid
+		if m, err := repo.Maintenances.Get(ctx, oid); err == nil {
+			maint = &m
 		}
 	}
 
-	shedule := ScheduleDoc{
-		ID:            primitive.NewObjectID(),
-		MaintenanceID: objMaintenance,
-		AssetID:       primitive.NilObjectID, // set below
-		Lable:         r.FormValue("label"),
-		SheduleType:   r.FormValue("shedule_type"),
-		Days:          days,
-		Services:      svcIDs,
-		Consumables:   consIDs,
-		Notes:         r.FormValue("notes"),
-	}
-
 	// AssetID must be determined from maintenance (if given) or form param asset_id
-	assetID := r.FormValue("asset_id")
-	if assetID == "" {
-		if objMaintenance != nil {
-			// lookup maintenance to get asset id
-			ctx, cancel := getCtx()
-			defer cancel()
-			var m MainteneceShedule
-			if err := db.Collection("maintenances").FindOne(ctx, bson.M{"_id": *objMaintenance}).Decode(&m); err != nil {
-				http.Error(w, "Maintenance not found to resolve asset_id", http.StatusInternalServerError)
-				return
-			}
-			shedule.AssetID = m.AssetID
-		} else {
-			http.Error(w, "Missing asset_id", http.StatusBadRequest)
-			return
-		}
-	} else {
-		if aoid, err := primitive.ObjectIDFromHex(assetID); err == nil {
-			shedule.AssetID = aoid
-		} else {
+	switch assetID := f.Get("asset_id"); {
+	case assetID != "":
+		aoid, err := primitive.ObjectIDFromHex(assetID)
+		if err != nil {
 			http.Error(w, "Invalid asset_id", http.StatusBadRequest)
 			return
 		}
+		shedule.AssetID = aoid
+	case maint != nil:
+		shedule.AssetID = maint.AssetID
+	case objMaintenance != nil:
+		http.Error(w, "Maintenance not found to resolve asset_id", http.StatusInternalServerError)
+		return
+	default:
+		http.Error(w, "Missing asset_id", http.StatusBadRequest)
+		return
 	}
 
-	ctx, cancel := getCtx()
-	defer cancel()
+	checkAsset(ctx, f, "asset_id", shedule.AssetID)
+	if objMaintenance != nil {
+		f.Check(maint != nil && maint.AssetID == shedule.AssetID, "maintenance_id", "Choose a maintenance of this asset")
+	}
+	if !f.Valid() {
+		showForm(w, r, f, func(w http.ResponseWriter, r *http.Request, f *validate.Form) {
+			renderSchedules(w, r, shedule.AssetID, f)
+		})
+		return
+	}
+	shedule.ID = primitive.NewObjectID()
+	shedule.MaintenanceID = objMaintenance
 
-	if _, err := schedulesCollection.InsertOne(ctx, shedule); err != nil {
+	_, err = changeSchedule(ctx, r, shedule.ID, func(ctx context.Context) error {
+		return repo.Schedules.Insert(ctx, shedule)
+	})
+	if err != nil {
 		http.Error(w, "Insert error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	recordAudit(ctx, r, audit.ActionCreate, "schedule", shedule.ID, shedule.Lable, nil, shedule)
+	publishEvent(ctx, webhook.ScheduleCreated, shedule)
 
 	// Redirect with asset_id
-	http.Redirect(w, r, "/schedules?asset_id="+shedule.AssetID.Hex()+"&message=Schedule added successfully&type=success", http.StatusSeeOther)
+	flash.Redirect(w, r, "/schedules?asset_id="+shedule.AssetID.Hex(), flash.Success, "Schedule added successfully")
 }
 
 // Edit schedule
@@ -246,57 +259,54 @@ func editSchedule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Parse form error: "+err.Error(), http.StatusBadRequest)
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	before, err := repo.Schedules.Get(ctx, objSchedule)
+	if err != nil {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
 		return
 	}
 
-	days, _ := strconv.Atoi(r.FormValue("days"))
-
-	// Services & consumables
-	svcVals := r.Form["services[]"]
-	consVals := r.Form["consumables[]"]
-
-	var svcIDs []primitive.ObjectID
-	for _, s := range svcVals {
-		if oid, err := primitive.ObjectIDFromHex(s); err == nil {
-			svcIDs = append(svcIDs, oid)
-		}
+	f, input, err := scheduleForm(ctx, r, objSchedule.Hex())
+	if err != nil {
+		http.Error(w, "Parse form error: "+err.Error(), http.StatusBadRequest)
+		return
 	}
-	var consIDs []primitive.ObjectID
-	for _, c := range consVals {
-		if oid, err := primitive.ObjectIDFromHex(c); err == nil {
-			consIDs = append(consIDs, oid)
-		}
+	version := f.Version(r)
+	if !f.Valid() {
+		showForm(w, r, f, func(w http.ResponseWriter, r *http.Request, f *validate.Form) {
+			renderSchedules(w, r, before.AssetID, f)
+		})
+		return
 	}
 
-	ctx, cancel := getCtx()
-	defer cancel()
-
 	// Update schedule document in schedules collection
-	filter := bson.M{"_id": objSchedule}
-	update := bson.M{"$set": bson.M{
-		"label":        r.FormValue("label"),
-		"shedule_type": r.FormValue("shedule_type"),
-		"days":         days,
-		"services":     svcIDs,
-		"consumables":  consIDs,
-		"notes":        r.FormValue("notes"),
-	}}
-
-	if _, err := schedulesCollection.UpdateOne(ctx, filter, update); err != nil {
-		http.Error(w, "Update error: "+err.Error(), http.StatusInternalServerError)
+	update := before
+	update.Lable = input.Lable
+	update.SheduleType = input.SheduleType
+	update.Days = input.Days
+	update.Services = input.Services
+	update.Consumables = input.Consumables
+	update.Notes = input.Notes
+	update.Version = version
+
+	updated, err := changeSchedule(ctx, r, objSchedule, func(ctx context.Context) error {
+		return repo.Schedules.Update(ctx, update)
+	})
+	if errors.Is(err, store.ErrConflict) {
+		showScheduleConflict(ctx, w, r, update, before)
 		return
 	}
-
-	// find schedule to get asset id for redirect
-	var updated ScheduleDoc
-	if err := schedulesCollection.FindOne(ctx, bson.M{"_id": objSchedule}).Decode(&updated); err != nil {
-		http.Error(w, "Schedule not found for redirect", http.StatusInternalServerError)
+	if err != nil {
+		http.Error(w, "Update error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	recordAudit(ctx, r, audit.ActionUpdate, "schedule", updated.ID, updated.Lable, before, updated)
+	publishEvent(ctx, webhook.ScheduleUpdated, updated)
 
-	http.Redirect(w, r, "/schedules?asset_id="+updated.AssetID.Hex()+"&message=Schedule updated successfully&type=success", http.StatusSeeOther)
+	w.Header().Set("ETag", jsonapi.ETag(updated.Version))
+	flash.Redirect(w, r, "/schedules?asset_id="+updated.AssetID.Hex(), flash.Success, "Schedule updated successfully")
 }
 
 // Delete schedule
@@ -321,18 +331,23 @@ func deleteSchedule(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	// find schedule first to get asset id for redirect
-	var sched ScheduleDoc
-	if err := schedulesCollection.FindOne(ctx, bson.M{"_id": objSchedule}).Decode(&sched); err != nil {
+	sched, err := repo.Schedules.Get(ctx, objSchedule)
+	if err != nil {
 		http.Error(w, "Schedule not found", http.StatusNotFound)
 		return
 	}
 
-	if _, err := schedulesCollection.DeleteOne(ctx, bson.M{"_id": objSchedule}); err != nil {
+	_, err = changeSchedule(ctx, r, objSchedule, func(ctx context.Context) error {
+		return repo.Schedules.Delete(ctx, objSchedule, audit.Actor(r))
+	})
+	if err != nil {
 		http.Error(w, "Delete error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	recordAudit(ctx, r, audit.ActionDelete, "schedule", sched.ID, sched.Lable, sched, nil)
+	publishEvent(ctx, webhook.ScheduleDeleted, sched)
 
-	http.Redirect(w, r, "/schedules?asset_id="+sched.AssetID.Hex()+"&message=Schedule deleted successfully&type=success", http.StatusSeeOther)
+	flash.Redirect(w, r, "/schedules?asset_id="+sched.AssetID.Hex(), flash.Success, "Schedule moved to the recycle bin")
 }
 
 func addShedule(w http.ResponseWriter, r *http.Request) {
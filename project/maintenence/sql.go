@@ -0,0 +1,416 @@
+package maintenence
+
+import (
+	"context"
+	"errors"
+	"shared/audit"
+	"shared/config"
+	"shared/events"
+	"shared/store"
+	"shared/webhook"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// migrations create the tables of the maintenance service in a SQL database
+var migrations = []store.Migration{
+	{Version: 1, Name: "create maintenances, schedules and schedule_completions", Statements: []string{
+		`CREATE TABLE maintenances (
+			id TEXT PRIMARY KEY,
+			doc TEXT NOT NULL,
+			deleted_at BIGINT,
+			deleted_by TEXT NOT NULL DEFAULT '',
+			asset_id TEXT NOT NULL
+		)`,
+		"CREATE INDEX maintenances_deleted_at ON maintenances (deleted_at)",
+		"CREATE INDEX maintenances_asset_id ON maintenances (asset_id)",
+		`CREATE TABLE schedules (
+			id TEXT PRIMARY KEY,
+			doc TEXT NOT NULL,
+			deleted_at BIGINT,
+			deleted_by TEXT NOT NULL DEFAULT '',
+			asset_id TEXT NOT NULL,
+			maintenance_id TEXT NOT NULL DEFAULT ''
+		)`,
+		"CREATE INDEX schedules_deleted_at ON schedules (deleted_at)",
+		"CREATE INDEX schedules_asset_id ON schedules (asset_id)",
+		"CREATE INDEX schedules_maintenance_id ON schedules (maintenance_id)",
+		`CREATE TABLE schedule_completions (
+			id TEXT PRIMARY KEY,
+			doc TEXT NOT NULL,
+			deleted_at BIGINT,
+			deleted_by TEXT NOT NULL DEFAULT '',
+			schedule_id TEXT NOT NULL,
+			due_date BIGINT NOT NULL
+		)`,
+		"CREATE UNIQUE INDEX schedule_completions_occurrence ON schedule_completions (schedule_id, due_date)",
+	}},
+	{Version: 2, Name: "rename shedule_type to schedule_type", Statements: []string{
+		`UPDATE schedules SET doc = REPLACE(doc, '"shedule_type":', '"schedule_type":')`,
+		`UPDATE maintenances SET doc = REPLACE(doc, '"shedule_type":', '"schedule_type":')`,
+	}},
+	{Version: 3, Name: "create notification_subscriptions and notification_log", Statements: []string{
+		`CREATE TABLE notification_subscriptions (
+			id TEXT PRIMARY KEY,
+			doc TEXT NOT NULL,
+			deleted_at BIGINT,
+			deleted_by TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE TABLE notification_log (
+			id TEXT PRIMARY KEY,
+			email TEXT NOT NULL,
+			schedule_id TEXT NOT NULL,
+			due_date BIGINT NOT NULL,
+			kind TEXT NOT NULL,
+			sent_at BIGINT NOT NULL
+		)`,
+		"CREATE INDEX notification_log_sent_at ON notification_log (sent_at)",
+	}},
+}
+
+// MigrateSQL creates or updates the tables of the maintenance service and
+// the webhooks in db
+func MigrateSQL(ctx context.Context, db *store.SQL) error {
+	if err := db.Migrate(ctx, "maintenance", migrations); err != nil {
+		return err
+	}
+	return webhook.MigrateSQL(ctx, db)
+}
+
+// NewSQLStore returns the store of the maintenance service in db, which
+// keeps the audit trail, the outbox and the webhooks too
+func NewSQLStore(db *store.SQL) *Store {
+	hooks := webhook.NewSQLStore(db)
+	return &Store{
+		Maintenances:    sqlMaintenances{maintenancesTable(db)},
+		Schedules:       sqlSchedules{schedulesTable(db)},
+		Completions:     sqlCompletions{db: db, table: completionsTable(db)},
+		Subscriptions:   sqlSubscriptions{db: db, table: subscriptionsTable(db)},
+		NotificationLog: sqlNotificationLog{db},
+		WebhookStore:    hooks,
+		Tx:              db,
+		Outbox:          events.NewSQLOutbox(db),
+		Audit:           audit.NewSQLStore(db),
+		Webhooks:        webhook.NewPublisher(hooks),
+	}
+}
+
+func maintenancesTable(db *store.SQL) *store.SQLTable[MainteneceShedule] {
+	return store.NewSQLTable(db, "maintenances",
+		func(m *MainteneceShedule) (*primitive.ObjectID, **time.Time, *string) {
+			return &m.ID, &m.DeletedAt, &m.DeletedBy
+		},
+		store.Column[MainteneceShedule]{Name: "asset_id", Value: func(m *MainteneceShedule) interface{} { return m.AssetID.Hex() }},
+	)
+}
+
+func schedulesTable(db *store.SQL) *store.SQLTable[ScheduleDoc] {
+	return store.NewSQLTable(db, "schedules",
+		func(s *ScheduleDoc) (*primitive.ObjectID, **time.Time, *string) {
+			return &s.ID, &s.DeletedAt, &s.DeletedBy
+		},
+		store.Column[ScheduleDoc]{Name: "asset_id", Value: func(s *ScheduleDoc) interface{} { return s.AssetID.Hex() }},
+		store.Column[ScheduleDoc]{Name: "maintenance_id", Value: func(s *ScheduleDoc) interface{} {
+			if s.MaintenanceID == nil {
+				return ""
+			}
+			return s.MaintenanceID.Hex()
+		}},
+	)
+}
+
+func completionsTable(db *store.SQL) *store.SQLTable[ScheduleCompletion] {
+	return store.NewSQLTable(db, "schedule_completions",
+		func(c *ScheduleCompletion) (*primitive.ObjectID, **time.Time, *string) { return &c.ID, nil, nil },
+		store.Column[ScheduleCompletion]{Name: "schedule_id", Value: func(c *ScheduleCompletion) interface{} { return c.ScheduleID.Hex() }},
+		store.Column[ScheduleCompletion]{Name: "due_date", Value: func(c *ScheduleCompletion) interface{} { return store.Millis(c.DueDate) }},
+	)
+}
+
+func subscriptionsTable(db *store.SQL) *store.SQLTable[NotificationSubscription] {
+	return store.NewSQLTable(db, "notification_subscriptions",
+		func(s *NotificationSubscription) (*primitive.ObjectID, **time.Time, *string) { return &s.ID, nil, nil },
+	)
+}
+
+// CopyToSQL copies the maintenances, schedules, completions, notification
+// subscriptions and webhook subscriptions kept in MongoDB by client into to
+// and returns how many records it copied. The notification log and the
+// webhook deliveries are not copied.
+func CopyToSQL(ctx context.Context, conf *config.Config, client *mongo.Client, to *store.SQL) (int, error) {
+	if err := MigrateSQL(ctx, to); err != nil {
+		return 0, err
+	}
+	db := client.Database(conf.Database(config.Maintenance))
+	total := 0
+	n, err := store.CopyCollection(ctx, db.Collection("maintenances"), maintenancesTable(to))
+	total += n
+	if err != nil {
+		return total, err
+	}
+	n, err = store.CopyCollection(ctx, db.Collection("schedules"), schedulesTable(to))
+	total += n
+	if err != nil {
+		return total, err
+	}
+	n, err = store.CopyCollection(ctx, db.Collection("schedule_completions"), completionsTable(to))
+	total += n
+	if err != nil {
+		return total, err
+	}
+	n, err = store.CopyCollection(ctx, db.Collection("notification_subscriptions"), subscriptionsTable(to))
+	total += n
+	if err != nil {
+		return total, err
+	}
+	n, err = webhook.CopyToSQL(ctx, db, to)
+	return total + n, err
+}
+
+// sqlMaintenances is the MaintenanceRepository of the maintenances table.
+// The schedules embedded in a maintenance are part of its document, so
+// Referencing filters them in Go.
+type sqlMaintenances struct {
+	table *store.SQLTable[MainteneceShedule]
+}
+
+func (m sqlMaintenances) List(ctx context.Context) ([]MainteneceShedule, error) {
+	return m.table.Select(ctx, "")
+}
+
+func (m sqlMaintenances) ListByAsset(ctx context.Context, assetID primitive.ObjectID) ([]MainteneceShedule, error) {
+	return m.table.Select(ctx, "asset_id = ?", assetID.Hex())
+}
+
+func (m sqlMaintenances) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]MainteneceShedule, error) {
+	return m.table.Select(ctx, store.In("id", len(ids)), store.Hexes(ids)...)
+}
+
+func (m sqlMaintenances) Referencing(ctx context.Context, field string, id primitive.ObjectID) ([]MainteneceShedule, error) {
+	if field == "asset_id" {
+		return m.ListByAsset(ctx, id)
+	}
+	all, err := m.table.Select(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return filter(all, maintenanceReferences(field, id)), nil
+}
+
+func (m sqlMaintenances) Get(ctx context.Context, id primitive.ObjectID) (MainteneceShedule, error) {
+	return m.table.Get(ctx, id)
+}
+
+func (m sqlMaintenances) Insert(ctx context.Context, item MainteneceShedule) error {
+	return m.table.Insert(ctx, item)
+}
+
+func (m sqlMaintenances) Update(ctx context.Context, item MainteneceShedule) error {
+	_, err := m.table.UpdateVersion(ctx, item.ID, item.Version, maintenanceVersion, func(rec *MainteneceShedule) {
+		rec.Lable, rec.AssetID, rec.Shedules = item.Lable, item.AssetID, item.Shedules
+	})
+	return err
+}
+
+func (m sqlMaintenances) Delete(ctx context.Context, id primitive.ObjectID, actor string) error {
+	return m.table.Delete(ctx, id, actor)
+}
+
+func (m sqlMaintenances) ListDeleted(ctx context.Context) ([]MainteneceShedule, error) {
+	return m.table.Deleted(ctx)
+}
+
+func (m sqlMaintenances) GetDeleted(ctx context.Context, id primitive.ObjectID) (MainteneceShedule, error) {
+	return m.table.GetDeleted(ctx, id)
+}
+
+func (m sqlMaintenances) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Restore(ctx, id)
+}
+
+func (m sqlMaintenances) Purge(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Purge(ctx, id)
+}
+
+func (m sqlMaintenances) PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	return m.table.PurgeExpired(ctx, retention)
+}
+
+// sqlSchedules is the ScheduleRepository of the schedules table. The assets
+// and maintenances are columns; the services and consumables of a schedule
+// are lists in its document, filtered in Go.
+type sqlSchedules struct {
+	table *store.SQLTable[ScheduleDoc]
+}
+
+func (m sqlSchedules) List(ctx context.Context) ([]ScheduleDoc, error) {
+	return m.table.Select(ctx, "")
+}
+
+func (m sqlSchedules) Find(ctx context.Context, f ScheduleFilter) ([]ScheduleDoc, error) {
+	where := "1 = 1"
+	var args []interface{}
+	if f.AssetIDs != nil {
+		where += " AND " + store.In("asset_id", len(f.AssetIDs))
+		args = append(args, store.Hexes(f.AssetIDs)...)
+	}
+	if f.MaintenanceID != nil {
+		where += " AND maintenance_id = ?"
+		args = append(args, f.MaintenanceID.Hex())
+	}
+	found, err := m.table.Select(ctx, where, args...)
+	if err != nil {
+		return nil, err
+	}
+	return filter(found, f.matches), nil
+}
+
+func (m sqlSchedules) Referencing(ctx context.Context, field string, id primitive.ObjectID) ([]ScheduleDoc, error) {
+	switch field {
+	case "asset_id", "maintenance_id":
+		return m.table.Select(ctx, field+" = ?", id.Hex())
+	}
+	all, err := m.table.Select(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return filter(all, scheduleReferences(field, id)), nil
+}
+
+func (m sqlSchedules) Get(ctx context.Context, id primitive.ObjectID) (ScheduleDoc, error) {
+	return m.table.Get(ctx, id)
+}
+
+func (m sqlSchedules) GetAny(ctx context.Context, id primitive.ObjectID) (ScheduleDoc, error) {
+	return m.table.GetAny(ctx, id)
+}
+
+func (m sqlSchedules) Insert(ctx context.Context, s ScheduleDoc) error {
+	return m.table.Insert(ctx, s)
+}
+
+func (m sqlSchedules) Update(ctx context.Context, s ScheduleDoc) error {
+	_, err := m.table.UpdateVersion(ctx, s.ID, s.Version, scheduleVersion, func(rec *ScheduleDoc) {
+		s.DeletedAt, s.DeletedBy = rec.DeletedAt, rec.DeletedBy
+		*rec = s
+	})
+	return err
+}
+
+func (m sqlSchedules) Delete(ctx context.Context, id primitive.ObjectID, actor string) error {
+	return m.table.Delete(ctx, id, actor)
+}
+
+func (m sqlSchedules) ListDeleted(ctx context.Context) ([]ScheduleDoc, error) {
+	return m.table.Deleted(ctx)
+}
+
+func (m sqlSchedules) GetDeleted(ctx context.Context, id primitive.ObjectID) (ScheduleDoc, error) {
+	return m.table.GetDeleted(ctx, id)
+}
+
+func (m sqlSchedules) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Restore(ctx, id)
+}
+
+func (m sqlSchedules) Purge(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Purge(ctx, id)
+}
+
+func (m sqlSchedules) PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	return m.table.PurgeExpired(ctx, retention)
+}
+
+// sqlCompletions is the CompletionRepository of the schedule_completions
+// table, with one row per schedule and due date
+type sqlCompletions struct {
+	db    *store.SQL
+	table *store.SQLTable[ScheduleCompletion]
+}
+
+func (m sqlCompletions) List(ctx context.Context, scheduleIDs []primitive.ObjectID, from, to time.Time) ([]ScheduleCompletion, error) {
+	args := append(store.Hexes(scheduleIDs), store.Millis(from), store.Millis(to))
+	return m.table.Select(ctx, store.In("schedule_id", len(scheduleIDs))+" AND due_date >= ? AND due_date < ?", args...)
+}
+
+func (m sqlCompletions) Get(ctx context.Context, scheduleID primitive.ObjectID, due time.Time) (ScheduleCompletion, error) {
+	found, err := m.table.Select(ctx, "schedule_id = ? AND due_date = ?", scheduleID.Hex(), store.Millis(due))
+	if err != nil {
+		return ScheduleCompletion{}, err
+	}
+	if len(found) == 0 {
+		return ScheduleCompletion{}, store.ErrNotFound
+	}
+	return found[0], nil
+}
+
+func (m sqlCompletions) Save(ctx context.Context, c ScheduleCompletion) error {
+	return m.db.Atomically(ctx, func(ctx context.Context) error {
+		existing, err := m.Get(ctx, c.ScheduleID, c.DueDate)
+		if err == nil {
+			c.ID = existing.ID
+		} else if !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		return m.table.Save(ctx, c)
+	})
+}
+
+// filter returns the records of all that keep selects
+func filter[T any](all []T, keep func(T) bool) []T {
+	out := []T{}
+	for _, rec := range all {
+		if keep(rec) {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+// sqlSubscriptions is the SubscriptionRepository of the
+// notification_subscriptions table
+type sqlSubscriptions struct {
+	db    *store.SQL
+	table *store.SQLTable[NotificationSubscription]
+}
+
+func (m sqlSubscriptions) List(ctx context.Context) ([]NotificationSubscription, error) {
+	return m.table.Select(ctx, "")
+}
+
+func (m sqlSubscriptions) Insert(ctx context.Context, sub NotificationSubscription) error {
+	return m.table.Insert(ctx, sub)
+}
+
+func (m sqlSubscriptions) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := m.db.Exec(ctx, "DELETE FROM notification_subscriptions WHERE id = ?", id.Hex())
+	return err
+}
+
+// sqlNotificationLog is the NotificationLogRepository of the
+// notification_log table, where the primary key rejects a second claim
+type sqlNotificationLog struct {
+	db *store.SQL
+}
+
+func (m sqlNotificationLog) Claim(ctx context.Context, e NotificationLog) (bool, error) {
+	res, err := m.db.Exec(ctx,
+		"INSERT INTO notification_log (id, email, schedule_id, due_date, kind, sent_at) VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING",
+		e.ID, e.Email, e.ScheduleID.Hex(), store.Millis(e.DueDate), e.Kind, store.Millis(e.SentAt))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (m sqlNotificationLog) Release(ctx context.Context, ids []string) error {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	_, err := m.db.Exec(ctx, "DELETE FROM notification_log WHERE "+store.In("id", len(ids)), args...)
+	return err
+}
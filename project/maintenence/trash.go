@@ -0,0 +1,188 @@
+package maintenence
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"shared/audit"
+	"shared/csrf"
+	"shared/flash"
+	"shared/trash"
+	"shared/webhook"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// trashBin is the recycle bin of one of the repositories
+type trashBin interface {
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	Purge(ctx context.Context, id primitive.ObjectID) error
+}
+
+// trashKind is what the recycle bin pages need of one kind of record: its
+// bin, the deleted record as it is and as it is once restored, and the
+// webhook event of a restore
+type trashKind struct {
+	bin      func() trashBin
+	deleted  func(ctx context.Context, id primitive.ObjectID) (before, after interface{}, label string, err error)
+	restored string
+}
+
+// trashKinds maps the kind form value of the recycle bin, which is also the
+// entity name used in the audit trail, to its kind
+var trashKinds = map[string]trashKind{
+	"maintenance": {
+		bin: func() trashBin { return repo.Maintenances },
+		deleted: func(ctx context.Context, id primitive.ObjectID) (interface{}, interface{}, string, error) {
+			before, err := repo.Maintenances.GetDeleted(ctx, id)
+			after := before
+			after.DeletedAt, after.DeletedBy = nil, ""
+			return before, after, before.Lable, err
+		},
+		restored: webhook.MaintenanceRestored,
+	},
+	"schedule": {
+		bin: func() trashBin { return repo.Schedules },
+		deleted: func(ctx context.Context, id primitive.ObjectID) (interface{}, interface{}, string, error) {
+			before, err := repo.Schedules.GetDeleted(ctx, id)
+			after := before
+			after.DeletedAt, after.DeletedBy = nil, ""
+			return before, after, before.Lable, err
+		},
+		restored: webhook.ScheduleRestored,
+	},
+}
+
+// Recycle bin of deleted maintenances and schedules
+func listTrash(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	maintenances, err := repo.Maintenances.ListDeleted(ctx)
+	if err != nil {
+		http.Error(w, "Failed to fetch deleted maintenances: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	schedules, err := repo.Schedules.ListDeleted(ctx)
+	if err != nil {
+		http.Error(w, "Failed to fetch deleted schedules: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var assetIDs []primitive.ObjectID
+	for _, m := range maintenances {
+		assetIDs = append(assetIDs, m.AssetID)
+	}
+	for _, s := range schedules {
+		assetIDs = append(assetIDs, s.AssetID)
+	}
+	var warnings incomplete
+	assetLabels, err := getAssetLabels(ctx, assetIDs)
+	warnings.add(err)
+
+	retention := trash.Retention()
+	message, messageType := flashMessage(w, r)
+	data := struct {
+		Maintenances  []MainteneceShedule
+		Schedules     []ScheduleDoc
+		AssetLabels   map[string]string
+		RetentionDays int
+		PurgeOn       func(*time.Time) string
+		Message       string
+		MessageType   string
+		Warnings      []string
+		CSRF          template.HTML
+	}{
+		Maintenances:  maintenances,
+		Schedules:     schedules,
+		AssetLabels:   assetLabels,
+		RetentionDays: int(retention.Hours() / 24),
+		PurgeOn: func(deletedAt *time.Time) string {
+			if deletedAt == nil {
+				return ""
+			}
+			return trash.PurgeDate(*deletedAt, retention).Format("2006-01-02")
+		},
+		Message:     message,
+		MessageType: messageType,
+		Warnings:    warnings,
+		CSRF:        csrf.Field(r),
+	}
+
+	renderTemplate(w, "trash.html", data)
+}
+
+// Restore a maintenance or schedule from the recycle bin
+func restoreTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	kind, ok := trashKinds[r.FormValue("kind")]
+	if !ok {
+		http.Error(w, "Invalid kind", http.StatusBadRequest)
+		return
+	}
+	objID, err := primitive.ObjectIDFromHex(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	before, after, label, err := kind.deleted(ctx, objID)
+	if err != nil {
+		flash.Redirect(w, r, "/trash", flash.Error, "Item not found in the recycle bin")
+		return
+	}
+
+	if err := kind.bin().Restore(ctx, objID); err != nil {
+		http.Error(w, "Restore error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(ctx, r, audit.ActionRestore, r.FormValue("kind"), objID, label, before, after)
+	publishEvent(ctx, kind.restored, after)
+
+	flash.Redirect(w, r, "/trash", flash.Success, label+" restored successfully")
+}
+
+// Permanently delete a maintenance or schedule from the recycle bin
+func purgeTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	kind, ok := trashKinds[r.FormValue("kind")]
+	if !ok {
+		http.Error(w, "Invalid kind", http.StatusBadRequest)
+		return
+	}
+	objID, err := primitive.ObjectIDFromHex(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	before, _, label, err := kind.deleted(ctx, objID)
+	if err != nil {
+		flash.Redirect(w, r, "/trash", flash.Error, "Item not found in the recycle bin")
+		return
+	}
+
+	if err := kind.bin().Purge(ctx, objID); err != nil {
+		http.Error(w, "Purge error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordAudit(ctx, r, audit.ActionPurge, r.FormValue("kind"), objID, label, before, nil)
+
+	flash.Redirect(w, r, "/trash", flash.Success, label+" permanently deleted")
+}
@@ -0,0 +1,238 @@
+package maintenence
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"shared/csrf"
+	"shared/flash"
+	"shared/validate"
+	"shared/webhook"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// publishEvent queues a webhook event; a failure is logged but never fails
+// the request, since the change itself was saved
+func publishEvent(ctx context.Context, eventType string, data interface{}) {
+	if err := repo.Webhooks.Publish(ctx, "maintenance", eventType, data); err != nil {
+		log.Printf("error publishing %s webhook: %v", eventType, err)
+	}
+}
+
+// List webhook subscriptions
+func listWebhooks(w http.ResponseWriter, r *http.Request) {
+	renderWebhooks(w, r, nil)
+}
+
+// renderWebhooks shows the webhook subscriptions and, when f is not nil,
+// the create form that did not validate
+func renderWebhooks(w http.ResponseWriter, r *http.Request, f *validate.Form) {
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	subs, err := webhook.ListSubscriptions(ctx, repo.WebhookStore)
+	if err != nil {
+		http.Error(w, "Failed to fetch webhooks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	message, messageType := flashMessage(w, r)
+	data := struct {
+		Subscriptions []webhook.Subscription
+		EventTypes    []string
+		Message       string
+		MessageType   string
+		Form          *validate.Form
+		CSRF          template.HTML
+	}{
+		Subscriptions: subs,
+		EventTypes:    webhook.EventTypes,
+		Message:       message,
+		MessageType:   messageType,
+		Form:          f,
+		CSRF:          csrf.Field(r),
+	}
+
+	pageStatus(w, f)
+	renderTemplate(w, "webhooks.html", data)
+}
+
+// Create a webhook subscription
+func createWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	f, err := validate.Parse(r, "create")
+	if err != nil {
+		http.Error(w, "Parse form error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sub := webhook.Subscription{
+		URL:    f.URL("url"),
+		Secret: f.Get("secret"),
+		Events: f.Values["events[]"],
+	}
+	f.MaxLength("secret", 200)
+	f.Check(len(sub.Events) > 0, "events[]", "Select at least one event")
+	for _, e := range sub.Events {
+		f.Check(e == "*" || slices.Contains(webhook.EventTypes, e), "events[]", fmt.Sprintf("%q is not an event", e))
+	}
+	if !f.Valid() {
+		showForm(w, r, f, renderWebhooks)
+		return
+	}
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	if _, err := webhook.CreateSubscription(ctx, repo.WebhookStore, sub); err != nil {
+		log.Printf("error creating webhook: %v", err)
+		flash.Redirect(w, r, "/webhooks", flash.Error, "Error creating webhook")
+		return
+	}
+
+	flash.Redirect(w, r, "/webhooks", flash.Success, "Webhook created successfully")
+}
+
+// Pause or resume a webhook subscription
+func toggleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	objID, err := primitive.ObjectIDFromHex(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	active := r.FormValue("active") == "true"
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	if err := webhook.SetSubscriptionActive(ctx, repo.WebhookStore, objID, active); err != nil {
+		http.Error(w, "Update error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	message := "Webhook paused"
+	if active {
+		message = "Webhook resumed"
+	}
+	flash.Redirect(w, r, "/webhooks", flash.Success, message)
+}
+
+// Delete a webhook subscription
+func deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	objID, err := primitive.ObjectIDFromHex(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	if err := webhook.DeleteSubscription(ctx, repo.WebhookStore, objID); err != nil {
+		http.Error(w, "Delete error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	flash.Redirect(w, r, "/webhooks", flash.Success, "Webhook deleted successfully")
+}
+
+// Delivery log of webhook events, most recent first
+func listWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := webhook.DeliveryFilter{
+		Event:  q.Get("event"),
+		Status: q.Get("status"),
+		Limit:  200,
+	}
+	if s := q.Get("subscription_id"); s != "" {
+		oid, err := primitive.ObjectIDFromHex(s)
+		if err != nil {
+			http.Error(w, "Invalid subscription_id", http.StatusBadRequest)
+			return
+		}
+		filter.SubscriptionID = &oid
+	}
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	deliveries, err := webhook.ListDeliveries(ctx, repo.WebhookStore, filter)
+	if err != nil {
+		http.Error(w, "Failed to fetch deliveries: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	subs, err := webhook.ListSubscriptions(ctx, repo.WebhookStore)
+	if err != nil {
+		http.Error(w, "Failed to fetch webhooks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	message, messageType := flashMessage(w, r)
+	data := struct {
+		Deliveries     []webhook.Delivery
+		Subscriptions  []webhook.Subscription
+		EventTypes     []string
+		Statuses       []string
+		SubscriptionID string
+		Event          string
+		Status         string
+		Message        string
+		MessageType    string
+		CSRF           template.HTML
+	}{
+		Deliveries:     deliveries,
+		Subscriptions:  subs,
+		EventTypes:     webhook.EventTypes,
+		Statuses:       []string{webhook.StatusPending, webhook.StatusSucceeded, webhook.StatusFailed},
+		SubscriptionID: q.Get("subscription_id"),
+		Event:          filter.Event,
+		Status:         filter.Status,
+		Message:        message,
+		MessageType:    messageType,
+		CSRF:           csrf.Field(r),
+	}
+
+	renderTemplate(w, "webhook_deliveries.html", data)
+}
+
+// Queue a delivery again, e.g. after the receiver has been fixed
+func redeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	objID, err := primitive.ObjectIDFromHex(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := getCtx()
+	defer cancel()
+
+	if err := webhook.Redeliver(ctx, repo.WebhookStore, objID); err != nil {
+		http.Error(w, "Redeliver error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	flash.Redirect(w, r, "/webhooks/deliveries", flash.Success, "Delivery queued again")
+}
@@ -0,0 +1,297 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v3.21.12
+// source: asset.proto
+
+package cmmspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	AssetRegister_GetAsset_FullMethodName          = "/cmms.v1.AssetRegister/GetAsset"
+	AssetRegister_BatchGetAssets_FullMethodName    = "/cmms.v1.AssetRegister/BatchGetAssets"
+	AssetRegister_ListAssets_FullMethodName        = "/cmms.v1.AssetRegister/ListAssets"
+	AssetRegister_ListDeletedAssets_FullMethodName = "/cmms.v1.AssetRegister/ListDeletedAssets"
+	AssetRegister_WatchAssets_FullMethodName       = "/cmms.v1.AssetRegister/WatchAssets"
+)
+
+// AssetRegisterClient is the client API for AssetRegister service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// AssetRegister is the asset service as the maintenance service sees it.
+// Assets in the recycle bin are only returned by ListDeletedAssets.
+type AssetRegisterClient interface {
+	// GetAsset returns one asset, or NOT_FOUND
+	GetAsset(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Asset, error)
+	// BatchGetAssets returns the listed assets
+	BatchGetAssets(ctx context.Context, in *BatchGetRequest, opts ...grpc.CallOption) (*Assets, error)
+	// ListAssets returns every asset, optionally of one type or location
+	ListAssets(ctx context.Context, in *ListAssetsRequest, opts ...grpc.CallOption) (*Assets, error)
+	// ListDeletedAssets returns the assets in the recycle bin, which can
+	// still be restored
+	ListDeletedAssets(ctx context.Context, in *ListDeletedRequest, opts ...grpc.CallOption) (*Assets, error)
+	// WatchAssets streams the assets created, changed, deleted and restored
+	// from now on
+	WatchAssets(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AssetChange], error)
+}
+
+type assetRegisterClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAssetRegisterClient(cc grpc.ClientConnInterface) AssetRegisterClient {
+	return &assetRegisterClient{cc}
+}
+
+func (c *assetRegisterClient) GetAsset(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Asset, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Asset)
+	err := c.cc.Invoke(ctx, AssetRegister_GetAsset_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *assetRegisterClient) BatchGetAssets(ctx context.Context, in *BatchGetRequest, opts ...grpc.CallOption) (*Assets, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Assets)
+	err := c.cc.Invoke(ctx, AssetRegister_BatchGetAssets_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *assetRegisterClient) ListAssets(ctx context.Context, in *ListAssetsRequest, opts ...grpc.CallOption) (*Assets, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Assets)
+	err := c.cc.Invoke(ctx, AssetRegister_ListAssets_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *assetRegisterClient) ListDeletedAssets(ctx context.Context, in *ListDeletedRequest, opts ...grpc.CallOption) (*Assets, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Assets)
+	err := c.cc.Invoke(ctx, AssetRegister_ListDeletedAssets_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *assetRegisterClient) WatchAssets(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AssetChange], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &AssetRegister_ServiceDesc.Streams[0], AssetRegister_WatchAssets_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequest, AssetChange]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AssetRegister_WatchAssetsClient = grpc.ServerStreamingClient[AssetChange]
+
+// AssetRegisterServer is the server API for AssetRegister service.
+// All implementations must embed UnimplementedAssetRegisterServer
+// for forward compatibility.
+//
+// AssetRegister is the asset service as the maintenance service sees it.
+// Assets in the recycle bin are only returned by ListDeletedAssets.
+type AssetRegisterServer interface {
+	// GetAsset returns one asset, or NOT_FOUND
+	GetAsset(context.Context, *GetRequest) (*Asset, error)
+	// BatchGetAssets returns the listed assets
+	BatchGetAssets(context.Context, *BatchGetRequest) (*Assets, error)
+	// ListAssets returns every asset, optionally of one type or location
+	ListAssets(context.Context, *ListAssetsRequest) (*Assets, error)
+	// ListDeletedAssets returns the assets in the recycle bin, which can
+	// still be restored
+	ListDeletedAssets(context.Context, *ListDeletedRequest) (*Assets, error)
+	// WatchAssets streams the assets created, changed, deleted and restored
+	// from now on
+	WatchAssets(*WatchRequest, grpc.ServerStreamingServer[AssetChange]) error
+	mustEmbedUnimplementedAssetRegisterServer()
+}
+
+// UnimplementedAssetRegisterServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAssetRegisterServer struct{}
+
+func (UnimplementedAssetRegisterServer) GetAsset(context.Context, *GetRequest) (*Asset, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAsset not implemented")
+}
+func (UnimplementedAssetRegisterServer) BatchGetAssets(context.Context, *BatchGetRequest) (*Assets, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetAssets not implemented")
+}
+func (UnimplementedAssetRegisterServer) ListAssets(context.Context, *ListAssetsRequest) (*Assets, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAssets not implemented")
+}
+func (UnimplementedAssetRegisterServer) ListDeletedAssets(context.Context, *ListDeletedRequest) (*Assets, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeletedAssets not implemented")
+}
+func (UnimplementedAssetRegisterServer) WatchAssets(*WatchRequest, grpc.ServerStreamingServer[AssetChange]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchAssets not implemented")
+}
+func (UnimplementedAssetRegisterServer) mustEmbedUnimplementedAssetRegisterServer() {}
+func (UnimplementedAssetRegisterServer) testEmbeddedByValue()                       {}
+
+// UnsafeAssetRegisterServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AssetRegisterServer will
+// result in compilation errors.
+type UnsafeAssetRegisterServer interface {
+	mustEmbedUnimplementedAssetRegisterServer()
+}
+
+func RegisterAssetRegisterServer(s grpc.ServiceRegistrar, srv AssetRegisterServer) {
+	// If the following call pancis, it indicates UnimplementedAssetRegisterServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AssetRegister_ServiceDesc, srv)
+}
+
+func _AssetRegister_GetAsset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AssetRegisterServer).GetAsset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AssetRegister_GetAsset_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AssetRegisterServer).GetAsset(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AssetRegister_BatchGetAssets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AssetRegisterServer).BatchGetAssets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AssetRegister_BatchGetAssets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AssetRegisterServer).BatchGetAssets(ctx, req.(*BatchGetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AssetRegister_ListAssets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAssetsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AssetRegisterServer).ListAssets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AssetRegister_ListAssets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AssetRegisterServer).ListAssets(ctx, req.(*ListAssetsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AssetRegister_ListDeletedAssets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeletedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AssetRegisterServer).ListDeletedAssets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AssetRegister_ListDeletedAssets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AssetRegisterServer).ListDeletedAssets(ctx, req.(*ListDeletedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AssetRegister_WatchAssets_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AssetRegisterServer).WatchAssets(m, &grpc.GenericServerStream[WatchRequest, AssetChange]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AssetRegister_WatchAssetsServer = grpc.ServerStreamingServer[AssetChange]
+
+// AssetRegister_ServiceDesc is the grpc.ServiceDesc for AssetRegister service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AssetRegister_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "cmms.v1.AssetRegister",
+	HandlerType: (*AssetRegisterServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetAsset",
+			Handler:    _AssetRegister_GetAsset_Handler,
+		},
+		{
+			MethodName: "BatchGetAssets",
+			Handler:    _AssetRegister_BatchGetAssets_Handler,
+		},
+		{
+			MethodName: "ListAssets",
+			Handler:    _AssetRegister_ListAssets_Handler,
+		},
+		{
+			MethodName: "ListDeletedAssets",
+			Handler:    _AssetRegister_ListDeletedAssets_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchAssets",
+			Handler:       _AssetRegister_WatchAssets_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "asset.proto",
+}
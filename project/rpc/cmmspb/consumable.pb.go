@@ -0,0 +1,396 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        v3.21.12
+// source: consumable.proto
+
+package cmmspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Consumable struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id    string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Label string `protobuf:"bytes,2,opt,name=label,proto3" json:"label,omitempty"`
+	Notes string `protobuf:"bytes,3,opt,name=notes,proto3" json:"notes,omitempty"`
+}
+
+func (x *Consumable) Reset() {
+	*x = Consumable{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_consumable_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Consumable) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Consumable) ProtoMessage() {}
+
+func (x *Consumable) ProtoReflect() protoreflect.Message {
+	mi := &file_consumable_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Consumable.ProtoReflect.Descriptor instead.
+func (*Consumable) Descriptor() ([]byte, []int) {
+	return file_consumable_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Consumable) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Consumable) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *Consumable) GetNotes() string {
+	if x != nil {
+		return x.Notes
+	}
+	return ""
+}
+
+type Consumables struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Consumables []*Consumable `protobuf:"bytes,1,rep,name=consumables,proto3" json:"consumables,omitempty"`
+}
+
+func (x *Consumables) Reset() {
+	*x = Consumables{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_consumable_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Consumables) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Consumables) ProtoMessage() {}
+
+func (x *Consumables) ProtoReflect() protoreflect.Message {
+	mi := &file_consumable_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Consumables.ProtoReflect.Descriptor instead.
+func (*Consumables) Descriptor() ([]byte, []int) {
+	return file_consumable_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Consumables) GetConsumables() []*Consumable {
+	if x != nil {
+		return x.Consumables
+	}
+	return nil
+}
+
+type ListConsumablesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ListConsumablesRequest) Reset() {
+	*x = ListConsumablesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_consumable_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListConsumablesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListConsumablesRequest) ProtoMessage() {}
+
+func (x *ListConsumablesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_consumable_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListConsumablesRequest.ProtoReflect.Descriptor instead.
+func (*ListConsumablesRequest) Descriptor() ([]byte, []int) {
+	return file_consumable_proto_rawDescGZIP(), []int{2}
+}
+
+type ConsumableChange struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type       ChangeType  `protobuf:"varint,1,opt,name=type,proto3,enum=cmms.v1.ChangeType" json:"type,omitempty"`
+	Consumable *Consumable `protobuf:"bytes,2,opt,name=consumable,proto3" json:"consumable,omitempty"`
+}
+
+func (x *ConsumableChange) Reset() {
+	*x = ConsumableChange{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_consumable_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConsumableChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConsumableChange) ProtoMessage() {}
+
+func (x *ConsumableChange) ProtoReflect() protoreflect.Message {
+	mi := &file_consumable_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConsumableChange.ProtoReflect.Descriptor instead.
+func (*ConsumableChange) Descriptor() ([]byte, []int) {
+	return file_consumable_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ConsumableChange) GetType() ChangeType {
+	if x != nil {
+		return x.Type
+	}
+	return ChangeType_CHANGE_TYPE_UNSPECIFIED
+}
+
+func (x *ConsumableChange) GetConsumable() *Consumable {
+	if x != nil {
+		return x.Consumable
+	}
+	return nil
+}
+
+var File_consumable_proto protoreflect.FileDescriptor
+
+var file_consumable_proto_rawDesc = []byte{
+	0x0a, 0x10, 0x63, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x61, 0x62, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x12, 0x07, 0x63, 0x6d, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x1a, 0x0c, 0x63, 0x68, 0x61,
+	0x6e, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x48, 0x0a, 0x0a, 0x43, 0x6f, 0x6e,
+	0x73, 0x75, 0x6d, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x61, 0x62, 0x65, 0x6c,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x12, 0x14, 0x0a,
+	0x05, 0x6e, 0x6f, 0x74, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6e, 0x6f,
+	0x74, 0x65, 0x73, 0x22, 0x44, 0x0a, 0x0b, 0x43, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x61, 0x62, 0x6c,
+	0x65, 0x73, 0x12, 0x35, 0x0a, 0x0b, 0x63, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x61, 0x62, 0x6c, 0x65,
+	0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x63, 0x6d, 0x6d, 0x73, 0x2e, 0x76,
+	0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x61, 0x62, 0x6c, 0x65, 0x52, 0x0b, 0x63, 0x6f,
+	0x6e, 0x73, 0x75, 0x6d, 0x61, 0x62, 0x6c, 0x65, 0x73, 0x22, 0x18, 0x0a, 0x16, 0x4c, 0x69, 0x73,
+	0x74, 0x43, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x61, 0x62, 0x6c, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x22, 0x70, 0x0a, 0x10, 0x43, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x61, 0x62, 0x6c,
+	0x65, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x27, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x13, 0x2e, 0x63, 0x6d, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e,
+	0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65,
+	0x12, 0x33, 0x0a, 0x0a, 0x63, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x63, 0x6d, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43,
+	0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x61, 0x62, 0x6c, 0x65, 0x52, 0x0a, 0x63, 0x6f, 0x6e, 0x73, 0x75,
+	0x6d, 0x61, 0x62, 0x6c, 0x65, 0x32, 0xf4, 0x02, 0x0a, 0x11, 0x43, 0x6f, 0x6e, 0x73, 0x75, 0x6d,
+	0x61, 0x62, 0x6c, 0x65, 0x43, 0x61, 0x74, 0x61, 0x6c, 0x6f, 0x67, 0x12, 0x39, 0x0a, 0x0d, 0x47,
+	0x65, 0x74, 0x43, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x13, 0x2e, 0x63,
+	0x6d, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x13, 0x2e, 0x63, 0x6d, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x73,
+	0x75, 0x6d, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x45, 0x0a, 0x13, 0x42, 0x61, 0x74, 0x63, 0x68, 0x47,
+	0x65, 0x74, 0x43, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x61, 0x62, 0x6c, 0x65, 0x73, 0x12, 0x18, 0x2e,
+	0x63, 0x6d, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x61, 0x74, 0x63, 0x68, 0x47, 0x65, 0x74,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x14, 0x2e, 0x63, 0x6d, 0x6d, 0x73, 0x2e, 0x76,
+	0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x61, 0x62, 0x6c, 0x65, 0x73, 0x12, 0x48, 0x0a,
+	0x0f, 0x4c, 0x69, 0x73, 0x74, 0x43, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x61, 0x62, 0x6c, 0x65, 0x73,
+	0x12, 0x1f, 0x2e, 0x63, 0x6d, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x43,
+	0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x61, 0x62, 0x6c, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x14, 0x2e, 0x63, 0x6d, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x73,
+	0x75, 0x6d, 0x61, 0x62, 0x6c, 0x65, 0x73, 0x12, 0x4b, 0x0a, 0x16, 0x4c, 0x69, 0x73, 0x74, 0x44,
+	0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x43, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x61, 0x62, 0x6c, 0x65,
+	0x73, 0x12, 0x1b, 0x2e, 0x63, 0x6d, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74,
+	0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x14,
+	0x2e, 0x63, 0x6d, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x61,
+	0x62, 0x6c, 0x65, 0x73, 0x12, 0x46, 0x0a, 0x10, 0x57, 0x61, 0x74, 0x63, 0x68, 0x43, 0x6f, 0x6e,
+	0x73, 0x75, 0x6d, 0x61, 0x62, 0x6c, 0x65, 0x73, 0x12, 0x15, 0x2e, 0x63, 0x6d, 0x6d, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x19, 0x2e, 0x63, 0x6d, 0x6d, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x73, 0x75, 0x6d,
+	0x61, 0x62, 0x6c, 0x65, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x30, 0x01, 0x42, 0x19, 0x5a, 0x17,
+	0x63, 0x6d, 0x6d, 0x73, 0x2f, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x2f, 0x72, 0x70, 0x63,
+	0x2f, 0x63, 0x6d, 0x6d, 0x73, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_consumable_proto_rawDescOnce sync.Once
+	file_consumable_proto_rawDescData = file_consumable_proto_rawDesc
+)
+
+func file_consumable_proto_rawDescGZIP() []byte {
+	file_consumable_proto_rawDescOnce.Do(func() {
+		file_consumable_proto_rawDescData = protoimpl.X.CompressGZIP(file_consumable_proto_rawDescData)
+	})
+	return file_consumable_proto_rawDescData
+}
+
+var file_consumable_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_consumable_proto_goTypes = []any{
+	(*Consumable)(nil),             // 0: cmms.v1.Consumable
+	(*Consumables)(nil),            // 1: cmms.v1.Consumables
+	(*ListConsumablesRequest)(nil), // 2: cmms.v1.ListConsumablesRequest
+	(*ConsumableChange)(nil),       // 3: cmms.v1.ConsumableChange
+	(ChangeType)(0),                // 4: cmms.v1.ChangeType
+	(*GetRequest)(nil),             // 5: cmms.v1.GetRequest
+	(*BatchGetRequest)(nil),        // 6: cmms.v1.BatchGetRequest
+	(*ListDeletedRequest)(nil),     // 7: cmms.v1.ListDeletedRequest
+	(*WatchRequest)(nil),           // 8: cmms.v1.WatchRequest
+}
+var file_consumable_proto_depIdxs = []int32{
+	0, // 0: cmms.v1.Consumables.consumables:type_name -> cmms.v1.Consumable
+	4, // 1: cmms.v1.ConsumableChange.type:type_name -> cmms.v1.ChangeType
+	0, // 2: cmms.v1.ConsumableChange.consumable:type_name -> cmms.v1.Consumable
+	5, // 3: cmms.v1.ConsumableCatalog.GetConsumable:input_type -> cmms.v1.GetRequest
+	6, // 4: cmms.v1.ConsumableCatalog.BatchGetConsumables:input_type -> cmms.v1.BatchGetRequest
+	2, // 5: cmms.v1.ConsumableCatalog.ListConsumables:input_type -> cmms.v1.ListConsumablesRequest
+	7, // 6: cmms.v1.ConsumableCatalog.ListDeletedConsumables:input_type -> cmms.v1.ListDeletedRequest
+	8, // 7: cmms.v1.ConsumableCatalog.WatchConsumables:input_type -> cmms.v1.WatchRequest
+	0, // 8: cmms.v1.ConsumableCatalog.GetConsumable:output_type -> cmms.v1.Consumable
+	1, // 9: cmms.v1.ConsumableCatalog.BatchGetConsumables:output_type -> cmms.v1.Consumables
+	1, // 10: cmms.v1.ConsumableCatalog.ListConsumables:output_type -> cmms.v1.Consumables
+	1, // 11: cmms.v1.ConsumableCatalog.ListDeletedConsumables:output_type -> cmms.v1.Consumables
+	3, // 12: cmms.v1.ConsumableCatalog.WatchConsumables:output_type -> cmms.v1.ConsumableChange
+	8, // [8:13] is the sub-list for method output_type
+	3, // [3:8] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_consumable_proto_init() }
+func file_consumable_proto_init() {
+	if File_consumable_proto != nil {
+		return
+	}
+	file_change_proto_init()
+	if !protoimpl.UnsafeEnabled {
+		file_consumable_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*Consumable); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_consumable_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*Consumables); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_consumable_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*ListConsumablesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_consumable_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*ConsumableChange); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_consumable_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_consumable_proto_goTypes,
+		DependencyIndexes: file_consumable_proto_depIdxs,
+		MessageInfos:      file_consumable_proto_msgTypes,
+	}.Build()
+	File_consumable_proto = out.File
+	file_consumable_proto_rawDesc = nil
+	file_consumable_proto_goTypes = nil
+	file_consumable_proto_depIdxs = nil
+}
@@ -0,0 +1,299 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v3.21.12
+// source: consumable.proto
+
+package cmmspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ConsumableCatalog_GetConsumable_FullMethodName          = "/cmms.v1.ConsumableCatalog/GetConsumable"
+	ConsumableCatalog_BatchGetConsumables_FullMethodName    = "/cmms.v1.ConsumableCatalog/BatchGetConsumables"
+	ConsumableCatalog_ListConsumables_FullMethodName        = "/cmms.v1.ConsumableCatalog/ListConsumables"
+	ConsumableCatalog_ListDeletedConsumables_FullMethodName = "/cmms.v1.ConsumableCatalog/ListDeletedConsumables"
+	ConsumableCatalog_WatchConsumables_FullMethodName       = "/cmms.v1.ConsumableCatalog/WatchConsumables"
+)
+
+// ConsumableCatalogClient is the client API for ConsumableCatalog service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ConsumableCatalog is the consumable service as the maintenance service
+// sees it. Consumables in the recycle bin are only returned by
+// ListDeletedConsumables.
+type ConsumableCatalogClient interface {
+	// GetConsumable returns one consumable, or NOT_FOUND
+	GetConsumable(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Consumable, error)
+	// BatchGetConsumables returns the listed consumables
+	BatchGetConsumables(ctx context.Context, in *BatchGetRequest, opts ...grpc.CallOption) (*Consumables, error)
+	// ListConsumables returns every consumable
+	ListConsumables(ctx context.Context, in *ListConsumablesRequest, opts ...grpc.CallOption) (*Consumables, error)
+	// ListDeletedConsumables returns the consumables in the recycle bin, which
+	// can still be restored
+	ListDeletedConsumables(ctx context.Context, in *ListDeletedRequest, opts ...grpc.CallOption) (*Consumables, error)
+	// WatchConsumables streams the consumables created, changed, deleted and
+	// restored from now on
+	WatchConsumables(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ConsumableChange], error)
+}
+
+type consumableCatalogClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewConsumableCatalogClient(cc grpc.ClientConnInterface) ConsumableCatalogClient {
+	return &consumableCatalogClient{cc}
+}
+
+func (c *consumableCatalogClient) GetConsumable(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Consumable, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Consumable)
+	err := c.cc.Invoke(ctx, ConsumableCatalog_GetConsumable_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *consumableCatalogClient) BatchGetConsumables(ctx context.Context, in *BatchGetRequest, opts ...grpc.CallOption) (*Consumables, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Consumables)
+	err := c.cc.Invoke(ctx, ConsumableCatalog_BatchGetConsumables_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *consumableCatalogClient) ListConsumables(ctx context.Context, in *ListConsumablesRequest, opts ...grpc.CallOption) (*Consumables, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Consumables)
+	err := c.cc.Invoke(ctx, ConsumableCatalog_ListConsumables_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *consumableCatalogClient) ListDeletedConsumables(ctx context.Context, in *ListDeletedRequest, opts ...grpc.CallOption) (*Consumables, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Consumables)
+	err := c.cc.Invoke(ctx, ConsumableCatalog_ListDeletedConsumables_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *consumableCatalogClient) WatchConsumables(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ConsumableChange], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ConsumableCatalog_ServiceDesc.Streams[0], ConsumableCatalog_WatchConsumables_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequest, ConsumableChange]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ConsumableCatalog_WatchConsumablesClient = grpc.ServerStreamingClient[ConsumableChange]
+
+// ConsumableCatalogServer is the server API for ConsumableCatalog service.
+// All implementations must embed UnimplementedConsumableCatalogServer
+// for forward compatibility.
+//
+// ConsumableCatalog is the consumable service as the maintenance service
+// sees it. Consumables in the recycle bin are only returned by
+// ListDeletedConsumables.
+type ConsumableCatalogServer interface {
+	// GetConsumable returns one consumable, or NOT_FOUND
+	GetConsumable(context.Context, *GetRequest) (*Consumable, error)
+	// BatchGetConsumables returns the listed consumables
+	BatchGetConsumables(context.Context, *BatchGetRequest) (*Consumables, error)
+	// ListConsumables returns every consumable
+	ListConsumables(context.Context, *ListConsumablesRequest) (*Consumables, error)
+	// ListDeletedConsumables returns the consumables in the recycle bin, which
+	// can still be restored
+	ListDeletedConsumables(context.Context, *ListDeletedRequest) (*Consumables, error)
+	// WatchConsumables streams the consumables created, changed, deleted and
+	// restored from now on
+	WatchConsumables(*WatchRequest, grpc.ServerStreamingServer[ConsumableChange]) error
+	mustEmbedUnimplementedConsumableCatalogServer()
+}
+
+// UnimplementedConsumableCatalogServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedConsumableCatalogServer struct{}
+
+func (UnimplementedConsumableCatalogServer) GetConsumable(context.Context, *GetRequest) (*Consumable, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConsumable not implemented")
+}
+func (UnimplementedConsumableCatalogServer) BatchGetConsumables(context.Context, *BatchGetRequest) (*Consumables, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetConsumables not implemented")
+}
+func (UnimplementedConsumableCatalogServer) ListConsumables(context.Context, *ListConsumablesRequest) (*Consumables, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListConsumables not implemented")
+}
+func (UnimplementedConsumableCatalogServer) ListDeletedConsumables(context.Context, *ListDeletedRequest) (*Consumables, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeletedConsumables not implemented")
+}
+func (UnimplementedConsumableCatalogServer) WatchConsumables(*WatchRequest, grpc.ServerStreamingServer[ConsumableChange]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchConsumables not implemented")
+}
+func (UnimplementedConsumableCatalogServer) mustEmbedUnimplementedConsumableCatalogServer() {}
+func (UnimplementedConsumableCatalogServer) testEmbeddedByValue()                           {}
+
+// UnsafeConsumableCatalogServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ConsumableCatalogServer will
+// result in compilation errors.
+type UnsafeConsumableCatalogServer interface {
+	mustEmbedUnimplementedConsumableCatalogServer()
+}
+
+func RegisterConsumableCatalogServer(s grpc.ServiceRegistrar, srv ConsumableCatalogServer) {
+	// If the following call pancis, it indicates UnimplementedConsumableCatalogServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ConsumableCatalog_ServiceDesc, srv)
+}
+
+func _ConsumableCatalog_GetConsumable_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConsumableCatalogServer).GetConsumable(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ConsumableCatalog_GetConsumable_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConsumableCatalogServer).GetConsumable(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConsumableCatalog_BatchGetConsumables_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConsumableCatalogServer).BatchGetConsumables(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ConsumableCatalog_BatchGetConsumables_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConsumableCatalogServer).BatchGetConsumables(ctx, req.(*BatchGetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConsumableCatalog_ListConsumables_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListConsumablesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConsumableCatalogServer).ListConsumables(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ConsumableCatalog_ListConsumables_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConsumableCatalogServer).ListConsumables(ctx, req.(*ListConsumablesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConsumableCatalog_ListDeletedConsumables_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeletedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConsumableCatalogServer).ListDeletedConsumables(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ConsumableCatalog_ListDeletedConsumables_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConsumableCatalogServer).ListDeletedConsumables(ctx, req.(*ListDeletedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConsumableCatalog_WatchConsumables_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ConsumableCatalogServer).WatchConsumables(m, &grpc.GenericServerStream[WatchRequest, ConsumableChange]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ConsumableCatalog_WatchConsumablesServer = grpc.ServerStreamingServer[ConsumableChange]
+
+// ConsumableCatalog_ServiceDesc is the grpc.ServiceDesc for ConsumableCatalog service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ConsumableCatalog_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "cmms.v1.ConsumableCatalog",
+	HandlerType: (*ConsumableCatalogServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetConsumable",
+			Handler:    _ConsumableCatalog_GetConsumable_Handler,
+		},
+		{
+			MethodName: "BatchGetConsumables",
+			Handler:    _ConsumableCatalog_BatchGetConsumables_Handler,
+		},
+		{
+			MethodName: "ListConsumables",
+			Handler:    _ConsumableCatalog_ListConsumables_Handler,
+		},
+		{
+			MethodName: "ListDeletedConsumables",
+			Handler:    _ConsumableCatalog_ListDeletedConsumables_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchConsumables",
+			Handler:       _ConsumableCatalog_WatchConsumables_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "consumable.proto",
+}
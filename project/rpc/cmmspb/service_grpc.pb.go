@@ -0,0 +1,297 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v3.21.12
+// source: service.proto
+
+package cmmspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ServiceCatalog_GetService_FullMethodName          = "/cmms.v1.ServiceCatalog/GetService"
+	ServiceCatalog_BatchGetServices_FullMethodName    = "/cmms.v1.ServiceCatalog/BatchGetServices"
+	ServiceCatalog_ListServices_FullMethodName        = "/cmms.v1.ServiceCatalog/ListServices"
+	ServiceCatalog_ListDeletedServices_FullMethodName = "/cmms.v1.ServiceCatalog/ListDeletedServices"
+	ServiceCatalog_WatchServices_FullMethodName       = "/cmms.v1.ServiceCatalog/WatchServices"
+)
+
+// ServiceCatalogClient is the client API for ServiceCatalog service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ServiceCatalog is the service service as the maintenance service sees it.
+// Services in the recycle bin are only returned by ListDeletedServices.
+type ServiceCatalogClient interface {
+	// GetService returns one service, or NOT_FOUND
+	GetService(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Service, error)
+	// BatchGetServices returns the listed services
+	BatchGetServices(ctx context.Context, in *BatchGetRequest, opts ...grpc.CallOption) (*Services, error)
+	// ListServices returns every service
+	ListServices(ctx context.Context, in *ListServicesRequest, opts ...grpc.CallOption) (*Services, error)
+	// ListDeletedServices returns the services in the recycle bin, which can
+	// still be restored
+	ListDeletedServices(ctx context.Context, in *ListDeletedRequest, opts ...grpc.CallOption) (*Services, error)
+	// WatchServices streams the services created, changed, deleted and
+	// restored from now on
+	WatchServices(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ServiceChange], error)
+}
+
+type serviceCatalogClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewServiceCatalogClient(cc grpc.ClientConnInterface) ServiceCatalogClient {
+	return &serviceCatalogClient{cc}
+}
+
+func (c *serviceCatalogClient) GetService(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Service, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Service)
+	err := c.cc.Invoke(ctx, ServiceCatalog_GetService_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *serviceCatalogClient) BatchGetServices(ctx context.Context, in *BatchGetRequest, opts ...grpc.CallOption) (*Services, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Services)
+	err := c.cc.Invoke(ctx, ServiceCatalog_BatchGetServices_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *serviceCatalogClient) ListServices(ctx context.Context, in *ListServicesRequest, opts ...grpc.CallOption) (*Services, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Services)
+	err := c.cc.Invoke(ctx, ServiceCatalog_ListServices_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *serviceCatalogClient) ListDeletedServices(ctx context.Context, in *ListDeletedRequest, opts ...grpc.CallOption) (*Services, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Services)
+	err := c.cc.Invoke(ctx, ServiceCatalog_ListDeletedServices_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *serviceCatalogClient) WatchServices(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ServiceChange], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ServiceCatalog_ServiceDesc.Streams[0], ServiceCatalog_WatchServices_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequest, ServiceChange]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ServiceCatalog_WatchServicesClient = grpc.ServerStreamingClient[ServiceChange]
+
+// ServiceCatalogServer is the server API for ServiceCatalog service.
+// All implementations must embed UnimplementedServiceCatalogServer
+// for forward compatibility.
+//
+// ServiceCatalog is the service service as the maintenance service sees it.
+// Services in the recycle bin are only returned by ListDeletedServices.
+type ServiceCatalogServer interface {
+	// GetService returns one service, or NOT_FOUND
+	GetService(context.Context, *GetRequest) (*Service, error)
+	// BatchGetServices returns the listed services
+	BatchGetServices(context.Context, *BatchGetRequest) (*Services, error)
+	// ListServices returns every service
+	ListServices(context.Context, *ListServicesRequest) (*Services, error)
+	// ListDeletedServices returns the services in the recycle bin, which can
+	// still be restored
+	ListDeletedServices(context.Context, *ListDeletedRequest) (*Services, error)
+	// WatchServices streams the services created, changed, deleted and
+	// restored from now on
+	WatchServices(*WatchRequest, grpc.ServerStreamingServer[ServiceChange]) error
+	mustEmbedUnimplementedServiceCatalogServer()
+}
+
+// UnimplementedServiceCatalogServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedServiceCatalogServer struct{}
+
+func (UnimplementedServiceCatalogServer) GetService(context.Context, *GetRequest) (*Service, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetService not implemented")
+}
+func (UnimplementedServiceCatalogServer) BatchGetServices(context.Context, *BatchGetRequest) (*Services, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetServices not implemented")
+}
+func (UnimplementedServiceCatalogServer) ListServices(context.Context, *ListServicesRequest) (*Services, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListServices not implemented")
+}
+func (UnimplementedServiceCatalogServer) ListDeletedServices(context.Context, *ListDeletedRequest) (*Services, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeletedServices not implemented")
+}
+func (UnimplementedServiceCatalogServer) WatchServices(*WatchRequest, grpc.ServerStreamingServer[ServiceChange]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchServices not implemented")
+}
+func (UnimplementedServiceCatalogServer) mustEmbedUnimplementedServiceCatalogServer() {}
+func (UnimplementedServiceCatalogServer) testEmbeddedByValue()                        {}
+
+// UnsafeServiceCatalogServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ServiceCatalogServer will
+// result in compilation errors.
+type UnsafeServiceCatalogServer interface {
+	mustEmbedUnimplementedServiceCatalogServer()
+}
+
+func RegisterServiceCatalogServer(s grpc.ServiceRegistrar, srv ServiceCatalogServer) {
+	// If the following call pancis, it indicates UnimplementedServiceCatalogServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ServiceCatalog_ServiceDesc, srv)
+}
+
+func _ServiceCatalog_GetService_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServiceCatalogServer).GetService(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ServiceCatalog_GetService_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServiceCatalogServer).GetService(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ServiceCatalog_BatchGetServices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServiceCatalogServer).BatchGetServices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ServiceCatalog_BatchGetServices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServiceCatalogServer).BatchGetServices(ctx, req.(*BatchGetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ServiceCatalog_ListServices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListServicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServiceCatalogServer).ListServices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ServiceCatalog_ListServices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServiceCatalogServer).ListServices(ctx, req.(*ListServicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ServiceCatalog_ListDeletedServices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeletedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServiceCatalogServer).ListDeletedServices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ServiceCatalog_ListDeletedServices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServiceCatalogServer).ListDeletedServices(ctx, req.(*ListDeletedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ServiceCatalog_WatchServices_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceCatalogServer).WatchServices(m, &grpc.GenericServerStream[WatchRequest, ServiceChange]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ServiceCatalog_WatchServicesServer = grpc.ServerStreamingServer[ServiceChange]
+
+// ServiceCatalog_ServiceDesc is the grpc.ServiceDesc for ServiceCatalog service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ServiceCatalog_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "cmms.v1.ServiceCatalog",
+	HandlerType: (*ServiceCatalogServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetService",
+			Handler:    _ServiceCatalog_GetService_Handler,
+		},
+		{
+			MethodName: "BatchGetServices",
+			Handler:    _ServiceCatalog_BatchGetServices_Handler,
+		},
+		{
+			MethodName: "ListServices",
+			Handler:    _ServiceCatalog_ListServices_Handler,
+		},
+		{
+			MethodName: "ListDeletedServices",
+			Handler:    _ServiceCatalog_ListDeletedServices_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchServices",
+			Handler:       _ServiceCatalog_WatchServices_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "service.proto",
+}
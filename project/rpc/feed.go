@@ -0,0 +1,71 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// feedBuffer is how many changes a watcher may lag behind before it is
+// dropped
+const feedBuffer = 64
+
+// Feed hands every change published to it to the Watch streams open at that
+// moment. Changes are published by the process making them, so a Watch sees
+// every change as long as the service is the only one writing its records.
+type Feed[T any] struct {
+	mu   sync.Mutex
+	subs map[chan T]struct{}
+}
+
+// Publish hands v to every watcher without waiting. A watcher too far behind
+// is dropped; its stream ends and the client starts over.
+func (f *Feed[T]) Publish(v T) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subs {
+		select {
+		case ch <- v:
+		default:
+			delete(f.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Stream sends every change published from now on with send until ctx is
+// done or the watcher falls behind
+func (f *Feed[T]) Stream(ctx context.Context, send func(T) error) error {
+	ch := make(chan T, feedBuffer)
+	f.mu.Lock()
+	if f.subs == nil {
+		f.subs = map[chan T]struct{}{}
+	}
+	f.subs[ch] = struct{}{}
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subs[ch]; ok {
+			delete(f.subs, ch)
+			close(ch)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case v, ok := <-ch:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watcher fell behind")
+			}
+			if err := send(v); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package rpc
+
+import (
+	"context"
+	"shared/metrics"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Counted returns the dial option recording the calls made on a connection
+// to the service target with metrics.Downstream. Answers about the request,
+// such as NOT_FOUND, are not counted as errors; a failed service or network
+// is.
+func Counted(target string) grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		metrics.Downstream(target, metrics.GRPC, failed(err))
+		return err
+	})
+}
+
+// failed returns err when it is a failure of the service called rather than
+// an answer about the request
+func failed(err error) error {
+	switch status.Code(err) {
+	case codes.OK, codes.NotFound, codes.InvalidArgument, codes.AlreadyExists, codes.FailedPrecondition, codes.Canceled:
+		return nil
+	}
+	return err
+}
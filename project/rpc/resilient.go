@@ -0,0 +1,173 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrCircuitOpen is returned without calling the service while its breaker
+// is open. It carries the UNAVAILABLE code, so callers treat it as any other
+// unreachable service.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Policy is how the unary calls to one service are retried and when they
+// stop being made
+type Policy struct {
+	// Retries is the number of attempts after the first one
+	Retries int
+	// BaseBackoff is the wait before the first retry, doubled for each next
+	// one up to MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// FailureThreshold consecutive failures open the breaker for Cooldown;
+	// then a single trial call decides whether it closes again
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+// DefaultPolicy retries twice starting at 200ms and opens the breaker for
+// 30s after 5 consecutive failures
+func DefaultPolicy() Policy {
+	return Policy{
+		Retries:          2,
+		BaseBackoff:      200 * time.Millisecond,
+		MaxBackoff:       2 * time.Second,
+		FailureThreshold: 5,
+		Cooldown:         30 * time.Second,
+	}
+}
+
+// Resilient returns the dial option retrying the unary calls to the service
+// target that fail with UNAVAILABLE, RESOURCE_EXHAUSTED or a deadline, with
+// backoff, behind a circuit breaker of its own. While the breaker is open a
+// call fails at once with ErrCircuitOpen, so a service that is down costs one
+// fast error per page instead of a timeout per call. Answers about the
+// request, such as NOT_FOUND, count as successes. Streams are left alone.
+func Resilient(target string, p Policy) grpc.DialOption {
+	b := &breaker{}
+	return grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		var lastErr error
+		for attempt := 0; attempt <= p.Retries; attempt++ {
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+					return lastErr
+				case <-time.After(p.backoff(attempt)):
+				}
+			}
+
+			if !b.allow(time.Now()) {
+				return &circuitOpenError{target: target, last: lastErr}
+			}
+
+			lastErr = invoker(ctx, method, req, reply, cc, opts...)
+			switch {
+			case errors.Is(ctx.Err(), context.Canceled):
+				// Given up by the caller; says nothing about the service
+				b.release()
+				return lastErr
+			case failed(lastErr) == nil:
+				b.success()
+				return lastErr
+			}
+			b.failure(time.Now(), p.FailureThreshold, p.Cooldown)
+			if !retryable(lastErr) || ctx.Err() != nil {
+				return lastErr
+			}
+		}
+		return lastErr
+	})
+}
+
+// retryable reports whether a failed call may succeed when made again
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// backoff returns the wait before the given retry
+func (p Policy) backoff(attempt int) time.Duration {
+	d := p.BaseBackoff << (attempt - 1)
+	if d > p.MaxBackoff || d <= 0 {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// circuitOpenError is ErrCircuitOpen for one service, with the failure that
+// opened the breaker when it happened during the same call
+type circuitOpenError struct {
+	target string
+	last   error
+}
+
+func (e *circuitOpenError) Error() string {
+	if e.last != nil {
+		return fmt.Sprintf("%s: %v (last error: %v)", e.target, ErrCircuitOpen, e.last)
+	}
+	return fmt.Sprintf("%s: %v", e.target, ErrCircuitOpen)
+}
+
+func (e *circuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+func (e *circuitOpenError) GRPCStatus() *status.Status {
+	return status.New(codes.Unavailable, e.Error())
+}
+
+// breaker is the circuit breaker of one service. It is closed while failures
+// stays below the threshold, open until openUntil, and then half open: one
+// trial call is let through and its outcome closes or reopens it.
+type breaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openUntil.IsZero() {
+		return true
+	}
+	if now.Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures, b.openUntil, b.trial = 0, time.Time{}, false
+}
+
+func (b *breaker) failure(now time.Time, threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.trial || b.failures >= threshold {
+		b.openUntil = now.Add(cooldown)
+		b.trial = false
+	}
+}
+
+// release lets another trial call through after one was abandoned
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
@@ -0,0 +1,86 @@
+package rpc
+
+import (
+	"cmms/project/rpc/cmmspb"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// downRegister answers every GetAsset with UNAVAILABLE, or NOT_FOUND when
+// found is set, counting the calls that reach it
+type downRegister struct {
+	cmmspb.UnimplementedAssetRegisterServer
+	calls atomic.Int32
+	found atomic.Bool
+}
+
+func (d *downRegister) GetAsset(ctx context.Context, req *cmmspb.GetRequest) (*cmmspb.Asset, error) {
+	d.calls.Add(1)
+	if d.found.Load() {
+		return nil, status.Error(codes.NotFound, "asset not found")
+	}
+	return nil, status.Error(codes.Unavailable, "down")
+}
+
+func TestResilientBreaker(t *testing.T) {
+	pipe := NewPipe()
+	server := grpc.NewServer()
+	down := &downRegister{}
+	cmmspb.RegisterAssetRegisterServer(server, down)
+	go server.Serve(pipe)
+	defer server.Stop()
+
+	policy := Policy{Retries: 2, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond, FailureThreshold: 5, Cooldown: 50 * time.Millisecond}
+	conn, err := Dial("asset", grpc.WithContextDialer(pipe.Dial), Resilient("asset", policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := cmmspb.NewAssetRegisterClient(conn)
+	get := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := client.GetAsset(ctx, &cmmspb.GetRequest{Id: "1"})
+		return err
+	}
+
+	// The first call is tried three times, the second twice before the fifth
+	// failure opens the breaker
+	if err := get(); status.Code(err) != codes.Unavailable || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("first call: %v", err)
+	}
+	if n := down.calls.Load(); n != 3 {
+		t.Fatalf("calls = %d, want 3 attempts", n)
+	}
+	if err := get(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second call: %v", err)
+	}
+
+	// While it is open the calls fail at once without reaching the service
+	for i := 0; i < 10; i++ {
+		if err := get(); !errors.Is(err, ErrCircuitOpen) || status.Code(err) != codes.Unavailable {
+			t.Fatalf("open breaker: %v", err)
+		}
+	}
+	if n := down.calls.Load(); n != 5 {
+		t.Errorf("calls = %d, want 5", n)
+	}
+
+	// After the cooldown one trial call closes it again; NOT_FOUND is an
+	// answer, not a failure
+	time.Sleep(policy.Cooldown)
+	down.found.Store(true)
+	if err := get(); status.Code(err) != codes.NotFound {
+		t.Fatalf("trial call: %v", err)
+	}
+	if err := get(); status.Code(err) != codes.NotFound || down.calls.Load() != 7 {
+		t.Errorf("closed breaker: %v, calls = %d", err, down.calls.Load())
+	}
+}
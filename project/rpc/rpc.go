@@ -0,0 +1,63 @@
+// Package rpc holds what the services need to talk gRPC to each other: the
+// contracts in cmmspb, generated from the .proto files there, and helpers to
+// dial, serve in-process and stream changes.
+//
+// The asset, service and consumable services serve their part of cmmspb
+// next to their HTTP pages; the maintenance service is the client.
+package rpc
+
+//go:generate protoc -I cmmspb --go_out=cmmspb --go_opt=paths=source_relative --go-grpc_out=cmmspb --go-grpc_opt=paths=source_relative change.proto asset.proto service.proto consumable.proto
+
+import (
+	"shared/jsonapi"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// Dial returns a connection to the gRPC server at target. The services trust
+// each other's network, like their HTTP calls, so it is not encrypted. A
+// host:port target is dialled as it is, resolving the host on every
+// connection, unless it names a resolver such as dns:///. Callers set their
+// own deadlines, since a Watch stream lasts as long as the client, and add
+// Resilient for retries and a circuit breaker.
+func Dial(target string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	if !strings.Contains(target, ":///") {
+		target = "passthrough:///" + target
+	}
+	opts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, opts...)
+	return grpc.NewClient(target, opts...)
+}
+
+// ObjectID parses the hex id of a request, answering INVALID_ARGUMENT when
+// it is not one
+func ObjectID(id string) (primitive.ObjectID, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return objID, status.Errorf(codes.InvalidArgument, "invalid id %q", id)
+	}
+	return objID, nil
+}
+
+// ObjectIDs parses the hex ids of a batch request, at most jsonapi.MaxIDs of
+// them as for the ?ids= endpoints
+func ObjectIDs(ids []string) ([]primitive.ObjectID, error) {
+	if len(ids) > jsonapi.MaxIDs {
+		return nil, status.Errorf(codes.InvalidArgument, "too many ids, at most %d", jsonapi.MaxIDs)
+	}
+	objIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		objID, err := ObjectID(id)
+		if err != nil {
+			return nil, err
+		}
+		objIDs = append(objIDs, objID)
+	}
+	return objIDs, nil
+}
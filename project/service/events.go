@@ -0,0 +1,22 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"shared/audit"
+	"shared/events"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// deleteWithEvent moves the service to the recycle bin and adds ServiceDeleted to
+// the outbox, in one transaction, so the maintenance service takes it out of
+// the schedules still using it
+func deleteWithEvent(r *http.Request, id primitive.ObjectID, before Service) error {
+	return repo.Tx.Atomically(r.Context(), func(ctx context.Context) error {
+		if err := repo.Services.Delete(ctx, id, audit.Actor(r)); err != nil {
+			return err
+		}
+		return repo.Outbox.Record(ctx, "service", events.ServiceDeleted, id, audit.Actor(r), before)
+	})
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"cmms/project/rpc"
+	"cmms/project/rpc/cmmspb"
+	"context"
+	"errors"
+	"shared/config"
+	"shared/store"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// changes streams the services changed by this process to WatchServices
+var changes rpc.Feed[*cmmspb.ServiceChange]
+
+// publishChange tells the WatchServices streams about a saved change
+func publishChange(typ cmmspb.ChangeType, s Service) {
+	changes.Publish(&cmmspb.ServiceChange{Type: typ, Service: s.proto()})
+}
+
+// RegisterGRPC adds the gRPC server of the services to s; it streams the
+// changes made through the Handler of the same process
+func RegisterGRPC(s grpc.ServiceRegistrar, conf *config.Config, b store.Backend) {
+	cmmspb.RegisterServiceCatalogServer(s, &catalog{services: storeOn(conf, b).Services})
+}
+
+// catalog serves the services over gRPC to the maintenance service
+type catalog struct {
+	cmmspb.UnimplementedServiceCatalogServer
+	services ServiceRepository
+}
+
+func (c *catalog) GetService(ctx context.Context, req *cmmspb.GetRequest) (*cmmspb.Service, error) {
+	id, err := rpc.ObjectID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.services.Get(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "service %s not found", req.GetId())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return s.proto(), nil
+}
+
+func (c *catalog) BatchGetServices(ctx context.Context, req *cmmspb.BatchGetRequest) (*cmmspb.Services, error) {
+	ids, err := rpc.ObjectIDs(req.GetIds())
+	if err != nil {
+		return nil, err
+	}
+	services, err := c.services.GetByIDs(ctx, ids)
+	return servicesProto(services, err)
+}
+
+func (c *catalog) ListServices(ctx context.Context, _ *cmmspb.ListServicesRequest) (*cmmspb.Services, error) {
+	services, err := c.services.List(ctx)
+	return servicesProto(services, err)
+}
+
+func (c *catalog) ListDeletedServices(ctx context.Context, _ *cmmspb.ListDeletedRequest) (*cmmspb.Services, error) {
+	services, err := c.services.ListDeleted(ctx)
+	return servicesProto(services, err)
+}
+
+func (c *catalog) WatchServices(_ *cmmspb.WatchRequest, stream cmmspb.ServiceCatalog_WatchServicesServer) error {
+	return changes.Stream(stream.Context(), stream.Send)
+}
+
+func servicesProto(services []Service, err error) (*cmmspb.Services, error) {
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	out := &cmmspb.Services{Services: make([]*cmmspb.Service, len(services))}
+	for i, s := range services {
+		out.Services[i] = s.proto()
+	}
+	return out, nil
+}
+
+func (s Service) proto() *cmmspb.Service {
+	return &cmmspb.Service{Id: s.ID.Hex(), Label: s.Label, Notes: s.Notes}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"shared/store"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryServices is a ServiceRepository kept in memory, for tests
+type memoryServices struct {
+	table *store.Table[Service]
+}
+
+func (m memoryServices) List(ctx context.Context) ([]Service, error) {
+	return m.table.Live(nil), nil
+}
+
+func (m memoryServices) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Service, error) {
+	wanted := map[primitive.ObjectID]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	return m.table.Live(func(s Service) bool { return wanted[s.ID] }), nil
+}
+
+func (m memoryServices) Get(ctx context.Context, id primitive.ObjectID) (Service, error) {
+	return m.table.Get(id)
+}
+
+func (m memoryServices) Insert(ctx context.Context, s Service) error {
+	return m.table.Insert(s)
+}
+
+func (m memoryServices) Update(ctx context.Context, s Service) error {
+	_, err := m.table.UpdateVersion(s.ID, s.Version, serviceVersion, func(rec *Service) { rec.Label, rec.Notes = s.Label, s.Notes })
+	return err
+}
+
+func (m memoryServices) Delete(ctx context.Context, id primitive.ObjectID, actor string) error {
+	return m.table.Delete(id, actor)
+}
+
+func (m memoryServices) ListDeleted(ctx context.Context) ([]Service, error) {
+	return m.table.Deleted(), nil
+}
+
+func (m memoryServices) GetDeleted(ctx context.Context, id primitive.ObjectID) (Service, error) {
+	return m.table.GetDeleted(id)
+}
+
+func (m memoryServices) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Restore(id)
+}
+
+func (m memoryServices) Purge(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Purge(id)
+}
+
+func (m memoryServices) PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	return m.table.PurgeExpired(retention), nil
+}
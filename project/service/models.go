@@ -1,9 +1,23 @@
-package main
+package service
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Service struct {
 	ID    primitive.ObjectID `bson:"_id"`
 	Label string             `bson:"label"`
 	Notes string             `bson:"notes"`
+	// Version counts the saved changes; an edit must be based on the latest
+	Version int64 `bson:"version" json:"version"`
+
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"-"`
+	DeletedBy string     `bson:"deleted_by,omitempty" json:"-"`
+}
+
+// serviceVersion is the version field of the versioned updates of the tables
+func serviceVersion(s *Service) *int64 {
+	return &s.Version
 }
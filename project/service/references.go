@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+	"shared/csrf"
+	"shared/references"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// referenceClient asks the maintenance service which schedules use a service;
+// it is set up in Handler from the configuration
+var referenceClient *references.Client
+
+// resolveServiceReferences removes a service from the schedules using it or
+// replaces it with the service chosen in the to form value
+func resolveServiceReferences(r *http.Request, id primitive.ObjectID, action string) error {
+	var to primitive.ObjectID
+	if action == references.ActionReassign {
+		var err error
+		if to, err = primitive.ObjectIDFromHex(r.FormValue("to")); err != nil {
+			return errors.New("choose a service to replace it with")
+		}
+		if _, err := repo.Services.Get(context.Background(), to); err != nil {
+			return errors.New("service to replace it with not found")
+		}
+	}
+
+	_, err := referenceClient.Resolve(r.Context(), r, references.KindService, id, action, to)
+	return err
+}
+
+// serviceDeleteConfirm shows the schedules still using a service and lets
+// the user remove it from them or replace it with another service
+func serviceDeleteConfirm(w http.ResponseWriter, r *http.Request, service Service, refs []references.Reference, errMsg string) {
+	services, err := repo.Services.List(context.Background())
+	if err != nil {
+		http.Error(w, "Failed to retrieve services", http.StatusInternalServerError)
+		return
+	}
+	var others []Service
+	for _, s := range services {
+		if s.ID != service.ID {
+			others = append(others, s)
+		}
+	}
+
+	data := struct {
+		Service Service
+		Refs    []references.Reference
+		Targets []Service
+		Error   string
+		CSRF    template.HTML
+	}{
+		Service: service,
+		Refs:    refs,
+		Targets: others,
+		Error:   errMsg,
+		CSRF:    csrf.Field(r),
+	}
+
+	templates.ExecuteTemplate(w, "delete.html", data)
+}
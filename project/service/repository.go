@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"shared/audit"
+	"shared/events"
+	"shared/store"
+	"shared/trash"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ServiceRepository keeps the services. Deleted services stay in the recycle
+// bin until they are restored or purged; only Deleted* and Restore and Purge
+// see them. A missing service is store.ErrNotFound.
+type ServiceRepository interface {
+	// List returns the live services
+	List(ctx context.Context) ([]Service, error)
+	GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Service, error)
+	Get(ctx context.Context, id primitive.ObjectID) (Service, error)
+	Insert(ctx context.Context, s Service) error
+	// Update saves the label and notes of a live service when it is still at
+	// s.Version, the version the edit is based on, and moves it to the next
+	// version. It returns store.ErrConflict when the service is at another
+	// version.
+	Update(ctx context.Context, s Service) error
+	// Delete moves a service to the recycle bin
+	Delete(ctx context.Context, id primitive.ObjectID, actor string) error
+	// ListDeleted returns the recycle bin, most recently deleted first
+	ListDeleted(ctx context.Context) ([]Service, error)
+	GetDeleted(ctx context.Context, id primitive.ObjectID) (Service, error)
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	Purge(ctx context.Context, id primitive.ObjectID) error
+	// PurgeExpired purges the services deleted more than retention ago
+	PurgeExpired(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// Store is where the service catalogue keeps its services and records their
+// changes: the audit trail and the domain events, which are saved in the
+// transaction of the change
+type Store struct {
+	Services ServiceRepository
+	Tx       store.Tx
+	Outbox   events.Outbox
+	Audit    audit.Store
+}
+
+// NewMongoStore returns the store of the service catalogue in db, with the
+// audit trail and the outbox in shared, the database shared by the services
+func NewMongoStore(db, shared *mongo.Database) *Store {
+	return &Store{
+		Services: mongoServices{db.Collection("services")},
+		Tx:       store.NewMongoTx(db.Client()),
+		Outbox:   events.NewOutbox(shared),
+		Audit:    audit.NewMongoStore(shared),
+	}
+}
+
+// mongoMigrations create the indexes of the services collection: the
+// recycle bin and the labels they are looked up and sorted by
+var mongoMigrations = []store.MongoMigration{
+	{Version: 1, Name: "services indexes", Up: store.Indexes("services",
+		mongo.IndexModel{Keys: store.Keys("deleted_at")},
+		mongo.IndexModel{Keys: store.Keys("label")},
+	)},
+}
+
+// MigrateMongo creates or updates the indexes of the service catalogue in db
+func MigrateMongo(ctx context.Context, db *mongo.Database) error {
+	return store.MigrateMongo(ctx, db, "service", mongoMigrations)
+}
+
+// NewMemoryStore returns a store keeping everything in memory, for tests
+func NewMemoryStore() *Store {
+	return &Store{
+		Services: memoryServices{store.NewTable(func(s *Service) (*primitive.ObjectID, **time.Time, *string) {
+			return &s.ID, &s.DeletedAt, &s.DeletedBy
+		})},
+		Tx:     store.MemoryTx{},
+		Outbox: &events.MemoryOutbox{},
+		Audit:  &audit.MemoryStore{},
+	}
+}
+
+// mongoServices is the ServiceRepository of the services collection
+type mongoServices struct {
+	coll *mongo.Collection
+}
+
+func (m mongoServices) List(ctx context.Context) ([]Service, error) {
+	return m.find(ctx, trash.Live(nil))
+}
+
+func (m mongoServices) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Service, error) {
+	return m.find(ctx, trash.Live(bson.M{"_id": bson.M{"$in": ids}}))
+}
+
+func (m mongoServices) find(ctx context.Context, filter bson.M) ([]Service, error) {
+	services := []Service{}
+	cur, err := m.coll.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.All(ctx, &services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+func (m mongoServices) Get(ctx context.Context, id primitive.ObjectID) (Service, error) {
+	var s Service
+	err := m.coll.FindOne(ctx, trash.Live(bson.M{"_id": id})).Decode(&s)
+	return s, store.MongoErr(err)
+}
+
+func (m mongoServices) Insert(ctx context.Context, s Service) error {
+	_, err := m.coll.InsertOne(ctx, s)
+	return err
+}
+
+func (m mongoServices) Update(ctx context.Context, s Service) error {
+	return store.UpdateMongoVersion(ctx, m.coll, trash.Live(bson.M{"_id": s.ID}), s.Version,
+		bson.M{"label": s.Label, "notes": s.Notes})
+}
+
+func (m mongoServices) Delete(ctx context.Context, id primitive.ObjectID, actor string) error {
+	return store.MongoErr(trash.Delete(ctx, m.coll, id, actor))
+}
+
+func (m mongoServices) ListDeleted(ctx context.Context) ([]Service, error) {
+	services := []Service{}
+	err := trash.List(ctx, m.coll, &services)
+	return services, err
+}
+
+func (m mongoServices) GetDeleted(ctx context.Context, id primitive.ObjectID) (Service, error) {
+	var s Service
+	err := m.coll.FindOne(ctx, trash.Deleted(bson.M{"_id": id})).Decode(&s)
+	return s, store.MongoErr(err)
+}
+
+func (m mongoServices) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return store.MongoErr(trash.Restore(ctx, m.coll, id))
+}
+
+func (m mongoServices) Purge(ctx context.Context, id primitive.ObjectID) error {
+	return store.MongoErr(trash.Purge(ctx, m.coll, id))
+}
+
+func (m mongoServices) PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	return trash.PurgeExpired(ctx, m.coll, retention)
+}
@@ -0,0 +1,83 @@
+// Package service is the service catalogue: the pages to manage services and
+// the /services API the maintenance service reads them from.
+package service
+
+import (
+	"context"
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"shared/audit"
+	"shared/config"
+	"shared/csrf"
+	"shared/metrics"
+	"shared/references"
+	"shared/store"
+	"shared/trash"
+)
+
+var (
+	templates *template.Template
+	// repo is where the handlers keep the services; it is set up by routes
+	repo *Store
+)
+
+//go:embed templates style
+var assets embed.FS
+
+// Handler sets the service up on b, starts its background jobs until
+// ctx is done and returns its routes. Calls to the maintenance service go
+// through transport when it is set, and over the network otherwise.
+func Handler(ctx context.Context, conf *config.Config, b store.Backend, transport http.RoundTripper) (http.Handler, error) {
+	referenceClient = references.NewClient(conf.URL(config.Maintenance))
+	referenceClient.HTTP.Transport = metrics.Transport(config.Maintenance, transport)
+
+	if err := Migrate(ctx, conf, b); err != nil {
+		return nil, err
+	}
+	s := storeOn(conf, b)
+
+	// Purge services that have been in the recycle bin longer than the retention period
+	trash.StartPurger(ctx, trash.Retention(), map[string]trash.Purger{"services": s.Services})
+
+	return routes(s, conf.PublicURL(config.Maintenance))
+}
+
+// routes returns the routes of the service catalogue over s, behind the CSRF
+// check and counted by route in the metrics, linking to the maintenance
+// service at maintenanceURL
+func routes(s *Store, maintenanceURL string) (http.Handler, error) {
+	repo = s
+	auditLog = audit.NewLogger(s.Audit, "service")
+
+	var err error
+	templates, err = template.New("").Funcs(template.FuncMap{
+		"maintenanceURL": func() string { return maintenanceURL },
+	}).ParseFS(assets, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	style, err := fs.Sub(assets, "style")
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/style/", http.StripPrefix("/style/", http.FileServer(http.FS(style))))
+
+	// Service routes
+	mux.HandleFunc("/service", serviceListHandler)
+	mux.HandleFunc("/service/create", serviceCreateHandler)
+	mux.HandleFunc("/service/edit", serviceEditHandler)
+	mux.HandleFunc("/service/delete", serviceDeleteHandler)
+	mux.HandleFunc("/service/trash", serviceTrashHandler)
+	mux.HandleFunc("/service/restore", serviceRestoreHandler)
+	mux.HandleFunc("/service/purge", servicePurgeHandler)
+
+	// API routes for other microservices
+	mux.HandleFunc("/services", serviceAPIHandler)
+
+	return metrics.Instrument(config.Service, metrics.ServeMuxRoute(mux), csrf.Protect(mux)), nil
+}
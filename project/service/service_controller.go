@@ -1,63 +1,98 @@
-package main
+package service
 
 import (
+	"cmms/project/rpc/cmmspb"
 	"context"
-	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
 	"net/http"
+	"shared/audit"
+	"shared/conflict"
+	"shared/csrf"
+	"shared/flash"
+	"shared/jsonapi"
+	"shared/references"
+	"shared/store"
+	"shared/validate"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// servicePage is the data of service.html: the services, the flash message
+// left by the last change and, when a form did not validate, its input and
+// errors
+type servicePage struct {
+	Services []Service
+	Form     *validate.Form
+	Flash    flash.Message
+	CSRF     template.HTML
+}
+
 // List Services
 func serviceListHandler(w http.ResponseWriter, r *http.Request) {
-	cur, err := serviceCollection.Find(context.Background(), bson.M{})
+	services, err := repo.Services.List(context.Background())
 	if err != nil {
 		http.Error(w, "Failed to retrieve services", http.StatusInternalServerError)
 		return
 	}
-	var services []Service
-	cur.All(context.Background(), &services)
-
-	data := struct {
-		Services []Service
-		Error    string
-	}{
-		Services: services,
-		Error:    "",
+
+	page := servicePage{Services: services, CSRF: csrf.Field(r)}
+	page.Flash, _ = flash.Get(w, r)
+	templates.ExecuteTemplate(w, "service.html", page)
+}
+
+// serviceForm checks the input of the create and edit forms, named name
+func serviceForm(r *http.Request, name string) (*validate.Form, error) {
+	f, err := validate.Parse(r, name)
+	if err != nil {
+		return nil, err
 	}
+	f.Required("label")
+	f.MaxLength("label", 200)
+	f.MaxLength("notes", 2000)
+	return f, nil
+}
 
-	templates.ExecuteTemplate(w, "service.html", data)
+// showForm answers a form that did not validate: the page again with the
+// form open, its input kept and the errors next to the fields, or the
+// errors as JSON for an API call
+func showForm(w http.ResponseWriter, r *http.Request, f *validate.Form) {
+	if validate.WantsJSON(r) {
+		validate.WriteErrors(w, f.Errors)
+		return
+	}
+	services, _ := repo.Services.List(context.Background())
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	templates.ExecuteTemplate(w, "service.html", servicePage{Services: services, Form: f, CSRF: csrf.Field(r)})
 }
 
 // Create Service
 func serviceCreateHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodPost {
-		label := r.FormValue("label")
-		notes := r.FormValue("notes")
-
-		if label == "" {
-			cur, _ := serviceCollection.Find(context.Background(), bson.M{})
-			var services []Service
-			cur.All(context.Background(), &services)
-
-			data := struct {
-				Services []Service
-				Error    string
-			}{
-				Services: services,
-				Error:    "Label is required!",
-			}
-			templates.ExecuteTemplate(w, "service.html", data)
+		f, err := serviceForm(r, "create")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !f.Valid() {
+			showForm(w, r, f)
 			return
 		}
 
-		serviceCollection.InsertOne(context.Background(), Service{
+		doc := Service{
 			ID:    primitive.NewObjectID(),
-			Label: label,
-			Notes: notes,
-		})
-		http.Redirect(w, r, "/service", http.StatusSeeOther)
+			Label: f.Get("label"),
+			Notes: f.Get("notes"),
+		}
+		if err := repo.Services.Insert(context.Background(), doc); err != nil {
+			log.Printf("error creating service: %v", err)
+			flash.Redirect(w, r, "/service", flash.Error, "Failed to create service")
+			return
+		}
+		recordAudit(r, audit.ActionCreate, doc.ID, doc.Label, nil, doc)
+		publishChange(cmmspb.ChangeType_CHANGE_TYPE_CREATED, doc)
+		flash.Redirect(w, r, "/service", flash.Success, "Service created successfully")
 	}
 }
 
@@ -69,41 +104,112 @@ func serviceEditHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if r.Method == http.MethodPost {
-		label := r.FormValue("label")
-		notes := r.FormValue("notes")
-		if label == "" {
-			data := struct{ Error string }{Error: "Label is required!"}
-			templates.ExecuteTemplate(w, "service.html", data)
+		f, err := serviceForm(r, id.Hex())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		serviceCollection.UpdateOne(context.Background(),
-			bson.M{"_id": id},
-			bson.M{"$set": bson.M{"label": label, "notes": notes}},
-		)
-		http.Redirect(w, r, "/service", http.StatusSeeOther)
+		version := f.Version(r)
+		if !f.Valid() {
+			showForm(w, r, f)
+			return
+		}
+		before, _ := repo.Services.Get(context.Background(), id)
+		after := Service{ID: id, Label: f.Get("label"), Notes: f.Get("notes"), Version: version}
+		err = repo.Services.Update(context.Background(), after)
+		if errors.Is(err, store.ErrConflict) {
+			showConflict(w, r, after, before)
+			return
+		}
+		if err != nil {
+			log.Printf("error updating service %s: %v", id.Hex(), err)
+			flash.Redirect(w, r, "/service", flash.Error, "Failed to update service")
+			return
+		}
+		after.Version++
+		recordAudit(r, audit.ActionUpdate, id, after.Label, before, after)
+		publishChange(cmmspb.ChangeType_CHANGE_TYPE_UPDATED, after)
+		w.Header().Set("ETag", jsonapi.ETag(after.Version))
+		flash.Redirect(w, r, "/service", flash.Success, "Service updated successfully")
+	}
+}
+
+// showConflict answers an edit, mine, rejected because someone else saved
+// the service since: the conflict page showing both versions, or the current
+// one as JSON for an API call
+func showConflict(w http.ResponseWriter, r *http.Request, mine, current Service) {
+	page := conflict.NewPage("Service "+current.Label, "/service", "/service/edit?id="+current.ID.Hex(), r.PostForm, current.Version,
+		conflict.Field{Label: "Label", Mine: mine.Label, Current: current.Label},
+		conflict.Field{Label: "Notes", Mine: mine.Notes, Current: current.Notes},
+	)
+	err := conflict.Write(w, r, page, current, current.Version, func(w http.ResponseWriter, p conflict.Page) error {
+		return templates.ExecuteTemplate(w, "conflict.html", p)
+	})
+	if err != nil {
+		log.Printf("error rendering the conflict page: %v", err)
 	}
 }
 
 // Delete Service
 func serviceDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 	id, err := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
 	if err != nil {
 		http.Error(w, "Invalid ID", http.StatusBadRequest)
 		return
 	}
-	serviceCollection.DeleteOne(context.Background(), bson.M{"_id": id})
-	http.Redirect(w, r, "/service", http.StatusSeeOther)
+	before, _ := repo.Services.Get(context.Background(), id)
+
+	// Schedules using the service must drop it or switch to another service first
+	refs, err := referenceClient.Find(r.Context(), references.KindService, id)
+	if err != nil {
+		http.Error(w, "Could not check the schedules using the service: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	if len(refs) > 0 {
+		action := r.FormValue("action")
+		if action == "" {
+			serviceDeleteConfirm(w, r, before, refs, "")
+			return
+		}
+		if err := resolveServiceReferences(r, id, action); err != nil {
+			serviceDeleteConfirm(w, r, before, refs, err.Error())
+			return
+		}
+	}
+
+	if err := deleteWithEvent(r, id, before); err != nil {
+		log.Printf("error deleting service %s: %v", id.Hex(), err)
+		flash.Redirect(w, r, "/service", flash.Error, "Failed to delete service")
+		return
+	}
+	recordAudit(r, audit.ActionDelete, id, before.Label, before, nil)
+	publishChange(cmmspb.ChangeType_CHANGE_TYPE_DELETED, before)
+	flash.Redirect(w, r, "/service", flash.Success, "Service moved to the recycle bin")
 }
 
+// API handler for other microservices to fetch services, all of them or
+// those listed in ?ids=
 func serviceAPIHandler(w http.ResponseWriter, r *http.Request) {
-	cur, err := serviceCollection.Find(context.Background(), bson.M{})
+	ids, ok, err := jsonapi.IDs(r)
 	if err != nil {
-		http.Error(w, "Failed to retrieve services", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+
 	var services []Service
-	cur.All(context.Background(), &services)
+	if ok {
+		services, err = repo.Services.GetByIDs(context.Background(), ids)
+	} else {
+		services, err = repo.Services.List(context.Background())
+	}
+	if err != nil {
+		http.Error(w, "Failed to retrieve services", http.StatusInternalServerError)
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(services)
+	jsonapi.WriteJSON(w, r, services)
 }
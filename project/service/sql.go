@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"shared/audit"
+	"shared/config"
+	"shared/events"
+	"shared/store"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// migrations create the tables of the service catalogue in a SQL database
+var migrations = []store.Migration{
+	{Version: 1, Name: "create services", Statements: []string{
+		`CREATE TABLE services (
+			id TEXT PRIMARY KEY,
+			doc TEXT NOT NULL,
+			deleted_at BIGINT,
+			deleted_by TEXT NOT NULL DEFAULT ''
+		)`,
+		"CREATE INDEX services_deleted_at ON services (deleted_at)",
+	}},
+}
+
+// MigrateSQL creates or updates the tables of the service catalogue in db
+func MigrateSQL(ctx context.Context, db *store.SQL) error {
+	return db.Migrate(ctx, "service", migrations)
+}
+
+// NewSQLStore returns the store of the service catalogue in db, which keeps
+// the audit trail and the outbox too
+func NewSQLStore(db *store.SQL) *Store {
+	return &Store{
+		Services: sqlServices{servicesTable(db)},
+		Tx:       db,
+		Outbox:   events.NewSQLOutbox(db),
+		Audit:    audit.NewSQLStore(db),
+	}
+}
+
+func servicesTable(db *store.SQL) *store.SQLTable[Service] {
+	return store.NewSQLTable(db, "services", func(s *Service) (*primitive.ObjectID, **time.Time, *string) {
+		return &s.ID, &s.DeletedAt, &s.DeletedBy
+	})
+}
+
+// Migrate creates or updates the tables or indexes of the service catalogue
+// on b
+func Migrate(ctx context.Context, conf *config.Config, b store.Backend) error {
+	if b.SQL != nil {
+		return MigrateSQL(ctx, b.SQL)
+	}
+	return MigrateMongo(ctx, b.Mongo.Database(conf.Database(config.Service)))
+}
+
+// storeOn returns the store of the service catalogue on b; its tables or
+// indexes are created by Migrate
+func storeOn(conf *config.Config, b store.Backend) *Store {
+	if b.SQL != nil {
+		return NewSQLStore(b.SQL)
+	}
+	return NewMongoStore(b.Mongo.Database(conf.Database(config.Service)), b.Mongo.Database(conf.Mongo.Database))
+}
+
+// CopyToSQL copies the services kept in MongoDB by client into to and returns
+// how many it copied
+func CopyToSQL(ctx context.Context, conf *config.Config, client *mongo.Client, to *store.SQL) (int, error) {
+	if err := MigrateSQL(ctx, to); err != nil {
+		return 0, err
+	}
+	db := client.Database(conf.Database(config.Service))
+	return store.CopyCollection(ctx, db.Collection("services"), servicesTable(to))
+}
+
+// sqlServices is the ServiceRepository of the services table
+type sqlServices struct {
+	table *store.SQLTable[Service]
+}
+
+func (m sqlServices) List(ctx context.Context) ([]Service, error) {
+	return m.table.Select(ctx, "")
+}
+
+func (m sqlServices) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]Service, error) {
+	return m.table.Select(ctx, store.In("id", len(ids)), store.Hexes(ids)...)
+}
+
+func (m sqlServices) Get(ctx context.Context, id primitive.ObjectID) (Service, error) {
+	return m.table.Get(ctx, id)
+}
+
+func (m sqlServices) Insert(ctx context.Context, s Service) error {
+	return m.table.Insert(ctx, s)
+}
+
+func (m sqlServices) Update(ctx context.Context, s Service) error {
+	_, err := m.table.UpdateVersion(ctx, s.ID, s.Version, serviceVersion, func(rec *Service) { rec.Label, rec.Notes = s.Label, s.Notes })
+	return err
+}
+
+func (m sqlServices) Delete(ctx context.Context, id primitive.ObjectID, actor string) error {
+	return m.table.Delete(ctx, id, actor)
+}
+
+func (m sqlServices) ListDeleted(ctx context.Context) ([]Service, error) {
+	return m.table.Deleted(ctx)
+}
+
+func (m sqlServices) GetDeleted(ctx context.Context, id primitive.ObjectID) (Service, error) {
+	return m.table.GetDeleted(ctx, id)
+}
+
+func (m sqlServices) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Restore(ctx, id)
+}
+
+func (m sqlServices) Purge(ctx context.Context, id primitive.ObjectID) error {
+	return m.table.Purge(ctx, id)
+}
+
+func (m sqlServices) PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	return m.table.PurgeExpired(ctx, retention)
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"cmms/project/rpc/cmmspb"
+	"context"
+	"html/template"
+	"log"
+	"net/http"
+	"shared/audit"
+	"shared/csrf"
+	"shared/flash"
+	"shared/trash"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Recycle bin of deleted services
+func serviceTrashHandler(w http.ResponseWriter, r *http.Request) {
+	services, err := repo.Services.ListDeleted(context.Background())
+	if err != nil {
+		http.Error(w, "Failed to retrieve deleted services", http.StatusInternalServerError)
+		return
+	}
+
+	type item struct {
+		Service
+		PurgeOn time.Time
+	}
+	retention := trash.Retention()
+	items := make([]item, 0, len(services))
+	for _, s := range services {
+		items = append(items, item{Service: s, PurgeOn: trash.PurgeDate(*s.DeletedAt, retention)})
+	}
+
+	data := struct {
+		Items         []item
+		RetentionDays int
+		Flash         flash.Message
+		CSRF          template.HTML
+	}{
+		Items:         items,
+		RetentionDays: int(retention.Hours() / 24),
+		CSRF:          csrf.Field(r),
+	}
+	data.Flash, _ = flash.Get(w, r)
+
+	templates.ExecuteTemplate(w, "trash.html", data)
+}
+
+// Restore service from the recycle bin
+func serviceRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	before, _ := repo.Services.GetDeleted(context.Background(), id)
+	if err := repo.Services.Restore(context.Background(), id); err != nil {
+		log.Printf("error restoring service %s: %v", id.Hex(), err)
+		flash.Redirect(w, r, "/service/trash", flash.Error, "Failed to restore service")
+		return
+	}
+	after := before
+	after.DeletedAt, after.DeletedBy = nil, ""
+	recordAudit(r, audit.ActionRestore, id, before.Label, before, after)
+	publishChange(cmmspb.ChangeType_CHANGE_TYPE_RESTORED, after)
+	flash.Redirect(w, r, "/service/trash", flash.Success, before.Label+" restored successfully")
+}
+
+// Permanently delete service from the recycle bin
+func servicePurgeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	before, _ := repo.Services.GetDeleted(context.Background(), id)
+	if err := repo.Services.Purge(context.Background(), id); err != nil {
+		log.Printf("error purging service %s: %v", id.Hex(), err)
+		flash.Redirect(w, r, "/service/trash", flash.Error, "Failed to purge service")
+		return
+	}
+	recordAudit(r, audit.ActionPurge, id, before.Label, before, nil)
+	flash.Redirect(w, r, "/service/trash", flash.Success, before.Label+" permanently deleted")
+}
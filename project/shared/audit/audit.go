@@ -0,0 +1,389 @@
+// Package audit records who created, changed or deleted what across the CMMS
+// services. Every service writes to the same audit_log collection in the
+// CMMS database so the trail can be searched in one place.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"shared/store"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Collection holding the audit trail
+const Collection = "audit_log"
+
+// Actions
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+// Actions are every action recorded, for the filters of the audit trail
+var Actions = []string{ActionCreate, ActionUpdate, ActionDelete, ActionRestore, ActionPurge}
+
+// Entry is one mutation of one entity
+type Entry struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Time     time.Time          `bson:"time"`
+	Actor    string             `bson:"actor"`
+	IP       string             `bson:"ip"`
+	Service  string             `bson:"service"`
+	Entity   string             `bson:"entity"`
+	EntityID string             `bson:"entity_id"`
+	Label    string             `bson:"label"`
+	Action   string             `bson:"action"`
+	Changes  []Change           `bson:"changes"`
+}
+
+// Change is the before and after value of a single field, formatted for
+// display; Before is empty on create and After is empty on delete
+type Change struct {
+	Field  string `bson:"field"`
+	Before string `bson:"before"`
+	After  string `bson:"after"`
+}
+
+// Store keeps the audit trail
+type Store interface {
+	Insert(ctx context.Context, e Entry) error
+	// Search returns matching entries, most recent first
+	Search(ctx context.Context, f Filter) ([]Entry, error)
+}
+
+// Logger writes entries on behalf of one service
+type Logger struct {
+	store   Store
+	service string
+}
+
+// NewLogger returns a logger writing to store, which should be the same for
+// every service
+func NewLogger(store Store, service string) *Logger {
+	return &Logger{
+		store:   store,
+		service: service,
+	}
+}
+
+// Record stores an audit entry for the request r. before is nil on create
+// and after is nil on delete; updates that change nothing are not recorded.
+// Errors are returned so callers can log them, but the mutation itself has
+// already happened and should not be failed because of the audit trail.
+func (l *Logger) Record(ctx context.Context, r *http.Request, action, entity string, id primitive.ObjectID, label string, before, after interface{}) error {
+	changes, err := Diff(before, after)
+	if err != nil {
+		return err
+	}
+	if action == ActionUpdate && len(changes) == 0 {
+		return nil
+	}
+
+	entry := Entry{
+		ID:       primitive.NewObjectID(),
+		Time:     time.Now(),
+		Actor:    Actor(r),
+		IP:       ClientIP(r),
+		Service:  l.service,
+		Entity:   entity,
+		EntityID: id.Hex(),
+		Label:    label,
+		Action:   action,
+		Changes:  changes,
+	}
+	return l.store.Insert(ctx, entry)
+}
+
+var (
+	trustedMu sync.RWMutex
+	// trusted are the proxies whose identity and forwarding headers are
+	// believed, set by SetTrustedProxies
+	trusted []*net.IPNet
+)
+
+// SetTrustedProxies sets the addresses of the gateway, proxies and services
+// whose X-Remote-User, X-Forwarded-User and X-Forwarded-For headers Actor
+// and ClientIP believe. Until it is called no one is trusted.
+func SetTrustedProxies(nets []*net.IPNet) {
+	trustedMu.Lock()
+	defer trustedMu.Unlock()
+	trusted = nets
+}
+
+// isTrusted reports whether the address addr, with or without a port, is
+// that of a trusted proxy
+func isTrusted(addr string) bool {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	trustedMu.RLock()
+	defer trustedMu.RUnlock()
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+type actorKey struct{}
+
+// WithActor returns ctx with the user a request the service makes for itself
+// acts for, such as the user behind a domain event, which Actor returns for
+// requests with that context
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor identifies the user behind a request. Authentication is expected to
+// happen in a proxy in front of the services, which passes the user name on
+// in the X-Remote-User header; the header is only believed from a trusted
+// proxy, any other request is anonymous.
+func Actor(r *http.Request) string {
+	if actor, ok := r.Context().Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	if !isTrusted(r.RemoteAddr) {
+		return "anonymous"
+	}
+	for _, h := range []string{"X-Remote-User", "X-Forwarded-User"} {
+		if v := strings.TrimSpace(r.Header.Get(h)); v != "" {
+			return v
+		}
+	}
+	if user, _, ok := r.BasicAuth(); ok && user != "" {
+		return user
+	}
+	return "anonymous"
+}
+
+// ClientIP returns the address of the client. Behind trusted proxies it is
+// the last address of X-Forwarded-For not of a trusted proxy, since a client
+// can put any address it likes at the front.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrusted(host) {
+		return host
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !isTrusted(hop) {
+			return hop
+		}
+		host = hop
+	}
+	return host
+}
+
+// Diff compares the BSON representation of two documents field by field
+func Diff(before, after interface{}) ([]Change, error) {
+	b, err := toMap(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := toMap(after)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]bool{}
+	for k := range b {
+		fields[k] = true
+	}
+	for k := range a {
+		fields[k] = true
+	}
+	delete(fields, "_id")
+
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	changes := []Change{}
+	for _, k := range names {
+		bv, bok := b[k]
+		av, aok := a[k]
+		c := Change{Field: k}
+		if bok {
+			c.Before = Format(bv)
+		}
+		if aok {
+			c.After = Format(av)
+		}
+		if c.Before != c.After {
+			changes = append(changes, c)
+		}
+	}
+	return changes, nil
+}
+
+func toMap(doc interface{}) (bson.M, error) {
+	if doc == nil {
+		return bson.M{}, nil
+	}
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var m bson.M
+	err = bson.Unmarshal(raw, &m)
+	return m, err
+}
+
+// Format renders a BSON value the way it is shown in the audit trail
+func Format(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case primitive.ObjectID:
+		return v.Hex()
+	case primitive.DateTime:
+		t := v.Time().UTC()
+		if t.Equal(t.Truncate(24 * time.Hour)) {
+			return t.Format("2006-01-02")
+		}
+		return t.Format(time.RFC3339)
+	case primitive.A:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = Format(e)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case bson.M:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = k + ": " + Format(v[k])
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Filter narrows Search; zero values match everything
+type Filter struct {
+	Service  string
+	Entity   string
+	EntityID string
+	Actor    string
+	Action   string
+	Text     string // matched against labels and changed values
+	From     time.Time
+	To       time.Time // exclusive
+	Limit    int64
+}
+
+// MongoStore is the audit collection of the shared database
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+// NewMongoStore returns the audit trail kept in db
+func NewMongoStore(db *mongo.Database) *MongoStore {
+	return &MongoStore{coll: db.Collection(Collection)}
+}
+
+// mongoMigrations create the indexes the audit page searches by
+var mongoMigrations = []store.MongoMigration{
+	{Version: 1, Name: "audit_log indexes", Up: store.Indexes(Collection,
+		mongo.IndexModel{Keys: bson.D{{Key: "time", Value: -1}}},
+		mongo.IndexModel{Keys: store.Keys("entity", "entity_id")},
+		mongo.IndexModel{Keys: store.Keys("service", "time")},
+	)},
+}
+
+// MigrateMongo creates or updates the indexes of the audit trail in db, the
+// shared database
+func MigrateMongo(ctx context.Context, db *mongo.Database) error {
+	return store.MigrateMongo(ctx, db, "audit", mongoMigrations)
+}
+
+// Insert adds an entry
+func (s *MongoStore) Insert(ctx context.Context, e Entry) error {
+	_, err := s.coll.InsertOne(ctx, e)
+	return err
+}
+
+// Search returns matching entries, most recent first
+func (s *MongoStore) Search(ctx context.Context, f Filter) ([]Entry, error) {
+	filter := bson.M{}
+	for field, value := range map[string]string{
+		"service":   f.Service,
+		"entity":    f.Entity,
+		"entity_id": f.EntityID,
+		"action":    f.Action,
+	} {
+		if value != "" {
+			filter[field] = value
+		}
+	}
+	if f.Actor != "" {
+		filter["actor"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(f.Actor), Options: "i"}
+	}
+	if f.Text != "" {
+		re := primitive.Regex{Pattern: regexp.QuoteMeta(f.Text), Options: "i"}
+		filter["$or"] = bson.A{
+			bson.M{"label": re},
+			bson.M{"changes.before": re},
+			bson.M{"changes.after": re},
+		}
+	}
+	if !f.From.IsZero() || !f.To.IsZero() {
+		window := bson.M{}
+		if !f.From.IsZero() {
+			window["$gte"] = f.From
+		}
+		if !f.To.IsZero() {
+			window["$lt"] = f.To
+		}
+		filter["time"] = window
+	}
+	entries := []Entry{}
+	cursor, err := s.coll.Find(ctx, filter,
+		options.Find().SetSort(bson.M{"time": -1}).SetLimit(f.limit()))
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &entries)
+	return entries, err
+}
+
+// limit returns the most entries Search returns, 200 by default
+func (f Filter) limit() int64 {
+	if f.Limit <= 0 {
+		return 200
+	}
+	return f.Limit
+}
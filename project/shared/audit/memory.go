@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryStore keeps the audit trail in memory, for tests
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// Insert adds an entry
+func (s *MemoryStore) Insert(ctx context.Context, e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+// Search returns matching entries, most recent first
+func (s *MemoryStore) Search(ctx context.Context, f Filter) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := []Entry{}
+	for _, e := range s.entries {
+		if f.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.After(entries[j].Time) })
+	if int64(len(entries)) > f.limit() {
+		entries = entries[:f.limit()]
+	}
+	return entries, nil
+}
+
+// matches reports whether the filter selects e, as the query of
+// MongoStore.Search does
+func (f Filter) matches(e Entry) bool {
+	for _, c := range [][2]string{
+		{f.Service, e.Service},
+		{f.Entity, e.Entity},
+		{f.EntityID, e.EntityID},
+		{f.Action, e.Action},
+	} {
+		if c[0] != "" && c[0] != c[1] {
+			return false
+		}
+	}
+	if f.Actor != "" && !strings.HasPrefix(strings.ToLower(e.Actor), strings.ToLower(f.Actor)) {
+		return false
+	}
+	if f.Text != "" {
+		text := strings.ToLower(f.Text)
+		found := strings.Contains(strings.ToLower(e.Label), text)
+		for _, c := range e.Changes {
+			found = found || strings.Contains(strings.ToLower(c.Before), text) || strings.Contains(strings.ToLower(c.After), text)
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.From.IsZero() && e.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !e.Time.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// Entries returns every entry, oldest first
+func (s *MemoryStore) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Entry(nil), s.entries...)
+}
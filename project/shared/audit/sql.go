@@ -0,0 +1,140 @@
+package audit
+
+import (
+	"context"
+	"shared/store"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// migrations create the audit_log table of the SQL storage drivers
+var migrations = []store.Migration{
+	{Version: 1, Name: "create audit_log", Statements: []string{
+		`CREATE TABLE audit_log (
+			id TEXT PRIMARY KEY,
+			doc TEXT NOT NULL,
+			time BIGINT NOT NULL,
+			service TEXT NOT NULL,
+			entity TEXT NOT NULL,
+			entity_id TEXT NOT NULL,
+			action TEXT NOT NULL
+		)`,
+		"CREATE INDEX audit_log_time ON audit_log (time)",
+		"CREATE INDEX audit_log_entity ON audit_log (entity, entity_id)",
+	}},
+}
+
+// MigrateSQL creates or updates the audit_log table in db
+func MigrateSQL(ctx context.Context, db *store.SQL) error {
+	return db.Migrate(ctx, "audit", migrations)
+}
+
+// SQLStore is the audit_log table of a SQL database
+type SQLStore struct {
+	db *store.SQL
+}
+
+// NewSQLStore returns the audit trail kept in db
+func NewSQLStore(db *store.SQL) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// Insert adds an entry, in the transaction of ctx if there is one
+func (s *SQLStore) Insert(ctx context.Context, e Entry) error {
+	doc, err := store.MarshalDoc(e)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(ctx,
+		"INSERT INTO audit_log (id, doc, time, service, entity, entity_id, action) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		e.ID.Hex(), doc, store.Millis(e.Time), e.Service, e.Entity, e.EntityID, e.Action)
+	return err
+}
+
+// Search returns matching entries, most recent first. The exact fields and
+// the period are matched by the query, the actor and text as the in-memory
+// store does.
+func (s *SQLStore) Search(ctx context.Context, f Filter) ([]Entry, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	for column, value := range map[string]string{
+		"service":   f.Service,
+		"entity":    f.Entity,
+		"entity_id": f.EntityID,
+		"action":    f.Action,
+	} {
+		if value != "" {
+			where = append(where, column+" = ?")
+			args = append(args, value)
+		}
+	}
+	if !f.From.IsZero() {
+		where = append(where, "time >= ?")
+		args = append(args, store.Millis(f.From))
+	}
+	if !f.To.IsZero() {
+		where = append(where, "time < ?")
+		args = append(args, store.Millis(f.To))
+	}
+	query := "SELECT doc FROM audit_log"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	rows, err := s.db.Query(ctx, query+" ORDER BY time DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() && int64(len(entries)) < f.limit() {
+		var doc string
+		if err := rows.Scan(&doc); err != nil {
+			return nil, err
+		}
+		var e Entry
+		if err := store.UnmarshalDoc(doc, &e); err != nil {
+			return nil, err
+		}
+		if f.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, rows.Err()
+}
+
+// CopyToSQL copies the audit trail of db, the shared MongoDB database, into
+// to and returns how many entries it copied; entries copied before are
+// skipped
+func CopyToSQL(ctx context.Context, db *mongo.Database, to *store.SQL) (int, error) {
+	cur, err := db.Collection(Collection).Find(ctx, map[string]interface{}{})
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	dst := NewSQLStore(to)
+	n := 0
+	for cur.Next(ctx) {
+		var e Entry
+		if err := cur.Decode(&e); err != nil {
+			return n, err
+		}
+		var exists int
+		if err := to.QueryRow(ctx, "SELECT COUNT(*) FROM audit_log WHERE id = ?", e.ID.Hex()).Scan(&exists); err != nil {
+			return n, err
+		}
+		if exists > 0 {
+			continue
+		}
+		if err := dst.Insert(ctx, e); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, cur.Err()
+}
@@ -0,0 +1,711 @@
+// Package config loads the settings shared by every CMMS service: where
+// MongoDB is, which database to use, whether the data is kept in a SQL
+// database instead, where each service listens and can be reached, how
+// domain events travel between them, how notifications are mailed and how
+// long the recycle bins keep deleted records.
+//
+// Settings start from the defaults below (a local development setup), are
+// overridden by a YAML file and then by environment variables. The file is
+// the one named by CMMS_CONFIG, or cmms.yaml in the working directory when
+// that exists. See cmms.example.yaml at the root of the repository.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Names of the services
+const (
+	Asset       = "asset"
+	Service     = "service"
+	Consumable  = "consumable"
+	Maintenance = "maintenance"
+	Monolith    = "monolith"
+	Gateway     = "gateway"
+)
+
+// DefaultFile is read when CMMS_CONFIG is not set, if it exists
+const DefaultFile = "cmms.yaml"
+
+// Config holds the settings of all services
+type Config struct {
+	Mongo    Mongo                    `yaml:"mongo"`
+	Storage  StorageConfig            `yaml:"storage"`
+	Services map[string]ServiceConfig `yaml:"services"`
+	Gateway  GatewayConfig            `yaml:"gateway"`
+	Events   EventsConfig             `yaml:"events"`
+	Session  SessionConfig            `yaml:"session"`
+	Proxy    ProxyConfig              `yaml:"proxy"`
+	Notify   NotifyConfig             `yaml:"notify"`
+	Trash    TrashConfig              `yaml:"trash"`
+}
+
+// Mongo is the database connection shared by the services
+type Mongo struct {
+	URI            string        `yaml:"uri"`
+	Database       string        `yaml:"database"`
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+}
+
+// Storage drivers
+const (
+	StorageMongo    = "mongo"
+	StorageSQLite   = "sqlite"
+	StoragePostgres = "postgres"
+)
+
+// StorageConfig is the database the services keep their data in
+type StorageConfig struct {
+	// Driver is mongo, sqlite or postgres; mongo when empty
+	Driver string `yaml:"driver,omitempty"`
+	// DSN is the database of the sqlite (a file name) and postgres (a
+	// connection URL) drivers
+	DSN string `yaml:"dsn,omitempty"`
+}
+
+// ServiceConfig is where one service listens and is reached
+type ServiceConfig struct {
+	// Addr is the address the service listens on, e.g. localhost:8080
+	Addr string `yaml:"addr"`
+	// URL is the base URL other services use to reach it
+	URL string `yaml:"url"`
+	// PublicURL is the base URL pages link to, e.g. the gateway; URL when empty
+	PublicURL string `yaml:"public_url,omitempty"`
+	// Database overrides Mongo.Database for this service
+	Database string `yaml:"database,omitempty"`
+	// GRPCAddr is the address the gRPC server of the asset, service and
+	// consumable services listens on
+	GRPCAddr string `yaml:"grpc_addr,omitempty"`
+	// GRPCTarget is how other services dial it; GRPCAddr when empty
+	GRPCTarget string `yaml:"grpc_target,omitempty"`
+}
+
+// GatewayConfig holds the settings of the gateway only
+type GatewayConfig struct {
+	// StyleDir is the directory of the stylesheets served under /style/
+	StyleDir string `yaml:"style_dir"`
+	// UsersFile is an htpasswd file of user:bcrypt-hash lines; when set every
+	// request needs a matching Basic login
+	UsersFile string `yaml:"users_file,omitempty"`
+}
+
+// Event transports
+const (
+	EventsInProcess = "inprocess"
+	EventsMongo     = "mongo"
+	EventsNATS      = "nats"
+)
+
+// EventsConfig is how domain events travel between the services
+type EventsConfig struct {
+	// Transport is inprocess, mongo or nats; when empty inprocess for every
+	// service in one process and mongo otherwise
+	Transport string `yaml:"transport,omitempty"`
+	// NATSURL is the server of the nats transport
+	NATSURL string `yaml:"nats_url,omitempty"`
+}
+
+// SessionConfig holds what the services sign the cookies they hand to
+// browsers with
+type SessionConfig struct {
+	// Secret is the signing key. Every process serving the pages of a
+	// service needs the same one; when empty each process picks a random key
+	// on start, which is enough for a single process.
+	Secret string `yaml:"secret,omitempty"`
+}
+
+// ProxyConfig is who the services believe about the user and address behind
+// a request
+type ProxyConfig struct {
+	// Trusted are the addresses or CIDR ranges of the gateway, proxies and
+	// services whose X-Remote-User, X-Forwarded-User and X-Forwarded-For
+	// headers are believed; from any other client they are ignored
+	Trusted []string `yaml:"trusted,omitempty"`
+}
+
+// NotifyConfig holds the email notifications of the maintenance service
+type NotifyConfig struct {
+	// Enabled turns the notifier on; it then needs an SMTP host
+	Enabled bool       `yaml:"enabled"`
+	SMTP    SMTPConfig `yaml:"smtp"`
+	// BaseURL is what the links in the mail point to; the public URL of the
+	// maintenance service when empty
+	BaseURL string `yaml:"base_url,omitempty"`
+	// DaysAhead is the horizon of subscriptions that do not set their own
+	DaysAhead int `yaml:"days_ahead"`
+	// Interval is how often the notifier runs
+	Interval time.Duration `yaml:"interval"`
+}
+
+// SMTPConfig is the mail server the notifications are sent through
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	From     string `yaml:"from"`
+}
+
+// TrashConfig holds the recycle bins of the services
+type TrashConfig struct {
+	// RetentionDays is how long deleted records are kept before they are
+	// purged automatically
+	RetentionDays int `yaml:"retention_days"`
+}
+
+// Default returns the settings of a local development setup
+func Default() *Config {
+	return &Config{
+		Mongo: Mongo{
+			URI:            "mongodb://localhost:27017",
+			Database:       "CMMS",
+			ConnectTimeout: 10 * time.Second,
+		},
+		Services: map[string]ServiceConfig{
+			Asset:       {Addr: "localhost:5500", URL: "http://localhost:5500", GRPCAddr: "localhost:5501"},
+			Service:     {Addr: "localhost:8081", URL: "http://localhost:8081", GRPCAddr: "localhost:8091"},
+			Consumable:  {Addr: "localhost:8082", URL: "http://localhost:8082", GRPCAddr: "localhost:8092"},
+			Maintenance: {Addr: "localhost:8080", URL: "http://localhost:8080"},
+			Monolith:    {Addr: "localhost:8080", URL: "http://localhost:8080"},
+			Gateway:     {Addr: "localhost:8000", URL: "http://localhost:8000"},
+		},
+		Gateway: GatewayConfig{StyleDir: "style"},
+		Events:  EventsConfig{NATSURL: "nats://localhost:4222"},
+		Proxy:   ProxyConfig{Trusted: []string{"127.0.0.0/8", "::1/128"}},
+		// Off until a mail server is configured
+		Notify: NotifyConfig{
+			SMTP:      SMTPConfig{Port: 25, From: "cmms@localhost"},
+			DaysAhead: 7,
+			Interval:  time.Hour,
+		},
+		Trash: TrashConfig{RetentionDays: 30},
+	}
+}
+
+// Load returns the defaults overridden by the config file and the
+// environment, and fails when the result is invalid
+func Load() (*Config, error) {
+	cfg := Default()
+
+	path, required := os.Getenv("CMMS_CONFIG"), true
+	if path == "" {
+		path, required = DefaultFile, false
+	}
+	if err := cfg.loadFile(path); err != nil {
+		if required || !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile overrides cfg with the settings present in the YAML file at path
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var file Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	if file.Mongo.URI != "" {
+		c.Mongo.URI = file.Mongo.URI
+	}
+	if file.Mongo.Database != "" {
+		c.Mongo.Database = file.Mongo.Database
+	}
+	if file.Mongo.ConnectTimeout != 0 {
+		c.Mongo.ConnectTimeout = file.Mongo.ConnectTimeout
+	}
+	if file.Storage.Driver != "" {
+		c.Storage.Driver = file.Storage.Driver
+	}
+	if file.Storage.DSN != "" {
+		c.Storage.DSN = file.Storage.DSN
+	}
+	for name, s := range file.Services {
+		current, ok := c.Services[name]
+		if !ok {
+			return fmt.Errorf("config file %s: unknown service %q", path, name)
+		}
+		if s.Addr != "" {
+			current.Addr = s.Addr
+		}
+		if s.URL != "" {
+			current.URL = s.URL
+		}
+		if s.PublicURL != "" {
+			current.PublicURL = s.PublicURL
+		}
+		if s.Database != "" {
+			current.Database = s.Database
+		}
+		if s.GRPCAddr != "" {
+			current.GRPCAddr = s.GRPCAddr
+		}
+		if s.GRPCTarget != "" {
+			current.GRPCTarget = s.GRPCTarget
+		}
+		c.Services[name] = current
+	}
+	if file.Gateway.StyleDir != "" {
+		c.Gateway.StyleDir = file.Gateway.StyleDir
+	}
+	if file.Gateway.UsersFile != "" {
+		c.Gateway.UsersFile = file.Gateway.UsersFile
+	}
+	if file.Events.Transport != "" {
+		c.Events.Transport = file.Events.Transport
+	}
+	if file.Events.NATSURL != "" {
+		c.Events.NATSURL = file.Events.NATSURL
+	}
+	if file.Session.Secret != "" {
+		c.Session.Secret = file.Session.Secret
+	}
+	if file.Proxy.Trusted != nil {
+		c.Proxy.Trusted = file.Proxy.Trusted
+	}
+	if file.Notify.Enabled {
+		c.Notify.Enabled = true
+	}
+	if file.Notify.SMTP.Host != "" {
+		c.Notify.SMTP.Host = file.Notify.SMTP.Host
+	}
+	if file.Notify.SMTP.Port != 0 {
+		c.Notify.SMTP.Port = file.Notify.SMTP.Port
+	}
+	if file.Notify.SMTP.Username != "" {
+		c.Notify.SMTP.Username = file.Notify.SMTP.Username
+	}
+	if file.Notify.SMTP.Password != "" {
+		c.Notify.SMTP.Password = file.Notify.SMTP.Password
+	}
+	if file.Notify.SMTP.From != "" {
+		c.Notify.SMTP.From = file.Notify.SMTP.From
+	}
+	if file.Notify.BaseURL != "" {
+		c.Notify.BaseURL = file.Notify.BaseURL
+	}
+	if file.Notify.DaysAhead != 0 {
+		c.Notify.DaysAhead = file.Notify.DaysAhead
+	}
+	if file.Notify.Interval != 0 {
+		c.Notify.Interval = file.Notify.Interval
+	}
+	if file.Trash.RetentionDays != 0 {
+		c.Trash.RetentionDays = file.Trash.RetentionDays
+	}
+	return nil
+}
+
+// loadEnv overrides cfg with CMMS_MONGO_URI (or MONGO_URI),
+// CMMS_MONGO_DATABASE, CMMS_MONGO_CONNECT_TIMEOUT, CMMS_STORAGE_DRIVER,
+// CMMS_STORAGE_DSN, for every service CMMS_<NAME>_ADDR, CMMS_<NAME>_URL,
+// CMMS_<NAME>_PUBLIC_URL, CMMS_<NAME>_DATABASE, CMMS_<NAME>_GRPC_ADDR and
+// CMMS_<NAME>_GRPC_TARGET, CMMS_GATEWAY_STYLE_DIR, CMMS_GATEWAY_USERS_FILE,
+// CMMS_EVENTS_TRANSPORT, CMMS_EVENTS_NATS_URL, CMMS_SESSION_SECRET,
+// CMMS_PROXY_TRUSTED (a comma-separated list), CMMS_SMTP_HOST,
+// CMMS_SMTP_PORT, CMMS_SMTP_USERNAME, CMMS_SMTP_PASSWORD, CMMS_SMTP_FROM,
+// CMMS_NOTIFY_BASE_URL, CMMS_NOTIFY_DAYS_AHEAD, CMMS_NOTIFY_INTERVAL,
+// CMMS_NOTIFY_ENABLED and CMMS_TRASH_RETENTION_DAYS. The names these had
+// before, such as SMTP_HOST, NOTIFY_INTERVAL and TRASH_RETENTION_DAYS, are
+// still read.
+func (c *Config) loadEnv() error {
+	if v := os.Getenv("MONGO_URI"); v != "" {
+		c.Mongo.URI = v
+	}
+	if v := os.Getenv("CMMS_MONGO_URI"); v != "" {
+		c.Mongo.URI = v
+	}
+	if v := os.Getenv("CMMS_MONGO_DATABASE"); v != "" {
+		c.Mongo.Database = v
+	}
+	if v := os.Getenv("CMMS_MONGO_CONNECT_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			if secs, serr := strconv.Atoi(v); serr == nil {
+				d, err = time.Duration(secs)*time.Second, nil
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("CMMS_MONGO_CONNECT_TIMEOUT: %w", err)
+		}
+		c.Mongo.ConnectTimeout = d
+	}
+	if v := os.Getenv("CMMS_STORAGE_DRIVER"); v != "" {
+		c.Storage.Driver = v
+	}
+	if v := os.Getenv("CMMS_STORAGE_DSN"); v != "" {
+		c.Storage.DSN = v
+	}
+
+	for name, s := range c.Services {
+		prefix := "CMMS_" + strings.ToUpper(name) + "_"
+		if v := os.Getenv(prefix + "ADDR"); v != "" {
+			s.Addr = v
+		}
+		if v := os.Getenv(prefix + "URL"); v != "" {
+			s.URL = v
+		}
+		if v := os.Getenv(prefix + "PUBLIC_URL"); v != "" {
+			s.PublicURL = v
+		}
+		if v := os.Getenv(prefix + "DATABASE"); v != "" {
+			s.Database = v
+		}
+		if v := os.Getenv(prefix + "GRPC_ADDR"); v != "" {
+			s.GRPCAddr = v
+		}
+		if v := os.Getenv(prefix + "GRPC_TARGET"); v != "" {
+			s.GRPCTarget = v
+		}
+		c.Services[name] = s
+	}
+
+	if v := os.Getenv("CMMS_GATEWAY_STYLE_DIR"); v != "" {
+		c.Gateway.StyleDir = v
+	}
+	if v := os.Getenv("CMMS_GATEWAY_USERS_FILE"); v != "" {
+		c.Gateway.UsersFile = v
+	}
+	if v := os.Getenv("CMMS_EVENTS_TRANSPORT"); v != "" {
+		c.Events.Transport = v
+	}
+	if v := os.Getenv("CMMS_EVENTS_NATS_URL"); v != "" {
+		c.Events.NATSURL = v
+	}
+	if v := os.Getenv("CMMS_SESSION_SECRET"); v != "" {
+		c.Session.Secret = v
+	}
+	var errs []error
+	str := func(dst *string, names ...string) {
+		if v := lookup(names...); v != "" {
+			*dst = v
+		}
+	}
+	num := func(dst *int, names ...string) {
+		if v := lookup(names...); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s %q is not a number", names[len(names)-1], v))
+				return
+			}
+			*dst = n
+		}
+	}
+	str(&c.Notify.SMTP.Host, "SMTP_HOST", "CMMS_SMTP_HOST")
+	num(&c.Notify.SMTP.Port, "SMTP_PORT", "CMMS_SMTP_PORT")
+	str(&c.Notify.SMTP.Username, "SMTP_USERNAME", "CMMS_SMTP_USERNAME")
+	str(&c.Notify.SMTP.Password, "SMTP_PASSWORD", "CMMS_SMTP_PASSWORD")
+	str(&c.Notify.SMTP.From, "SMTP_FROM", "CMMS_SMTP_FROM")
+	str(&c.Notify.BaseURL, "CMMS_BASE_URL", "CMMS_NOTIFY_BASE_URL")
+	num(&c.Notify.DaysAhead, "NOTIFY_DAYS_AHEAD", "CMMS_NOTIFY_DAYS_AHEAD")
+	if v := lookup("NOTIFY_INTERVAL", "CMMS_NOTIFY_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("CMMS_NOTIFY_INTERVAL: %w", err))
+		}
+		c.Notify.Interval = d
+	}
+	if v := lookup("CMMS_NOTIFY_ENABLED"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("CMMS_NOTIFY_ENABLED %q is not true or false", v))
+		}
+		c.Notify.Enabled = b
+	}
+	num(&c.Trash.RetentionDays, "TRASH_RETENTION_DAYS", "CMMS_TRASH_RETENTION_DAYS")
+
+	if v, ok := os.LookupEnv("CMMS_PROXY_TRUSTED"); ok {
+		c.Proxy.Trusted = nil
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				c.Proxy.Trusted = append(c.Proxy.Trusted, p)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lookup returns the value of the last of the environment variables names
+// that is set, so a current name wins over the one it replaced
+func lookup(names ...string) string {
+	var v string
+	for _, name := range names {
+		if s := os.Getenv(name); s != "" {
+			v = s
+		}
+	}
+	return v
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	if u, err := url.Parse(c.Mongo.URI); err != nil || (u.Scheme != "mongodb" && u.Scheme != "mongodb+srv") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("mongo.uri %q is not a mongodb:// or mongodb+srv:// URI", c.Mongo.URI))
+	}
+	if err := validDatabase(c.Mongo.Database); err != nil {
+		errs = append(errs, fmt.Errorf("mongo.database: %w", err))
+	}
+	if c.Mongo.ConnectTimeout <= 0 {
+		errs = append(errs, errors.New("mongo.connect_timeout must be positive"))
+	}
+
+	switch c.Storage.Driver {
+	case "", StorageMongo:
+	case StorageSQLite, StoragePostgres:
+		if c.Storage.DSN == "" {
+			errs = append(errs, fmt.Errorf("storage.dsn must be set for the %s driver", c.Storage.Driver))
+		}
+		if c.Events.Transport == EventsMongo {
+			errs = append(errs, fmt.Errorf("events.transport mongo needs storage.driver mongo"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage.driver %q is not mongo, sqlite or postgres", c.Storage.Driver))
+	}
+
+	for _, name := range []string{Asset, Service, Consumable, Maintenance, Monolith, Gateway} {
+		s := c.Services[name]
+		if err := validAddr(s.Addr); err != nil {
+			errs = append(errs, fmt.Errorf("services.%s.addr %q: %v", name, s.Addr, err))
+		}
+		if !httpURL(s.URL) {
+			errs = append(errs, fmt.Errorf("services.%s.url %q is not an http(s) URL", name, s.URL))
+		}
+		if s.PublicURL != "" && !httpURL(s.PublicURL) {
+			errs = append(errs, fmt.Errorf("services.%s.public_url %q is not an http(s) URL", name, s.PublicURL))
+		}
+		if s.Database != "" {
+			if err := validDatabase(s.Database); err != nil {
+				errs = append(errs, fmt.Errorf("services.%s.database: %w", name, err))
+			}
+		}
+	}
+
+	for _, name := range []string{Asset, Service, Consumable} {
+		s := c.Services[name]
+		if err := validAddr(s.GRPCAddr); err != nil {
+			errs = append(errs, fmt.Errorf("services.%s.grpc_addr %q: %v", name, s.GRPCAddr, err))
+		}
+	}
+
+	switch c.Events.Transport {
+	case "", EventsInProcess, EventsMongo:
+	case EventsNATS:
+		if u, err := url.Parse(c.Events.NATSURL); err != nil || u.Scheme != "nats" && u.Scheme != "tls" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("events.nats_url %q is not a nats:// or tls:// URL", c.Events.NATSURL))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("events.transport %q is not inprocess, mongo or nats", c.Events.Transport))
+	}
+
+	if c.Session.Secret != "" && len(c.Session.Secret) < MinSecretLength {
+		errs = append(errs, fmt.Errorf("session.secret must be at least %d characters", MinSecretLength))
+	}
+
+	if c.Notify.Enabled {
+		if c.Notify.SMTP.Host == "" {
+			errs = append(errs, errors.New("notify.smtp.host must be set when notify.enabled is"))
+		}
+		if p := c.Notify.SMTP.Port; p < 1 || p > 65535 {
+			errs = append(errs, fmt.Errorf("notify.smtp.port %d is not a port", p))
+		}
+		if _, err := mail.ParseAddress(c.Notify.SMTP.From); err != nil {
+			errs = append(errs, fmt.Errorf("notify.smtp.from %q is not an email address", c.Notify.SMTP.From))
+		}
+	}
+	if c.Notify.BaseURL != "" && !httpURL(c.Notify.BaseURL) {
+		errs = append(errs, fmt.Errorf("notify.base_url %q is not an http(s) URL", c.Notify.BaseURL))
+	}
+	if c.Notify.DaysAhead < 1 {
+		errs = append(errs, errors.New("notify.days_ahead must be at least 1"))
+	}
+	if c.Notify.Interval <= 0 {
+		errs = append(errs, errors.New("notify.interval must be positive"))
+	}
+	if c.Trash.RetentionDays < 1 {
+		errs = append(errs, errors.New("trash.retention_days must be at least 1"))
+	}
+
+	for _, p := range c.Proxy.Trusted {
+		if _, err := parseNet(p); err != nil {
+			errs = append(errs, fmt.Errorf("proxy.trusted %q is not an address or CIDR range", p))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// MinSecretLength is the shortest session.secret accepted
+const MinSecretLength = 32
+
+// validAddr checks a host:port listen address
+func validAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return errors.New("invalid port")
+	}
+	return nil
+}
+
+func httpURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func validDatabase(name string) error {
+	if name == "" {
+		return errors.New("must not be empty")
+	}
+	if len(name) > 63 || strings.ContainsAny(name, `/\. "$*<>:|?`) {
+		return fmt.Errorf("%q is not a valid database name", name)
+	}
+	return nil
+}
+
+// parseNet parses an address, as a range of one, or a CIDR range
+func parseNet(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err := net.ParseCIDR(s)
+	return n, err
+}
+
+// NotifyBaseURL returns the base URL of the links in the notifications,
+// without a trailing slash
+func (c *Config) NotifyBaseURL() string {
+	if c.Notify.BaseURL != "" {
+		return strings.TrimRight(c.Notify.BaseURL, "/")
+	}
+	return c.PublicURL(Maintenance)
+}
+
+// TrashRetention returns how long deleted records are kept
+func (c *Config) TrashRetention() time.Duration {
+	return time.Duration(c.Trash.RetentionDays) * 24 * time.Hour
+}
+
+// TrustedProxies returns the ranges of proxy.trusted
+func (c *Config) TrustedProxies() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, p := range c.Proxy.Trusted {
+		if n, err := parseNet(p); err == nil {
+			nets = append(nets, n)
+		}
+	}
+	return nets
+}
+
+// Addr returns the listen address of the named service
+func (c *Config) Addr(service string) string {
+	return c.Services[service].Addr
+}
+
+// URL returns the base URL of the named service, without a trailing slash
+func (c *Config) URL(service string) string {
+	return strings.TrimRight(c.Services[service].URL, "/")
+}
+
+// PublicURL returns the base URL pages use to link to the named service,
+// without a trailing slash
+func (c *Config) PublicURL(service string) string {
+	if u := c.Services[service].PublicURL; u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return c.URL(service)
+}
+
+// GRPCAddr returns the gRPC listen address of the named service
+func (c *Config) GRPCAddr(service string) string {
+	return c.Services[service].GRPCAddr
+}
+
+// GRPCTarget returns the target other services dial to reach the gRPC server
+// of the named service
+func (c *Config) GRPCTarget(service string) string {
+	if t := c.Services[service].GRPCTarget; t != "" {
+		return t
+	}
+	return c.GRPCAddr(service)
+}
+
+// Database returns the database the named service keeps its data in
+func (c *Config) Database(service string) string {
+	if db := c.Services[service].Database; db != "" {
+		return db
+	}
+	return c.Mongo.Database
+}
+
+// StorageDriver returns the configured storage driver, mongo by default
+func (c *Config) StorageDriver() string {
+	if c.Storage.Driver == "" {
+		return StorageMongo
+	}
+	return c.Storage.Driver
+}
+
+// SQLStorage reports whether the data is kept in a SQL database
+func (c *Config) SQLStorage() bool {
+	return c.StorageDriver() != StorageMongo
+}
+
+// EventTransport returns the configured event transport, mongo by default
+func (c *Config) EventTransport() string {
+	if c.Events.Transport == "" {
+		return EventsMongo
+	}
+	return c.Events.Transport
+}
+
+// Monolithic points the links of every service at the monolith, for running
+// them all in one process; a public_url set for a service is kept. Events
+// go in-process unless a transport is configured.
+func (c *Config) Monolithic() {
+	if c.Events.Transport == "" {
+		c.Events.Transport = EventsInProcess
+	}
+	public := c.PublicURL(Monolith)
+	for _, name := range []string{Asset, Service, Consumable, Maintenance} {
+		sc := c.Services[name]
+		if sc.PublicURL == "" {
+			sc.PublicURL = public
+			c.Services[name] = sc
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Package conflict answers an edit the repositories rejected with
+// store.ErrConflict because someone else saved the record after the version
+// the edit was based on. A page shows the changes of the user next to the
+// record as it is now and lets them save theirs over it; an API call gets
+// 409 Conflict with the current record and its ETag.
+package conflict
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"shared/csrf"
+	"shared/jsonapi"
+	"shared/validate"
+	"sort"
+	"strconv"
+)
+
+// Field is a field of the record shown on the conflict page
+type Field struct {
+	Label string
+	// Mine is what the rejected edit would have saved
+	Mine string
+	// Current is what the record has now
+	Current string
+}
+
+// Changed reports whether the edit and the current record differ on the
+// field
+func (f Field) Changed() bool {
+	return f.Mine != f.Current
+}
+
+// Input is a hidden field of the form saving the edit again
+type Input struct {
+	Name  string
+	Value string
+}
+
+// Page is the data of the conflict.html page of a service
+type Page struct {
+	// Title names the record, e.g. "Service Oil change"
+	Title string
+	// Back is the page showing the current version
+	Back string
+	// Action is where the edit form posts
+	Action string
+	Fields []Field
+	// Resubmit is the rejected edit on the current version, posted by the
+	// button saving it over the current record
+	Resubmit []Input
+	// CSRF is the token field of the forms, set by Write
+	CSRF template.HTML
+}
+
+// NewPage returns the conflict page of the edit posted as values to action,
+// resubmitted on version, the version of the record now
+func NewPage(title, back, action string, values url.Values, version int64, fields ...Field) Page {
+	p := Page{Title: title, Back: back, Action: action, Fields: fields}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if name != "version" && name != csrf.FieldName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range values[name] {
+			p.Resubmit = append(p.Resubmit, Input{Name: name, Value: v})
+		}
+	}
+	p.Resubmit = append(p.Resubmit, Input{Name: "version", Value: strconv.FormatInt(version, 10)})
+	return p
+}
+
+// Write answers the edit of r rejected with store.ErrConflict with 409
+// Conflict: for an API call {"error": ..., "current": current} with the ETag
+// of version, the version of current; otherwise the page p, rendered by
+// render
+func Write(w http.ResponseWriter, r *http.Request, p Page, current interface{}, version int64, render func(w http.ResponseWriter, p Page) error) error {
+	if validate.WantsJSON(r) {
+		w.Header().Set("ETag", jsonapi.ETag(version))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		return json.NewEncoder(w).Encode(struct {
+			Error   string      `json:"error"`
+			Current interface{} `json:"current"`
+		}{"The record was changed by someone else; apply your changes to the current version", current})
+	}
+	p.CSRF = csrf.Field(r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusConflict)
+	return render(w, p)
+}
@@ -0,0 +1,150 @@
+// Package csrf keeps other websites from submitting the forms of the
+// services on behalf of a logged-in user.
+//
+// Protect gives every client a random id in a cookie, and the pages put a
+// token, the HMAC-SHA256 of that id, in a hidden field of their POST forms
+// with Field. A POST, PUT, PATCH or DELETE must send the token back in the
+// field or the X-CSRF-Token header. Another site can make the browser send
+// the cookie, but it cannot read the token out of the page.
+//
+// Requests a form of another site cannot send need no token: those with a
+// JSON body and those with an X-Requested-With header, such as the calls the
+// services make to each other.
+package csrf
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"html/template"
+	"mime"
+	"net/http"
+	"sync"
+)
+
+// Names of the cookie, form field and header
+const (
+	CookieName = "cmms_csrf"
+	FieldName  = "csrf_token"
+	HeaderName = "X-CSRF-Token"
+)
+
+// maxIDLength bounds the id taken from the cookie
+const maxIDLength = 128
+
+var (
+	mu  sync.RWMutex
+	key = randomBytes()
+)
+
+// randomBytes returns 32 random bytes, the key used until SetKey is called
+// and the id of a new client
+func randomBytes() []byte {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("csrf: " + err.Error())
+	}
+	return b
+}
+
+// SetKey sets the key the tokens are signed with. Processes serving the pages
+// of the same service need the same key; without a call each process signs
+// with a random key of its own.
+func SetKey(k []byte) {
+	mu.Lock()
+	defer mu.Unlock()
+	key = append([]byte(nil), k...)
+}
+
+// sign returns the token of the client id
+func sign(id string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+type tokenKey struct{}
+
+// Token returns the token a request must send to be accepted: the one of the
+// request passed to the handler by Protect, or of the id in its cookie. It is
+// empty when r has no id yet.
+func Token(r *http.Request) string {
+	if t, ok := r.Context().Value(tokenKey{}).(string); ok {
+		return t
+	}
+	if c, err := r.Cookie(CookieName); err == nil && validID(c.Value) {
+		return sign(c.Value)
+	}
+	return ""
+}
+
+// Field returns the hidden input carrying the token of r, which the POST
+// forms of a page add as {{.CSRF}}
+func Field(r *http.Request) template.HTML {
+	return template.HTML(`<input type="hidden" name="` + FieldName + `" value="` + template.HTMLEscapeString(Token(r)) + `">`)
+}
+
+// validID reports whether the id of a cookie can be used
+func validID(id string) bool {
+	return id != "" && len(id) <= maxIDLength
+}
+
+// Protect checks the token of the requests changing something and passes
+// it on to next, for Token and Field. A request without a valid token gets
+// 403 Forbidden.
+func Protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := ""
+		if c, err := r.Cookie(CookieName); err == nil && validID(c.Value) {
+			id = c.Value
+		}
+		if id == "" {
+			id = base64.RawURLEncoding.EncodeToString(randomBytes())
+			http.SetCookie(w, &http.Cookie{
+				Name:     CookieName,
+				Value:    id,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		token := sign(id)
+
+		if !safe(r.Method) && !exempt(r) && !hmac.Equal([]byte(sent(r)), []byte(token)) {
+			http.Error(w, "Invalid or missing CSRF token: reload the page and try again", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenKey{}, token)))
+	})
+}
+
+// safe reports whether requests of the method change nothing
+func safe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// exempt reports whether r is one a form of another site cannot send
+func exempt(r *http.Request) bool {
+	if r.Header.Get("X-Requested-With") != "" {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+// sent returns the token sent with r
+func sent(r *http.Request) string {
+	if t := r.Header.Get(HeaderName); t != "" {
+		return t
+	}
+	return r.PostFormValue(FieldName)
+}
@@ -0,0 +1,99 @@
+package events
+
+import (
+	"context"
+	"log"
+	"shared/store"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// processed records that a subscriber handled an event
+type processed struct {
+	ID          string    `bson:"_id"` // subscriber:event id
+	ProcessedAt time.Time `bson:"processed_at"`
+}
+
+// Ledger records the events each subscriber handled, in the database the
+// subscriber's handlers write to
+type Ledger interface {
+	store.Tx
+	// Handled reports whether key, subscriber:event id, was recorded
+	Handled(ctx context.Context, key string) (bool, error)
+	// Record saves key in the transaction of ctx; a key recorded before is
+	// not an error
+	Record(ctx context.Context, key string) error
+}
+
+// MongoLedger is the processed collection of the shared database
+type MongoLedger struct {
+	store.MongoTx
+	coll *mongo.Collection
+}
+
+// NewMongoLedger returns the ledger in db, the shared database
+func NewMongoLedger(db *mongo.Database) MongoLedger {
+	return MongoLedger{MongoTx: store.NewMongoTx(db.Client()), coll: db.Collection(ProcessedCollection)}
+}
+
+// Handled reports whether key was recorded
+func (l MongoLedger) Handled(ctx context.Context, key string) (bool, error) {
+	err := l.coll.FindOne(ctx, bson.M{"_id": key}).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Record saves key
+func (l MongoLedger) Record(ctx context.Context, key string) error {
+	_, err := l.coll.InsertOne(ctx, processed{ID: key, ProcessedAt: time.Now()})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// Consume subscribes handle to t as name until ctx is done, subscribing again
+// with a growing wait when the subscription fails. Events the subscriber
+// already handled are skipped; the record of one handled is saved in ledger,
+// in the transaction of the handler.
+func Consume(ctx context.Context, t Transport, ledger Ledger, name string, handle Handler) {
+	once := func(ctx context.Context, e Event) error {
+		key := name + ":" + e.ID.Hex()
+		if handled, err := ledger.Handled(ctx, key); handled || err != nil {
+			return err
+		}
+
+		return ledger.Atomically(ctx, func(ctx context.Context) error {
+			if err := handle(ctx, e); err != nil {
+				return err
+			}
+			return ledger.Record(ctx, key)
+		})
+	}
+
+	wait := time.Second
+	for {
+		started := time.Now()
+		err := t.Subscribe(ctx, name, once)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("events: %s: %v", name, err)
+
+		if time.Since(started) > time.Minute {
+			wait = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > time.Minute {
+			wait = time.Minute
+		}
+	}
+}
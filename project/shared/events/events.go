@@ -0,0 +1,126 @@
+// Package events carries domain events from the service making a change to
+// the services that must react to it, e.g. the maintenance service moving
+// the maintenances of a deleted asset to the recycle bin.
+//
+// A change and its event are saved together: Record adds the event to the
+// outbox collection of the shared database, inside the transaction of the
+// change when it runs in a store.Tx. A Relay claims unsent events from the
+// outbox and publishes them through a Transport, marking them sent once the
+// transport took them; an event is never lost but may be published twice.
+// Consume runs a subscriber's handler for every event and records the events
+// it handled, so an event delivered twice is handled once.
+//
+// With the sqlite and postgres storage drivers the outbox and the records of
+// handled events are tables of that database instead: SQLOutbox,
+// NewSQLRelay and SQLLedger.
+//
+// Transports: InProcess for services running in one binary, MongoStream
+// (change streams, which need a replica set) and NATS (JetStream).
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Collections used by the package, in the shared database
+const (
+	OutboxCollection    = "event_outbox"
+	ProcessedCollection = "event_processed"
+)
+
+// Event types. The data of the asset events is the asset, that of
+// ScheduleChanged the schedule; ServiceDeleted and ConsumableDeleted carry
+// the deleted record.
+const (
+	AssetCreated      = "AssetCreated"
+	AssetUpdated      = "AssetUpdated"
+	AssetDeleted      = "AssetDeleted"
+	ServiceDeleted    = "ServiceDeleted"
+	ConsumableDeleted = "ConsumableDeleted"
+	ScheduleChanged   = "ScheduleChanged"
+)
+
+// Event is something that happened to a record of one service
+type Event struct {
+	ID   primitive.ObjectID `bson:"_id" json:"id"`
+	Type string             `bson:"type" json:"type"`
+	// Source is the service that made the change
+	Source string `bson:"source" json:"source"`
+	// EntityID is the record the event is about
+	EntityID primitive.ObjectID `bson:"entity_id" json:"entity_id"`
+	// Actor is the audit actor of the change; subscribers audit what they
+	// do in reaction under the same name
+	Actor      string    `bson:"actor" json:"actor"`
+	OccurredAt time.Time `bson:"occurred_at" json:"occurred_at"`
+	// Payload is the JSON of the event's data
+	Payload string `bson:"payload" json:"payload"`
+}
+
+// Decode unmarshals the event's data into v
+func (e Event) Decode(v interface{}) error {
+	if err := json.Unmarshal([]byte(e.Payload), v); err != nil {
+		return fmt.Errorf("event %s %s: %w", e.Type, e.ID.Hex(), err)
+	}
+	return nil
+}
+
+// outboxEntry is an event waiting in the outbox, or already relayed
+type outboxEntry struct {
+	Event       `bson:",inline"`
+	SentAt      *time.Time `bson:"sent_at"`
+	LockedUntil time.Time  `bson:"locked_until"`
+	Attempts    int        `bson:"attempts"`
+	NextAttempt time.Time  `bson:"next_attempt"`
+	LastError   string     `bson:"last_error,omitempty"`
+}
+
+// Outbox is where a service records the events of its changes, in the
+// transaction of the change
+type Outbox interface {
+	Record(ctx context.Context, source, eventType string, entityID primitive.ObjectID, actor string, data interface{}) error
+}
+
+// MongoOutbox is the outbox collection of the shared database
+type MongoOutbox struct {
+	DB *mongo.Database
+}
+
+// NewOutbox returns the outbox in db, the shared database
+func NewOutbox(db *mongo.Database) MongoOutbox {
+	return MongoOutbox{DB: db}
+}
+
+// Record adds an event about entityID to the outbox; in a store.Tx it is
+// saved only if the change is
+func (o MongoOutbox) Record(ctx context.Context, source, eventType string, entityID primitive.ObjectID, actor string, data interface{}) error {
+	return Record(ctx, o.DB, source, eventType, entityID, actor, data)
+}
+
+// Record adds an event about entityID to the outbox of db, the shared
+// database
+func Record(ctx context.Context, db *mongo.Database, source, eventType string, entityID primitive.ObjectID, actor string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	_, err = db.Collection(OutboxCollection).InsertOne(ctx, outboxEntry{
+		Event: Event{
+			ID:         primitive.NewObjectID(),
+			Type:       eventType,
+			Source:     source,
+			EntityID:   entityID,
+			Actor:      actor,
+			OccurredAt: now,
+			Payload:    string(payload),
+		},
+		NextAttempt: now,
+	})
+	return err
+}
@@ -0,0 +1,43 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryOutbox keeps the recorded events in memory, for tests
+type MemoryOutbox struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// Record adds an event to the outbox
+func (o *MemoryOutbox) Record(ctx context.Context, source, eventType string, entityID primitive.ObjectID, actor string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, Event{
+		ID:         primitive.NewObjectID(),
+		Type:       eventType,
+		Source:     source,
+		EntityID:   entityID,
+		Actor:      actor,
+		OccurredAt: time.Now().UTC(),
+		Payload:    string(payload),
+	})
+	return nil
+}
+
+// Events returns the recorded events, oldest first
+func (o *MemoryOutbox) Events() []Event {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Event(nil), o.events...)
+}
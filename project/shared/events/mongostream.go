@@ -0,0 +1,89 @@
+package events
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Collections of the MongoStream transport
+const (
+	LogCollection     = "event_log"
+	CursorsCollection = "event_cursors"
+)
+
+// MongoStream publishes events into a log collection and delivers them with a
+// change stream on it. The position of every subscriber is saved, so one
+// restarting resumes where it stopped as long as the oplog still holds it; a
+// subscriber that never ran starts with the events published after it first
+// subscribed. Change streams need a replica set.
+type MongoStream struct {
+	DB *mongo.Database
+}
+
+// NewMongoStream returns a transport on the log of db, the shared database
+func NewMongoStream(db *mongo.Database) *MongoStream {
+	return &MongoStream{DB: db}
+}
+
+// Publish adds e to the log; an event already in it is not added twice
+func (m *MongoStream) Publish(ctx context.Context, e Event) error {
+	_, err := m.DB.Collection(LogCollection).InsertOne(ctx, e)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// cursor is the saved position of a subscriber
+type cursor struct {
+	Name  string   `bson:"_id"`
+	Token bson.Raw `bson:"token"`
+}
+
+// Subscribe watches the log from the saved position of name, saving it
+// after every event handled. It returns when handle fails, so the event is
+// delivered again on the next Subscribe.
+func (m *MongoStream) Subscribe(ctx context.Context, name string, handle Handler) error {
+	cursors := m.DB.Collection(CursorsCollection)
+
+	opts := options.ChangeStream()
+	var saved cursor
+	err := cursors.FindOne(ctx, bson.M{"_id": name}).Decode(&saved)
+	switch {
+	case err == nil:
+		opts.SetResumeAfter(saved.Token)
+	case err != mongo.ErrNoDocuments:
+		return err
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"operationType": "insert"}}}}
+	stream, err := m.DB.Collection(LogCollection).Watch(ctx, pipeline, opts)
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		var change struct {
+			FullDocument Event `bson:"fullDocument"`
+		}
+		if err := stream.Decode(&change); err != nil {
+			return err
+		}
+		if err := handle(ctx, change.FullDocument); err != nil {
+			return err
+		}
+		_, err := cursors.UpdateOne(ctx,
+			bson.M{"_id": name},
+			bson.M{"$set": bson.M{"token": stream.ResumeToken()}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return stream.Err()
+}
@@ -0,0 +1,93 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// The JetStream stream holding the events, one subject per event type
+const (
+	natsStream  = "CMMS_EVENTS"
+	natsSubject = "cmms.events."
+)
+
+// NATS publishes events to a JetStream stream, with the event id as message
+// id so a repeated publish is dropped, and delivers them through a durable
+// consumer per subscriber
+type NATS struct {
+	conn *nats.Conn
+	js   jetstream.JetStream
+}
+
+// NewNATS connects to the NATS server at url and creates the stream
+func NewNATS(ctx context.Context, url string) (*NATS, error) {
+	conn, err := nats.Connect(url, nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:       natsStream,
+		Subjects:   []string{natsSubject + ">"},
+		Duplicates: 10 * time.Minute,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &NATS{conn: conn, js: js}, nil
+}
+
+// Close drains the connection
+func (n *NATS) Close() error {
+	return n.conn.Drain()
+}
+
+// Publish adds e to the stream
+func (n *NATS) Publish(ctx context.Context, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = n.js.Publish(ctx, natsSubject+e.Type, data, jetstream.WithMsgID(e.ID.Hex()))
+	return err
+}
+
+// Subscribe consumes the stream as the durable consumer name until ctx is
+// done. An event handle fails for is redelivered after a few seconds.
+func (n *NATS) Subscribe(ctx context.Context, name string, handle Handler) error {
+	consumer, err := n.js.CreateOrUpdateConsumer(ctx, natsStream, jetstream.ConsumerConfig{
+		Durable:   name,
+		AckPolicy: jetstream.AckExplicitPolicy,
+	})
+	if err != nil {
+		return err
+	}
+
+	consuming, err := consumer.Consume(func(msg jetstream.Msg) {
+		var e Event
+		if err := json.Unmarshal(msg.Data(), &e); err != nil {
+			msg.Term()
+			return
+		}
+		if err := handle(ctx, e); err != nil {
+			msg.NakWithDelay(5 * time.Second)
+			return
+		}
+		msg.Ack()
+	})
+	if err != nil {
+		return err
+	}
+	<-ctx.Done()
+	consuming.Stop()
+	return ctx.Err()
+}
@@ -0,0 +1,189 @@
+package events
+
+import (
+	"context"
+	"log"
+	"shared/store"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Relay publishes the events of the outbox through a Transport. Events are
+// claimed with a lease, so any number of relays can share the outbox.
+type Relay struct {
+	outbox      queue
+	Transport   Transport
+	Interval    time.Duration // how often the outbox is polled
+	Lease       time.Duration // how long a claimed event is hidden from others
+	BaseBackoff time.Duration // wait after the first failure, doubled every attempt
+	MaxBackoff  time.Duration
+}
+
+// queue is the outbox as a relay works through it
+type queue interface {
+	// claim hides the oldest due event from other relays until lease ends
+	// and returns it; it reports false when nothing is due
+	claim(ctx context.Context, now time.Time, lease time.Duration) (outboxEntry, bool, error)
+	// finish releases a claimed event, marking it sent or, after a failed
+	// attempt, retried at next
+	finish(ctx context.Context, id primitive.ObjectID, sent bool, next time.Time, lastError string) error
+	// expire drops the relayed events and the records of handled ones that
+	// are older than Retention
+	expire(ctx context.Context) error
+}
+
+// NewRelay returns a relay of the outbox in db, the shared database, polling
+// every second and retrying failures from 5s up to 5 minutes apart
+func NewRelay(db *mongo.Database, t Transport) *Relay {
+	return newRelay(mongoQueue{db.Collection(OutboxCollection)}, t)
+}
+
+// NewSQLRelay returns a relay of the outbox in a SQL database, as NewRelay
+func NewSQLRelay(db *store.SQL, t Transport) *Relay {
+	return newRelay(sqlQueue{db}, t)
+}
+
+func newRelay(outbox queue, t Transport) *Relay {
+	return &Relay{
+		outbox:      outbox,
+		Transport:   t,
+		Interval:    time.Second,
+		Lease:       time.Minute,
+		BaseBackoff: 5 * time.Second,
+		MaxBackoff:  5 * time.Minute,
+	}
+}
+
+// Backoff returns the wait before the next attempt after n failed attempts
+func (r *Relay) Backoff(n int) time.Duration {
+	wait := r.BaseBackoff
+	for i := 1; i < n && wait < r.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > r.MaxBackoff {
+		wait = r.MaxBackoff
+	}
+	return wait
+}
+
+// Run relays the outbox until ctx is done
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	var expired time.Time
+	for {
+		for {
+			relayed, err := r.relayNext(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("events: %v", err)
+				}
+				break
+			}
+			if !relayed {
+				break
+			}
+		}
+		if time.Since(expired) > time.Hour {
+			if err := r.outbox.expire(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("events: %v", err)
+			}
+			expired = time.Now()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relayNext claims and publishes the oldest due event; it reports false when
+// nothing is due
+func (r *Relay) relayNext(ctx context.Context) (bool, error) {
+	entry, ok, err := r.outbox.claim(ctx, time.Now(), r.Lease)
+	if !ok || err != nil {
+		return false, err
+	}
+
+	if perr := r.Transport.Publish(ctx, entry.Event); perr != nil {
+		log.Printf("events: publishing %s %s: %v", entry.Type, entry.ID.Hex(), perr)
+		return true, r.outbox.finish(ctx, entry.ID, false, time.Now().Add(r.Backoff(entry.Attempts+1)), perr.Error())
+	}
+	return true, r.outbox.finish(ctx, entry.ID, true, time.Time{}, "")
+}
+
+// mongoQueue is the outbox collection of the shared database
+type mongoQueue struct {
+	coll *mongo.Collection
+}
+
+func (q mongoQueue) claim(ctx context.Context, now time.Time, lease time.Duration) (outboxEntry, bool, error) {
+	var entry outboxEntry
+	err := q.coll.FindOneAndUpdate(ctx,
+		bson.M{
+			"sent_at":      nil,
+			"next_attempt": bson.M{"$lte": now},
+			"locked_until": bson.M{"$lte": now},
+		},
+		bson.M{"$set": bson.M{"locked_until": now.Add(lease)}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "occurred_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&entry)
+	if err == mongo.ErrNoDocuments {
+		return entry, false, nil
+	}
+	return entry, err == nil, err
+}
+
+func (q mongoQueue) finish(ctx context.Context, id primitive.ObjectID, sent bool, next time.Time, lastError string) error {
+	update := bson.M{"locked_until": time.Time{}}
+	if sent {
+		update["sent_at"] = time.Now()
+	} else {
+		update["next_attempt"] = next
+		update["last_error"] = lastError
+	}
+	_, err := q.coll.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": update, "$inc": bson.M{"attempts": 1}},
+	)
+	return err
+}
+
+// expire leaves old events to the TTL indexes created by MigrateMongo
+func (q mongoQueue) expire(ctx context.Context) error {
+	return nil
+}
+
+// Retention is how long relayed events and the records of handled ones are
+// kept
+const Retention = 7 * 24 * time.Hour
+
+// mongoMigrations create the indexes the relay polls the outbox by and
+// those dropping what is older than Retention
+var mongoMigrations = []store.MongoMigration{
+	{Version: 1, Name: "outbox, log and processed indexes", Up: store.Steps(
+		store.Indexes(OutboxCollection,
+			mongo.IndexModel{Keys: store.Keys("sent_at", "next_attempt")},
+			mongo.IndexModel{Keys: store.Keys("sent_at"), Options: expireAfterRetention()},
+		),
+		store.Indexes(LogCollection, mongo.IndexModel{Keys: store.Keys("occurred_at"), Options: expireAfterRetention()}),
+		store.Indexes(ProcessedCollection, mongo.IndexModel{Keys: store.Keys("processed_at"), Options: expireAfterRetention()}),
+	)},
+}
+
+func expireAfterRetention() *options.IndexOptions {
+	return options.Index().SetExpireAfterSeconds(int32(Retention.Seconds()))
+}
+
+// MigrateMongo creates or updates the indexes of the domain events in db,
+// the shared database
+func MigrateMongo(ctx context.Context, db *mongo.Database) error {
+	return store.MigrateMongo(ctx, db, "events", mongoMigrations)
+}
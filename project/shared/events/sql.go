@@ -0,0 +1,156 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"shared/store"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// migrations create the outbox and the processed events tables of the SQL
+// storage drivers
+var migrations = []store.Migration{
+	{Version: 1, Name: "create event_outbox and event_processed", Statements: []string{
+		`CREATE TABLE event_outbox (
+			id TEXT PRIMARY KEY,
+			type TEXT NOT NULL,
+			source TEXT NOT NULL,
+			entity_id TEXT NOT NULL,
+			actor TEXT NOT NULL,
+			occurred_at BIGINT NOT NULL,
+			payload TEXT NOT NULL,
+			sent_at BIGINT,
+			locked_until BIGINT NOT NULL DEFAULT 0,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt BIGINT NOT NULL,
+			last_error TEXT NOT NULL DEFAULT ''
+		)`,
+		"CREATE INDEX event_outbox_due ON event_outbox (sent_at, next_attempt)",
+		`CREATE TABLE event_processed (
+			id TEXT PRIMARY KEY,
+			processed_at BIGINT NOT NULL
+		)`,
+		"CREATE INDEX event_processed_at ON event_processed (processed_at)",
+	}},
+}
+
+// MigrateSQL creates or updates the tables of the package in db
+func MigrateSQL(ctx context.Context, db *store.SQL) error {
+	return db.Migrate(ctx, "events", migrations)
+}
+
+// SQLOutbox is the event_outbox table of a SQL database
+type SQLOutbox struct {
+	DB *store.SQL
+}
+
+// NewSQLOutbox returns the outbox in db
+func NewSQLOutbox(db *store.SQL) SQLOutbox {
+	return SQLOutbox{DB: db}
+}
+
+// Record adds an event about entityID to the outbox; in the transaction of
+// ctx it is saved only if the change is
+func (o SQLOutbox) Record(ctx context.Context, source, eventType string, entityID primitive.ObjectID, actor string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	now := store.Millis(time.Now())
+	_, err = o.DB.Exec(ctx,
+		"INSERT INTO event_outbox (id, type, source, entity_id, actor, occurred_at, payload, next_attempt) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		primitive.NewObjectID().Hex(), eventType, source, entityID.Hex(), actor, now, string(payload), now)
+	return err
+}
+
+// sqlQueue is the event_outbox table as a relay works through it
+type sqlQueue struct {
+	db *store.SQL
+}
+
+func (q sqlQueue) claim(ctx context.Context, now time.Time, lease time.Duration) (outboxEntry, bool, error) {
+	var (
+		entry              outboxEntry
+		id, entityID       string
+		occurred           int64
+		skipLockedByOthers string
+	)
+	if q.db.Driver == store.Postgres {
+		skipLockedByOthers = " FOR UPDATE SKIP LOCKED"
+	}
+	err := q.db.Atomically(ctx, func(ctx context.Context) error {
+		err := q.db.QueryRow(ctx,
+			"SELECT id, type, source, entity_id, actor, occurred_at, payload, attempts FROM event_outbox"+
+				" WHERE sent_at IS NULL AND next_attempt <= ? AND locked_until <= ? ORDER BY occurred_at LIMIT 1"+skipLockedByOthers,
+			store.Millis(now), store.Millis(now),
+		).Scan(&id, &entry.Type, &entry.Source, &entityID, &entry.Actor, &occurred, &entry.Payload, &entry.Attempts)
+		if err != nil {
+			return err
+		}
+		_, err = q.db.Exec(ctx, "UPDATE event_outbox SET locked_until = ? WHERE id = ?", store.Millis(now.Add(lease)), id)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return entry, false, nil
+	}
+	if err != nil {
+		return entry, false, err
+	}
+	if entry.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+		return entry, false, err
+	}
+	if entry.EntityID, err = primitive.ObjectIDFromHex(entityID); err != nil {
+		return entry, false, err
+	}
+	entry.OccurredAt = time.UnixMilli(occurred).UTC()
+	return entry, true, nil
+}
+
+func (q sqlQueue) finish(ctx context.Context, id primitive.ObjectID, sent bool, next time.Time, lastError string) error {
+	var err error
+	if sent {
+		_, err = q.db.Exec(ctx, "UPDATE event_outbox SET locked_until = 0, attempts = attempts + 1, sent_at = ? WHERE id = ?",
+			store.Millis(time.Now()), id.Hex())
+	} else {
+		_, err = q.db.Exec(ctx, "UPDATE event_outbox SET locked_until = 0, attempts = attempts + 1, next_attempt = ?, last_error = ? WHERE id = ?",
+			store.Millis(next), lastError, id.Hex())
+	}
+	return err
+}
+
+func (q sqlQueue) expire(ctx context.Context) error {
+	before := store.Millis(time.Now().Add(-Retention))
+	if _, err := q.db.Exec(ctx, "DELETE FROM event_outbox WHERE sent_at < ?", before); err != nil {
+		return err
+	}
+	_, err := q.db.Exec(ctx, "DELETE FROM event_processed WHERE processed_at < ?", before)
+	return err
+}
+
+// SQLLedger is the event_processed table of a SQL database
+type SQLLedger struct {
+	*store.SQL
+}
+
+// NewSQLLedger returns the ledger in db
+func NewSQLLedger(db *store.SQL) SQLLedger {
+	return SQLLedger{SQL: db}
+}
+
+// Handled reports whether key was recorded
+func (l SQLLedger) Handled(ctx context.Context, key string) (bool, error) {
+	var n int
+	err := l.QueryRow(ctx, "SELECT COUNT(*) FROM event_processed WHERE id = ?", key).Scan(&n)
+	return n > 0, err
+}
+
+// Record saves key
+func (l SQLLedger) Record(ctx context.Context, key string) error {
+	_, err := l.Exec(ctx, "INSERT INTO event_processed (id, processed_at) VALUES (?, ?) ON CONFLICT (id) DO NOTHING",
+		key, store.Millis(time.Now()))
+	return err
+}
@@ -0,0 +1,76 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Handler handles one event. An event it returns an error for is delivered
+// again later.
+type Handler func(ctx context.Context, e Event) error
+
+// Transport carries events from the relay to the subscribers
+type Transport interface {
+	// Publish hands e to the transport; once it returned nil the event
+	// reaches every subscriber
+	Publish(ctx context.Context, e Event) error
+	// Subscribe delivers the events to handle as the subscriber name until
+	// ctx is done or the subscription fails
+	Subscribe(ctx context.Context, name string, handle Handler) error
+}
+
+// Transports that can be configured
+const (
+	TransportInProcess = "inprocess"
+	TransportMongo     = "mongo"
+	TransportNATS      = "nats"
+)
+
+// InProcess hands events straight to the subscribers of the same process. It
+// only reaches services running in one binary, and a publish fails while
+// nobody subscribed, so the relay keeps the events until someone does.
+type InProcess struct {
+	mu   sync.RWMutex
+	subs map[string]Handler
+}
+
+// NewInProcess returns an in-process transport without subscribers
+func NewInProcess() *InProcess {
+	return &InProcess{subs: map[string]Handler{}}
+}
+
+// Publish runs the handler of every subscriber, failing if any of them did
+func (p *InProcess) Publish(ctx context.Context, e Event) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.subs) == 0 {
+		return errors.New("no subscribers")
+	}
+	var errs []error
+	for name, handle := range p.subs {
+		if err := handle(ctx, e); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Subscribe adds handle as name until ctx is done
+func (p *InProcess) Subscribe(ctx context.Context, name string, handle Handler) error {
+	p.mu.Lock()
+	if _, ok := p.subs[name]; ok {
+		p.mu.Unlock()
+		return fmt.Errorf("%s already subscribed", name)
+	}
+	p.subs[name] = handle
+	p.mu.Unlock()
+
+	<-ctx.Done()
+
+	p.mu.Lock()
+	delete(p.subs, name)
+	p.mu.Unlock()
+	return ctx.Err()
+}
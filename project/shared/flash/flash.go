@@ -0,0 +1,124 @@
+// Package flash passes a message from a handler to the page it redirects to,
+// e.g. "Asset added successfully". The message travels in a cookie signed
+// with HMAC-SHA256, so only the services can set one, and it is shown once:
+// reading it deletes the cookie.
+package flash
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// CookieName is the cookie holding the message
+const CookieName = "cmms_flash"
+
+// Kinds of messages
+const (
+	Success = "success"
+	Error   = "error"
+)
+
+// maxAge is how long, in seconds, a message waits for the page to show it
+const maxAge = 60
+
+// Message is a message for the next page
+type Message struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+}
+
+var (
+	mu  sync.RWMutex
+	key = randomKey()
+)
+
+// randomKey returns the key used until SetKey is called
+func randomKey() []byte {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("flash: " + err.Error())
+	}
+	return b
+}
+
+// SetKey sets the key the cookies are signed with. Processes serving the
+// pages of the same service need the same key; without a call each process
+// signs with a random key of its own.
+func SetKey(k []byte) {
+	mu.Lock()
+	defer mu.Unlock()
+	key = append([]byte(nil), k...)
+}
+
+// sign returns the signature of payload
+func sign(payload string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Set stores the message text of the kind Success or Error for the next
+// page shown to the client
+func Set(w http.ResponseWriter, kind, text string) {
+	data, err := json.Marshal(Message{Kind: kind, Text: text})
+	if err != nil {
+		return
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    payload + "." + sign(payload),
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Redirect stores the message text of the kind Success or Error and
+// redirects to url with 303 See Other
+func Redirect(w http.ResponseWriter, r *http.Request, url, kind, text string) {
+	Set(w, kind, text)
+	http.Redirect(w, r, url, http.StatusSeeOther)
+}
+
+// Get returns the message stored for this page, if any, and deletes it. A
+// cookie with a wrong signature is deleted and ignored.
+func Get(w http.ResponseWriter, r *http.Request) (Message, bool) {
+	c, err := r.Cookie(CookieName)
+	if err != nil {
+		return Message{}, false
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	payload, sig, ok := strings.Cut(c.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(sign(payload))) {
+		return Message{}, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return Message{}, false
+	}
+	var m Message
+	if err := json.Unmarshal(data, &m); err != nil || m.Text == "" {
+		return Message{}, false
+	}
+	if m.Kind != Success && m.Kind != Error {
+		return Message{}, false
+	}
+	return m, true
+}
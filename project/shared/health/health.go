@@ -0,0 +1,110 @@
+// Package health answers the probes of the monitoring: /healthz, alive as
+// long as the process serves requests, and /readyz, ready when the database
+// and the services it depends on answer.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timeout bounds each check of a readiness probe
+const timeout = 3 * time.Second
+
+// Check is one dependency of a service checked by Ready
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result is the outcome of a check as answered by Ready
+type Result struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Live answers 200 as long as the process serves requests
+func Live() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		write(w, http.StatusOK, struct {
+			Status string `json:"status"`
+		}{"ok"})
+	})
+}
+
+// Ready runs every check at once and answers 200 when all pass, 503
+// otherwise, with the outcome of each in JSON
+func Ready(checks ...Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		results := make([]Result, len(checks))
+		var wg sync.WaitGroup
+		for i, c := range checks {
+			wg.Add(1)
+			go func(i int, c Check) {
+				defer wg.Done()
+				results[i] = run(r.Context(), c)
+			}(i, c)
+		}
+		wg.Wait()
+
+		status, code := "ready", http.StatusOK
+		for _, res := range results {
+			if !res.OK {
+				status, code = "unavailable", http.StatusServiceUnavailable
+			}
+		}
+		write(w, code, struct {
+			Status string   `json:"status"`
+			Checks []Result `json:"checks"`
+		}{status, results})
+	})
+}
+
+func run(ctx context.Context, c Check) (res Result) {
+	res = Result{Name: c.Name}
+	start := time.Now()
+	defer func() { res.Latency = time.Since(start).Round(time.Millisecond).String() }()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := c.Run(ctx); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.OK = true
+	return res
+}
+
+// Ping checks the service at baseURL is alive, with its /healthz
+func Ping(client *http.Client, baseURL string) func(ctx context.Context) error {
+	url := strings.TrimSuffix(baseURL, "/") + "/healthz"
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("answered %s", resp.Status)
+		}
+		return nil
+	}
+}
+
+func write(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
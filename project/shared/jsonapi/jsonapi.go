@@ -0,0 +1,123 @@
+// Package jsonapi holds the helpers of the JSON endpoints the services serve
+// to each other: answers carry an ETag so callers can revalidate their cached
+// copy with If-None-Match, lists can be narrowed with ?ids=, and a single
+// record carries its version as ETag, sent back in If-Match by an edit.
+package jsonapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxIDs bounds the number of ids accepted by IDs
+const MaxIDs = 500
+
+// WriteJSON writes v as the JSON answer with an ETag of its content, or a 304
+// Not Modified when the caller already has that content
+func WriteJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if match(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf.Bytes())
+}
+
+// ETag returns the entity tag of the given version of a record
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ParseETag returns the version in an entity tag written by ETag; the weak
+// form W/"3" is accepted too
+func ParseETag(tag string) (int64, bool) {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || v < 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// WriteRecord writes the record v at the given version as the JSON answer,
+// with the ETag of its version, or a 304 Not Modified when the caller
+// already has that version
+func WriteRecord(w http.ResponseWriter, r *http.Request, v interface{}, version int64) {
+	etag := ETag(version)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if match(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// match reports whether the If-None-Match header lists etag
+func match(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == etag || v == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// IDs parses the comma separated ids query parameter of r. ok is false when
+// the parameter is absent, i.e. the whole list is asked for.
+func IDs(r *http.Request) (ids []primitive.ObjectID, ok bool, err error) {
+	if !r.URL.Query().Has("ids") {
+		return nil, false, nil
+	}
+	seen := map[primitive.ObjectID]bool{}
+	ids = []primitive.ObjectID{}
+	for _, v := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		id, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid id %q", v)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > MaxIDs {
+		return nil, true, fmt.Errorf("at most %d ids", MaxIDs)
+	}
+	return ids, true, nil
+}
+
+// Query returns the ids query parameter for the given ids
+func Query(ids []primitive.ObjectID) string {
+	hexes := make([]string, len(ids))
+	for i, id := range ids {
+		hexes[i] = id.Hex()
+	}
+	return "ids=" + strings.Join(hexes, ",")
+}
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Protocols of the calls between the services
+const (
+	HTTP = "http"
+	GRPC = "grpc"
+)
+
+var (
+	httpRequests = NewCounter("cmms_http_requests_total",
+		"HTTP requests served, by service, route, method and status code.",
+		"service", "route", "method", "code")
+	httpDuration = NewHistogram("cmms_http_request_duration_seconds",
+		"Time taken to serve HTTP requests, by service, route and method.",
+		DefaultBuckets, "service", "route", "method")
+	downstreamRequests = NewCounter("cmms_downstream_requests_total",
+		"Calls to the other services, by target service and protocol.",
+		"target", "protocol")
+	downstreamErrors = NewCounter("cmms_downstream_errors_total",
+		"Calls to the other services that failed or answered with a server error, by target service and protocol.",
+		"target", "protocol")
+)
+
+// unmatched is the route of requests no route of the service matches, so
+// unknown paths do not each get series of their own
+const unmatched = "unmatched"
+
+// Instrument counts and times the requests next serves for service by the
+// route the request matches, as returned by route: a pattern such as
+// /assets/{id}/edit rather than the path, or "" when none matches
+func Instrument(service string, route func(r *http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pattern := route(r)
+		if pattern == "" {
+			pattern = unmatched
+		}
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		httpRequests.Inc(service, pattern, r.Method, strconv.Itoa(sw.status))
+		httpDuration.Since(start, service, pattern, r.Method)
+	})
+}
+
+// ServeMuxRoute returns the pattern of mux a request matches, for Instrument
+func ServeMuxRoute(mux *http.ServeMux) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		return pattern
+	}
+}
+
+// statusWriter keeps the status code of the answer
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Downstream records a call to the service target over protocol, which
+// failed when err is not nil
+func Downstream(target, protocol string, err error) {
+	downstreamRequests.Inc(target, protocol)
+	if err != nil {
+		downstreamErrors.Inc(target, protocol)
+	}
+}
+
+// errServer marks an HTTP answer with a 5xx status as failed
+var errServer = errors.New("server error")
+
+// Transport returns a RoundTripper making the calls of next, http.DefaultTransport
+// when nil, to the service target and recording them with Downstream
+func Transport(target string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripper(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		failed := err
+		if err == nil && resp.StatusCode >= 500 {
+			failed = errServer
+		}
+		Downstream(target, HTTP, failed)
+		return resp, err
+	})
+}
+
+type roundTripper func(req *http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
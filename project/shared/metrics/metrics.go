@@ -0,0 +1,292 @@
+// Package metrics keeps the counters, histograms and gauges of a process and
+// serves them in the Prometheus text format, for /metrics. It measures what
+// the services have in common: the requests they serve by route and their
+// calls to each other. Other packages add metrics of their own, such as the
+// MongoDB command latencies of store and the overdue schedules gauge of the
+// maintenance service.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the latency histograms
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// gaugeTimeout bounds the work of the gauges for one scrape
+const gaugeTimeout = 5 * time.Second
+
+// metric is a family of series written by Handler
+type metric interface {
+	name() string
+	write(ctx context.Context, b *strings.Builder)
+}
+
+var (
+	mu      sync.Mutex
+	metrics = map[string]metric{}
+)
+
+// register adds m, or returns the metric of the same name registered before
+func register[M metric](m M) M {
+	mu.Lock()
+	defer mu.Unlock()
+	if old, ok := metrics[m.name()].(M); ok {
+		return old
+	}
+	metrics[m.name()] = m
+	return m
+}
+
+// key joins the label values of a series
+func key(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// labels writes the labels of a series, names=values, with extra appended
+func labels(b *strings.Builder, names, values []string, extra ...string) {
+	if len(names) == 0 && len(extra) == 0 {
+		return
+	}
+	b.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(b, "%s=%q", n, escape(values[i]))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if len(names) > 0 || i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(b, "%s=%q", extra[i], extra[i+1])
+	}
+	b.WriteByte('}')
+}
+
+// escape drops what %q would write in a form Prometheus does not read
+func escape(v string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, v)
+}
+
+// header writes the HELP and TYPE lines of a family
+func header(b *strings.Builder, name, help, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, strings.ReplaceAll(help, "\n", " "), name, kind)
+}
+
+func number(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// fixed checks the number of label values given for a series
+func fixed(name string, names, values []string) {
+	if len(names) != len(values) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", name, len(names), len(values)))
+	}
+}
+
+// Counter counts events, in series told apart by the values of its labels
+type Counter struct {
+	n, help string
+	labels  []string
+
+	mu     sync.Mutex
+	values map[string]float64
+	series map[string][]string
+}
+
+// NewCounter returns the counter name with the given labels
+func NewCounter(name, help string, labels ...string) *Counter {
+	return register(&Counter{n: name, help: help, labels: labels, values: map[string]float64{}, series: map[string][]string{}})
+}
+
+// Inc adds one to the series with the given label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v to the series with the given label values
+func (c *Counter) Add(v float64, values ...string) {
+	fixed(c.n, c.labels, values)
+	k := key(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.series[k]; !ok {
+		c.series[k] = append([]string(nil), values...)
+	}
+	c.values[k] += v
+}
+
+// Value returns the count of the series with the given label values
+func (c *Counter) Value(values ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key(values)]
+}
+
+func (c *Counter) name() string { return c.n }
+
+func (c *Counter) write(ctx context.Context, b *strings.Builder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	header(b, c.n, c.help, "counter")
+	for _, k := range sortedKeys(c.series) {
+		b.WriteString(c.n)
+		labels(b, c.labels, c.series[k])
+		b.WriteString(" " + number(c.values[k]) + "\n")
+	}
+}
+
+// Histogram counts observations, such as latencies in seconds, in buckets
+type Histogram struct {
+	n, help string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram returns the histogram name with the given buckets, upper
+// bounds in increasing order, and labels
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return register(&Histogram{n: name, help: help, labels: labels, buckets: buckets, series: map[string]*histogramSeries{}})
+}
+
+// Observe records v in the series with the given label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	fixed(h.n, h.labels, values)
+	k := key(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[k]
+	if !ok {
+		s = &histogramSeries{values: append([]string(nil), values...), counts: make([]uint64, len(h.buckets))}
+		h.series[k] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// Since records the time elapsed since start, in seconds
+func (h *Histogram) Since(start time.Time, values ...string) {
+	h.Observe(time.Since(start).Seconds(), values...)
+}
+
+// Count returns the number of observations of the series with the given
+// label values
+func (h *Histogram) Count(values ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[key(values)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) name() string { return h.n }
+
+func (h *Histogram) write(ctx context.Context, b *strings.Builder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	header(b, h.n, h.help, "histogram")
+	for _, k := range sortedKeys(h.series) {
+		s := h.series[k]
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += s.counts[i]
+			b.WriteString(h.n + "_bucket")
+			labels(b, h.labels, s.values, "le", number(le))
+			fmt.Fprintf(b, " %d\n", cumulative)
+		}
+		b.WriteString(h.n + "_bucket")
+		labels(b, h.labels, s.values, "le", "+Inf")
+		fmt.Fprintf(b, " %d\n", s.count)
+		b.WriteString(h.n + "_sum")
+		labels(b, h.labels, s.values)
+		b.WriteString(" " + number(s.sum) + "\n")
+		b.WriteString(h.n + "_count")
+		labels(b, h.labels, s.values)
+		fmt.Fprintf(b, " %d\n", s.count)
+	}
+}
+
+// gauge is a value measured by a function at every scrape
+type gauge struct {
+	n, help string
+	value   func(ctx context.Context) (float64, error)
+}
+
+// SetGauge sets the gauge name to the value fn measures when the metrics are
+// read, replacing the function set before. A gauge whose function fails is
+// left out of that scrape, and the error logged.
+func SetGauge(name, help string, fn func(ctx context.Context) (float64, error)) {
+	mu.Lock()
+	defer mu.Unlock()
+	metrics[name] = &gauge{n: name, help: help, value: fn}
+}
+
+func (g *gauge) name() string { return g.n }
+
+func (g *gauge) write(ctx context.Context, b *strings.Builder) {
+	v, err := g.value(ctx)
+	if err != nil {
+		log.Printf("metrics: %s: %v", g.n, err)
+		return
+	}
+	header(b, g.n, g.help, "gauge")
+	b.WriteString(g.n + " " + number(v) + "\n")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Handler serves every metric of the process in the Prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		all := make([]metric, 0, len(metrics))
+		for _, name := range sortedKeys(metrics) {
+			all = append(all, metrics[name])
+		}
+		mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(r.Context(), gaugeTimeout)
+		defer cancel()
+		var b strings.Builder
+		for _, m := range all {
+			m.write(ctx, &b)
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write([]byte(b.String()))
+	})
+}
@@ -0,0 +1,142 @@
+// Package references lets a service ask the maintenance service which
+// maintenances and schedules point at a record before it deletes it, and
+// have those references removed or moved to another record.
+//
+// The maintenance service owns every cross-service reference (asset_id,
+// maintenance_id and the services/consumables of schedules), so it serves
+// /api/references and /api/references/resolve; the other services use Client.
+package references
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"shared/audit"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of records that can be referenced
+const (
+	KindAsset       = "asset"
+	KindMaintenance = "maintenance"
+	KindService     = "service"
+	KindConsumable  = "consumable"
+)
+
+// Ways of resolving references before a delete
+const (
+	// ActionCascade deletes the referencing maintenances and schedules, or
+	// for services and consumables removes them from the referencing schedules
+	ActionCascade = "cascade"
+	// ActionReassign points the references at another record of the same kind
+	ActionReassign = "reassign"
+)
+
+// Reference is a live record pointing at the record about to be deleted
+type Reference struct {
+	Entity  string             `json:"entity"`
+	ID      primitive.ObjectID `json:"id"`
+	Label   string             `json:"label"`
+	AssetID primitive.ObjectID `json:"asset_id"`
+	Field   string             `json:"field"`
+	// URL is the maintenance service page showing the referencing record
+	URL string `json:"url"`
+}
+
+// Result is the response of /api/references/resolve
+type Result struct {
+	Updated int    `json:"updated"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ValidKind reports whether kind can be looked up
+func ValidKind(kind string) bool {
+	switch kind {
+	case KindAsset, KindMaintenance, KindService, KindConsumable:
+		return true
+	}
+	return false
+}
+
+// Client calls the reference API of the maintenance service
+type Client struct {
+	BaseURL string
+	HTTP    *http.Client
+}
+
+// NewClient returns a client for the maintenance service at baseURL
+func NewClient(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTP: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Find lists the live records referencing the kind record with the given id
+func (c *Client) Find(ctx context.Context, kind string, id primitive.ObjectID) ([]Reference, error) {
+	q := url.Values{"kind": {kind}, "id": {id.Hex()}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/api/references?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("maintenance service answered %s", resp.Status)
+	}
+
+	var refs []Reference
+	if err := json.NewDecoder(resp.Body).Decode(&refs); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// Resolve cascades or reassigns the references to the kind record with the
+// given id. to is the replacement record for ActionReassign. The user of the
+// original request r is passed on so the changes are audited under their name.
+func (c *Client) Resolve(ctx context.Context, r *http.Request, kind string, id primitive.ObjectID, action string, to primitive.ObjectID) (int, error) {
+	form := url.Values{"kind": {kind}, "id": {id.Hex()}, "action": {action}}
+	if action == ActionReassign {
+		form.Set("to", to.Hex())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/api/references/resolve", strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// Tells the CSRF check of the maintenance service this is no browser form
+	req.Header.Set("X-Requested-With", "cmms")
+	forwardUser(req, r)
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result Result
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("maintenance service answered %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return result.Updated, fmt.Errorf("maintenance service answered %s: %s", resp.Status, result.Error)
+	}
+	return result.Updated, nil
+}
+
+// forwardUser passes the user and address of r on to req
+func forwardUser(req, r *http.Request) {
+	if r == nil {
+		return
+	}
+	req.Header.Set("X-Remote-User", audit.Actor(r))
+	req.Header.Set("X-Forwarded-For", audit.ClientIP(r))
+}
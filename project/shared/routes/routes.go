@@ -0,0 +1,39 @@
+// Package routes knows which service owns which URL path, so the gateway and
+// the single cmms binary send every request to the same place.
+package routes
+
+import (
+	"shared/config"
+	"strings"
+)
+
+// table maps path prefixes to the service owning them. A prefix matches the
+// path itself and everything below it; paths matching none, such as
+// /maintenances, /schedules, /calendar and /trash, belong to the maintenance
+// service.
+var table = []struct {
+	prefix  string
+	service string
+}{
+	{"/assets", config.Asset},
+	{"/api/assets", config.Asset},
+	{"/kpis", config.Asset},
+	{"/api/kpis", config.Asset},
+	{"/service", config.Service},
+	{"/services", config.Service},
+	{"/consumable", config.Consumable},
+	{"/consumables", config.Consumable},
+}
+
+// Services are the services serving pages, in the order they are started
+var Services = []string{config.Asset, config.Service, config.Consumable, config.Maintenance}
+
+// ServiceFor returns the service serving path
+func ServiceFor(path string) string {
+	for _, rt := range table {
+		if path == rt.prefix || strings.HasPrefix(path, rt.prefix+"/") {
+			return rt.service
+		}
+	}
+	return config.Maintenance
+}
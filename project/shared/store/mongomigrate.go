@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MigrationsCollection records the migrations applied to a MongoDB database,
+// one document per component and version
+const MigrationsCollection = "schema_migrations"
+
+// MongoMigration is one step of the MongoDB schema of a component, run once:
+// indexes to create or documents to reshape. A migration failing half way
+// runs again on the next start, and two processes starting together may
+// both run it, so Up must be safe to repeat.
+type MongoMigration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+}
+
+// migrationRecord is the document of an applied migration
+type migrationRecord struct {
+	ID        string    `bson:"_id"`
+	Component string    `bson:"component"`
+	Version   int       `bson:"version"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+// MigrateMongo brings the schema of component in db up to date: the
+// migrations not yet recorded in MigrationsCollection run in the order of
+// their versions, each recorded once it succeeds
+func MigrateMongo(ctx context.Context, db *mongo.Database, component string, migrations []MongoMigration) error {
+	coll := db.Collection(MigrationsCollection)
+	cur, err := coll.Find(ctx, bson.M{"component": component})
+	if err != nil {
+		return fmt.Errorf("%s: %w", MigrationsCollection, err)
+	}
+	var records []migrationRecord
+	if err := cur.All(ctx, &records); err != nil {
+		return fmt.Errorf("%s: %w", MigrationsCollection, err)
+	}
+	applied := map[int]bool{}
+	for _, r := range records {
+		applied[r.Version] = true
+	}
+
+	pending := append([]MongoMigration(nil), migrations...)
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Version < pending[j].Version })
+	for _, m := range pending {
+		if applied[m.Version] {
+			continue
+		}
+		if err := m.Up(ctx, db); err != nil {
+			return fmt.Errorf("%s migration %d (%s): %w", component, m.Version, m.Name, err)
+		}
+		_, err := coll.InsertOne(ctx, migrationRecord{
+			ID:        fmt.Sprintf("%s:%d", component, m.Version),
+			Component: component,
+			Version:   m.Version,
+			Name:      m.Name,
+			AppliedAt: time.Now(),
+		})
+		// Another process may have run it at the same time
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("%s migration %d (%s): %w", component, m.Version, m.Name, err)
+		}
+		log.Printf("%s: applied migration %d (%s)", component, m.Version, m.Name)
+	}
+	return nil
+}
+
+// Indexes returns the Up of a migration creating indexes on the collection
+// coll; creating an index that exists with the same keys and options does
+// nothing
+func Indexes(coll string, indexes ...mongo.IndexModel) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		if _, err := db.Collection(coll).Indexes().CreateMany(ctx, indexes); err != nil {
+			return fmt.Errorf("%s: %w", coll, err)
+		}
+		return nil
+	}
+}
+
+// Steps returns the Up of a migration running ups in order
+func Steps(ups ...func(ctx context.Context, db *mongo.Database) error) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for _, up := range ups {
+			if err := up(ctx, db); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Keys returns the keys of an index on fields, ascending
+func Keys(fields ...string) bson.D {
+	keys := bson.D{}
+	for _, f := range fields {
+		keys = append(keys, bson.E{Key: f, Value: 1})
+	}
+	return keys
+}
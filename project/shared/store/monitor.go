@@ -0,0 +1,25 @@
+package store
+
+import (
+	"context"
+	"shared/metrics"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var mongoDuration = metrics.NewHistogram("cmms_mongo_command_duration_seconds",
+	"Time taken by the commands sent to MongoDB, by command and outcome (ok or error).",
+	metrics.DefaultBuckets, "command", "outcome")
+
+// MongoMonitor returns the command monitor timing every command a MongoDB
+// client sends in the metrics, for options.Client().SetMonitor
+func MongoMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			mongoDuration.Observe(e.Duration.Seconds(), e.CommandName, "ok")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			mongoDuration.Observe(e.Duration.Seconds(), e.CommandName, "error")
+		},
+	}
+}
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/mattn/go-sqlite3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SQL drivers, the values of storage.driver besides mongo
+const (
+	SQLite   = "sqlite"
+	Postgres = "postgres"
+)
+
+// SQL is a SQLite or PostgreSQL database. Queries are written with ?
+// placeholders, which are rewritten for PostgreSQL. It runs the writes of a
+// change together with Atomically, as a Tx.
+type SQL struct {
+	DB     *sql.DB
+	Driver string
+}
+
+// OpenSQL connects to the database of driver at dsn: a file name for
+// SQLite, a connection URL for PostgreSQL
+func OpenSQL(ctx context.Context, driver, dsn string) (*SQL, error) {
+	var (
+		db  *sql.DB
+		err error
+	)
+	switch driver {
+	case SQLite:
+		db, err = sql.Open("sqlite3", dsn+sqliteOptions(dsn))
+		if err == nil {
+			// SQLite takes one writer at a time; a single connection queues
+			// the writers of this process instead of failing them with
+			// SQLITE_BUSY
+			db.SetMaxOpenConns(1)
+		}
+	case Postgres:
+		db, err = sql.Open("pgx", dsn)
+	default:
+		return nil, fmt.Errorf("unknown SQL driver %q", driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s %s: %w", driver, dsn, err)
+	}
+	return &SQL{DB: db, Driver: driver}, nil
+}
+
+// sqliteOptions returns the connection options appended to a SQLite file
+// name: foreign keys on, a write-ahead log and a wait for other processes
+// holding the lock
+func sqliteOptions(dsn string) string {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return sep + "_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000"
+}
+
+// Close closes the database
+func (s *SQL) Close() error {
+	return s.DB.Close()
+}
+
+// rebind rewrites the ? placeholders of query to $1, $2... for PostgreSQL
+func (s *SQL) rebind(query string) string {
+	if s.Driver != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// querier is what *sql.DB and *sql.Tx have in common
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type sqlTxKey struct{}
+
+// conn returns the transaction of ctx, or the database outside one
+func (s *SQL) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(sqlTxKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return s.DB
+}
+
+// Exec runs a statement, in the transaction of ctx if there is one
+func (s *SQL) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return s.conn(ctx).ExecContext(ctx, s.rebind(query), args...)
+}
+
+// Query runs a query, in the transaction of ctx if there is one
+func (s *SQL) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return s.conn(ctx).QueryContext(ctx, s.rebind(query), args...)
+}
+
+// QueryRow runs a query returning at most one row, in the transaction of ctx
+// if there is one
+func (s *SQL) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return s.conn(ctx).QueryRowContext(ctx, s.rebind(query), args...)
+}
+
+// Atomically runs fn in a transaction, committed when fn succeeds. Called
+// within a transaction, fn joins it.
+func (s *SQL) Atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(sqlTxKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(context.WithValue(ctx, sqlTxKey{}, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Migration is one step of the schema of a component, run once
+type Migration struct {
+	Version    int
+	Name       string
+	Statements []string
+}
+
+// Migrate brings the schema of component up to date: the migrations not
+// yet applied run in the order of their versions, each in a transaction
+// with its record in schema_migrations
+func (s *SQL) Migrate(ctx context.Context, component string, migrations []Migration) error {
+	if _, err := s.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		component TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		applied_at BIGINT NOT NULL,
+		PRIMARY KEY (component, version)
+	)`); err != nil {
+		return fmt.Errorf("schema_migrations: %w", err)
+	}
+
+	applied := map[int]bool{}
+	rows, err := s.Query(ctx, "SELECT version FROM schema_migrations WHERE component = ?", component)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[v] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	pending := append([]Migration(nil), migrations...)
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Version < pending[j].Version })
+	for _, m := range pending {
+		if applied[m.Version] {
+			continue
+		}
+		err := s.Atomically(ctx, func(ctx context.Context) error {
+			for _, stmt := range m.Statements {
+				if _, err := s.Exec(ctx, stmt); err != nil {
+					return err
+				}
+			}
+			_, err := s.Exec(ctx, "INSERT INTO schema_migrations (component, version, name, applied_at) VALUES (?, ?, ?, ?)",
+				component, m.Version, m.Name, Millis(time.Now()))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("%s migration %d (%s): %w", component, m.Version, m.Name, err)
+		}
+		log.Printf("%s: applied migration %d (%s)", component, m.Version, m.Name)
+	}
+	return nil
+}
+
+// Millis returns t as the milliseconds since the Unix epoch, the way the SQL
+// tables keep times
+func Millis(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+// In returns the condition "column IN (?, ...)" for n values; with none it
+// matches nothing
+func In(column string, n int) string {
+	if n == 0 {
+		return "1 = 0"
+	}
+	return column + " IN (?" + strings.Repeat(", ?", n-1) + ")"
+}
+
+// MarshalDoc returns v as the relaxed extended JSON of its BSON encoding,
+// the document a SQL table keeps, so records look the same as in MongoDB
+func MarshalDoc(v interface{}) (string, error) {
+	data, err := bson.MarshalExtJSON(v, false, false)
+	return string(data), err
+}
+
+// UnmarshalDoc decodes a document written by MarshalDoc into v
+func UnmarshalDoc(doc string, v interface{}) error {
+	return bson.UnmarshalExtJSON([]byte(doc), false, v)
+}
+
+// Hexes returns ids as the arguments of a query on an id column
+func Hexes(ids []primitive.ObjectID) []interface{} {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id.Hex()
+	}
+	return args
+}
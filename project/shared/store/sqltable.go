@@ -0,0 +1,264 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Column is a field of a record copied into a column of its SQL table, so
+// queries can filter on it
+type Column[T any] struct {
+	Name  string
+	Value func(rec *T) interface{}
+}
+
+// SQLTable keeps records of type T in a table of a SQL database: the id, the
+// record as a document (see MarshalDoc), the recycle bin fields of package
+// trash and the given columns. Its schema, created by a migration of the
+// service, is
+//
+//	id TEXT PRIMARY KEY, doc TEXT NOT NULL, deleted_at BIGINT,
+//	deleted_by TEXT NOT NULL DEFAULT '', <columns>
+//
+// Records without a recycle bin return nil for deletedAt and deletedBy from
+// their Fields; they are always live.
+type SQLTable[T any] struct {
+	db      *SQL
+	name    string
+	fields  Fields[T]
+	columns []Column[T]
+}
+
+// NewSQLTable returns the table name of db, keeping records with the given
+// fields and columns
+func NewSQLTable[T any](db *SQL, name string, fields Fields[T], columns ...Column[T]) *SQLTable[T] {
+	return &SQLTable[T]{db: db, name: name, fields: fields, columns: columns}
+}
+
+// Select returns the live records matching where, a condition on the
+// columns with ? placeholders for args, in the order of their ids; an empty
+// where matches all
+func (t *SQLTable[T]) Select(ctx context.Context, where string, args ...interface{}) ([]T, error) {
+	cond := "deleted_at IS NULL"
+	if where != "" {
+		cond += " AND (" + where + ")"
+	}
+	return t.query(ctx, "SELECT doc FROM "+t.name+" WHERE "+cond+" ORDER BY id", args...)
+}
+
+// Deleted returns the records in the recycle bin, most recently deleted first
+func (t *SQLTable[T]) Deleted(ctx context.Context) ([]T, error) {
+	return t.query(ctx, "SELECT doc FROM "+t.name+" WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC")
+}
+
+func (t *SQLTable[T]) query(ctx context.Context, query string, args ...interface{}) ([]T, error) {
+	rows, err := t.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []T{}
+	for rows.Next() {
+		var doc string
+		if err := rows.Scan(&doc); err != nil {
+			return nil, err
+		}
+		var rec T
+		if err := UnmarshalDoc(doc, &rec); err != nil {
+			return nil, fmt.Errorf("%s: %w", t.name, err)
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// Get returns the live record with the given id
+func (t *SQLTable[T]) Get(ctx context.Context, id primitive.ObjectID) (T, error) {
+	return t.one(ctx, "id = ? AND deleted_at IS NULL", id.Hex())
+}
+
+// GetDeleted returns the record with the given id from the recycle bin
+func (t *SQLTable[T]) GetDeleted(ctx context.Context, id primitive.ObjectID) (T, error) {
+	return t.one(ctx, "id = ? AND deleted_at IS NOT NULL", id.Hex())
+}
+
+// GetAny returns the record with the given id, live or deleted
+func (t *SQLTable[T]) GetAny(ctx context.Context, id primitive.ObjectID) (T, error) {
+	return t.one(ctx, "id = ?", id.Hex())
+}
+
+func (t *SQLTable[T]) one(ctx context.Context, where string, args ...interface{}) (T, error) {
+	var rec T
+	var doc string
+	err := t.db.QueryRow(ctx, "SELECT doc FROM "+t.name+" WHERE "+where+t.lock(ctx), args...).Scan(&doc)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rec, ErrNotFound
+	}
+	if err != nil {
+		return rec, err
+	}
+	return rec, UnmarshalDoc(doc, &rec)
+}
+
+// lock returns the clause locking the row read in a transaction until it
+// ends, for PostgreSQL; SQLite has one writer at a time
+func (t *SQLTable[T]) lock(ctx context.Context) string {
+	if _, ok := ctx.Value(sqlTxKey{}).(*sql.Tx); ok && t.db.Driver == Postgres {
+		return " FOR UPDATE"
+	}
+	return ""
+}
+
+// row returns the column names and values of rec
+func (t *SQLTable[T]) row(rec *T) ([]string, []interface{}, error) {
+	doc, err := MarshalDoc(rec)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, at, by := t.fields(rec)
+	var deletedAt interface{}
+	deletedBy := ""
+	if at != nil && *at != nil {
+		deletedAt = Millis(**at)
+	}
+	if by != nil {
+		deletedBy = *by
+	}
+
+	names := []string{"id", "doc", "deleted_at", "deleted_by"}
+	values := []interface{}{id.Hex(), doc, deletedAt, deletedBy}
+	for _, c := range t.columns {
+		names = append(names, c.Name)
+		values = append(values, c.Value(rec))
+	}
+	return names, values, nil
+}
+
+// Insert adds rec, failing when a record with its id exists
+func (t *SQLTable[T]) Insert(ctx context.Context, rec T) error {
+	names, values, err := t.row(&rec)
+	if err != nil {
+		return err
+	}
+	_, err = t.db.Exec(ctx, "INSERT INTO "+t.name+" ("+strings.Join(names, ", ")+") VALUES (?"+strings.Repeat(", ?", len(names)-1)+")", values...)
+	return err
+}
+
+// Save writes rec, replacing the record with its id, live or deleted
+func (t *SQLTable[T]) Save(ctx context.Context, rec T) error {
+	names, values, err := t.row(&rec)
+	if err != nil {
+		return err
+	}
+	var set []string
+	for _, n := range names[1:] {
+		set = append(set, n+" = excluded."+n)
+	}
+	_, err = t.db.Exec(ctx, "INSERT INTO "+t.name+" ("+strings.Join(names, ", ")+") VALUES (?"+strings.Repeat(", ?", len(names)-1)+
+		") ON CONFLICT (id) DO UPDATE SET "+strings.Join(set, ", "), values...)
+	return err
+}
+
+// write saves rec over its existing row
+func (t *SQLTable[T]) write(ctx context.Context, rec *T) error {
+	names, values, err := t.row(rec)
+	if err != nil {
+		return err
+	}
+	var set []string
+	for _, n := range names[1:] {
+		set = append(set, n+" = ?")
+	}
+	_, err = t.db.Exec(ctx, "UPDATE "+t.name+" SET "+strings.Join(set, ", ")+" WHERE id = ?", append(values[1:], values[0])...)
+	return err
+}
+
+// Update applies change to the live record with the given id and returns it
+// as changed
+func (t *SQLTable[T]) Update(ctx context.Context, id primitive.ObjectID, change func(rec *T)) (T, error) {
+	var rec T
+	err := t.db.Atomically(ctx, func(ctx context.Context) error {
+		var err error
+		if rec, err = t.Get(ctx, id); err != nil {
+			return err
+		}
+		change(&rec)
+		return t.write(ctx, &rec)
+	})
+	return rec, err
+}
+
+// Delete moves the live record with the given id to the recycle bin
+func (t *SQLTable[T]) Delete(ctx context.Context, id primitive.ObjectID, actor string) error {
+	now := time.Now()
+	_, err := t.Update(ctx, id, func(rec *T) {
+		_, at, by := t.fields(rec)
+		*at, *by = &now, actor
+	})
+	return err
+}
+
+// Restore takes the record with the given id out of the recycle bin
+func (t *SQLTable[T]) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return t.db.Atomically(ctx, func(ctx context.Context) error {
+		rec, err := t.GetDeleted(ctx, id)
+		if err != nil {
+			return err
+		}
+		_, at, by := t.fields(&rec)
+		*at, *by = nil, ""
+		return t.write(ctx, &rec)
+	})
+}
+
+// Purge removes the record with the given id from the recycle bin for good
+func (t *SQLTable[T]) Purge(ctx context.Context, id primitive.ObjectID) error {
+	res, err := t.db.Exec(ctx, "DELETE FROM "+t.name+" WHERE id = ? AND deleted_at IS NOT NULL", id.Hex())
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// PurgeExpired removes the records deleted more than retention ago
+func (t *SQLTable[T]) PurgeExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	res, err := t.db.Exec(ctx, "DELETE FROM "+t.name+" WHERE deleted_at < ?", Millis(time.Now().Add(-retention)))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// CopyCollection saves every document of coll, live or deleted, into t,
+// replacing the rows with the same ids, and returns how many it copied
+func CopyCollection[T any](ctx context.Context, coll *mongo.Collection, t *SQLTable[T]) (int, error) {
+	cur, err := coll.Find(ctx, map[string]interface{}{})
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	n := 0
+	for cur.Next(ctx) {
+		var rec T
+		if err := cur.Decode(&rec); err != nil {
+			return n, fmt.Errorf("%s: %w", coll.Name(), err)
+		}
+		if err := t.Save(ctx, rec); err != nil {
+			return n, fmt.Errorf("%s: %w", t.name, err)
+		}
+		n++
+	}
+	return n, cur.Err()
+}
@@ -0,0 +1,104 @@
+// Package store holds what the repositories of the services have in common:
+// the errors for a missing record and a stale edit, the versioned updates
+// rejecting those edits, the transactions grouping the writes of a change,
+// the migrations of MongoDB, the SQL database of the sqlite and postgres
+// storage drivers with its migrations and tables, a table with a recycle bin
+// for the in-memory repositories used in tests, and the MongoDB command
+// monitor of the metrics.
+package store
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotFound is returned by the repositories for a record that does not
+// exist, or is not in the state asked for (live or in the recycle bin)
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is returned by the repositories for an update based on a
+// version of the record that is no longer the latest: someone else saved a
+// change in between
+var ErrConflict = errors.New("changed by someone else")
+
+// Backend is the database the services keep their data in: a MongoDB
+// client, or a SQL database when storage.driver is sqlite or postgres
+type Backend struct {
+	Mongo *mongo.Client
+	SQL   *SQL
+}
+
+// Ping checks the database answers, for the readiness of the services
+func (b Backend) Ping(ctx context.Context) error {
+	if b.SQL != nil {
+		return b.SQL.DB.PingContext(ctx)
+	}
+	return b.Mongo.Ping(ctx, nil)
+}
+
+// MongoErr turns the errors of the MongoDB driver for a missing document
+// into ErrNotFound
+func MongoErr(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Tx runs fn so the writes it makes through ctx are saved together or not at
+// all, e.g. a change and the domain event recorded for it
+type Tx interface {
+	Atomically(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// MongoTx runs transactions on a MongoDB client
+type MongoTx struct {
+	Client *mongo.Client
+}
+
+// NewMongoTx returns the transactions of client
+func NewMongoTx(client *mongo.Client) MongoTx {
+	return MongoTx{Client: client}
+}
+
+// Atomically runs fn in a transaction. A standalone MongoDB server has no
+// transactions; there fn runs as it is, and a write may be saved without
+// the others if the process stops in between. Called within a transaction,
+// fn joins it.
+func (t MongoTx) Atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	session, err := t.Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	if noTransactions(err) {
+		return fn(ctx)
+	}
+	return err
+}
+
+// noTransactions reports whether err says the server does not support
+// transactions (IllegalOperation on a standalone server)
+func noTransactions(err error) bool {
+	var se mongo.ServerError
+	return errors.As(err, &se) && se.HasErrorCode(20)
+}
+
+// MemoryTx runs fn as it is, for in-memory repositories; writes made before
+// a failure are kept
+type MemoryTx struct{}
+
+// Atomically runs fn
+func (MemoryTx) Atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
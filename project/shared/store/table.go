@@ -0,0 +1,186 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Fields gives a Table the id and recycle bin fields of a record
+type Fields[T any] func(rec *T) (id *primitive.ObjectID, deletedAt **time.Time, deletedBy *string)
+
+// Table keeps records of type T in memory, in the order they were inserted,
+// with the soft deletes of package trash: a deleted record stays in the
+// recycle bin until it is restored or purged. It is safe for concurrent use.
+type Table[T any] struct {
+	mu     sync.RWMutex
+	rows   []T
+	fields Fields[T]
+}
+
+// NewTable returns an empty table of records with the given fields
+func NewTable[T any](fields Fields[T]) *Table[T] {
+	return &Table[T]{fields: fields}
+}
+
+func (t *Table[T]) id(rec *T) primitive.ObjectID {
+	id, _, _ := t.fields(rec)
+	return *id
+}
+
+func (t *Table[T]) deleted(rec *T) bool {
+	_, at, _ := t.fields(rec)
+	return *at != nil
+}
+
+// find returns the index of the record with the given id, or -1
+func (t *Table[T]) find(id primitive.ObjectID) int {
+	for i := range t.rows {
+		if t.id(&t.rows[i]) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Live returns the live records match accepts; a nil match accepts all
+func (t *Table[T]) Live(match func(T) bool) []T {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	out := []T{}
+	for i := range t.rows {
+		if !t.deleted(&t.rows[i]) && (match == nil || match(t.rows[i])) {
+			out = append(out, t.rows[i])
+		}
+	}
+	return out
+}
+
+// Deleted returns the records in the recycle bin, most recently deleted first
+func (t *Table[T]) Deleted() []T {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	out := []T{}
+	for i := range t.rows {
+		if t.deleted(&t.rows[i]) {
+			out = append(out, t.rows[i])
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		_, a, _ := t.fields(&out[i])
+		_, b, _ := t.fields(&out[j])
+		return (*a).After(**b)
+	})
+	return out
+}
+
+// Get returns the live record with the given id
+func (t *Table[T]) Get(id primitive.ObjectID) (T, error) {
+	return t.get(id, false)
+}
+
+// GetDeleted returns the record with the given id from the recycle bin
+func (t *Table[T]) GetDeleted(id primitive.ObjectID) (T, error) {
+	return t.get(id, true)
+}
+
+// GetAny returns the record with the given id, live or deleted
+func (t *Table[T]) GetAny(id primitive.ObjectID) (T, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if i := t.find(id); i >= 0 {
+		return t.rows[i], nil
+	}
+	var zero T
+	return zero, ErrNotFound
+}
+
+func (t *Table[T]) get(id primitive.ObjectID, deleted bool) (T, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if i := t.find(id); i >= 0 && t.deleted(&t.rows[i]) == deleted {
+		return t.rows[i], nil
+	}
+	var zero T
+	return zero, ErrNotFound
+}
+
+// Insert adds rec, failing when a record with its id exists
+func (t *Table[T]) Insert(rec T) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.find(t.id(&rec)) >= 0 {
+		return fmt.Errorf("duplicate id %s", t.id(&rec).Hex())
+	}
+	t.rows = append(t.rows, rec)
+	return nil
+}
+
+// Update applies change to the live record with the given id and returns it
+// as changed
+func (t *Table[T]) Update(id primitive.ObjectID, change func(rec *T)) (T, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i := t.find(id)
+	if i < 0 || t.deleted(&t.rows[i]) {
+		var zero T
+		return zero, ErrNotFound
+	}
+	change(&t.rows[i])
+	return t.rows[i], nil
+}
+
+// Delete moves the live record with the given id to the recycle bin
+func (t *Table[T]) Delete(id primitive.ObjectID, actor string) error {
+	now := time.Now()
+	_, err := t.Update(id, func(rec *T) {
+		_, at, by := t.fields(rec)
+		*at, *by = &now, actor
+	})
+	return err
+}
+
+// Restore takes the record with the given id out of the recycle bin
+func (t *Table[T]) Restore(id primitive.ObjectID) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i := t.find(id)
+	if i < 0 || !t.deleted(&t.rows[i]) {
+		return ErrNotFound
+	}
+	_, at, by := t.fields(&t.rows[i])
+	*at, *by = nil, ""
+	return nil
+}
+
+// Purge removes the record with the given id from the recycle bin for good
+func (t *Table[T]) Purge(id primitive.ObjectID) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i := t.find(id)
+	if i < 0 || !t.deleted(&t.rows[i]) {
+		return ErrNotFound
+	}
+	t.rows = append(t.rows[:i], t.rows[i+1:]...)
+	return nil
+}
+
+// PurgeExpired removes the records deleted more than retention ago
+func (t *Table[T]) PurgeExpired(retention time.Duration) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cutoff := time.Now().Add(-retention)
+	kept, purged := t.rows[:0], int64(0)
+	for _, rec := range t.rows {
+		if _, at, _ := t.fields(&rec); *at != nil && (*at).Before(cutoff) {
+			purged++
+			continue
+		}
+		kept = append(kept, rec)
+	}
+	t.rows = kept
+	return purged
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FieldVersion is the field of a record counting the changes saved to it.
+// Records saved before versions were kept have none and are at version 0.
+const FieldVersion = "version"
+
+// Version gives the versioned updates of a table the version field of a
+// record
+type Version[T any] func(rec *T) *int64
+
+// UpdateVersion applies change to the live record with the given id when it
+// is still at version, moves it to the next version and returns it as
+// changed. It returns ErrConflict when the record is at another version.
+func (t *Table[T]) UpdateVersion(id primitive.ObjectID, version int64, field Version[T], change func(rec *T)) (T, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var zero T
+	i := t.find(id)
+	if i < 0 || t.deleted(&t.rows[i]) {
+		return zero, ErrNotFound
+	}
+	if *field(&t.rows[i]) != version {
+		return zero, ErrConflict
+	}
+	rec := t.rows[i]
+	change(&rec)
+	*field(&rec) = version + 1
+	t.rows[i] = rec
+	return rec, nil
+}
+
+// UpdateVersion applies change to the live record with the given id when it
+// is still at version, moves it to the next version and returns it as
+// changed. It returns ErrConflict when the record is at another version.
+func (t *SQLTable[T]) UpdateVersion(ctx context.Context, id primitive.ObjectID, version int64, field Version[T], change func(rec *T)) (T, error) {
+	var rec T
+	err := t.db.Atomically(ctx, func(ctx context.Context) error {
+		var err error
+		if rec, err = t.Get(ctx, id); err != nil {
+			return err
+		}
+		if *field(&rec) != version {
+			return ErrConflict
+		}
+		change(&rec)
+		*field(&rec) = version + 1
+		return t.write(ctx, &rec)
+	})
+	return rec, err
+}
+
+// UpdateMongoVersion sets the fields in set on the document of coll matching
+// filter when it is still at version, and moves it to the next version. It
+// returns ErrConflict when the document is at another version and
+// ErrNotFound when none matches filter.
+func UpdateMongoVersion(ctx context.Context, coll *mongo.Collection, filter bson.M, version int64, set bson.M) error {
+	versioned := bson.M{FieldVersion: version}
+	if version == 0 {
+		// null also matches documents saved before versions were kept
+		versioned[FieldVersion] = bson.M{"$in": bson.A{int64(0), nil}}
+	}
+	for k, v := range filter {
+		versioned[k] = v
+	}
+	res, err := coll.UpdateOne(ctx, versioned, bson.M{"$set": set, "$inc": bson.M{FieldVersion: int64(1)}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount > 0 {
+		return nil
+	}
+	n, err := coll.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrConflict
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,162 @@
+// Package trash implements soft deletes. A deleted document keeps its data
+// and gets deleted_at/deleted_by set; it stays in the recycle bin until it is
+// restored, purged by hand or purged automatically after the retention period.
+package trash
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Fields set on deleted documents
+const (
+	FieldDeletedAt = "deleted_at"
+	FieldDeletedBy = "deleted_by"
+)
+
+// DefaultRetention is how long deleted documents are kept until
+// SetRetention is called
+const DefaultRetention = 30 * 24 * time.Hour
+
+var retention atomic.Int64
+
+// SetRetention sets the retention period, trash.retention_days of the
+// configuration
+func SetRetention(d time.Duration) {
+	retention.Store(int64(d))
+}
+
+// Retention returns the retention period
+func Retention() time.Duration {
+	if d := retention.Load(); d > 0 {
+		return time.Duration(d)
+	}
+	return DefaultRetention
+}
+
+// Live restricts filter to documents that are not deleted. A nil filter
+// matches every live document.
+func Live(filter bson.M) bson.M {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	// null also matches documents that never had the field
+	filter[FieldDeletedAt] = nil
+	return filter
+}
+
+// Deleted restricts filter to documents in the recycle bin
+func Deleted(filter bson.M) bson.M {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	filter[FieldDeletedAt] = bson.M{"$ne": nil}
+	return filter
+}
+
+// Delete moves a live document to the recycle bin
+func Delete(ctx context.Context, coll *mongo.Collection, id primitive.ObjectID, actor string) error {
+	res, err := coll.UpdateOne(ctx,
+		Live(bson.M{"_id": id}),
+		bson.M{"$set": bson.M{FieldDeletedAt: time.Now(), FieldDeletedBy: actor}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// Restore takes a document out of the recycle bin
+func Restore(ctx context.Context, coll *mongo.Collection, id primitive.ObjectID) error {
+	res, err := coll.UpdateOne(ctx,
+		Deleted(bson.M{"_id": id}),
+		bson.M{"$unset": bson.M{FieldDeletedAt: "", FieldDeletedBy: ""}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// Purge permanently removes a document from the recycle bin
+func Purge(ctx context.Context, coll *mongo.Collection, id primitive.ObjectID) error {
+	res, err := coll.DeleteOne(ctx, Deleted(bson.M{"_id": id}))
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// List decodes the documents in the recycle bin of coll into result,
+// most recently deleted first
+func List(ctx context.Context, coll *mongo.Collection, result interface{}) error {
+	cur, err := coll.Find(ctx, Deleted(nil), options.Find().SetSort(bson.M{FieldDeletedAt: -1}))
+	if err != nil {
+		return err
+	}
+	return cur.All(ctx, result)
+}
+
+// PurgeExpired removes the documents deleted more than retention ago
+func PurgeExpired(ctx context.Context, coll *mongo.Collection, retention time.Duration) (int64, error) {
+	res, err := coll.DeleteMany(ctx, bson.M{FieldDeletedAt: bson.M{"$lt": time.Now().Add(-retention)}})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// Purger purges the records deleted more than retention ago, e.g. a
+// repository of records with a recycle bin
+type Purger interface {
+	PurgeExpired(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// StartPurger purges expired records from every purger, named in the log by
+// its key, every hour until ctx is done
+func StartPurger(ctx context.Context, retention time.Duration, purgers map[string]Purger) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			for name, p := range purgers {
+				n, err := p.PurgeExpired(ctx, retention)
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Printf("trash: purging %s: %v", name, err)
+					}
+					continue
+				}
+				if n > 0 {
+					log.Printf("trash: purged %d expired document(s) from %s", n, name)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// PurgeDate returns when a document deleted at deletedAt will be purged
+func PurgeDate(deletedAt time.Time, retention time.Duration) time.Time {
+	return deletedAt.Add(retention)
+}
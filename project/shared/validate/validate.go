@@ -0,0 +1,342 @@
+// Package validate checks the input of the forms and API calls of the
+// services field by field. A Form collects what is wrong with each field in
+// Errors; a page shows the form again with the errors next to the fields
+// and the input kept, and an API call gets the same errors as JSON.
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"shared/jsonapi"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Errors holds what is wrong with the input, one message per field; a
+// problem with the input as a whole is under the empty field name
+type Errors map[string]string
+
+// Add records message for field, unless the field already has one
+func (e Errors) Add(field, message string) {
+	if _, ok := e[field]; !ok {
+		e[field] = message
+	}
+}
+
+// Error lists the fields and their messages in the order of the fields
+func (e Errors) Error() string {
+	fields := make([]string, 0, len(e))
+	for f := range e {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	msgs := make([]string, len(fields))
+	for i, f := range fields {
+		msgs[i] = e[f]
+		if f != "" {
+			msgs[i] = f + ": " + e[f]
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Form is the input of a form or API call being checked. Name tells the
+// forms of a page apart, e.g. "create" or the id of the record an edit form
+// is for, so the page can open the right one again.
+type Form struct {
+	Name   string
+	Values url.Values
+	Errors Errors
+}
+
+// Parse reads the input of r, a JSON object when it is sent as
+// application/json and the form or query otherwise, as the form name
+func Parse(r *http.Request, name string) (*Form, error) {
+	f := &Form{Name: name, Values: url.Values{}, Errors: Errors{}}
+	if !isJSON(r.Header.Get("Content-Type")) {
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+		f.Values = r.Form
+		return f, nil
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	for k, v := range body {
+		if list, ok := v.([]interface{}); ok {
+			for _, item := range list {
+				f.Values.Add(k, jsonString(item))
+			}
+			continue
+		}
+		f.Values.Set(k, jsonString(v))
+	}
+	// The query names the record, as for forms
+	for k, v := range r.URL.Query() {
+		if !f.Values.Has(k) {
+			f.Values[k] = v
+		}
+	}
+	return f, nil
+}
+
+// jsonString returns a JSON value as the text a form would have sent
+func jsonString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+func isJSON(contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	return mt == "application/json"
+}
+
+// WantsJSON reports whether the caller of r takes JSON answers: it sent
+// JSON or asked for it in Accept
+func WantsJSON(r *http.Request) bool {
+	return isJSON(r.Header.Get("Content-Type")) || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// WriteErrors answers an API call with 422 Unprocessable Entity and
+// {"errors": {"field": "message", ...}}
+func WriteErrors(w http.ResponseWriter, errs Errors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(struct {
+		Errors Errors `json:"errors"`
+	}{errs})
+}
+
+// Valid reports whether no check failed
+func (f *Form) Valid() bool {
+	return len(f.Errors) == 0
+}
+
+// Get returns the value of field without surrounding spaces
+func (f *Form) Get(field string) string {
+	if f == nil {
+		return ""
+	}
+	return strings.TrimSpace(f.Values.Get(field))
+}
+
+// Check records message for field when ok is false
+func (f *Form) Check(ok bool, field, message string) {
+	if !ok {
+		f.Errors.Add(field, message)
+	}
+}
+
+// Required checks that the fields are not blank
+func (f *Form) Required(fields ...string) {
+	for _, field := range fields {
+		f.Check(f.Get(field) != "", field, "This field is required")
+	}
+}
+
+// MaxLength checks that field has at most n characters
+func (f *Form) MaxLength(field string, n int) {
+	f.Check(utf8.RuneCountInString(f.Get(field)) <= n, field, fmt.Sprintf("At most %d characters", n))
+}
+
+// Int returns field as a whole number from min to max; it is required
+func (f *Form) Int(field string, min, max int) int {
+	v := f.Get(field)
+	if v == "" {
+		f.Errors.Add(field, "This field is required")
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		f.Errors.Add(field, fmt.Sprintf("Enter a whole number from %d to %d", min, max))
+		return 0
+	}
+	return n
+}
+
+// Version returns the version of the record an edit is based on: the
+// If-Match header of an API call, an ETag of package jsonapi, or else the
+// version field the edit forms carry. It is required, so an edit cannot
+// overwrite changes it has not seen.
+func (f *Form) Version(r *http.Request) int64 {
+	if tag := r.Header.Get("If-Match"); tag != "" {
+		v, ok := jsonapi.ParseETag(tag)
+		f.Check(ok, "version", "If-Match must be the ETag of the record")
+		return v
+	}
+	v := f.Get("version")
+	if v == "" {
+		f.Errors.Add("version", "The version of the record is missing: reload the page and edit it again")
+		return 0
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		f.Errors.Add("version", "Enter the version of the record")
+		return 0
+	}
+	return n
+}
+
+// OneOf returns field, which must be one of allowed
+func (f *Form) OneOf(field string, allowed ...string) string {
+	v := f.Get(field)
+	for _, a := range allowed {
+		if v == a {
+			return v
+		}
+	}
+	f.Errors.Add(field, "Choose one of "+strings.Join(allowed, ", "))
+	return ""
+}
+
+// ID returns field as a record id; it is required
+func (f *Form) ID(field string) primitive.ObjectID {
+	id, err := primitive.ObjectIDFromHex(f.Get(field))
+	if err != nil {
+		f.Errors.Add(field, "Choose a valid record")
+	}
+	return id
+}
+
+// OptionalID returns field as a record id, or nil when it is blank
+func (f *Form) OptionalID(field string) *primitive.ObjectID {
+	if f.Get(field) == "" {
+		return nil
+	}
+	id := f.ID(field)
+	return &id
+}
+
+// IDs returns every value of field as record ids, skipping blank ones
+func (f *Form) IDs(field string) []primitive.ObjectID {
+	ids := []primitive.ObjectID{}
+	for _, v := range f.Values[field] {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		id, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			f.Errors.Add(field, fmt.Sprintf("%q is not a valid record", v))
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Time returns field parsed with layout, e.g. "2006-01-02" for a date
+// input; it is required
+func (f *Form) Time(field, layout string) time.Time {
+	v := f.Get(field)
+	if v == "" {
+		f.Errors.Add(field, "This field is required")
+		return time.Time{}
+	}
+	t, err := time.Parse(layout, v)
+	if err != nil {
+		f.Errors.Add(field, "Enter a date as "+layoutHint(layout))
+	}
+	return t
+}
+
+// OptionalTime returns field parsed with layout, or nil when it is blank
+func (f *Form) OptionalTime(field, layout string) *time.Time {
+	if f.Get(field) == "" {
+		return nil
+	}
+	t := f.Time(field, layout)
+	return &t
+}
+
+// layoutHint shows a time layout the way users type it
+func layoutHint(layout string) string {
+	return strings.NewReplacer("2006", "YYYY", "01", "MM", "02", "DD", "15", "hh", "04", "mm").Replace(layout)
+}
+
+// Email returns field, which must be an email address; it is required
+func (f *Form) Email(field string) string {
+	v := f.Get(field)
+	if v == "" {
+		f.Errors.Add(field, "This field is required")
+		return ""
+	}
+	if a, err := mail.ParseAddress(v); err != nil || a.Address != v {
+		f.Errors.Add(field, "Enter an email address such as name@example.com")
+	}
+	return v
+}
+
+// URL returns field, which must be an absolute http or https URL; it is
+// required
+func (f *Form) URL(field string) string {
+	v := f.Get(field)
+	if v == "" {
+		f.Errors.Add(field, "This field is required")
+		return ""
+	}
+	if u, err := url.Parse(v); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		f.Errors.Add(field, "Enter an http or https URL")
+	}
+	return v
+}
+
+// For reports whether f is the input of the form name, e.g. for a page to
+// open that form again
+func (f *Form) For(name string) bool {
+	return f != nil && f.Name == name
+}
+
+// Value returns the input of field when f is the input of the form name and
+// fallback, what the form shows otherwise, when it is not
+func (f *Form) Value(name, field, fallback string) string {
+	if !f.For(name) {
+		return fallback
+	}
+	return f.Values.Get(field)
+}
+
+// Selected reports whether value is among the input of field when f is
+// the input of the form name, and returns fallback when it is not
+func (f *Form) Selected(name, field, value string, fallback bool) bool {
+	if !f.For(name) {
+		return fallback
+	}
+	for _, v := range f.Values[field] {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorOf returns what is wrong with field when f is the input of the form
+// name; field "" is the problem with the input as a whole
+func (f *Form) ErrorOf(name, field string) string {
+	if !f.For(name) {
+		return ""
+	}
+	return f.Errors[field]
+}
@@ -0,0 +1,145 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"shared/store"
+	"strconv"
+	"time"
+)
+
+// Dispatcher posts queued deliveries. Deliveries are claimed with a lease, so
+// any number of dispatchers can share the queue.
+type Dispatcher struct {
+	Store       Store
+	Client      *http.Client
+	Interval    time.Duration // how often the queue is polled
+	Lease       time.Duration // how long a claimed delivery is hidden from others
+	MaxAttempts int
+	BaseBackoff time.Duration // wait after the first failure, doubled every attempt
+	MaxBackoff  time.Duration
+}
+
+// NewDispatcher returns a dispatcher with the default retry policy: 8
+// attempts spread over about an hour
+func NewDispatcher(s Store) *Dispatcher {
+	return &Dispatcher{
+		Store:       s,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		Interval:    5 * time.Second,
+		Lease:       time.Minute,
+		MaxAttempts: 8,
+		BaseBackoff: 30 * time.Second,
+		MaxBackoff:  time.Hour,
+	}
+}
+
+// Backoff returns the wait before the next attempt after n failed attempts
+func (d *Dispatcher) Backoff(n int) time.Duration {
+	wait := d.BaseBackoff
+	for i := 1; i < n && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.MaxBackoff {
+		wait = d.MaxBackoff
+	}
+	return wait
+}
+
+// Run processes the queue until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		for {
+			delivered, err := d.processNext(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("webhooks: %v", err)
+				}
+				break
+			}
+			if !delivered {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processNext claims and attempts one due delivery; it reports false when
+// the queue has nothing due
+func (d *Dispatcher) processNext(ctx context.Context) (bool, error) {
+	delivery, ok, err := d.Store.Claim(ctx, time.Now(), d.Lease)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	var attempt Attempt
+	sub, err := d.Store.Subscription(ctx, delivery.SubscriptionID)
+	gone := errors.Is(err, store.ErrNotFound)
+	switch {
+	case err == nil:
+		attempt = d.attempt(ctx, delivery, sub.Secret)
+	case gone:
+		attempt = Attempt{At: time.Now(), Error: "subscription no longer exists"}
+	default:
+		return false, err
+	}
+
+	switch {
+	case attempt.Error == "":
+		delivery.Status = StatusSucceeded
+		delivery.DeliveredAt = &attempt.At
+	case gone || len(delivery.Attempts)+1 >= d.MaxAttempts:
+		delivery.Status = StatusFailed
+	default:
+		delivery.NextAttempt = attempt.At.Add(d.Backoff(len(delivery.Attempts) + 1))
+	}
+	return true, d.Store.Finish(ctx, delivery, attempt)
+}
+
+// attempt POSTs the payload once and records the outcome
+func (d *Dispatcher) attempt(ctx context.Context, delivery Delivery, secret string) Attempt {
+	start := time.Now()
+	result := Attempt{At: start}
+
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(start.Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "CMMS-Webhooks/1.0")
+	req.Header.Set("X-CMMS-Event", delivery.Event)
+	req.Header.Set("X-CMMS-Delivery", delivery.ID.Hex())
+	req.Header.Set("X-CMMS-Timestamp", timestamp)
+	req.Header.Set("X-CMMS-Signature", "sha256="+Sign(secret, timestamp, body))
+
+	resp, err := d.Client.Do(req)
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		result.Error = fmt.Sprintf("unexpected status %s", resp.Status)
+	}
+	return result
+}
@@ -0,0 +1,189 @@
+package webhook
+
+import (
+	"context"
+	"shared/store"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryPublisher keeps the published events in memory, for tests
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// Publish records the event
+func (p *MemoryPublisher) Publish(ctx context.Context, source, eventType string, data interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, Event{
+		ID:         primitive.NewObjectID().Hex(),
+		Type:       eventType,
+		Source:     source,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	})
+	return nil
+}
+
+// Events returns the published events, oldest first
+func (p *MemoryPublisher) Events() []Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Event(nil), p.events...)
+}
+
+// MemoryStore keeps the webhooks in memory, for tests
+type MemoryStore struct {
+	mu            sync.Mutex
+	subscriptions []Subscription
+	deliveries    []Delivery
+}
+
+// Subscriptions returns every subscription, oldest first
+func (s *MemoryStore) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Subscription{}, s.subscriptions...), nil
+}
+
+// Subscribers returns the active subscriptions wanting eventType
+func (s *MemoryStore) Subscribers(ctx context.Context, eventType string) ([]Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subs := []Subscription{}
+	for _, sub := range s.subscriptions {
+		if sub.Active && sub.Wants(eventType) {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+// Subscription returns the subscription with the given id
+func (s *MemoryStore) Subscription(ctx context.Context, id primitive.ObjectID) (Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sub := range s.subscriptions {
+		if sub.ID == id {
+			return sub, nil
+		}
+	}
+	return Subscription{}, store.ErrNotFound
+}
+
+// InsertSubscription adds sub
+func (s *MemoryStore) InsertSubscription(ctx context.Context, sub Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions = append(s.subscriptions, sub)
+	return nil
+}
+
+// SetActive pauses or resumes a subscription
+func (s *MemoryStore) SetActive(ctx context.Context, id primitive.ObjectID, active bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.subscriptions {
+		if s.subscriptions[i].ID == id {
+			s.subscriptions[i].Active = active
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+// DeleteSubscription removes a subscription and its pending deliveries
+func (s *MemoryStore) DeleteSubscription(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subs := s.subscriptions[:0]
+	for _, sub := range s.subscriptions {
+		if sub.ID != id {
+			subs = append(subs, sub)
+		}
+	}
+	s.subscriptions = subs
+	deliveries := s.deliveries[:0]
+	for _, d := range s.deliveries {
+		if d.SubscriptionID != id || d.Status != StatusPending {
+			deliveries = append(deliveries, d)
+		}
+	}
+	s.deliveries = deliveries
+	return nil
+}
+
+// Enqueue adds deliveries to the queue
+func (s *MemoryStore) Enqueue(ctx context.Context, deliveries []Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries = append(s.deliveries, deliveries...)
+	return nil
+}
+
+// Deliveries returns the deliveries matching f, most recent first
+func (s *MemoryStore) Deliveries(ctx context.Context, f DeliveryFilter) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deliveries := []Delivery{}
+	for i := len(s.deliveries) - 1; i >= 0 && int64(len(deliveries)) < f.limit(); i-- {
+		if f.matches(s.deliveries[i]) {
+			deliveries = append(deliveries, s.deliveries[i])
+		}
+	}
+	return deliveries, nil
+}
+
+// Claim returns the pending delivery due first, locked for lease
+func (s *MemoryStore) Claim(ctx context.Context, now time.Time, lease time.Duration) (Delivery, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := -1
+	for i, d := range s.deliveries {
+		if d.Status == StatusPending && !d.NextAttempt.After(now) && !d.LockedUntil.After(now) &&
+			(next < 0 || d.NextAttempt.Before(s.deliveries[next].NextAttempt)) {
+			next = i
+		}
+	}
+	if next < 0 {
+		return Delivery{}, false, nil
+	}
+	s.deliveries[next].LockedUntil = now.Add(lease)
+	return s.deliveries[next], true, nil
+}
+
+// Finish records attempt on d and releases it
+func (s *MemoryStore) Finish(ctx context.Context, d Delivery, attempt Attempt) error {
+	return s.update(d.ID, func(rec *Delivery) {
+		rec.Status = d.Status
+		rec.NextAttempt = d.NextAttempt
+		rec.DeliveredAt = d.DeliveredAt
+		rec.LockedUntil = time.Time{}
+		rec.Attempts = append(rec.Attempts, attempt)
+	})
+}
+
+// Redeliver queues a delivery again for an attempt at now
+func (s *MemoryStore) Redeliver(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	return s.update(id, func(d *Delivery) {
+		d.Status = StatusPending
+		d.NextAttempt = now
+		d.LockedUntil = time.Time{}
+	})
+}
+
+func (s *MemoryStore) update(id primitive.ObjectID, change func(d *Delivery)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.deliveries {
+		if s.deliveries[i].ID == id {
+			change(&s.deliveries[i])
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
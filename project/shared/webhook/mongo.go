@@ -0,0 +1,191 @@
+package webhook
+
+import (
+	"context"
+	"shared/store"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoMigrations create the indexes the dispatcher claims deliveries by,
+// those of the delivery log and one delivery per event and subscription
+var mongoMigrations = []store.MongoMigration{
+	{Version: 1, Name: "webhook indexes", Up: store.Steps(
+		store.Indexes(DeliveriesCollection,
+			mongo.IndexModel{Keys: store.Keys("status", "next_attempt")},
+			mongo.IndexModel{Keys: bson.D{{Key: "subscription_id", Value: 1}, {Key: "created_at", Value: -1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "created_at", Value: -1}}},
+			mongo.IndexModel{Keys: store.Keys("event_id", "subscription_id"), Options: options.Index().SetUnique(true)},
+		),
+		store.Indexes(SubscriptionsCollection, mongo.IndexModel{Keys: store.Keys("events", "active")}),
+	)},
+}
+
+// MigrateMongo creates or updates the indexes of the webhooks of db, the
+// database of a service publishing them
+func MigrateMongo(ctx context.Context, db *mongo.Database) error {
+	return store.MigrateMongo(ctx, db, "webhook", mongoMigrations)
+}
+
+// MongoStore keeps the webhooks in the collections of a MongoDB database
+type MongoStore struct {
+	subscriptions *mongo.Collection
+	deliveries    *mongo.Collection
+}
+
+// NewMongoStore returns the webhooks kept in db
+func NewMongoStore(db *mongo.Database) *MongoStore {
+	return &MongoStore{
+		subscriptions: db.Collection(SubscriptionsCollection),
+		deliveries:    db.Collection(DeliveriesCollection),
+	}
+}
+
+// Subscriptions returns every subscription, oldest first
+func (s *MongoStore) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	return s.findSubscriptions(ctx, bson.M{})
+}
+
+// Subscribers returns the active subscriptions wanting eventType
+func (s *MongoStore) Subscribers(ctx context.Context, eventType string) ([]Subscription, error) {
+	return s.findSubscriptions(ctx, bson.M{
+		"active": true,
+		"events": bson.M{"$in": []string{eventType, AllEvents}},
+	})
+}
+
+func (s *MongoStore) findSubscriptions(ctx context.Context, filter bson.M) ([]Subscription, error) {
+	subs := []Subscription{}
+	cursor, err := s.subscriptions.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &subs)
+	return subs, err
+}
+
+// Subscription returns the subscription with the given id
+func (s *MongoStore) Subscription(ctx context.Context, id primitive.ObjectID) (Subscription, error) {
+	var sub Subscription
+	err := s.subscriptions.FindOne(ctx, bson.M{"_id": id}).Decode(&sub)
+	return sub, store.MongoErr(err)
+}
+
+// InsertSubscription adds sub
+func (s *MongoStore) InsertSubscription(ctx context.Context, sub Subscription) error {
+	_, err := s.subscriptions.InsertOne(ctx, sub)
+	return err
+}
+
+// SetActive pauses or resumes a subscription
+func (s *MongoStore) SetActive(ctx context.Context, id primitive.ObjectID, active bool) error {
+	res, err := s.subscriptions.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"active": active}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteSubscription removes a subscription and its pending deliveries
+func (s *MongoStore) DeleteSubscription(ctx context.Context, id primitive.ObjectID) error {
+	if _, err := s.subscriptions.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return err
+	}
+	_, err := s.deliveries.DeleteMany(ctx, bson.M{"subscription_id": id, "status": StatusPending})
+	return err
+}
+
+// Enqueue adds deliveries to the queue
+func (s *MongoStore) Enqueue(ctx context.Context, deliveries []Delivery) error {
+	docs := make([]interface{}, len(deliveries))
+	for i, d := range deliveries {
+		docs[i] = d
+	}
+	_, err := s.deliveries.InsertMany(ctx, docs)
+	return err
+}
+
+// Deliveries returns the deliveries matching f, most recent first
+func (s *MongoStore) Deliveries(ctx context.Context, f DeliveryFilter) ([]Delivery, error) {
+	filter := bson.M{}
+	if f.SubscriptionID != nil {
+		filter["subscription_id"] = *f.SubscriptionID
+	}
+	if f.Event != "" {
+		filter["event"] = f.Event
+	}
+	if f.Status != "" {
+		filter["status"] = f.Status
+	}
+
+	deliveries := []Delivery{}
+	cursor, err := s.deliveries.Find(ctx, filter,
+		options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(f.limit()))
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &deliveries)
+	return deliveries, err
+}
+
+// Claim returns the pending delivery due first, locked for lease
+func (s *MongoStore) Claim(ctx context.Context, now time.Time, lease time.Duration) (Delivery, bool, error) {
+	var d Delivery
+	err := s.deliveries.FindOneAndUpdate(ctx,
+		bson.M{
+			"status":       StatusPending,
+			"next_attempt": bson.M{"$lte": now},
+			"locked_until": bson.M{"$lte": now},
+		},
+		bson.M{"$set": bson.M{"locked_until": now.Add(lease)}},
+		options.FindOneAndUpdate().
+			SetSort(bson.M{"next_attempt": 1}).
+			SetReturnDocument(options.After),
+	).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return d, false, nil
+	}
+	return d, err == nil, err
+}
+
+// Finish records attempt on d and releases it
+func (s *MongoStore) Finish(ctx context.Context, d Delivery, attempt Attempt) error {
+	set := bson.M{
+		"status":       d.Status,
+		"next_attempt": d.NextAttempt,
+		"locked_until": time.Time{},
+	}
+	if d.DeliveredAt != nil {
+		set["delivered_at"] = *d.DeliveredAt
+	}
+	_, err := s.deliveries.UpdateOne(ctx,
+		bson.M{"_id": d.ID},
+		bson.M{"$set": set, "$push": bson.M{"attempts": attempt}},
+	)
+	return err
+}
+
+// Redeliver queues a delivery again for an attempt at now
+func (s *MongoStore) Redeliver(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	res, err := s.deliveries.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{
+			"status":       StatusPending,
+			"next_attempt": now,
+			"locked_until": time.Time{},
+		}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
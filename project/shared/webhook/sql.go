@@ -0,0 +1,250 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"shared/store"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// migrations create the subscriptions and deliveries tables of the SQL
+// storage drivers
+var migrations = []store.Migration{
+	{Version: 1, Name: "create webhook_subscriptions and webhook_deliveries", Statements: []string{
+		`CREATE TABLE webhook_subscriptions (
+			id TEXT PRIMARY KEY,
+			doc TEXT NOT NULL,
+			deleted_at BIGINT,
+			deleted_by TEXT NOT NULL DEFAULT '',
+			active INTEGER NOT NULL
+		)`,
+		`CREATE TABLE webhook_deliveries (
+			id TEXT PRIMARY KEY,
+			doc TEXT NOT NULL,
+			deleted_at BIGINT,
+			deleted_by TEXT NOT NULL DEFAULT '',
+			subscription_id TEXT NOT NULL,
+			event_id TEXT NOT NULL,
+			event TEXT NOT NULL,
+			status TEXT NOT NULL,
+			next_attempt BIGINT NOT NULL,
+			locked_until BIGINT NOT NULL,
+			created_at BIGINT NOT NULL
+		)`,
+		"CREATE INDEX webhook_deliveries_due ON webhook_deliveries (status, next_attempt)",
+		"CREATE INDEX webhook_deliveries_subscription ON webhook_deliveries (subscription_id, created_at)",
+		"CREATE INDEX webhook_deliveries_created_at ON webhook_deliveries (created_at)",
+		"CREATE UNIQUE INDEX webhook_deliveries_event ON webhook_deliveries (event_id, subscription_id)",
+	}},
+}
+
+// MigrateSQL creates or updates the tables of the webhooks in db
+func MigrateSQL(ctx context.Context, db *store.SQL) error {
+	return db.Migrate(ctx, "webhook", migrations)
+}
+
+// SQLStore keeps the webhooks in the tables of a SQL database
+type SQLStore struct {
+	db            *store.SQL
+	subscriptions *store.SQLTable[Subscription]
+	deliveries    *store.SQLTable[Delivery]
+}
+
+// NewSQLStore returns the webhooks kept in db
+func NewSQLStore(db *store.SQL) *SQLStore {
+	return &SQLStore{
+		db: db,
+		subscriptions: store.NewSQLTable(db, SubscriptionsCollection,
+			func(s *Subscription) (*primitive.ObjectID, **time.Time, *string) { return &s.ID, nil, nil },
+			store.Column[Subscription]{Name: "active", Value: func(s *Subscription) interface{} {
+				if s.Active {
+					return 1
+				}
+				return 0
+			}},
+		),
+		deliveries: store.NewSQLTable(db, DeliveriesCollection,
+			func(d *Delivery) (*primitive.ObjectID, **time.Time, *string) { return &d.ID, nil, nil },
+			store.Column[Delivery]{Name: "subscription_id", Value: func(d *Delivery) interface{} { return d.SubscriptionID.Hex() }},
+			store.Column[Delivery]{Name: "event_id", Value: func(d *Delivery) interface{} { return d.EventID }},
+			store.Column[Delivery]{Name: "event", Value: func(d *Delivery) interface{} { return d.Event }},
+			store.Column[Delivery]{Name: "status", Value: func(d *Delivery) interface{} { return d.Status }},
+			store.Column[Delivery]{Name: "next_attempt", Value: func(d *Delivery) interface{} { return store.Millis(d.NextAttempt) }},
+			store.Column[Delivery]{Name: "locked_until", Value: func(d *Delivery) interface{} { return store.Millis(d.LockedUntil) }},
+			store.Column[Delivery]{Name: "created_at", Value: func(d *Delivery) interface{} { return store.Millis(d.CreatedAt) }},
+		),
+	}
+}
+
+// CopyToSQL copies the subscriptions of db, the MongoDB database of a
+// service, into to and returns how many it copied. The deliveries are not
+// copied.
+func CopyToSQL(ctx context.Context, db *mongo.Database, to *store.SQL) (int, error) {
+	if err := MigrateSQL(ctx, to); err != nil {
+		return 0, err
+	}
+	return store.CopyCollection(ctx, db.Collection(SubscriptionsCollection), NewSQLStore(to).subscriptions)
+}
+
+// Subscriptions returns every subscription, oldest first
+func (s *SQLStore) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	return s.subscriptions.Select(ctx, "")
+}
+
+// Subscribers returns the active subscriptions wanting eventType
+func (s *SQLStore) Subscribers(ctx context.Context, eventType string) ([]Subscription, error) {
+	active, err := s.subscriptions.Select(ctx, "active = ?", 1)
+	if err != nil {
+		return nil, err
+	}
+	subs := []Subscription{}
+	for _, sub := range active {
+		if sub.Wants(eventType) {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+// Subscription returns the subscription with the given id
+func (s *SQLStore) Subscription(ctx context.Context, id primitive.ObjectID) (Subscription, error) {
+	return s.subscriptions.Get(ctx, id)
+}
+
+// InsertSubscription adds sub
+func (s *SQLStore) InsertSubscription(ctx context.Context, sub Subscription) error {
+	return s.subscriptions.Insert(ctx, sub)
+}
+
+// SetActive pauses or resumes a subscription
+func (s *SQLStore) SetActive(ctx context.Context, id primitive.ObjectID, active bool) error {
+	_, err := s.subscriptions.Update(ctx, id, func(sub *Subscription) { sub.Active = active })
+	return err
+}
+
+// DeleteSubscription removes a subscription and its pending deliveries
+func (s *SQLStore) DeleteSubscription(ctx context.Context, id primitive.ObjectID) error {
+	return s.db.Atomically(ctx, func(ctx context.Context) error {
+		if _, err := s.db.Exec(ctx, "DELETE FROM "+SubscriptionsCollection+" WHERE id = ?", id.Hex()); err != nil {
+			return err
+		}
+		_, err := s.db.Exec(ctx, "DELETE FROM "+DeliveriesCollection+" WHERE subscription_id = ? AND status = ?",
+			id.Hex(), StatusPending)
+		return err
+	})
+}
+
+// Enqueue adds deliveries to the queue
+func (s *SQLStore) Enqueue(ctx context.Context, deliveries []Delivery) error {
+	return s.db.Atomically(ctx, func(ctx context.Context) error {
+		for _, d := range deliveries {
+			if err := s.deliveries.Insert(ctx, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Deliveries returns the deliveries matching f, most recent first
+func (s *SQLStore) Deliveries(ctx context.Context, f DeliveryFilter) ([]Delivery, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if f.SubscriptionID != nil {
+		where = append(where, "subscription_id = ?")
+		args = append(args, f.SubscriptionID.Hex())
+	}
+	if f.Event != "" {
+		where = append(where, "event = ?")
+		args = append(args, f.Event)
+	}
+	if f.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, f.Status)
+	}
+	query := "SELECT doc FROM " + DeliveriesCollection
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	rows, err := s.db.Query(ctx, query+" ORDER BY created_at DESC, id DESC LIMIT ?", append(args, f.limit())...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		var doc string
+		if err := rows.Scan(&doc); err != nil {
+			return nil, err
+		}
+		var d Delivery
+		if err := store.UnmarshalDoc(doc, &d); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// Claim returns the pending delivery due first, locked for lease
+func (s *SQLStore) Claim(ctx context.Context, now time.Time, lease time.Duration) (Delivery, bool, error) {
+	var (
+		d                  Delivery
+		skipLockedByOthers string
+	)
+	if s.db.Driver == store.Postgres {
+		skipLockedByOthers = " FOR UPDATE SKIP LOCKED"
+	}
+	err := s.db.Atomically(ctx, func(ctx context.Context) error {
+		var id string
+		err := s.db.QueryRow(ctx,
+			"SELECT id FROM "+DeliveriesCollection+
+				" WHERE status = ? AND next_attempt <= ? AND locked_until <= ? ORDER BY next_attempt LIMIT 1"+skipLockedByOthers,
+			StatusPending, store.Millis(now), store.Millis(now),
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+		oid, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return err
+		}
+		d, err = s.deliveries.Update(ctx, oid, func(d *Delivery) { d.LockedUntil = now.Add(lease) })
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return d, false, nil
+	}
+	return d, err == nil, err
+}
+
+// Finish records attempt on d and releases it
+func (s *SQLStore) Finish(ctx context.Context, d Delivery, attempt Attempt) error {
+	_, err := s.deliveries.Update(ctx, d.ID, func(rec *Delivery) {
+		rec.Status = d.Status
+		rec.NextAttempt = d.NextAttempt
+		rec.DeliveredAt = d.DeliveredAt
+		rec.LockedUntil = time.Time{}
+		rec.Attempts = append(rec.Attempts, attempt)
+	})
+	return err
+}
+
+// Redeliver queues a delivery again for an attempt at now
+func (s *SQLStore) Redeliver(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	_, err := s.deliveries.Update(ctx, id, func(d *Delivery) {
+		d.Status = StatusPending
+		d.NextAttempt = now
+		d.LockedUntil = time.Time{}
+	})
+	return err
+}
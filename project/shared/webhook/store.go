@@ -0,0 +1,150 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Delivery states
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Delivery is one event queued for one subscription, with the outcome of
+// every attempt made so far
+type Delivery struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	SubscriptionID primitive.ObjectID `bson:"subscription_id"`
+	URL            string             `bson:"url"`
+	EventID        string             `bson:"event_id"`
+	Event          string             `bson:"event"`
+	Payload        string             `bson:"payload"`
+	Status         string             `bson:"status"`
+	Attempts       []Attempt          `bson:"attempts"`
+	NextAttempt    time.Time          `bson:"next_attempt"`
+	LockedUntil    time.Time          `bson:"locked_until"`
+	CreatedAt      time.Time          `bson:"created_at"`
+	DeliveredAt    *time.Time         `bson:"delivered_at,omitempty"`
+}
+
+// Attempt is the result of a single POST
+type Attempt struct {
+	At         time.Time     `bson:"at"`
+	StatusCode int           `bson:"status_code,omitempty"`
+	Error      string        `bson:"error,omitempty"`
+	Duration   time.Duration `bson:"duration"`
+}
+
+// LastAttempt returns the most recent attempt, or nil before the first one
+func (d Delivery) LastAttempt() *Attempt {
+	if len(d.Attempts) == 0 {
+		return nil
+	}
+	return &d.Attempts[len(d.Attempts)-1]
+}
+
+// Store keeps the subscriptions and the queue of their deliveries. A
+// missing subscription or delivery is store.ErrNotFound.
+type Store interface {
+	// Subscriptions returns every subscription, oldest first
+	Subscriptions(ctx context.Context) ([]Subscription, error)
+	// Subscribers returns the active subscriptions wanting eventType
+	Subscribers(ctx context.Context, eventType string) ([]Subscription, error)
+	// Subscription returns the subscription with the given id
+	Subscription(ctx context.Context, id primitive.ObjectID) (Subscription, error)
+	InsertSubscription(ctx context.Context, sub Subscription) error
+	SetActive(ctx context.Context, id primitive.ObjectID, active bool) error
+	// DeleteSubscription removes a subscription and its pending deliveries
+	DeleteSubscription(ctx context.Context, id primitive.ObjectID) error
+
+	// Enqueue adds deliveries to the queue
+	Enqueue(ctx context.Context, deliveries []Delivery) error
+	// Deliveries returns the deliveries matching f, most recent first
+	Deliveries(ctx context.Context, f DeliveryFilter) ([]Delivery, error)
+	// Claim returns the pending delivery due first at now, hidden from the
+	// other dispatchers until lease has passed; false when none is due
+	Claim(ctx context.Context, now time.Time, lease time.Duration) (Delivery, bool, error)
+	// Finish records attempt on the claimed delivery d, saves its Status,
+	// NextAttempt and DeliveredAt and releases it
+	Finish(ctx context.Context, d Delivery, attempt Attempt) error
+	// Redeliver queues a delivery again for an attempt at now
+	Redeliver(ctx context.Context, id primitive.ObjectID, now time.Time) error
+}
+
+var errInvalidURL = errors.New("webhook URL must be an absolute http or https URL")
+
+// CreateSubscription validates and stores a subscription, generating a
+// secret when none is given
+func CreateSubscription(ctx context.Context, s Store, sub Subscription) (Subscription, error) {
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return sub, errInvalidURL
+	}
+	if len(sub.Events) == 0 {
+		return sub, errors.New("select at least one event")
+	}
+	if sub.Secret == "" {
+		if sub.Secret, err = NewSecret(); err != nil {
+			return sub, err
+		}
+	}
+	sub.ID = primitive.NewObjectID()
+	sub.Active = true
+	sub.CreatedAt = time.Now()
+
+	return sub, s.InsertSubscription(ctx, sub)
+}
+
+// ListSubscriptions returns every subscription, oldest first
+func ListSubscriptions(ctx context.Context, s Store) ([]Subscription, error) {
+	return s.Subscriptions(ctx)
+}
+
+// SetSubscriptionActive pauses or resumes a subscription
+func SetSubscriptionActive(ctx context.Context, s Store, id primitive.ObjectID, active bool) error {
+	return s.SetActive(ctx, id, active)
+}
+
+// DeleteSubscription removes a subscription and drops its pending deliveries
+func DeleteSubscription(ctx context.Context, s Store, id primitive.ObjectID) error {
+	return s.DeleteSubscription(ctx, id)
+}
+
+// DeliveryFilter narrows ListDeliveries; zero values match everything
+type DeliveryFilter struct {
+	SubscriptionID *primitive.ObjectID
+	Event          string
+	Status         string
+	Limit          int64
+}
+
+// limit returns the number of deliveries to list, 100 by default
+func (f DeliveryFilter) limit() int64 {
+	if f.Limit <= 0 {
+		return 100
+	}
+	return f.Limit
+}
+
+// matches reports whether d passes the filter
+func (f DeliveryFilter) matches(d Delivery) bool {
+	return (f.SubscriptionID == nil || d.SubscriptionID == *f.SubscriptionID) &&
+		(f.Event == "" || d.Event == f.Event) &&
+		(f.Status == "" || d.Status == f.Status)
+}
+
+// ListDeliveries returns the most recent deliveries first
+func ListDeliveries(ctx context.Context, s Store, f DeliveryFilter) ([]Delivery, error) {
+	return s.Deliveries(ctx, f)
+}
+
+// Redeliver queues a delivery again for an immediate attempt
+func Redeliver(ctx context.Context, s Store, id primitive.ObjectID) error {
+	return s.Redeliver(ctx, id, time.Now())
+}
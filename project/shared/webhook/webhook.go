@@ -0,0 +1,161 @@
+// Package webhook delivers CMMS events to external HTTP endpoints.
+//
+// Services call Publish after a successful write; it queues one delivery per
+// matching subscription in a Store: MongoDB, or the SQL database of the
+// sqlite and postgres storage drivers. A Dispatcher, which may run in several
+// services at once, claims due deliveries, POSTs the signed JSON payload and
+// retries failures with exponential backoff.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Collections, or tables, used by the package
+const (
+	SubscriptionsCollection = "webhook_subscriptions"
+	DeliveriesCollection    = "webhook_deliveries"
+)
+
+// Event types
+const (
+	AssetCreated        = "asset.created"
+	AssetUpdated        = "asset.updated"
+	AssetDeleted        = "asset.deleted"
+	AssetRestored       = "asset.restored"
+	MaintenanceCreated  = "maintenance.created"
+	MaintenanceUpdated  = "maintenance.updated"
+	MaintenanceDeleted  = "maintenance.deleted"
+	MaintenanceRestored = "maintenance.restored"
+	ScheduleCreated     = "schedule.created"
+	ScheduleUpdated     = "schedule.updated"
+	ScheduleDeleted     = "schedule.deleted"
+	ScheduleRestored    = "schedule.restored"
+	ScheduleCompleted   = "schedule.completed"
+	AllEvents           = "*"
+)
+
+// EventTypes lists every event a subscription can ask for
+var EventTypes = []string{
+	AssetCreated, AssetUpdated, AssetDeleted, AssetRestored,
+	MaintenanceCreated, MaintenanceUpdated, MaintenanceDeleted, MaintenanceRestored,
+	ScheduleCreated, ScheduleUpdated, ScheduleDeleted, ScheduleRestored, ScheduleCompleted,
+}
+
+// Subscription sends the events listed in Events to URL, signed with Secret
+type Subscription struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	URL       string             `bson:"url" json:"url"`
+	Secret    string             `bson:"secret" json:"-"`
+	Events    []string           `bson:"events" json:"events"`
+	Active    bool               `bson:"active" json:"active"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Wants reports whether the subscription covers the event type
+func (s Subscription) Wants(eventType string) bool {
+	for _, e := range s.Events {
+		if e == eventType || e == AllEvents {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the JSON body POSTed to subscribers
+type Event struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	Source     string      `json:"source"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// Publisher queues the webhook events of a service
+type Publisher interface {
+	Publish(ctx context.Context, source, eventType string, data interface{}) error
+}
+
+// StorePublisher queues deliveries in a Store
+type StorePublisher struct {
+	Store Store
+}
+
+// NewPublisher returns a publisher queueing deliveries in s
+func NewPublisher(s Store) StorePublisher {
+	return StorePublisher{Store: s}
+}
+
+// Publish queues eventType, see Publish
+func (p StorePublisher) Publish(ctx context.Context, source, eventType string, data interface{}) error {
+	return Publish(ctx, p.Store, source, eventType, data)
+}
+
+// Publish queues eventType for every active subscription that wants it.
+// source names the publishing service and data becomes the payload's data.
+func Publish(ctx context.Context, s Store, source, eventType string, data interface{}) error {
+	subs, err := s.Subscribers(ctx, eventType)
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+
+	event := Event{
+		ID:         primitive.NewObjectID().Hex(),
+		Type:       eventType,
+		Source:     source,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	deliveries := make([]Delivery, 0, len(subs))
+	for _, sub := range subs {
+		deliveries = append(deliveries, Delivery{
+			ID:             primitive.NewObjectID(),
+			SubscriptionID: sub.ID,
+			URL:            sub.URL,
+			EventID:        event.ID,
+			Event:          eventType,
+			Payload:        string(payload),
+			Status:         StatusPending,
+			NextAttempt:    now,
+			CreatedAt:      now,
+		})
+	}
+	return s.Enqueue(ctx, deliveries)
+}
+
+// Sign returns the hex HMAC-SHA256 of "timestamp.body" keyed with secret.
+// Receivers recompute it from the X-CMMS-Timestamp header and the raw body
+// and compare it with X-CMMS-Signature (without its "sha256=" prefix).
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret returns a random signing secret
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}